@@ -11,6 +11,8 @@ import (
 	"krillin-ai/internal/service"
 	"krillin-ai/log"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
@@ -32,11 +34,18 @@ func main() {
 		writeAndExit(cli.Execute(context.Background(), nil, cmd))
 		return
 	}
+	if cmd.Name == "tm" {
+		if cmd.TM.Memory == "" {
+			_ = config.LoadConfig()
+		}
+		writeAndExit(cli.Execute(context.Background(), nil, cmd))
+		return
+	}
 	if cmd.DryRun {
 		writeAndExit(cli.Execute(context.Background(), nil, cmd))
 		return
 	}
-	if cmd.Name == "update" {
+	if cmd.Name == "update" || cmd.Name == "status" {
 		writeAndExit(cli.Execute(context.Background(), nil, cmd))
 		return
 	}
@@ -57,9 +66,13 @@ func main() {
 	if err := deps.CheckDependency(); err != nil {
 		writeAndExit(errorResponse(err, pipeline.ErrorKindDependency))
 	}
+	// Ctrl-C / SIGTERM 取消当前任务，终止正在运行的 ffmpeg、转录等子进程
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	svc := service.NewService()
 	adapter := pipeline.NewServiceAdapter(svc)
-	writeAndExit(cli.Execute(context.Background(), adapter, cmd))
+	resp := cli.Execute(ctx, adapter, cmd)
+	stop()
+	writeAndExit(resp)
 }
 
 func errorResponse(err error, kind pipeline.ErrorKind) pipeline.Response {
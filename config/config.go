@@ -4,10 +4,14 @@ import (
 	"errors"
 	"fmt"
 	"krillin-ai/log"
+	"net/netip"
 	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/BurntSushi/toml"
 	"go.uber.org/zap"
@@ -16,23 +20,81 @@ import (
 var ConfigBackup Config // 用于在开始任务之前，检测配置是否更新，更新后要重启服务端
 
 type App struct {
-	SegmentDuration       int      `toml:"segment_duration"`
-	TranscribeParallelNum int      `toml:"transcribe_parallel_num"`
-	TranslateParallelNum  int      `toml:"translate_parallel_num"`
-	TranscribeMaxAttempts int      `toml:"transcribe_max_attempts"`
-	TranslateMaxAttempts  int      `toml:"translate_max_attempts"`
-	MaxSentenceLength     int      `toml:"max_sentence_length"`
-	EnableBlockVttBatch   bool     `toml:"enable_block_vtt_batch"`
-	VttBatchSize          int      `toml:"vtt_batch_size"`
-	TargetLanguageFirst   bool     `toml:"target_language_first"`    // 双语字幕中目标语言是否在上
-	ShortSubtitleMaxChars int      `toml:"short_subtitle_max_chars"` // 短字幕英文每行最大字符数
-	Proxy                 string   `toml:"proxy"`
-	ParsedProxy           *url.URL `toml:"-"`
+	SegmentDuration       int               `toml:"segment_duration"`
+	TranscribeParallelNum int               `toml:"transcribe_parallel_num"`
+	TranslateParallelNum  int               `toml:"translate_parallel_num"`
+	TranscribeMaxAttempts int               `toml:"transcribe_max_attempts"`
+	TranslateMaxAttempts  int               `toml:"translate_max_attempts"`
+	MaxSentenceLength     int               `toml:"max_sentence_length"`
+	EnableBlockVttBatch   bool              `toml:"enable_block_vtt_batch"`
+	VttBatchSize          int               `toml:"vtt_batch_size"`
+	TargetLanguageFirst   bool              `toml:"target_language_first"`    // 双语字幕中目标语言是否在上
+	ShortSubtitleMaxChars int               `toml:"short_subtitle_max_chars"` // 短字幕英文每行最大字符数
+	Glossary              string            `toml:"glossary"`                 // 项目术语表文件（CSV或TOML），对所有任务生效，任务提交的术语优先
+	Proxy                 string            `toml:"proxy"`
+	ParsedProxy           *url.URL          `toml:"-"`
+	Vad                   Vad               `toml:"vad"`
+	TranslationMemory     TranslationMemory `toml:"translation_memory"`
+}
+
+// TranslationMemory 翻译记忆库，记录已完成任务的译文，之后的任务遇到相同的句子直接复用，相近的句子作为参考译文
+type TranslationMemory struct {
+	Enable         bool    `toml:"enable"`
+	Path           string  `toml:"path"`            // 记忆库文件，所有任务共享
+	FuzzyThreshold float64 `toml:"fuzzy_threshold"` // 原文相似度不低于该值时把已有译文作为参考，1表示只复用完全相同的句子
+}
+
+// Vad 语音活动检测，用于在静音处切分音频并裁掉首尾的长静音
+type Vad struct {
+	Enable      bool    `toml:"enable"`
+	ThresholdDb float64 `toml:"threshold_db"` // 语音需高出底噪的分贝数，背景音乐较大时可以适当调高
+	TrimSilence float64 `toml:"trim_silence"` // 音频开头和结尾超过该时长的静音不送去转录，单位：秒，0表示不裁剪
 }
 
 type Server struct {
-	Host string `toml:"host"`
-	Port int    `toml:"port"`
+	Host         string      `toml:"host"`
+	Port         int         `toml:"port"`
+	TaskStore    string      `toml:"task_store"`     // 任务存储方式，file 或 memory
+	TaskStoreDir string      `toml:"task_store_dir"` // file 方式下任务记录保存目录
+	Workers      int         `toml:"workers"`        // 同时处理的任务数
+	QueueSize    int         `toml:"queue_size"`     // 排队等待的任务数上限
+	Concurrency  Concurrency `toml:"concurrency"`
+	Retention    Retention   `toml:"retention"`
+	// 配置了api_keys后接口需要鉴权，allow_loopback为true时本机请求免鉴权（默认关闭，本机反向代理转发的请求同样来自本机）
+	ApiKeys       []ApiKey `toml:"api_keys"`
+	AllowLoopback bool     `toml:"allow_loopback"`
+
+	// 允许回调的内网地址（IP或CIDR），默认禁止callback_url指向本机、内网和链路本地地址
+	CallbackAllowedNetworks []string `toml:"callback_allowed_networks"`
+}
+
+// Retention 任务目录的清理策略，只处理已结束的任务，各项为0表示不启用
+type Retention struct {
+	IntervalMinutes      int  `toml:"interval_minutes"`        // 清理间隔
+	MaxAgeHours          int  `toml:"max_age_hours"`           // 任务结束超过该时长后删除任务及其目录
+	MaxTotalSizeMb       int  `toml:"max_total_size_mb"`       // 任务目录总大小超过该值时从最早的任务开始删除
+	KeepFinalOutputsOnly bool `toml:"keep_final_outputs_only"` // 成功的任务只保留最终产物，删除切分音频等中间文件
+}
+
+// ApiKey 调用方的密钥，请求时通过 Authorization: Bearer <key> 或 X-API-Key 头传入
+type ApiKey struct {
+	Name      string   `toml:"name"`
+	Key       string   `toml:"key"`
+	Scopes    []string `toml:"scopes"`     // task：提交、查询任务及上传下载文件；config：查看和修改配置
+	RateLimit int      `toml:"rate_limit"` // 每分钟最多请求数，0表示不限制
+}
+
+const (
+	ScopeTask   = "task"
+	ScopeConfig = "config"
+)
+
+// Concurrency 所有任务共享的各类资源并发上限，0表示不限制
+type Concurrency struct {
+	Transcribe int `toml:"transcribe"`
+	Llm        int `toml:"llm"`
+	Tts        int `toml:"tts"`
+	Ffmpeg     int `toml:"ffmpeg"`
 }
 
 type OpenaiCompatibleConfig struct {
@@ -41,10 +103,115 @@ type OpenaiCompatibleConfig struct {
 	Model   string `toml:"model"`
 }
 
+// LlmConfig 大模型服务配置，translate、split、dubbing、general 为各用途调用时的默认参数
+type LlmConfig struct {
+	Provider  string     `toml:"provider"` // openai（兼容openai接口的服务）、anthropic、gemini、ollama
+	BaseUrl   string     `toml:"base_url"`
+	ApiKey    string     `toml:"api_key"`
+	Model     string     `toml:"model"`
+	Json      bool       `toml:"json"` // 接口是否支持JSON Schema结构化输出，开启后拆句、批量翻译等要求JSON的调用会附带格式约束
+	Translate LlmUseCase `toml:"translate"`
+	Split     LlmUseCase `toml:"split"`
+	Dubbing   LlmUseCase `toml:"dubbing"`
+	General   LlmUseCase `toml:"general"`
+}
+
+// LlmUseCase 某一用途调用大模型时的默认参数，调用方单独指定的参数优先
+type LlmUseCase struct {
+	SystemPrompt string   `toml:"system_prompt"`
+	Temperature  float64  `toml:"temperature"`
+	MaxTokens    int      `toml:"max_tokens"` // 0表示不限制
+	Stop         []string `toml:"stop"`
+}
+
+const (
+	LlmUseCaseTranslate = "translate" // 字幕、标题翻译
+	LlmUseCaseSplit     = "split"     // 长句拆分、原文译文对齐
+	LlmUseCaseDubbing   = "dubbing"   // 配音前改写字幕
+	LlmUseCaseGeneral   = "general"   // 其他
+)
+
+// UseCase 对应用途的默认参数，未知用途使用general
+func (c LlmConfig) UseCase(name string) LlmUseCase {
+	switch name {
+	case LlmUseCaseTranslate:
+		return c.Translate
+	case LlmUseCaseSplit:
+		return c.Split
+	case LlmUseCaseDubbing:
+		return c.Dubbing
+	default:
+		return c.General
+	}
+}
+
 type LocalModelConfig struct {
 	Model string `toml:"model"`
 }
 
+type WhisperXConfig struct {
+	Model          string `toml:"model"`
+	ComputeType    string `toml:"compute_type"` // 留空时按是否开启GPU加速选择，float16 需要GPU，CPU上使用 int8 或 float32
+	BatchSize      int    `toml:"batch_size"`
+	Align          bool   `toml:"align"`            // 是否进行词级对齐，关闭时按句子时长估算单词时间戳
+	ModelCacheOnly bool   `toml:"model_cache_only"` // 只使用./models/whisperx中已有的模型，不联网下载
+}
+
+// ComputeTypeFor 返回实际使用的compute_type，未配置时GPU上使用float16，CPU上使用int8
+func (c WhisperXConfig) ComputeTypeFor(gpu bool) string {
+	if c.ComputeType != "" {
+		return c.ComputeType
+	}
+	if gpu {
+		return "float16"
+	}
+	return "int8"
+}
+
+type WhispercppConfig struct {
+	Model     string `toml:"model"`
+	ServerUrl string `toml:"server_url"` // 已运行的whisper-server地址，填写后通过HTTP转录，不再调用本地whisper-cli
+}
+
+// WhispercppModels whisper.cpp支持自动下载的ggml模型
+var WhispercppModels = []string{
+	"tiny", "tiny.en", "base", "base.en", "small", "small.en", "medium", "medium.en",
+	"large-v1", "large-v2", "large-v3", "large-v3-turbo",
+}
+
+type DiarizationConfig struct {
+	Enable      bool    `toml:"enable"`
+	Provider    string  `toml:"provider"`     // cluster 本地按频谱特征聚类；whisperx 使用whisperx的diarize结果（需要hf_token）
+	HfToken     string  `toml:"hf_token"`     // whisperx diarize所需的huggingface token
+	MinSpeakers int     `toml:"min_speakers"` // 0表示不限制
+	MaxSpeakers int     `toml:"max_speakers"`
+	Threshold   float64 `toml:"threshold"` // 本地聚类和跨分段匹配说话人的相似度阈值，越大越容易区分出新的说话人
+	Labels      string  `toml:"labels"`    // 字幕中的说话人标注：none 不标注；prefix 在字幕前加[S1]；ass 烧录时按说话人使用不同颜色
+}
+
+// HallucinationConfig 转录后过滤whisper类模型在静音、音乐处编造的内容
+type HallucinationConfig struct {
+	Enable            bool                `toml:"enable"`
+	Action            string              `toml:"action"`               // drop 删除可疑片段；mark 只在转录数据中标记并写入报告
+	MaxRepeat         int                 `toml:"max_repeat"`           // 同一短语连续重复超过该次数视为循环输出
+	MaxWordsPerSecond float64             `toml:"max_words_per_second"` // 语速超过该值视为异常，中日韩文字每两个字按一个词计
+	Blocklist         map[string][]string `toml:"blocklist"`            // 按语言配置的屏蔽短语，key为语言代码，"*"对所有语言生效，与内置列表合并
+}
+
+// ReviewConfig 按转录置信度列出需要人工复核的字幕，转录服务未提供置信度时不生成
+type ReviewConfig struct {
+	Enable            bool    `toml:"enable"`
+	MinAvgConfidence  float64 `toml:"min_avg_confidence"`  // 字幕内单词的平均置信度低于该值时需要复核
+	MinWordConfidence float64 `toml:"min_word_confidence"` // 字幕内任一单词的置信度低于该值时需要复核
+}
+
+// TranscriptionCacheConfig 按分段音频内容缓存转录结果，同一视频换目标语言或样式重新处理时不必重复转录
+type TranscriptionCacheConfig struct {
+	Enable    bool   `toml:"enable"`
+	Dir       string `toml:"dir"`         // 缓存目录，所有任务共享
+	MaxSizeMb int    `toml:"max_size_mb"` // 缓存总大小上限，超过时删除最久未使用的结果，0表示不限制
+}
+
 type AliyunSpeechConfig struct {
 	AccessKeyId     string `toml:"access_key_id"`
 	AccessKeySecret string `toml:"access_key_secret"`
@@ -62,14 +229,89 @@ type AliyunTranscribeConfig struct {
 	Speech AliyunSpeechConfig `toml:"speech"`
 }
 
+// ProviderChain 按顺序排列的转录服务，首个失败时依次回退到后面的服务。
+// 配置中既可以写单个字符串 provider = "openai"，也可以写列表 provider = ["openai","fasterwhisper"]
+type ProviderChain []string
+
+func ParseProviderChain(s string) ProviderChain {
+	var chain ProviderChain
+	for _, provider := range strings.Split(s, ",") {
+		if provider = strings.TrimSpace(provider); provider != "" {
+			chain = append(chain, provider)
+		}
+	}
+	return chain
+}
+
+func (p ProviderChain) Primary() string {
+	if len(p) == 0 {
+		return ""
+	}
+	return p[0]
+}
+
+func (p ProviderChain) String() string {
+	return strings.Join(p, ",")
+}
+
+// WithPrimary 将provider调整为首选，保留其余回退服务的顺序
+func (p ProviderChain) WithPrimary(provider string) ProviderChain {
+	chain := ProviderChain{provider}
+	for _, other := range p {
+		if other != provider {
+			chain = append(chain, other)
+		}
+	}
+	return chain
+}
+
+func (p *ProviderChain) UnmarshalTOML(data any) error {
+	switch v := data.(type) {
+	case string:
+		*p = ParseProviderChain(v)
+	case []any:
+		chain := make(ProviderChain, 0, len(v))
+		for _, item := range v {
+			provider, ok := item.(string)
+			if !ok {
+				return fmt.Errorf("transcribe.provider 列表只能包含字符串: %v", item)
+			}
+			chain = append(chain, strings.TrimSpace(provider))
+		}
+		*p = chain
+	default:
+		return fmt.Errorf("transcribe.provider 必须是字符串或字符串列表: %v", data)
+	}
+	return nil
+}
+
+// MarshalTOML 只有一个服务时保存为字符串，兼容旧版本配置
+func (p ProviderChain) MarshalTOML() ([]byte, error) {
+	if len(p) == 1 {
+		return []byte(strconv.Quote(p[0])), nil
+	}
+	quoted := make([]string, len(p))
+	for i, provider := range p {
+		quoted[i] = strconv.Quote(provider)
+	}
+	return []byte("[" + strings.Join(quoted, ", ") + "]"), nil
+}
+
 type Transcribe struct {
-	Provider              string                 `toml:"provider"`
-	EnableGpuAcceleration bool                   `toml:"enable_gpu_acceleration"`
-	Openai                OpenaiCompatibleConfig `toml:"openai"`
-	Fasterwhisper         LocalModelConfig       `toml:"fasterwhisper"`
-	Whisperkit            LocalModelConfig       `toml:"whisperkit"`
-	Whispercpp            LocalModelConfig       `toml:"whispercpp"`
-	Aliyun                AliyunTranscribeConfig `toml:"aliyun"`
+	Provider              ProviderChain            `toml:"provider"`
+	EnableGpuAcceleration bool                     `toml:"enable_gpu_acceleration"`
+	Openai                OpenaiCompatibleConfig   `toml:"openai"`
+	Fasterwhisper         LocalModelConfig         `toml:"fasterwhisper"`
+	Whisperkit            LocalModelConfig         `toml:"whisperkit"`
+	Whispercpp            WhispercppConfig         `toml:"whispercpp"`
+	Whisperx              WhisperXConfig           `toml:"whisperx"`
+	Aliyun                AliyunTranscribeConfig   `toml:"aliyun"`
+	Diarization           DiarizationConfig        `toml:"diarization"`
+	Hallucination         HallucinationConfig      `toml:"hallucination"`
+	Cache                 TranscriptionCacheConfig `toml:"cache"`
+	Review                ReviewConfig             `toml:"review"`
+	Vocabulary            []string                 `toml:"vocabulary"` // 默认热词，与任务提交的热词合并
+	Prompt                string                   `toml:"prompt"`     // 默认背景说明，任务未填写时使用
 }
 
 type AliyunTtsConfig struct {
@@ -107,13 +349,13 @@ type OpenAiWhisper struct {
 }
 
 type Config struct {
-	App        App                    `toml:"app"`
-	Server     Server                 `toml:"server"`
-	Llm        OpenaiCompatibleConfig `toml:"llm"`
-	Transcribe Transcribe             `toml:"transcribe"`
-	Tts        Tts                    `toml:"tts"`
-	Dubbing    Dubbing                `toml:"dubbing"`
-	Image      Image                  `toml:"image"`
+	App        App        `toml:"app"`
+	Server     Server     `toml:"server"`
+	Llm        LlmConfig  `toml:"llm"`
+	Transcribe Transcribe `toml:"transcribe"`
+	Tts        Tts        `toml:"tts"`
+	Dubbing    Dubbing    `toml:"dubbing"`
+	Image      Image      `toml:"image"`
 }
 
 var Conf = Config{
@@ -126,16 +368,60 @@ var Conf = Config{
 		MaxSentenceLength:     70,
 		EnableBlockVttBatch:   false,
 		VttBatchSize:          10,
+		Vad: Vad{
+			Enable:      true,
+			ThresholdDb: 12,
+			TrimSilence: 2,
+		},
+		TranslationMemory: TranslationMemory{
+			Enable:         false,
+			Path:           "./cache/translation_memory.jsonl",
+			FuzzyThreshold: 0.85,
+		},
 	},
 	Server: Server{
-		Host: "127.0.0.1",
-		Port: 8888,
+		Host:         "127.0.0.1",
+		Port:         8888,
+		TaskStore:    "file",
+		TaskStoreDir: "./data/task_store",
+		Workers:      2,
+		QueueSize:    20,
+		Concurrency: Concurrency{
+			Transcribe: 2,
+			Llm:        6,
+			Tts:        4,
+			Ffmpeg:     1,
+		},
+		Retention: Retention{
+			IntervalMinutes: 60,
+		},
 	},
-	Llm: OpenaiCompatibleConfig{
-		Model: "gpt-4o-mini",
+	Llm: LlmConfig{
+		Provider: "openai",
+		Model:    "gpt-4o-mini",
+		Translate: LlmUseCase{
+			SystemPrompt: "You are a professional subtitle translator. Output only what the user asks for.",
+			Temperature:  0, // 同一句多次翻译结果一致，便于断点续跑和术语检查
+			MaxTokens:    4096,
+		},
+		Split: LlmUseCase{
+			SystemPrompt: "You split and align subtitle sentences. Output only valid JSON.",
+			Temperature:  0,
+			MaxTokens:    4096,
+		},
+		Dubbing: LlmUseCase{
+			SystemPrompt: "You rewrite subtitles into short, natural lines for voice-over.",
+			Temperature:  0.7,
+			MaxTokens:    1024,
+		},
+		General: LlmUseCase{
+			SystemPrompt: "You are an assistant that helps with subtitle translation.",
+			Temperature:  0.9,
+			MaxTokens:    8192,
+		},
 	},
 	Transcribe: Transcribe{
-		Provider:              "openai",
+		Provider:              ProviderChain{"openai"},
 		EnableGpuAcceleration: false, // 默认不开启GPU加速
 		Openai: OpenaiCompatibleConfig{
 			Model: "whisper-1",
@@ -146,9 +432,37 @@ var Conf = Config{
 		Whisperkit: LocalModelConfig{
 			Model: "large-v2",
 		},
-		Whispercpp: LocalModelConfig{
+		Whispercpp: WhispercppConfig{
 			Model: "large-v2",
 		},
+		Whisperx: WhisperXConfig{
+			Model:          "large-v2",
+			BatchSize:      16,
+			Align:          true,
+			ModelCacheOnly: true,
+		},
+		Diarization: DiarizationConfig{
+			Provider:    "cluster",
+			MaxSpeakers: 4,
+			Threshold:   0.9,
+			Labels:      "prefix",
+		},
+		Hallucination: HallucinationConfig{
+			Enable:            false,
+			Action:            "mark",
+			MaxRepeat:         3,
+			MaxWordsPerSecond: 7,
+		},
+		Cache: TranscriptionCacheConfig{
+			Enable:    false, // 默认关闭，缓存不随任务删除和过期清理而删除
+			Dir:       "./cache/transcription",
+			MaxSizeMb: 1024,
+		},
+		Review: ReviewConfig{
+			Enable:            true,
+			MinAvgConfidence:  0.6,
+			MinWordConfidence: 0.3,
+		},
 	},
 	Tts: Tts{
 		Provider: "openai",
@@ -177,8 +491,145 @@ var Conf = Config{
 
 // 检查必要的配置是否完整
 func validateConfig() error {
+	if Conf.Server.TaskStore != "" && Conf.Server.TaskStore != "file" && Conf.Server.TaskStore != "memory" {
+		return fmt.Errorf("不支持的任务存储方式: %s", Conf.Server.TaskStore)
+	}
+	if Conf.Server.Workers <= 0 {
+		return errors.New("server.workers 必须大于0")
+	}
+	if Conf.Server.QueueSize <= 0 {
+		return errors.New("server.queue_size 必须大于0")
+	}
+	if Conf.Server.Retention.IntervalMinutes < 0 || Conf.Server.Retention.MaxAgeHours < 0 || Conf.Server.Retention.MaxTotalSizeMb < 0 {
+		return errors.New("server.retention 的配置项不能小于0")
+	}
+	if err := validateApiKeys(Conf.Server.ApiKeys); err != nil {
+		return err
+	}
+	for _, network := range Conf.Server.CallbackAllowedNetworks {
+		if _, err := ParseNetwork(network); err != nil {
+			return fmt.Errorf("server.callback_allowed_networks 地址不合法: %s", network)
+		}
+	}
+	if Conf.App.Vad.Enable && (Conf.App.Vad.ThresholdDb <= 0 || Conf.App.Vad.TrimSilence < 0) {
+		return errors.New("app.vad.threshold_db 必须大于0，trim_silence 不能小于0")
+	}
+	if Conf.App.Glossary != "" {
+		if _, err := os.Stat(Conf.App.Glossary); err != nil {
+			return fmt.Errorf("app.glossary 术语表文件不可用: %w", err)
+		}
+	}
+	if tm := Conf.App.TranslationMemory; tm.Enable && (tm.Path == "" || tm.FuzzyThreshold < 0 || tm.FuzzyThreshold > 1) {
+		return errors.New("app.translation_memory.path 不能为空，fuzzy_threshold 必须在0到1之间")
+	}
+	if err := validateLlm(Conf.Llm); err != nil {
+		return err
+	}
+
 	// 检查转写服务提供商配置
-	switch Conf.Transcribe.Provider {
+	if len(Conf.Transcribe.Provider) == 0 {
+		return errors.New("不支持的转录提供商")
+	}
+	if err := validateDiarization(Conf.Transcribe.Diarization); err != nil {
+		return err
+	}
+	if err := validateHallucination(Conf.Transcribe.Hallucination); err != nil {
+		return err
+	}
+	if Conf.Transcribe.Cache.Enable && (Conf.Transcribe.Cache.Dir == "" || Conf.Transcribe.Cache.MaxSizeMb < 0) {
+		return errors.New("transcribe.cache.dir 不能为空，max_size_mb 不能小于0")
+	}
+	if review := Conf.Transcribe.Review; review.Enable && (review.MinAvgConfidence < 0 || review.MinAvgConfidence > 1 || review.MinWordConfidence < 0 || review.MinWordConfidence > 1) {
+		return errors.New("transcribe.review 的置信度阈值必须在0到1之间")
+	}
+	seen := make(map[string]bool, len(Conf.Transcribe.Provider))
+	for _, provider := range Conf.Transcribe.Provider {
+		if seen[provider] {
+			return fmt.Errorf("transcribe.provider 存在重复的转录提供商: %s", provider)
+		}
+		seen[provider] = true
+		if err := validateTranscribeProvider(provider); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateDiarization(d DiarizationConfig) error {
+	if !d.Enable {
+		return nil
+	}
+	switch d.Provider {
+	case "cluster":
+	case "whisperx":
+		if !slices.Contains(Conf.Transcribe.Provider, "whisperx") {
+			return errors.New("diarization.provider 为 whisperx 时转录服务需要包含 whisperx")
+		}
+		if d.HfToken == "" {
+			return errors.New("whisperx说话人识别需要配置 diarization.hf_token")
+		}
+	default:
+		return fmt.Errorf("不支持的说话人识别方式: %s，可选值：cluster,whisperx", d.Provider)
+	}
+	if d.MinSpeakers < 0 || d.MaxSpeakers < 0 || (d.MaxSpeakers > 0 && d.MinSpeakers > d.MaxSpeakers) {
+		return errors.New("diarization 的 min_speakers/max_speakers 配置不正确")
+	}
+	if d.Threshold <= 0 || d.Threshold >= 1 {
+		return errors.New("diarization.threshold 必须在0到1之间")
+	}
+	if d.Labels != "none" && d.Labels != "prefix" && d.Labels != "ass" {
+		return fmt.Errorf("不支持的说话人标注方式: %s，可选值：none,prefix,ass", d.Labels)
+	}
+	return nil
+}
+
+func validateLlm(c LlmConfig) error {
+	switch c.Provider {
+	case "", "openai":
+	case "anthropic", "gemini", "ollama":
+		// 默认模型是openai的，换用其他服务时必须指定模型
+		if c.Model == "" || c.Model == "gpt-4o-mini" {
+			return fmt.Errorf("llm.provider为%s时需要在llm.model中填写对应的模型名", c.Provider)
+		}
+		if c.Provider != "ollama" && c.ApiKey == "" {
+			return fmt.Errorf("llm.provider为%s时需要配置llm.api_key", c.Provider)
+		}
+	default:
+		return fmt.Errorf("不支持的llm.provider: %s，可选值：openai,anthropic,gemini,ollama", c.Provider)
+	}
+	for name, useCase := range map[string]LlmUseCase{
+		LlmUseCaseTranslate: c.Translate,
+		LlmUseCaseSplit:     c.Split,
+		LlmUseCaseDubbing:   c.Dubbing,
+		LlmUseCaseGeneral:   c.General,
+	} {
+		if useCase.Temperature < 0 || useCase.Temperature > 2 {
+			return fmt.Errorf("llm.%s.temperature 必须在0到2之间", name)
+		}
+		if useCase.MaxTokens < 0 {
+			return fmt.Errorf("llm.%s.max_tokens 不能小于0", name)
+		}
+	}
+	return nil
+}
+
+func validateHallucination(h HallucinationConfig) error {
+	if !h.Enable {
+		return nil
+	}
+	if h.Action != "drop" && h.Action != "mark" {
+		return fmt.Errorf("不支持的幻觉内容处理方式: %s，可选值：drop,mark", h.Action)
+	}
+	if h.MaxRepeat < 1 || h.MaxWordsPerSecond <= 0 {
+		return errors.New("hallucination.max_repeat 和 max_words_per_second 必须大于0")
+	}
+	return nil
+}
+
+// validateTranscribeProvider 检查单个转录服务的配置
+func validateTranscribeProvider(provider string) error {
+	switch provider {
 	case "openai":
 		if Conf.Transcribe.Openai.ApiKey == "" {
 			return errors.New("使用OpenAI转录服务需要配置 OpenAI API Key")
@@ -196,21 +647,83 @@ func validateConfig() error {
 			return errors.New("检测到开启了whisperkit，但模型选型配置不正确，请检查配置")
 		}
 	case "whispercpp":
-		if runtime.GOOS != "windows" { // 当前先仅支持win，模型仅支持large-v2，最小化产品
-			log.GetLogger().Error("whispercpp only support windows", zap.String("current os", runtime.GOOS))
-			return fmt.Errorf("whispercpp only support windows")
+		if Conf.Transcribe.Whispercpp.ServerUrl != "" { // 使用whisper-server时模型由服务端加载，不限制系统
+			if _, err := url.ParseRequestURI(Conf.Transcribe.Whispercpp.ServerUrl); err != nil {
+				return fmt.Errorf("whispercpp的server_url不合法: %w", err)
+			}
+			break
 		}
-		if Conf.Transcribe.Whispercpp.Model != "large-v2" {
-			return errors.New("检测到开启了whisper.cpp，但模型选型配置不正确，请检查配置")
+		if runtime.GOOS != "windows" && runtime.GOOS != "linux" {
+			log.GetLogger().Error("whispercpp cli only support windows and linux", zap.String("current os", runtime.GOOS))
+			return fmt.Errorf("whispercpp本地命令行只支持windows和linux，其它系统请配置server_url使用whisper-server")
+		}
+		if !slices.Contains(WhispercppModels, Conf.Transcribe.Whispercpp.Model) {
+			return fmt.Errorf("检测到开启了whisper.cpp，但模型选型配置不正确，可选值：%s", strings.Join(WhispercppModels, ","))
+		}
+	case "whisperx":
+		if runtime.GOOS != "linux" && runtime.GOOS != "windows" {
+			log.GetLogger().Error("whisperx only support linux and windows", zap.String("current os", runtime.GOOS))
+			return fmt.Errorf("whisperx只支持linux和windows")
+		}
+		if Conf.Transcribe.Whisperx.Model == "" {
+			return errors.New("检测到开启了whisperx，但未配置模型，请检查配置")
+		}
+		switch computeType := Conf.Transcribe.Whisperx.ComputeTypeFor(Conf.Transcribe.EnableGpuAcceleration); computeType {
+		case "float16":
+			if !Conf.Transcribe.EnableGpuAcceleration {
+				return errors.New("whisperx的compute_type为float16时需要开启enable_gpu_acceleration，CPU请使用int8或float32")
+			}
+		case "int8", "float32":
+		default:
+			return fmt.Errorf("whisperx不支持的compute_type: %s，可选值：float16,int8,float32", computeType)
+		}
+		if Conf.Transcribe.Whisperx.BatchSize <= 0 {
+			return errors.New("whisperx的batch_size必须大于0")
 		}
 	case "aliyun":
 		if Conf.Transcribe.Aliyun.Speech.AccessKeyId == "" || Conf.Transcribe.Aliyun.Speech.AccessKeySecret == "" || Conf.Transcribe.Aliyun.Speech.AppKey == "" {
 			return errors.New("使用阿里云语音服务需要配置相关密钥")
 		}
 	default:
-		return errors.New("不支持的转录提供商")
+		return fmt.Errorf("不支持的转录提供商: %s", provider)
 	}
+	return nil
+}
 
+// ParseNetwork 解析CIDR，单个IP视为只包含该地址的网段
+func ParseNetwork(s string) (netip.Prefix, error) {
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return prefix.Masked(), nil
+}
+
+func validateApiKeys(keys []ApiKey) error {
+	seen := make(map[string]bool, len(keys))
+	for i, key := range keys {
+		if key.Key == "" {
+			return fmt.Errorf("server.api_keys 第%d项未填写key", i+1)
+		}
+		if seen[key.Key] {
+			return fmt.Errorf("server.api_keys 存在重复的key: %s", key.Name)
+		}
+		seen[key.Key] = true
+		if len(key.Scopes) == 0 {
+			return fmt.Errorf("server.api_keys %s 未配置scopes", key.Name)
+		}
+		for _, scope := range key.Scopes {
+			if scope != ScopeTask && scope != ScopeConfig {
+				return fmt.Errorf("server.api_keys %s 存在不支持的scope: %s", key.Name, scope)
+			}
+		}
+		if key.RateLimit < 0 {
+			return fmt.Errorf("server.api_keys %s 的rate_limit不能小于0", key.Name)
+		}
+	}
 	return nil
 }
 
@@ -1,6 +1,12 @@
 package config
 
-import "testing"
+import (
+	"runtime"
+	"slices"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+)
 
 func TestDefaultImageConfig(t *testing.T) {
 	if Conf.Image.Provider != "openai-compatible" {
@@ -34,3 +40,121 @@ func TestDefaultDubbingConfig(t *testing.T) {
 		t.Fatalf("Estimator = %q, want statistical", Conf.Dubbing.Estimator)
 	}
 }
+
+func TestTranscribeProviderChainToml(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  []string
+	}{
+		{`provider = "openai"`, []string{"openai"}},
+		{`provider = ["openai", "fasterwhisper"]`, []string{"openai", "fasterwhisper"}},
+	} {
+		var transcribe Transcribe
+		if _, err := toml.Decode(tc.input, &transcribe); err != nil {
+			t.Fatalf("Decode(%s) error = %v", tc.input, err)
+		}
+		if !slices.Equal(transcribe.Provider, tc.want) {
+			t.Fatalf("Decode(%s) = %v, want %v", tc.input, transcribe.Provider, tc.want)
+		}
+
+		data, err := toml.Marshal(transcribe)
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		var decoded Transcribe
+		if _, err := toml.Decode(string(data), &decoded); err != nil {
+			t.Fatalf("Decode(Marshal()) error = %v\n%s", err, data)
+		}
+		if !slices.Equal(decoded.Provider, tc.want) {
+			t.Fatalf("round trip = %v, want %v", decoded.Provider, tc.want)
+		}
+	}
+}
+
+func TestValidateTranscribeProviderChain(t *testing.T) {
+	backup := Conf
+	defer func() { Conf = backup }()
+
+	Conf.Transcribe.Provider = ProviderChain{"openai", "fasterwhisper"}
+	Conf.Transcribe.Openai.ApiKey = "sk-test"
+	Conf.Transcribe.Fasterwhisper.Model = "medium"
+	if err := validateConfig(); err != nil {
+		t.Fatalf("validateConfig() error = %v", err)
+	}
+
+	Conf.Transcribe.Provider = ProviderChain{"openai", "openai"}
+	if err := validateConfig(); err == nil {
+		t.Fatal("validateConfig() accepted duplicate providers")
+	}
+
+	Conf.Transcribe.Provider = ProviderChain{"openai", "unknown"}
+	if err := validateConfig(); err == nil {
+		t.Fatal("validateConfig() accepted unknown fallback provider")
+	}
+}
+
+func TestValidateWhisperxComputeType(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "windows" {
+		t.Skip("whisperx只支持linux和windows")
+	}
+	backup := Conf
+	defer func() { Conf = backup }()
+
+	// 默认配置未开启GPU加速，compute_type自动使用int8
+	if err := validateTranscribeProvider("whisperx"); err != nil {
+		t.Fatalf("validateTranscribeProvider() error = %v", err)
+	}
+	if got := Conf.Transcribe.Whisperx.ComputeTypeFor(false); got != "int8" {
+		t.Fatalf("ComputeTypeFor(false) = %q, want int8", got)
+	}
+	if got := Conf.Transcribe.Whisperx.ComputeTypeFor(true); got != "float16" {
+		t.Fatalf("ComputeTypeFor(true) = %q, want float16", got)
+	}
+
+	Conf.Transcribe.Whisperx.ComputeType = "float16"
+	if err := validateTranscribeProvider("whisperx"); err == nil {
+		t.Fatal("validateTranscribeProvider() accepted float16 without gpu")
+	}
+}
+
+func TestLlmUseCaseToml(t *testing.T) {
+	backup := Conf
+	defer func() { Conf = backup }()
+
+	input := "[llm]\njson = true\n[llm.translate]\ntemperature = 0.3\n"
+	if _, err := toml.Decode(input, &Conf); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	translate := Conf.Llm.UseCase(LlmUseCaseTranslate)
+	if !Conf.Llm.Json || translate.Temperature != 0.3 || translate.MaxTokens != 4096 || translate.SystemPrompt == "" {
+		t.Fatalf("translate use case = %+v", translate)
+	}
+	if Conf.Llm.UseCase("unknown").Temperature != Conf.Llm.General.Temperature {
+		t.Fatal("unknown use case should fall back to general")
+	}
+
+	Conf.Llm.Dubbing.Temperature = 2.5
+	if err := validateLlm(Conf.Llm); err == nil {
+		t.Fatal("validateLlm() accepted temperature above 2")
+	}
+}
+
+func TestValidateLlmProvider(t *testing.T) {
+	llm := Conf.Llm
+	llm.Provider = "ollama"
+	if err := validateLlm(llm); err == nil {
+		t.Fatal("validateLlm() accepted ollama with the default openai model")
+	}
+	llm.Model = "qwen2.5:14b"
+	if err := validateLlm(llm); err != nil {
+		t.Fatalf("validateLlm() error = %v", err)
+	}
+	llm.Provider = "anthropic"
+	if err := validateLlm(llm); err == nil {
+		t.Fatal("validateLlm() accepted anthropic without api_key")
+	}
+	llm.Provider = "unknown"
+	if err := validateLlm(llm); err == nil {
+		t.Fatal("validateLlm() accepted unknown provider")
+	}
+}
@@ -17,6 +17,7 @@ require (
 	github.com/texttheater/golang-levenshtein v1.0.1
 	go.uber.org/zap v1.25.0
 	golang.org/x/sync v0.9.0
+	golang.org/x/time v0.4.0
 )
 
 require (
@@ -77,7 +78,6 @@ require (
 	golang.org/x/net v0.25.0 // indirect
 	golang.org/x/sys v0.20.0 // indirect
 	golang.org/x/text v0.20.0 // indirect
-	golang.org/x/time v0.4.0 // indirect
 	google.golang.org/protobuf v1.34.1 // indirect
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
 	gopkg.in/ini.v1 v1.67.0 // indirect
@@ -0,0 +1,154 @@
+package cli
+
+import (
+	"krillin-ai/internal/pipeline"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	pipelineWorkerEnv   = "KRILLINAI_PIPELINE_WORKER"
+	pipelineLogFileName = "pipeline_async.log"
+)
+
+var isPipelineWorker = func() bool {
+	return os.Getenv(pipelineWorkerEnv) == "1"
+}
+
+// startPipelineWorker re-runs the current executable as a detached pipeline worker
+// with stdout and stderr redirected to logFile, returning the worker PID.
+var startPipelineWorker = func(args []string, logFile string) (int, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+	out, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+	worker := exec.Command(exe, args...)
+	worker.Env = append(os.Environ(), pipelineWorkerEnv+"=1")
+	if wd, err := os.Getwd(); err == nil {
+		worker.Dir = wd
+	}
+	worker.Stdout = out
+	worker.Stderr = out
+	detachWorker(worker)
+	if err := worker.Start(); err != nil {
+		return 0, err
+	}
+	pid := worker.Process.Pid
+	_ = worker.Process.Release()
+	return pid, nil
+}
+
+func executeAsyncPipeline(cmd Command) pipeline.Response {
+	req := cmd.Pipeline
+	if err := pipeline.ResolvePipelineWorkdir(&req); err != nil {
+		return asyncPipelineError(req, pipeline.ErrorKindUsage, "resolve_workdir_failed", err)
+	}
+	if _, err := pipeline.PlanOutputs(req.Outputs); err != nil {
+		return asyncPipelineError(req, pipeline.ErrorKindUsage, "invalid_outputs", err)
+	}
+	logFile := filepath.Join(req.Workdir, pipelineLogFileName)
+	now := time.Now().UTC().Format(time.RFC3339)
+	run := pipeline.RunStatus{
+		State:     pipeline.RunStateQueued,
+		Async:     true,
+		Outputs:   req.Outputs,
+		StartedAt: now,
+		Heartbeat: now,
+		LogFile:   logFile,
+	}
+	if err := pipeline.SaveRunStatus(req.Workdir, req.TaskID, run); err != nil {
+		return asyncPipelineError(req, pipeline.ErrorKindInternal, "save_manifest_failed", err)
+	}
+	pid, err := startPipelineWorker(pipelineWorkerArgs(req, cmd.SubtitleStyleFile), logFile)
+	if err != nil {
+		run.State = pipeline.RunStateFailed
+		run.Error = &pipeline.Error{Kind: pipeline.ErrorKindInternal, Code: "start_worker_failed", Message: err.Error()}
+		run.FinishedAt = time.Now().UTC().Format(time.RFC3339)
+		_ = pipeline.SaveRunStatus(req.Workdir, req.TaskID, run)
+		return asyncPipelineError(req, pipeline.ErrorKindInternal, "start_worker_failed", err)
+	}
+	run.PID = pid
+	if err := pipeline.RecordRunPID(req.Workdir, pid); err != nil {
+		return asyncPipelineError(req, pipeline.ErrorKindInternal, "save_manifest_failed", err)
+	}
+	return pipeline.Response{
+		OK:      true,
+		Stage:   pipeline.StagePipeline,
+		Workdir: req.Workdir,
+		TaskID:  req.TaskID,
+		Inputs: map[string]string{
+			"outputs":  req.Outputs,
+			"pid":      strconv.Itoa(pid),
+			"log_file": logFile,
+		},
+		Run: &run,
+	}
+}
+
+// pipelineWorkerArgs rebuilds the pipeline command line for the worker, pinning the resolved workdir and task id.
+func pipelineWorkerArgs(req pipeline.PipelineRequest, subtitleStyleFile string) []string {
+	args := []string{"pipeline"}
+	if req.Input != "" {
+		args = append(args, req.Input)
+	}
+	args = append(args,
+		"--outputs", req.Outputs,
+		"--workdir", req.Workdir,
+		"--task-id", req.TaskID,
+		"--async",
+	)
+	appendFlag := func(name, value string) {
+		if value != "" {
+			args = append(args, "--"+name, value)
+		}
+	}
+	appendFlag("origin-lang", req.Subtitle.OriginLang)
+	appendFlag("target-lang", req.Subtitle.TargetLang)
+	appendFlag("user-lang", req.Subtitle.UserLang)
+	appendFlag("caption-source", string(req.Subtitle.CaptionSource))
+	args = append(args, "--bilingual-top="+strconv.FormatBool(req.Subtitle.BilingualTop))
+	if req.Subtitle.MaxWordOneLine > 0 {
+		appendFlag("max-word-one-line", strconv.Itoa(req.Subtitle.MaxWordOneLine))
+	}
+	appendFlag("subtitle-style-file", subtitleStyleFile)
+	if req.Subtitle.Resume {
+		args = append(args, "--resume")
+	}
+	appendFlag("vocabulary", strings.Join(req.Subtitle.Vocabulary, ","))
+	appendFlag("transcribe-prompt", req.Subtitle.Prompt)
+	if req.Subtitle.NoCache {
+		args = append(args, "--no-cache")
+	}
+	appendFlag("glossary", req.Subtitle.GlossaryFile)
+	appendFlag("line-mode", string(req.TTS.LineMode))
+	appendFlag("voice", req.TTS.Voice)
+	appendFlag("voice-clone-source", req.TTS.VoiceCloneSource)
+	appendFlag("major-title", req.Render.MajorTitle)
+	appendFlag("minor-title", req.Render.MinorTitle)
+	appendFlag("prompt", req.Cover.Prompt)
+	appendFlag("size", req.Cover.Size)
+	return args
+}
+
+func asyncPipelineError(req pipeline.PipelineRequest, kind pipeline.ErrorKind, code string, err error) pipeline.Response {
+	return pipeline.Response{
+		OK:      false,
+		Stage:   pipeline.StagePipeline,
+		Workdir: req.Workdir,
+		TaskID:  req.TaskID,
+		Error: &pipeline.Error{
+			Kind:    kind,
+			Code:    code,
+			Message: err.Error(),
+		},
+	}
+}
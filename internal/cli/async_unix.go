@@ -0,0 +1,14 @@
+//go:build !windows
+
+package cli
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detachWorker starts the worker in its own session so a terminal hangup or
+// Ctrl-C in the caller's shell does not reach it.
+func detachWorker(worker *exec.Cmd) {
+	worker.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
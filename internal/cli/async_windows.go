@@ -0,0 +1,17 @@
+//go:build windows
+
+package cli
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detachedProcess is DETACHED_PROCESS, which the syscall package does not export.
+const detachedProcess = 0x00000008
+
+// detachWorker starts the worker in a new process group without a console so
+// Ctrl-C or closing the caller's console window does not reach it.
+func detachWorker(worker *exec.Cmd) {
+	worker.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP | detachedProcess}
+}
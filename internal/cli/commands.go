@@ -55,6 +55,8 @@ type Command struct {
 	Pipeline          pipeline.PipelineRequest
 	Update            UpdateRequest
 	Voices            VoicesRequest
+	Status            StatusRequest
+	TM                TMRequest
 }
 
 type UpdateRequest struct {
@@ -94,10 +96,9 @@ func Parse(args []string) (Command, error) {
 	case "voices":
 		return parseVoices(name, args[1:])
 	case "status":
-		if hasHelpArg(args[1:]) {
-			return Command{Name: name, Help: true}, nil
-		}
-		return Command{Name: name}, nil
+		return parseStatus(name, args[1:])
+	case "tm":
+		return parseTM(name, args[1:])
 	default:
 		return Command{}, fmt.Errorf("unknown command: %s", name)
 	}
@@ -110,8 +111,8 @@ func Help(cmd Command) string {
   krillinai-cli subtitle <input> --origin-lang <lang> --target-lang <lang> --workdir <dir> [flags]
 
 Flags:
-  --origin-lang <lang>       Source language, such as en, zh, ja
-  --target-lang <lang>       Target language, such as zh_cn
+  --origin-lang <lang>       Source language, such as en, zh, ja; auto or omitted to detect it
+  --target-lang <lang>       Target language, such as zh_cn; comma-separated for several, such as zh_cn,ja
   --user-lang <lang>         UI language for generated messages
   --workdir <dir>            Task working directory
   --task-id <id>             Optional task id
@@ -119,6 +120,11 @@ Flags:
   --bilingual-top            Put target subtitle on top (default true)
   --max-word-one-line <n>    Max words per subtitle line
   --subtitle-style-file <file>  JSON subtitle style override file
+  --resume                   Reuse finished segment transcriptions and translations in the workdir
+  --vocabulary <words>       Comma-separated product names and jargon to help transcription
+  --transcribe-prompt <text> Context for transcription, such as the video title
+  --no-cache                 Re-transcribe instead of reusing cached transcriptions
+  --glossary <file>          CSV or TOML glossary of fixed term translations
   --dry-run                  Validate command without external calls
   -h, --help                 Show this help
 `
@@ -171,13 +177,37 @@ Flags:
 `
 	case "pipeline":
 		return `Usage:
-  krillinai-cli pipeline --outputs <list> [flags]
+  krillinai-cli pipeline [input] --outputs <list> --workdir <dir> [flags]
+
+Stages run in the order subtitle, tts, render, cover against one workdir.
+Stages already marked ok in the manifest are skipped; the first failure stops the run.
 
 Flags:
-  --outputs <list>  Comma-separated outputs, such as subtitle,tts,vertical-bilingual
-  --async           Run asynchronously when supported
-  --dry-run         Validate requested outputs
-  -h, --help        Show this help
+  --outputs <list>              Comma-separated outputs, such as subtitle,tts,vertical-bilingual
+  --workdir <dir>               Task working directory (required when input is omitted)
+  --task-id <id>                Optional task id
+  --origin-lang <lang>          Source language for the subtitle stage; auto or omitted to detect it
+  --target-lang <lang>          Target language for the subtitle stage; comma-separated for several
+  --user-lang <lang>            UI language for generated messages
+  --caption-source <source>     any, manual, auto, or whisper
+  --bilingual-top               Put target subtitle on top (default true)
+  --max-word-one-line <n>       Max words per subtitle line
+  --subtitle-style-file <file>  JSON subtitle style override file
+  --resume                      Reuse finished segment transcriptions and translations in the workdir
+  --vocabulary <words>          Comma-separated product names and jargon to help transcription
+  --transcribe-prompt <text>    Context for transcription, such as the video title
+  --no-cache                    Re-transcribe instead of reusing cached transcriptions
+  --glossary <file>             CSV or TOML glossary of fixed term translations
+  --line-mode <mode>            TTS line mode: target-only, bilingual-target-top, or bilingual-target-bottom
+  --voice <voice>               Provider-specific TTS voice
+  --voice-clone-source <source> Optional voice clone source
+  --major-title <text>          Vertical video major title
+  --minor-title <text>          Vertical video minor title
+  --prompt <text>               Cover prompt (required when outputs include cover)
+  --size <size>                 Cover image size, such as 1024x1024
+  --async                       Detach a background worker and return immediately; poll with status
+  --dry-run                     Validate requested outputs
+  -h, --help                    Show this help
 `
 	case "cover":
 		return `Usage:
@@ -214,9 +244,29 @@ Flags:
 `
 	case "status":
 		return `Usage:
-  krillinai-cli status
+  krillinai-cli status --workdir <dir>
+
+Reads krillinai_manifest.json and reports the pipeline run state
+(queued, running, succeeded, failed, or stale) with per-stage timings.
+A queued or running pipeline without a recent heartbeat is reported as stale.
+
+Flags:
+  --workdir <dir>   Task working directory
+  -h, --help        Show this help
+`
+	case "tm":
+		return `Usage:
+  krillinai-cli tm import --file <file.tmx> [flags]
+  krillinai-cli tm export --file <file.tmx> [flags]
+
+Imports translations from a TMX file into the translation memory shared by
+all tasks, or exports the translation memory as TMX 1.4.
 
-Status query is a reserved/planned CLI surface in the current implementation.
+Flags:
+  --file <file>     TMX file to read (import) or write (export)
+  --memory <file>   Translation memory file; default app.translation_memory.path
+  --dry-run         Parse and count entries without writing files
+  -h, --help        Show this help
 `
 	default:
 		return `Usage:
@@ -227,11 +277,12 @@ Commands:
   tts                  Generate target-language dubbing from SRT subtitles
   render-horizontal    Render landscape subtitle or dubbed videos
   render-vertical      Render portrait subtitle or dubbed videos
-  pipeline             Plan or run multi-stage workflows when supported
+  pipeline             Run multi-stage workflows against one workdir
   cover                Generate a cover image from a prompt
   update               Update krillinai-cli from GitHub releases
   voices               List available TTS voice codes
-  status               Reserved status query surface
+  status               Report pipeline progress from a workdir manifest
+  tm                   Import or export the translation memory as TMX
 
 Run "krillinai-cli <command> --help" for command-specific flags.
 `
@@ -265,10 +316,26 @@ func Execute(ctx context.Context, svc pipeline.StageService, cmd Command) pipeli
 	case "cover":
 		resp, err := pipeline.GenerateCover(ctx, svc, cmd.Cover)
 		return responseWithError(resp, err)
+	case "pipeline":
+		style, err := loadSubtitleStyleForCLI(cmd.SubtitleStyleFile)
+		if err != nil {
+			return styleLoadFailure(pipeline.StagePipeline, cmd.Pipeline.Workdir, cmd.Pipeline.TaskID, err)
+		}
+		if cmd.Pipeline.Async && !isPipelineWorker() {
+			return executeAsyncPipeline(cmd)
+		}
+		cmd.Pipeline.Subtitle.SubtitleStyle = style
+		cmd.Pipeline.Render.SubtitleStyle = style
+		resp, err := pipeline.RunPipeline(ctx, svc, cmd.Pipeline)
+		return responseWithError(resp, err)
 	case "update":
 		return executeUpdate(ctx, cmd.Update)
 	case "voices":
 		return executeVoices(cmd.Voices)
+	case "status":
+		return executeStatus(cmd.Status)
+	case "tm":
+		return executeTM(cmd.TM, false)
 	default:
 		return pipeline.Response{
 			OK: false,
@@ -445,6 +512,11 @@ func parseSubtitle(name string, args []string) (Command, error) {
 	bilingualTop := fs.Bool("bilingual-top", true, "put target subtitle on top")
 	maxWordOneLine := fs.Int("max-word-one-line", 0, "max words per line")
 	subtitleStyleFile := fs.String("subtitle-style-file", "", "subtitle style JSON file")
+	resume := fs.Bool("resume", false, "reuse finished segment artifacts in workdir")
+	vocabulary := fs.String("vocabulary", "", "comma-separated transcription vocabulary")
+	transcribePrompt := fs.String("transcribe-prompt", "", "transcription context")
+	noCache := fs.Bool("no-cache", false, "skip the shared transcription cache")
+	glossary := fs.String("glossary", "", "translation glossary CSV or TOML file")
 	dryRun := fs.Bool("dry-run", false, "validate command without running external services")
 	input := ""
 	parseArgs := args
@@ -475,6 +547,11 @@ func parseSubtitle(name string, args []string) (Command, error) {
 			CaptionSource:  pipeline.CaptionSource(*captionSource),
 			BilingualTop:   *bilingualTop,
 			MaxWordOneLine: *maxWordOneLine,
+			Resume:         *resume,
+			Vocabulary:     pipeline.ParseVocabulary(*vocabulary),
+			Prompt:         *transcribePrompt,
+			NoCache:        *noCache,
+			GlossaryFile:   *glossary,
 		},
 	}, nil
 }
@@ -556,17 +633,89 @@ func parsePipeline(name string, args []string) (Command, error) {
 	fs := newFlagSet(name)
 	outputs := fs.String("outputs", "subtitle", "outputs")
 	async := fs.Bool("async", false, "run async")
+	workdir := fs.String("workdir", "", "workdir")
+	taskID := fs.String("task-id", "", "task id")
+	originLang := fs.String("origin-lang", "", "origin language")
+	targetLang := fs.String("target-lang", "", "target language")
+	userLang := fs.String("user-lang", "", "user interface language")
+	captionSource := fs.String("caption-source", string(pipeline.CaptionSourceAny), "caption source")
+	bilingualTop := fs.Bool("bilingual-top", true, "put target subtitle on top")
+	maxWordOneLine := fs.Int("max-word-one-line", 0, "max words per line")
+	subtitleStyleFile := fs.String("subtitle-style-file", "", "subtitle style JSON file")
+	resume := fs.Bool("resume", false, "reuse finished segment artifacts in workdir")
+	vocabulary := fs.String("vocabulary", "", "comma-separated transcription vocabulary")
+	transcribePrompt := fs.String("transcribe-prompt", "", "transcription context")
+	noCache := fs.Bool("no-cache", false, "skip the shared transcription cache")
+	glossary := fs.String("glossary", "", "translation glossary CSV or TOML file")
+	lineMode := fs.String("line-mode", string(pipeline.LineModeTargetOnly), "line mode")
+	voice := fs.String("voice", "", "voice")
+	voiceCloneSource := fs.String("voice-clone-source", "", "voice clone source")
+	majorTitle := fs.String("major-title", "", "vertical major title")
+	minorTitle := fs.String("minor-title", "", "vertical minor title")
+	prompt := fs.String("prompt", "", "cover image prompt")
+	size := fs.String("size", "", "cover image size")
 	dryRun := fs.Bool("dry-run", false, "validate command without running external services")
-	if err := fs.Parse(args); err != nil {
+	input := ""
+	parseArgs := args
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		input = args[0]
+		parseArgs = args[1:]
+	}
+	if err := fs.Parse(parseArgs); err != nil {
 		return Command{}, err
 	}
-	if _, err := pipeline.PlanOutputs(*outputs); err != nil {
+	if input == "" && fs.NArg() == 1 {
+		input = fs.Arg(0)
+	}
+	if fs.NArg() > 1 {
+		return Command{}, errors.New("pipeline accepts at most one input")
+	}
+	stages, err := pipeline.PlanOutputs(*outputs)
+	if err != nil {
 		return Command{}, err
 	}
+	if input == "" && *workdir == "" {
+		return Command{}, errors.New("pipeline requires input or --workdir")
+	}
+	for _, stage := range stages {
+		if stage == pipeline.StageCover && strings.TrimSpace(*prompt) == "" {
+			return Command{}, errors.New("pipeline cover output requires --prompt")
+		}
+	}
 	return Command{
-		Name:   name,
-		DryRun: *dryRun,
+		Name:              name,
+		DryRun:            *dryRun,
+		SubtitleStyleFile: *subtitleStyleFile,
 		Pipeline: pipeline.PipelineRequest{
+			Input:   input,
+			Workdir: *workdir,
+			TaskID:  *taskID,
+			Subtitle: pipeline.SubtitleRequest{
+				OriginLang:     *originLang,
+				TargetLang:     *targetLang,
+				UserLang:       *userLang,
+				CaptionSource:  pipeline.CaptionSource(*captionSource),
+				BilingualTop:   *bilingualTop,
+				MaxWordOneLine: *maxWordOneLine,
+				Resume:         *resume,
+				Vocabulary:     pipeline.ParseVocabulary(*vocabulary),
+				Prompt:         *transcribePrompt,
+				NoCache:        *noCache,
+				GlossaryFile:   *glossary,
+			},
+			TTS: pipeline.TTSRequest{
+				LineMode:         pipeline.LineMode(*lineMode),
+				Voice:            *voice,
+				VoiceCloneSource: *voiceCloneSource,
+			},
+			Render: pipeline.RenderRequest{
+				MajorTitle: *majorTitle,
+				MinorTitle: *minorTitle,
+			},
+			Cover: pipeline.CoverRequest{
+				Prompt: *prompt,
+				Size:   *size,
+			},
 			Outputs: *outputs,
 			Async:   *async,
 		},
@@ -597,7 +746,10 @@ func dryRun(cmd Command) pipeline.Response {
 			m.Outputs.FinalCoverPrompt = m.Outputs.FinalCoverPrompt
 		})
 	case "pipeline":
-		return pipeline.Response{OK: true, Stage: pipeline.StagePipeline}
+		if _, err := loadSubtitleStyleForCLI(cmd.SubtitleStyleFile); err != nil {
+			return styleLoadFailure(pipeline.StagePipeline, cmd.Pipeline.Workdir, cmd.Pipeline.TaskID, err)
+		}
+		return dryRunResponse(pipeline.StagePipeline, cmd.Pipeline.Workdir, cmd.Pipeline.TaskID)
 	case "update":
 		inputs := map[string]string{
 			"repo": cmd.Update.Repo,
@@ -615,6 +767,8 @@ func dryRun(cmd Command) pipeline.Response {
 		}
 	case "voices":
 		return executeVoices(cmd.Voices)
+	case "tm":
+		return executeTM(cmd.TM, true)
 	default:
 		return pipeline.Response{
 			OK: false,
@@ -7,6 +7,8 @@ import (
 	subtitlestyle "krillin-ai/internal/subtitle_style"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 )
@@ -54,6 +56,48 @@ func TestParseSubtitleCommandCanPutTargetLanguageOnBottom(t *testing.T) {
 	}
 }
 
+func TestParseSubtitleCommandAcceptsResume(t *testing.T) {
+	cmd, err := Parse([]string{
+		"subtitle",
+		"local:demo.mp4",
+		"--workdir", "tasks/demo",
+		"--resume",
+	})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if !cmd.Subtitle.Resume {
+		t.Fatalf("Resume = false, want true")
+	}
+}
+
+func TestParseSubtitleCommandAcceptsTranscriptionOptions(t *testing.T) {
+	cmd, err := Parse([]string{
+		"subtitle",
+		"local:demo.mp4",
+		"--workdir", "tasks/demo",
+		"--vocabulary", "KrillinAI, yt-dlp,,Whisper",
+		"--transcribe-prompt", "KrillinAI release notes",
+		"--no-cache",
+		"--glossary", "terms.csv",
+	})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if got := strings.Join(cmd.Subtitle.Vocabulary, "|"); got != "KrillinAI|yt-dlp|Whisper" {
+		t.Fatalf("Vocabulary = %q", got)
+	}
+	if cmd.Subtitle.Prompt != "KrillinAI release notes" {
+		t.Fatalf("Prompt = %q", cmd.Subtitle.Prompt)
+	}
+	if !cmd.Subtitle.NoCache {
+		t.Fatalf("NoCache = false, want true")
+	}
+	if cmd.Subtitle.GlossaryFile != "terms.csv" {
+		t.Fatalf("GlossaryFile = %q", cmd.Subtitle.GlossaryFile)
+	}
+}
+
 func TestParseSubtitleCommandAcceptsSubtitleStyleFile(t *testing.T) {
 	cmd, err := Parse([]string{
 		"subtitle",
@@ -464,3 +508,153 @@ func TestStyleLoadFailureClassifiesUserStyleErrorsAsUsage(t *testing.T) {
 		t.Fatalf("Code = %q, want subtitle_style_load_failed", resp.Error.Code)
 	}
 }
+
+func TestParsePipelineCommand(t *testing.T) {
+	cmd, err := Parse([]string{
+		"pipeline",
+		"local:demo.mp4",
+		"--outputs", "subtitle,tts,horizontal-dubbed",
+		"--workdir", "tasks/demo",
+		"--origin-lang", "en",
+		"--target-lang", "zh_cn",
+		"--voice", "alloy",
+	})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if cmd.Name != "pipeline" {
+		t.Fatalf("Name = %q, want pipeline", cmd.Name)
+	}
+	if cmd.Pipeline.Input != "local:demo.mp4" || cmd.Pipeline.Workdir != "tasks/demo" {
+		t.Fatalf("Pipeline = %#v", cmd.Pipeline)
+	}
+	if cmd.Pipeline.Subtitle.OriginLang != "en" || cmd.Pipeline.Subtitle.TargetLang != "zh_cn" {
+		t.Fatalf("Subtitle = %#v", cmd.Pipeline.Subtitle)
+	}
+	if cmd.Pipeline.TTS.Voice != "alloy" {
+		t.Fatalf("Voice = %q, want alloy", cmd.Pipeline.TTS.Voice)
+	}
+}
+
+func TestParsePipelineCoverRequiresPrompt(t *testing.T) {
+	_, err := Parse([]string{"pipeline", "--workdir", "tasks/demo", "--outputs", "cover"})
+	if err == nil {
+		t.Fatalf("Parse() error = nil, want error")
+	}
+}
+
+func TestExecuteAsyncPipelineStartsWorkerAndQueuesRun(t *testing.T) {
+	dir := t.TempDir()
+	var gotArgs []string
+	original := startPipelineWorker
+	// use a live PID so status does not report the queued run as stale
+	workerPID := os.Getpid()
+	startPipelineWorker = func(args []string, logFile string) (int, error) {
+		gotArgs = args
+		return workerPID, nil
+	}
+	t.Cleanup(func() {
+		startPipelineWorker = original
+	})
+
+	cmd, err := Parse([]string{
+		"pipeline", "local:demo.mp4", "--workdir", dir, "--outputs", "subtitle,tts", "--async",
+		"--resume",
+		"--vocabulary", "KrillinAI, yt-dlp",
+		"--transcribe-prompt", "KrillinAI release notes",
+		"--no-cache",
+		"--glossary", "terms.csv",
+	})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	resp := Execute(context.Background(), nil, cmd)
+	if !resp.OK {
+		t.Fatalf("OK = false, error = %#v", resp.Error)
+	}
+	if resp.Inputs["pid"] != strconv.Itoa(workerPID) || resp.TaskID == "" {
+		t.Fatalf("resp = %#v, want worker pid and task id", resp)
+	}
+	worker, err := Parse(gotArgs)
+	if err != nil {
+		t.Fatalf("Parse(worker args %v) error = %v", gotArgs, err)
+	}
+	if worker.Pipeline.Workdir != dir || worker.Pipeline.TaskID != resp.TaskID || worker.Pipeline.Input != "local:demo.mp4" {
+		t.Fatalf("worker pipeline = %#v", worker.Pipeline)
+	}
+	if got, want := worker.Pipeline.Subtitle, cmd.Pipeline.Subtitle; !reflect.DeepEqual(got, want) {
+		t.Fatalf("worker subtitle request = %#v, want %#v", got, want)
+	}
+
+	status, err := Parse([]string{"status", "--workdir", dir})
+	if err != nil {
+		t.Fatalf("Parse(status) error = %v", err)
+	}
+	statusResp := Execute(context.Background(), nil, status)
+	if !statusResp.OK || statusResp.Run == nil || statusResp.Run.State != pipeline.RunStateQueued || statusResp.Run.PID != workerPID {
+		t.Fatalf("status = %#v, want queued run with worker pid", statusResp)
+	}
+}
+
+func TestExecuteStatusReportsMissingManifest(t *testing.T) {
+	cmd, err := Parse([]string{"status", "--workdir", t.TempDir()})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	resp := Execute(context.Background(), nil, cmd)
+	if resp.OK || resp.Error == nil || resp.Error.Code != "manifest_not_found" {
+		t.Fatalf("resp = %#v, want manifest_not_found", resp)
+	}
+}
+
+func TestParseStatusRequiresWorkdir(t *testing.T) {
+	if _, err := Parse([]string{"status"}); err == nil {
+		t.Fatalf("Parse() error = nil, want error")
+	}
+}
+
+func TestExecuteTMImportExport(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "in.tmx")
+	if err := os.WriteFile(source, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<tmx version="1.4">
+  <header creationtool="Other" segtype="sentence" adminlang="en" srclang="en-US" datatype="plaintext" o-tmf="x"/>
+  <body>
+    <tu><tuv xml:lang="en-US"><seg>Thanks for watching</seg></tuv><tuv xml:lang="zh-CN"><seg>感谢观看</seg></tuv></tu>
+    <tu><tuv xml:lang="en-US"><seg>Hi</seg></tuv><tuv xml:lang="zh-CN"><seg>嗨</seg></tuv></tu>
+  </body>
+</tmx>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	memory := filepath.Join(dir, "tm.jsonl")
+
+	cmd, err := Parse([]string{"tm", "import", "--file", source, "--memory", memory})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	resp := Execute(context.Background(), nil, cmd)
+	if !resp.OK || resp.Stage != pipeline.StageTranslationMemory || resp.Inputs["entries"] != "2" || resp.Inputs["added"] != "1" {
+		t.Fatalf("import resp = %#v", resp)
+	}
+
+	exported := filepath.Join(dir, "out.tmx")
+	cmd, err = Parse([]string{"tm", "export", "--file", exported, "--memory", memory})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if resp = Execute(context.Background(), nil, cmd); !resp.OK || resp.Inputs["entries"] != "1" {
+		t.Fatalf("export resp = %#v", resp)
+	}
+	data, err := os.ReadFile(exported)
+	if err != nil || !strings.Contains(string(data), "<seg>感谢观看</seg>") {
+		t.Fatalf("exported tmx = %s, %v", data, err)
+	}
+}
+
+func TestParseTMRequiresActionAndFile(t *testing.T) {
+	for _, args := range [][]string{{"tm"}, {"tm", "sync", "--file", "a.tmx"}, {"tm", "export"}} {
+		if _, err := Parse(args); err == nil {
+			t.Fatalf("Parse(%v) error = nil, want error", args)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"errors"
+	"krillin-ai/internal/pipeline"
+	"os"
+	"time"
+)
+
+type StatusRequest struct {
+	Workdir string
+}
+
+func parseStatus(name string, args []string) (Command, error) {
+	if hasHelpArg(args) {
+		return Command{Name: name, Help: true}, nil
+	}
+	fs := newFlagSet(name)
+	workdir := fs.String("workdir", "", "workdir")
+	if err := fs.Parse(args); err != nil {
+		return Command{}, err
+	}
+	if *workdir == "" {
+		return Command{}, errors.New("status requires --workdir")
+	}
+	return Command{
+		Name:   name,
+		Status: StatusRequest{Workdir: *workdir},
+	}, nil
+}
+
+func executeStatus(req StatusRequest) pipeline.Response {
+	manifest, run, err := pipeline.ReadStatus(req.Workdir, time.Now())
+	if err != nil {
+		kind := pipeline.ErrorKindInternal
+		code := "load_manifest_failed"
+		if errors.Is(err, os.ErrNotExist) {
+			kind = pipeline.ErrorKindUsage
+			code = "manifest_not_found"
+		}
+		return pipeline.Response{
+			OK:      false,
+			Stage:   pipeline.StageStatusQuery,
+			Workdir: req.Workdir,
+			Error: &pipeline.Error{
+				Kind:    kind,
+				Code:    code,
+				Message: err.Error(),
+			},
+		}
+	}
+	return pipeline.Response{
+		OK:            true,
+		Stage:         pipeline.StageStatusQuery,
+		Workdir:       manifest.Workdir,
+		TaskID:        manifest.TaskID,
+		CaptionSource: pipeline.CaptionSource(manifest.CaptionSource),
+		Outputs:       manifest.Outputs,
+		Languages:     manifest.Languages,
+		Warnings:      manifest.Warnings,
+		FailedIndexes: manifest.FailedIndexes,
+		Run:           &run,
+	}
+}
@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"krillin-ai/config"
+	"krillin-ai/internal/pipeline"
+	"krillin-ai/internal/tm"
+	"os"
+	"strconv"
+)
+
+type TMRequest struct {
+	Action string // import 或 export
+	File   string
+	Memory string // 记忆库文件，为空时使用配置中的路径
+}
+
+func parseTM(name string, args []string) (Command, error) {
+	if hasHelpArg(args) {
+		return Command{Name: name, Help: true}, nil
+	}
+	if len(args) == 0 || (args[0] != "import" && args[0] != "export") {
+		return Command{}, errors.New("tm requires an action: import or export")
+	}
+	action := args[0]
+	fs := newFlagSet(name)
+	file := fs.String("file", "", "tmx file")
+	memory := fs.String("memory", "", "translation memory file")
+	dryRun := fs.Bool("dry-run", false, "validate without writing files")
+	if err := fs.Parse(args[1:]); err != nil {
+		return Command{}, err
+	}
+	if fs.NArg() != 0 {
+		return Command{}, errors.New("tm does not accept positional arguments")
+	}
+	if *file == "" {
+		return Command{}, fmt.Errorf("tm %s requires --file", action)
+	}
+	return Command{
+		Name:   name,
+		DryRun: *dryRun,
+		TM: TMRequest{
+			Action: action,
+			File:   *file,
+			Memory: *memory,
+		},
+	}, nil
+}
+
+// executeTM 在TMX文件和翻译记忆库之间导入导出，dryRun时只读取不写入
+func executeTM(req TMRequest, dryRun bool) pipeline.Response {
+	path := req.Memory
+	if path == "" {
+		path = config.Conf.App.TranslationMemory.Path
+	}
+	inputs := map[string]string{
+		"action": req.Action,
+		"file":   req.File,
+		"memory": path,
+	}
+	if path == "" {
+		return tmFailure(inputs, pipeline.ErrorKindUsage, "memory_not_configured", errors.New("translation memory path is empty, set app.translation_memory.path or --memory"))
+	}
+	memory, err := tm.Open(path)
+	if err != nil {
+		return tmFailure(inputs, pipeline.ErrorKindInternal, "open_memory_failed", err)
+	}
+
+	switch req.Action {
+	case "import":
+		file, err := os.Open(req.File)
+		if err != nil {
+			kind := pipeline.ErrorKindInternal
+			if errors.Is(err, os.ErrNotExist) {
+				kind = pipeline.ErrorKindUsage
+			}
+			return tmFailure(inputs, kind, "read_tmx_failed", err)
+		}
+		entries, err := tm.ReadTMX(file)
+		file.Close()
+		if err != nil {
+			return tmFailure(inputs, pipeline.ErrorKindUsage, "parse_tmx_failed", err)
+		}
+		inputs["entries"] = strconv.Itoa(len(entries))
+		if dryRun {
+			break
+		}
+		added, err := memory.Add(entries...)
+		if err != nil {
+			return tmFailure(inputs, pipeline.ErrorKindInternal, "write_memory_failed", err)
+		}
+		inputs["added"] = strconv.Itoa(added)
+	case "export":
+		entries := memory.Entries()
+		inputs["entries"] = strconv.Itoa(len(entries))
+		if dryRun {
+			break
+		}
+		file, err := os.Create(req.File)
+		if err != nil {
+			return tmFailure(inputs, pipeline.ErrorKindInternal, "write_tmx_failed", err)
+		}
+		err = tm.WriteTMX(file, entries)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return tmFailure(inputs, pipeline.ErrorKindInternal, "write_tmx_failed", err)
+		}
+	}
+	return pipeline.Response{
+		OK:     true,
+		Stage:  pipeline.StageTranslationMemory,
+		Inputs: inputs,
+	}
+}
+
+func tmFailure(inputs map[string]string, kind pipeline.ErrorKind, code string, err error) pipeline.Response {
+	return pipeline.Response{
+		OK:     false,
+		Stage:  pipeline.StageTranslationMemory,
+		Inputs: inputs,
+		Error: &pipeline.Error{
+			Kind:    kind,
+			Code:    code,
+			Message: err.Error(),
+		},
+	}
+}
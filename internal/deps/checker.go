@@ -32,7 +32,24 @@ func CheckDependency() error {
 		log.GetLogger().Error("yt-dlp环境准备失败", zap.Error(err))
 		return err
 	}
-	if config.Conf.Transcribe.Provider == "fasterwhisper" {
+	for _, provider := range config.Conf.Transcribe.Provider {
+		if err = checkTranscribeProvider(provider); err != nil {
+			return err
+		}
+	}
+	if config.Conf.Tts.Provider == "edge-tts" {
+		if err = checkEdgeTts(); err != nil {
+			log.GetLogger().Error("edge-tts环境准备失败", zap.Error(err))
+		}
+	}
+
+	return nil
+}
+
+// checkTranscribeProvider 准备单个转录服务的本地环境和模型，回退链中的每个服务都需要检查
+func checkTranscribeProvider(provider string) error {
+	var err error
+	if provider == "fasterwhisper" {
 		err = checkFasterWhisper()
 		if err != nil {
 			log.GetLogger().Error("fasterwhisper环境准备失败", zap.Error(err))
@@ -44,7 +61,7 @@ func CheckDependency() error {
 			return err
 		}
 	}
-	if config.Conf.Transcribe.Provider == "whisperkit" {
+	if provider == "whisperkit" {
 		if err = checkWhisperKit(); err != nil {
 			log.GetLogger().Error("whisperkit环境准备失败", zap.Error(err))
 			return err
@@ -55,7 +72,7 @@ func CheckDependency() error {
 			return err
 		}
 	}
-	if config.Conf.Transcribe.Provider == "whisperx" {
+	if provider == "whisperx" {
 		err = checkWhisperX()
 		if err != nil {
 			log.GetLogger().Error("whisperx环境准备失败", zap.Error(err))
@@ -67,7 +84,7 @@ func CheckDependency() error {
 			return err
 		}
 	}
-	if config.Conf.Transcribe.Provider == "whispercpp" {
+	if provider == "whispercpp" && config.Conf.Transcribe.Whispercpp.ServerUrl == "" { // 使用whisper-server时无需本地命令行和模型
 		if err = checkWhispercpp(); err != nil {
 			log.GetLogger().Error("whispercpp环境准备失败", zap.Error(err))
 			return err
@@ -78,12 +95,6 @@ func CheckDependency() error {
 			return err
 		}
 	}
-	if config.Conf.Tts.Provider == "edge-tts" {
-		if err = checkEdgeTts(); err != nil {
-			log.GetLogger().Error("edge-tts环境准备失败", zap.Error(err))
-		}
-	}
-
 	return nil
 }
 
@@ -490,6 +501,17 @@ func checkWhisperX() error {
 	} else if runtime.GOOS == "linux" {
 		filePath = "./bin/whisperx/.venv/bin/whisperx"
 		_filePath = "./bin/whisperx/.venv/bin/whisperx"
+		// 未放置安装包时使用自行通过pip安装的whisperx
+		if _, err = os.Stat(_filePath); os.IsNotExist(err) {
+			if _, statErr := os.Stat("./bin/WhisperX.zip"); os.IsNotExist(statErr) {
+				if path, lookErr := exec.LookPath("whisperx"); lookErr == nil {
+					storage.WhisperXPath = path
+					log.GetLogger().Info("已找到whisperx", zap.String("路径", path))
+					return nil
+				}
+				return fmt.Errorf("没有找到whisperx，请通过 pip install whisperx 安装，或将WhisperX.zip放到./bin目录下")
+			}
+		}
 	} else {
 		return fmt.Errorf("WhisperX不支持你当前的操作系统: %s，请选择WhisperKit", runtime.GOOS)
 	}
@@ -556,10 +578,23 @@ func checkWhispercpp() error {
 		filePath string
 		err      error
 	)
-	if runtime.GOOS == "windows" {
+	switch runtime.GOOS {
+	case "windows":
 		filePath = filepath.Join("bin", "whispercpp", "whisper-cli.exe")
-	} else {
-		return fmt.Errorf("whisper.cpp不支持你当前的操作系统: %s，请选择其它transcription provider", runtime.GOOS)
+	case "linux":
+		// linux没有预编译包，优先使用./bin/whispercpp下的，其次是PATH中的whisper-cli
+		filePath = filepath.Join("bin", "whispercpp", "whisper-cli")
+		if _, err = os.Stat(filePath); err == nil {
+			break
+		}
+		if filePath, err = exec.LookPath("whisper-cli"); err != nil {
+			return fmt.Errorf("没有找到whisper-cli，请编译whisper.cpp后将whisper-cli放到./bin/whispercpp目录或PATH中，或配置server_url使用whisper-server")
+		}
+		storage.WhispercppPath = filePath
+		log.GetLogger().Info("whispercpp检查完成", zap.String("路径", filePath))
+		return nil
+	default:
+		return fmt.Errorf("whisper.cpp不支持你当前的操作系统: %s，请配置server_url使用whisper-server或选择其它transcription provider", runtime.GOOS)
 	}
 	if _, err = os.Stat(filePath); os.IsNotExist(err) {
 		log.GetLogger().Info("没有找到whispercpp，即将开始自动下载，文件较大请耐心等待")
@@ -634,11 +634,11 @@ func createLlmConfigGroup() *fyne.Container {
 
 // 创建语音识别配置组
 func createTranscribeConfigGroup() *fyne.Container {
-	providerOptions := []string{"openai", "fasterwhisper", "whisperkit", "whispercpp", "aliyun"}
+	providerOptions := []string{"openai", "fasterwhisper", "whisperkit", "whispercpp", "whisperx", "aliyun"}
 	providerSelect := widget.NewSelect(providerOptions, func(value string) {
-		config.Conf.Transcribe.Provider = value
+		config.Conf.Transcribe.Provider = config.Conf.Transcribe.Provider.WithPrimary(value) // 保留配置文件中的回退服务
 	})
-	providerSelect.SetSelected(config.Conf.Transcribe.Provider)
+	providerSelect.SetSelected(config.Conf.Transcribe.Provider.Primary())
 
 	openaiBaseUrlEntry := StyledEntry("API Base URL")
 	openaiBaseUrlEntry.Bind(binding.BindString(&config.Conf.Transcribe.Openai.BaseUrl))
@@ -655,6 +655,8 @@ func createTranscribeConfigGroup() *fyne.Container {
 
 	whisperCppModelEntry := StyledEntry("模型名称 Model name")
 	whisperCppModelEntry.Bind(binding.BindString(&config.Conf.Transcribe.Whispercpp.Model))
+	whisperCppServerUrlEntry := StyledEntry("whisper-server地址，留空使用本地命令行 Server URL")
+	whisperCppServerUrlEntry.Bind(binding.BindString(&config.Conf.Transcribe.Whispercpp.ServerUrl))
 
 	aliyunOssKeyIdEntry := StyledEntry("阿里云 Aliyun Access Key ID")
 	aliyunOssKeyIdEntry.Bind(binding.BindString(&config.Conf.Transcribe.Aliyun.Oss.AccessKeyId))
@@ -683,6 +685,7 @@ func createTranscribeConfigGroup() *fyne.Container {
 		widget.NewFormItem("WhisperKit 模型 Model", whisperKitModelEntry),
 
 		widget.NewFormItem("WhisperCpp 模型 Model", whisperCppModelEntry),
+		widget.NewFormItem("WhisperCpp Server URL", whisperCppServerUrlEntry),
 
 		widget.NewFormItem("阿里云 Aliyun OSS Access Key ID", aliyunOssKeyIdEntry),
 		widget.NewFormItem("阿里云 Aliyun OSS Access Key Secret", aliyunOssKeySecretEntry),
@@ -871,10 +874,10 @@ func createSubtitleSettingsCard(sm *SubtitleManager) *fyne.Container {
 		container.NewHBox(
 			widget.NewLabel("源语言 Original Language:"),
 			StyledSelect([]string{
-				"简体中文", "English", "日本語", "Türkçe", "Deutsch", "한국어", "Русский язык", "Bahasa Melayu",
+				"自动识别 Auto", "简体中文", "English", "日本語", "Türkçe", "Deutsch", "한국어", "Русский язык", "Bahasa Melayu",
 			}, func(value string) {
 				sourceLangMap := map[string]string{
-					"简体中文": "zh_cn", "English": "en", "日本語": "ja",
+					"自动识别 Auto": "auto", "简体中文": "zh_cn", "English": "en", "日本語": "ja",
 					"Türkçe": "tr", "Deutsch": "de", "한국어": "ko", "Русский язык": "ru",
 					"Bahasa Melayu": "ms",
 				}
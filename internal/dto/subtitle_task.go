@@ -1,23 +1,60 @@
 package dto
 
+import (
+	"encoding/json"
+	"krillin-ai/internal/types"
+	"slices"
+)
+
 type StartVideoSubtitleTaskReq struct {
-	AppId                     uint32   `json:"app_id"`
-	Url                       string   `json:"url"`
-	OriginLanguage            string   `json:"origin_lang"`
-	TargetLang                string   `json:"target_lang"`
-	Bilingual                 uint8    `json:"bilingual"`
-	TranslationSubtitlePos    uint8    `json:"translation_subtitle_pos"`
-	ModalFilter               uint8    `json:"modal_filter"`
-	Tts                       uint8    `json:"tts"`
-	TtsVoiceCode              string   `json:"tts_voice_code"`
-	TtsVoiceCloneSrcFileUrl   string   `json:"tts_voice_clone_src_file_url"`
-	Replace                   []string `json:"replace"`
-	Language                  string   `json:"language"`
-	EmbedSubtitleVideoType    string   `json:"embed_subtitle_video_type"`
-	VerticalMajorTitle        string   `json:"vertical_major_title"`
-	VerticalMinorTitle        string   `json:"vertical_minor_title"`
-	OriginLanguageWordOneLine int      `json:"origin_language_word_one_line"`
-	VttSwitch                 bool     `json:"vtt_switch"` // 是否使用VTT格式字幕文件
+	AppId                     uint32         `json:"app_id"`
+	Url                       string         `json:"url"`
+	OriginLanguage            string         `json:"origin_lang"`
+	TargetLang                LanguageList   `json:"target_lang"` // 目标语言，多个时转录只做一次，翻译、配音和视频合成按语言分别进行
+	Bilingual                 uint8          `json:"bilingual"`
+	TranslationSubtitlePos    uint8          `json:"translation_subtitle_pos"`
+	ModalFilter               uint8          `json:"modal_filter"`
+	Tts                       uint8          `json:"tts"`
+	TtsVoiceCode              string         `json:"tts_voice_code"`
+	TtsVoiceCloneSrcFileUrl   string         `json:"tts_voice_clone_src_file_url"`
+	Replace                   []string       `json:"replace"`
+	Language                  string         `json:"language"`
+	EmbedSubtitleVideoType    string         `json:"embed_subtitle_video_type"`
+	VerticalMajorTitle        string         `json:"vertical_major_title"`
+	VerticalMinorTitle        string         `json:"vertical_minor_title"`
+	OriginLanguageWordOneLine int            `json:"origin_language_word_one_line"`
+	VttSwitch                 bool           `json:"vtt_switch"`        // 是否使用VTT格式字幕文件
+	Vocabulary                []string       `json:"vocabulary"`        // 热词，如产品名、人名、专业术语，帮助转录模型正确识别
+	TranscribePrompt          string         `json:"transcribe_prompt"` // 转录背景说明，如视频标题或简介
+	NoCache                   bool           `json:"no_cache"`          // 不使用转录缓存，重新转录
+	Glossary                  types.Glossary `json:"glossary"`          // 翻译术语表，与配置中的项目术语表合并，同一术语以此为准
+	ResumeTaskId              string         `json:"resume_task_id"`    // 断点续跑的原任务id，复用其任务目录中已完成的分段结果
+	CallbackUrl               string         `json:"callback_url"`      // 任务成功、失败或取消后POST通知的地址
+	CallbackSecret            string         `json:"callback_secret"`   // 可选，用于对回调内容做HMAC-SHA256签名
+}
+
+// LanguageList 语言代码列表，JSON中可以是字符串数组，也可以是逗号分隔的字符串
+type LanguageList []string
+
+func (l *LanguageList) UnmarshalJSON(data []byte) error {
+	var items []string
+	if err := json.Unmarshal(data, &items); err != nil {
+		var s string
+		if err = json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		items = []string{s}
+	}
+	list := LanguageList{}
+	for _, item := range items {
+		for _, code := range types.ParseLanguageList(item) {
+			if !slices.Contains(list, string(code)) {
+				list = append(list, string(code))
+			}
+		}
+	}
+	*l = list
+	return nil
 }
 
 type StartVideoSubtitleTaskResData struct {
@@ -34,6 +71,10 @@ type GetVideoSubtitleTaskReq struct {
 	TaskId string `form:"taskId"`
 }
 
+type CancelVideoSubtitleTaskReq struct {
+	TaskId string `form:"taskId"`
+}
+
 type VideoInfo struct {
 	Title                 string `json:"title"`
 	Description           string `json:"description"`
@@ -47,13 +88,22 @@ type SubtitleInfo struct {
 	DownloadUrl string `json:"download_url"`
 }
 
-type GetVideoSubtitleTaskResData struct {
-	TaskId            string          `json:"task_id"`
-	ProcessPercent    uint8           `json:"process_percent"`
-	VideoInfo         *VideoInfo      `json:"video_info"`
+// LanguageResult 一种目标语言的字幕和配音下载地址
+type LanguageResult struct {
+	Language          string          `json:"language"`
 	SubtitleInfo      []*SubtitleInfo `json:"subtitle_info"`
-	TargetLanguage    string          `json:"target_language"`
-	SpeechDownloadUrl string          `json:"speech_download_url"`
+	SpeechDownloadUrl string          `json:"speech_download_url,omitempty"`
+}
+
+type GetVideoSubtitleTaskResData struct {
+	TaskId            string            `json:"task_id"`
+	ProcessPercent    uint8             `json:"process_percent"`
+	QueuePosition     int               `json:"queue_position"` // 排队中的位置，从1开始，0表示已开始处理
+	VideoInfo         *VideoInfo        `json:"video_info"`
+	SubtitleInfo      []*SubtitleInfo   `json:"subtitle_info"` // 全部目标语言的字幕
+	TargetLanguage    string            `json:"target_language"`
+	SpeechDownloadUrl string            `json:"speech_download_url"` // 第一个目标语言的配音
+	Languages         []*LanguageResult `json:"languages,omitempty"` // 按目标语言分组的下载地址，顺序与请求中的target_lang一致
 }
 
 type GetVideoSubtitleTaskRes struct {
@@ -61,3 +111,68 @@ type GetVideoSubtitleTaskRes struct {
 	Msg   string                       `json:"msg"`
 	Data  *GetVideoSubtitleTaskResData `json:"data"`
 }
+
+// SubtitleTaskEvent 任务进度流中的一条事件
+type SubtitleTaskEvent struct {
+	Type           string                       `json:"type"` // snapshot stage progress warning succeeded failed cancelled
+	TaskId         string                       `json:"task_id"`
+	Stage          string                       `json:"stage,omitempty"` // queued download split transcribe translate tts render upload
+	Current        int                          `json:"current,omitempty"`
+	Total          int                          `json:"total,omitempty"`
+	ProcessPercent uint8                        `json:"process_percent"`
+	QueuePosition  int                          `json:"queue_position,omitempty"`
+	Message        string                       `json:"message,omitempty"`
+	Result         *GetVideoSubtitleTaskResData `json:"result,omitempty"`
+	Time           int64                        `json:"time"`
+}
+
+// SubtitleTaskCallbackPayload 任务结束时POST到callback_url的内容
+type SubtitleTaskCallbackPayload struct {
+	Event             string            `json:"event"` // task.succeeded task.failed task.cancelled
+	TaskId            string            `json:"task_id"`
+	Status            string            `json:"status"` // success failed cancelled
+	FailReason        string            `json:"fail_reason,omitempty"`
+	SubtitleInfo      []*SubtitleInfo   `json:"subtitle_info"`
+	SpeechDownloadUrl string            `json:"speech_download_url"`
+	Languages         []*LanguageResult `json:"languages,omitempty"` // 多目标语言任务按语言分组的下载地址
+	Timestamp         int64             `json:"timestamp"`
+}
+
+type CallbackAttempt struct {
+	Attempt    int    `json:"attempt"`
+	Time       int64  `json:"time"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Success    bool   `json:"success"`
+}
+
+type SubtitleTaskItem struct {
+	TaskId            string             `json:"task_id"`
+	VideoSrc          string             `json:"video_src"`
+	Status            uint8              `json:"status"` // 1-处理中 2-成功 3-失败 4-已取消
+	FailReason        string             `json:"fail_reason"`
+	ProcessPercent    uint8              `json:"process_percent"`
+	QueuePosition     int                `json:"queue_position"` // 排队中的位置，从1开始，0表示已开始处理
+	SubtitleInfo      []*SubtitleInfo    `json:"subtitle_info"`
+	SpeechDownloadUrl string             `json:"speech_download_url"`
+	CallbackAttempts  []*CallbackAttempt `json:"callback_attempts,omitempty"`
+	CreateTime        int64              `json:"create_time"`
+	UpdateTime        int64              `json:"update_time"`
+}
+
+type ListVideoSubtitleTasksReq struct {
+	Page     int     `form:"page"`      // 从1开始，默认1
+	PageSize int     `form:"page_size"` // 默认20，最大100
+	Status   []uint8 `form:"status"`    // 按状态过滤，可传多个，如 status=1&status=3
+}
+
+type ListVideoSubtitleTasksResData struct {
+	Total    int                 `json:"total"` // 过滤后的任务总数
+	Page     int                 `json:"page"`
+	PageSize int                 `json:"page_size"`
+	Tasks    []*SubtitleTaskItem `json:"tasks"`
+}
+
+type DeleteVideoSubtitleTaskReq struct {
+	TaskId string `form:"taskId"`
+}
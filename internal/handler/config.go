@@ -28,12 +28,13 @@ type ConfigRequest struct {
 		Port int    `json:"port"`
 	} `json:"server"`
 	Llm struct {
-		BaseUrl string `json:"baseUrl"`
-		ApiKey  string `json:"apiKey"`
-		Model   string `json:"model"`
+		Provider string `json:"provider"`
+		BaseUrl  string `json:"baseUrl"`
+		ApiKey   string `json:"apiKey"`
+		Model    string `json:"model"`
 	} `json:"llm"`
 	Transcribe struct {
-		Provider              string `json:"provider"`
+		Provider              string `json:"provider"` // 多个转录服务用逗号分隔，按顺序回退
 		EnableGpuAcceleration bool   `json:"enableGpuAcceleration"`
 		Openai                struct {
 			BaseUrl string `json:"baseUrl"`
@@ -47,7 +48,8 @@ type ConfigRequest struct {
 			Model string `json:"model"`
 		} `json:"whisperkit"`
 		Whispercpp struct {
-			Model string `json:"model"`
+			Model     string `json:"model"`
+			ServerUrl string `json:"serverUrl"`
 		} `json:"whispercpp"`
 		Aliyun struct {
 			Oss struct {
@@ -115,18 +117,20 @@ func (h Handler) GetConfig(c *gin.Context) {
 			Port: config.Conf.Server.Port,
 		},
 		Llm: struct {
-			BaseUrl string `json:"baseUrl"`
-			ApiKey  string `json:"apiKey"`
-			Model   string `json:"model"`
+			Provider string `json:"provider"`
+			BaseUrl  string `json:"baseUrl"`
+			ApiKey   string `json:"apiKey"`
+			Model    string `json:"model"`
 		}{
-			BaseUrl: config.Conf.Llm.BaseUrl,
-			ApiKey:  config.Conf.Llm.ApiKey,
-			Model:   config.Conf.Llm.Model,
+			Provider: config.Conf.Llm.Provider,
+			BaseUrl:  config.Conf.Llm.BaseUrl,
+			ApiKey:   config.Conf.Llm.ApiKey,
+			Model:    config.Conf.Llm.Model,
 		},
 	}
 
 	// 转录配置
-	configResponse.Transcribe.Provider = config.Conf.Transcribe.Provider
+	configResponse.Transcribe.Provider = config.Conf.Transcribe.Provider.String()
 	configResponse.Transcribe.EnableGpuAcceleration = config.Conf.Transcribe.EnableGpuAcceleration
 	configResponse.Transcribe.Openai.BaseUrl = config.Conf.Transcribe.Openai.BaseUrl
 	configResponse.Transcribe.Openai.ApiKey = config.Conf.Transcribe.Openai.ApiKey
@@ -134,6 +138,7 @@ func (h Handler) GetConfig(c *gin.Context) {
 	configResponse.Transcribe.Fasterwhisper.Model = config.Conf.Transcribe.Fasterwhisper.Model
 	configResponse.Transcribe.Whisperkit.Model = config.Conf.Transcribe.Whisperkit.Model
 	configResponse.Transcribe.Whispercpp.Model = config.Conf.Transcribe.Whispercpp.Model
+	configResponse.Transcribe.Whispercpp.ServerUrl = config.Conf.Transcribe.Whispercpp.ServerUrl
 	configResponse.Transcribe.Aliyun.Oss.AccessKeyId = config.Conf.Transcribe.Aliyun.Oss.AccessKeyId
 	configResponse.Transcribe.Aliyun.Oss.AccessKeySecret = config.Conf.Transcribe.Aliyun.Oss.AccessKeySecret
 	configResponse.Transcribe.Aliyun.Oss.Bucket = config.Conf.Transcribe.Aliyun.Oss.Bucket
@@ -194,13 +199,16 @@ func (h Handler) UpdateConfig(c *gin.Context) {
 	config.Conf.Server.Host = req.Server.Host
 	config.Conf.Server.Port = req.Server.Port
 
-	// 更新LLM配置
+	// 更新LLM配置，旧版页面不提交provider时保持原值
+	if req.Llm.Provider != "" {
+		config.Conf.Llm.Provider = req.Llm.Provider
+	}
 	config.Conf.Llm.BaseUrl = req.Llm.BaseUrl
 	config.Conf.Llm.ApiKey = req.Llm.ApiKey
 	config.Conf.Llm.Model = req.Llm.Model
 
 	// 更新转录配置
-	config.Conf.Transcribe.Provider = req.Transcribe.Provider
+	config.Conf.Transcribe.Provider = config.ParseProviderChain(req.Transcribe.Provider)
 	config.Conf.Transcribe.EnableGpuAcceleration = req.Transcribe.EnableGpuAcceleration
 	config.Conf.Transcribe.Openai.BaseUrl = req.Transcribe.Openai.BaseUrl
 	config.Conf.Transcribe.Openai.ApiKey = req.Transcribe.Openai.ApiKey
@@ -208,6 +216,7 @@ func (h Handler) UpdateConfig(c *gin.Context) {
 	config.Conf.Transcribe.Fasterwhisper.Model = req.Transcribe.Fasterwhisper.Model
 	config.Conf.Transcribe.Whisperkit.Model = req.Transcribe.Whisperkit.Model
 	config.Conf.Transcribe.Whispercpp.Model = req.Transcribe.Whispercpp.Model
+	config.Conf.Transcribe.Whispercpp.ServerUrl = req.Transcribe.Whispercpp.ServerUrl
 	config.Conf.Transcribe.Aliyun.Oss.AccessKeyId = req.Transcribe.Aliyun.Oss.AccessKeyId
 	config.Conf.Transcribe.Aliyun.Oss.AccessKeySecret = req.Transcribe.Aliyun.Oss.AccessKeySecret
 	config.Conf.Transcribe.Aliyun.Oss.Bucket = req.Transcribe.Aliyun.Oss.Bucket
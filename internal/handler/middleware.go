@@ -1 +1,119 @@
 package handler
+
+import (
+	"crypto/subtle"
+	"krillin-ai/config"
+	"krillin-ai/internal/response"
+	"krillin-ai/log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+type apiClient struct {
+	name    string
+	key     []byte
+	scopes  map[string]bool
+	limiter *rate.Limiter // 为nil表示不限流
+}
+
+// Auth 基于 config.Server.ApiKeys 的接口鉴权，未配置任何key时不做校验
+type Auth struct {
+	clients       []*apiClient
+	allowLoopback bool
+}
+
+func NewAuth(server config.Server) *Auth {
+	auth := &Auth{allowLoopback: server.AllowLoopback}
+	for _, key := range server.ApiKeys {
+		client := &apiClient{name: key.Name, key: []byte(key.Key), scopes: make(map[string]bool)}
+		for _, scope := range key.Scopes {
+			client.scopes[scope] = true
+		}
+		if key.RateLimit > 0 {
+			client.limiter = rate.NewLimiter(rate.Every(time.Minute/time.Duration(key.RateLimit)), key.RateLimit)
+		}
+		auth.clients = append(auth.clients, client)
+	}
+	if len(auth.clients) > 0 && !auth.allowLoopback {
+		log.GetLogger().Warn("已配置api_keys且未开启allow_loopback，内置网页不会携带API Key，其请求都将被拒绝")
+	}
+	return auth
+}
+
+// Require 校验请求携带的key拥有指定scope，并按key限流
+func (a *Auth) Require(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(a.clients) == 0 || (a.allowLoopback && isLoopback(c.Request.RemoteAddr)) {
+			c.Next()
+			return
+		}
+		client := a.lookup(requestApiKey(c))
+		if client == nil {
+			abort(c, http.StatusUnauthorized, "未授权，请提供有效的API Key")
+			return
+		}
+		if !client.scopes[scope] {
+			log.GetLogger().Warn("api key scope denied", zap.String("client", client.name), zap.String("scope", scope), zap.String("path", c.FullPath()))
+			abort(c, http.StatusForbidden, "当前API Key无权访问该接口")
+			return
+		}
+		if client.limiter != nil && !client.limiter.Allow() {
+			c.Header("Retry-After", "60")
+			abort(c, http.StatusTooManyRequests, "请求过于频繁，请稍后再试")
+			return
+		}
+		c.Set("api_client", client.name)
+		c.Next()
+	}
+}
+
+func (a *Auth) lookup(key string) *apiClient {
+	if key == "" {
+		return nil
+	}
+	var matched *apiClient
+	for _, client := range a.clients {
+		// 逐个做常量时间比较，避免通过响应耗时猜测key
+		if subtle.ConstantTimeCompare(client.key, []byte(key)) == 1 {
+			matched = client
+		}
+	}
+	return matched
+}
+
+// requestApiKey 依次读取 Authorization: Bearer、X-API-Key 头和 api_key 查询参数（EventSource和下载链接无法设置请求头）
+func requestApiKey(c *gin.Context) string {
+	if authorization := c.GetHeader("Authorization"); authorization != "" {
+		if token, ok := strings.CutPrefix(authorization, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+	}
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key
+	}
+	return c.Query("api_key")
+}
+
+// isLoopback 只看TCP对端地址，不信任X-Forwarded-For
+func isLoopback(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func abort(c *gin.Context, status int, msg string) {
+	c.AbortWithStatusJSON(status, response.Response{
+		Error: -1,
+		Msg:   msg,
+		Data:  nil,
+	})
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"krillin-ai/config"
+	"krillin-ai/log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newAuthTestEngine(server config.Server) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	auth := NewAuth(server)
+	engine.GET("/task", auth.Require(config.ScopeTask), func(c *gin.Context) { c.Status(http.StatusOK) })
+	engine.GET("/config", auth.Require(config.ScopeConfig), func(c *gin.Context) { c.Status(http.StatusOK) })
+	return engine
+}
+
+func authTestRequest(engine *gin.Engine, path, remoteAddr string, header map[string]string) int {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = remoteAddr
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestAuthRequireChecksKeyScopeAndRateLimit(t *testing.T) {
+	log.InitLogger()
+	engine := newAuthTestEngine(config.Server{
+		AllowLoopback: true,
+		ApiKeys: []config.ApiKey{
+			{Name: "cms", Key: "task-key", Scopes: []string{config.ScopeTask}, RateLimit: 2},
+			{Name: "admin", Key: "admin-key", Scopes: []string{config.ScopeTask, config.ScopeConfig}},
+		},
+	})
+	remote := "203.0.113.7:4321"
+	cases := []struct {
+		name   string
+		path   string
+		remote string
+		header map[string]string
+		want   int
+	}{
+		{"missing key", "/task", remote, nil, http.StatusUnauthorized},
+		{"wrong key", "/task", remote, map[string]string{"Authorization": "Bearer nope"}, http.StatusUnauthorized},
+		{"bearer", "/task", remote, map[string]string{"Authorization": "Bearer task-key"}, http.StatusOK},
+		{"header key", "/task?api_key=ignored", remote, map[string]string{"X-API-Key": "task-key"}, http.StatusOK},
+		{"rate limited", "/task", remote, map[string]string{"X-API-Key": "task-key"}, http.StatusTooManyRequests},
+		{"config scope", "/config", remote, map[string]string{"X-API-Key": "admin-key"}, http.StatusOK},
+		{"query key", "/config?api_key=admin-key", remote, nil, http.StatusOK},
+		{"loopback", "/config", "127.0.0.1:5555", map[string]string{"X-Forwarded-For": "203.0.113.7"}, http.StatusOK},
+	}
+	for _, tc := range cases {
+		if got := authTestRequest(engine, tc.path, tc.remote, tc.header); got != tc.want {
+			t.Fatalf("%s: status = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+	if got := authTestRequest(engine, "/config", remote, map[string]string{"Authorization": "Bearer task-key"}); got != http.StatusForbidden {
+		t.Fatalf("task key on config: status = %d, want 403", got)
+	}
+}
+
+func TestAuthRequireChecksLoopbackByDefault(t *testing.T) {
+	log.InitLogger()
+	engine := newAuthTestEngine(config.Server{
+		ApiKeys: []config.ApiKey{{Name: "cms", Key: "task-key", Scopes: []string{config.ScopeTask}}},
+	})
+	// 本机反向代理转发的请求对端地址也是127.0.0.1，默认同样需要key
+	if got := authTestRequest(engine, "/task", "127.0.0.1:5555", nil); got != http.StatusUnauthorized {
+		t.Fatalf("loopback without key: status = %d, want 401", got)
+	}
+	if got := authTestRequest(engine, "/task", "127.0.0.1:5555", map[string]string{"X-API-Key": "task-key"}); got != http.StatusOK {
+		t.Fatalf("loopback with key: status = %d, want 200", got)
+	}
+}
+
+func TestAuthRequireDisabledWithoutKeys(t *testing.T) {
+	engine := newAuthTestEngine(config.Server{})
+	if got := authTestRequest(engine, "/config", "203.0.113.7:4321", nil); got != http.StatusOK {
+		t.Fatalf("status = %d, want 200 when no keys configured", got)
+	}
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"io"
 	"krillin-ai/internal/deps"
 	"krillin-ai/internal/dto"
 	"krillin-ai/internal/response"
@@ -10,6 +11,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -88,6 +90,141 @@ func (h Handler) GetSubtitleTask(c *gin.Context) {
 	})
 }
 
+// taskEventsKeepAlive SSE连接空闲时发送心跳的间隔，防止被代理断开
+const taskEventsKeepAlive = 15 * time.Second
+
+// StreamSubtitleTaskEvents 以SSE推送任务的阶段切换、进度、警告和最终结果
+func (h Handler) StreamSubtitleTaskEvents(c *gin.Context) {
+	var req dto.GetVideoSubtitleTaskReq
+	if err := c.ShouldBindQuery(&req); err != nil || req.TaskId == "" {
+		response.R(c, response.Response{
+			Error: -1,
+			Msg:   "参数错误",
+			Data:  nil,
+		})
+		return
+	}
+	snapshot, events, unsubscribe, err := h.Service.SubscribeTaskEvents(req.TaskId)
+	if err != nil {
+		response.R(c, response.Response{
+			Error: -1,
+			Msg:   err.Error(),
+			Data:  nil,
+		})
+		return
+	}
+	defer unsubscribe()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.SSEvent(snapshot.Type, snapshot)
+	c.Writer.Flush()
+	if events == nil {
+		return
+	}
+	keepAlive := time.NewTicker(taskEventsKeepAlive)
+	defer keepAlive.Stop()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.SSEvent(event.Type, event)
+			return !service.IsTerminalTaskEvent(event.Type)
+		case <-keepAlive.C:
+			// 任务已结束或记录已被删除时发送最终状态并结束推送，不依赖结束事件送达
+			if event, finished := h.Service.FinishedTaskEvent(req.TaskId); finished {
+				if event != nil {
+					c.SSEvent(event.Type, event)
+				}
+				return false
+			}
+			c.SSEvent("ping", time.Now().Unix())
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+func (h Handler) CancelSubtitleTask(c *gin.Context) {
+	var req dto.CancelVideoSubtitleTaskReq
+	if err := c.ShouldBindQuery(&req); err != nil || req.TaskId == "" {
+		response.R(c, response.Response{
+			Error: -1,
+			Msg:   "参数错误",
+			Data:  nil,
+		})
+		return
+	}
+	if err := h.Service.CancelTask(req.TaskId); err != nil {
+		response.R(c, response.Response{
+			Error: -1,
+			Msg:   err.Error(),
+			Data:  nil,
+		})
+		return
+	}
+	response.R(c, response.Response{
+		Error: 0,
+		Msg:   "成功",
+		Data:  nil,
+	})
+}
+
+func (h Handler) ListSubtitleTasks(c *gin.Context) {
+	var req dto.ListVideoSubtitleTasksReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.R(c, response.Response{
+			Error: -1,
+			Msg:   "参数错误",
+			Data:  nil,
+		})
+		return
+	}
+	data, err := h.Service.ListTasks(req)
+	if err != nil {
+		response.R(c, response.Response{
+			Error: -1,
+			Msg:   err.Error(),
+			Data:  nil,
+		})
+		return
+	}
+	response.R(c, response.Response{
+		Error: 0,
+		Msg:   "成功",
+		Data:  data,
+	})
+}
+
+func (h Handler) DeleteSubtitleTask(c *gin.Context) {
+	var req dto.DeleteVideoSubtitleTaskReq
+	if err := c.ShouldBindQuery(&req); err != nil || req.TaskId == "" {
+		response.R(c, response.Response{
+			Error: -1,
+			Msg:   "参数错误",
+			Data:  nil,
+		})
+		return
+	}
+	if err := h.Service.DeleteTask(req.TaskId); err != nil {
+		response.R(c, response.Response{
+			Error: -1,
+			Msg:   err.Error(),
+			Data:  nil,
+		})
+		return
+	}
+	response.R(c, response.Response{
+		Error: 0,
+		Msg:   "成功",
+		Data:  nil,
+	})
+}
+
 func (h Handler) UploadFile(c *gin.Context) {
 	form, err := c.MultipartForm()
 	if err != nil {
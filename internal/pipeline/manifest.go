@@ -4,10 +4,15 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 )
 
 const ManifestFileName = "krillinai_manifest.json"
 
+// manifestMu serializes manifest writes so the async run heartbeat and stage saves do not clobber each other.
+var manifestMu sync.Mutex
+
 type StageStatus struct {
 	OK      bool   `json:"ok"`
 	Error   string `json:"error,omitempty"`
@@ -15,17 +20,23 @@ type StageStatus struct {
 }
 
 type Manifest struct {
-	TaskID         string                 `json:"task_id"`
-	Workdir        string                 `json:"workdir"`
-	InputURL       string                 `json:"input_url,omitempty"`
-	OriginLanguage string                 `json:"origin_language,omitempty"`
-	TargetLanguage string                 `json:"target_language,omitempty"`
-	CaptionSource  string                 `json:"caption_source,omitempty"`
-	Provider       map[string]string      `json:"provider,omitempty"`
-	Outputs        Outputs                `json:"outputs"`
-	Warnings       []string               `json:"warnings,omitempty"`
-	FailedIndexes  []int                  `json:"failed_indexes,omitempty"`
-	Stages         map[string]StageStatus `json:"stages"`
+	TaskID         string `json:"task_id"`
+	Workdir        string `json:"workdir"`
+	InputURL       string `json:"input_url,omitempty"`
+	OriginLanguage string `json:"origin_language,omitempty"`
+	// 源语言由auto自动识别得到时为true，此时origin_language为识别结果
+	OriginLanguageDetected bool                   `json:"origin_language_detected,omitempty"`
+	TargetLanguage         string                 `json:"target_language,omitempty"`
+	TargetLanguages        []string               `json:"target_languages,omitempty"` // 多个目标语言时的全部目标语言，target_language为其中第一个
+	CaptionSource          string                 `json:"caption_source,omitempty"`
+	Provider               map[string]string      `json:"provider,omitempty"`
+	Transcriptions         map[string]string      `json:"transcriptions,omitempty"` // 分段转录文件 -> 产生它的转录服务
+	Outputs                Outputs                `json:"outputs"`
+	Languages              LanguageOutputs        `json:"languages,omitempty"` // 多个目标语言时各语言的产物，附加语言的产物在 languages/<语言代码> 目录下
+	Warnings               []string               `json:"warnings,omitempty"`
+	FailedIndexes          []int                  `json:"failed_indexes,omitempty"`
+	Stages                 map[string]StageStatus `json:"stages"`
+	Run                    *RunStatus             `json:"run,omitempty"`
 }
 
 func NewManifest(taskID, workdir string) *Manifest {
@@ -59,7 +70,21 @@ func LoadManifest(workdir string) (*Manifest, error) {
 	return &m, nil
 }
 
+// Save writes the manifest. The run section is owned by SaveRunStatus, so the copy on disk is kept.
+// For multi-language tasks the first target language entry in Languages mirrors Outputs.
 func (m *Manifest) Save() error {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	if existing, err := LoadManifest(m.Workdir); err == nil {
+		m.Run = existing.Run
+	}
+	if len(m.Languages) > 0 && m.TargetLanguage != "" {
+		m.Languages[m.TargetLanguage] = m.Outputs
+	}
+	return m.write()
+}
+
+func (m *Manifest) write() error {
 	if err := os.MkdirAll(m.Workdir, 0755); err != nil {
 		return err
 	}
@@ -67,7 +92,11 @@ func (m *Manifest) Save() error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(ManifestPath(m.Workdir), append(data, '\n'), 0644)
+	tmp := ManifestPath(m.Workdir) + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, ManifestPath(m.Workdir))
 }
 
 func (m *Manifest) ApplyDefaultOutputs() error {
@@ -95,5 +124,5 @@ func (m *Manifest) ApplyDefaultOutputs() error {
 }
 
 func (m *Manifest) MarkStage(stage Stage, ok bool, msg string) {
-	m.Stages[string(stage)] = StageStatus{OK: ok, Error: msg}
+	m.Stages[string(stage)] = StageStatus{OK: ok, Error: msg, Updated: time.Now().UTC().Format(time.RFC3339)}
 }
@@ -1,37 +1,357 @@
 package pipeline
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"krillin-ai/internal/service"
+	"krillin-ai/internal/types"
+	"os"
+	"sort"
 	"strings"
+	"time"
 )
 
 type PipelineRequest struct {
+	Input    string
+	Workdir  string
+	TaskID   string
 	Subtitle SubtitleRequest
 	TTS      TTSRequest
+	Render   RenderRequest
+	Cover    CoverRequest
 	Outputs  string
 	Async    bool
 }
 
+// StageResult is the per-output breakdown reported by a pipeline run.
+type StageResult struct {
+	Stage      Stage  `json:"stage"`
+	Output     string `json:"output"`
+	OK         bool   `json:"ok"`
+	Skipped    bool   `json:"skipped,omitempty"`
+	Error      *Error `json:"error,omitempty"`
+	StartedAt  string `json:"started_at,omitempty"`
+	FinishedAt string `json:"finished_at,omitempty"`
+	DurationMS int64  `json:"duration_ms,omitempty"`
+}
+
+type pipelineStep struct {
+	Stage  Stage
+	Output string
+	Dubbed bool
+}
+
 func PlanOutputs(outputs string) ([]Stage, error) {
+	steps, err := planSteps(outputs)
+	if err != nil {
+		return nil, err
+	}
+	stages := make([]Stage, 0, len(steps))
+	for _, step := range steps {
+		stages = append(stages, step.Stage)
+	}
+	return stages, nil
+}
+
+func planSteps(outputs string) ([]pipelineStep, error) {
 	parts := strings.Split(outputs, ",")
-	stages := make([]Stage, 0, len(parts))
+	steps := make([]pipelineStep, 0, len(parts))
 	for _, part := range parts {
 		output := strings.TrimSpace(part)
 		switch output {
 		case "subtitle":
-			stages = append(stages, StageSubtitle)
+			steps = append(steps, pipelineStep{Stage: StageSubtitle, Output: output})
 		case "tts":
-			stages = append(stages, StageTTS)
+			steps = append(steps, pipelineStep{Stage: StageTTS, Output: output})
 		case "horizontal-bilingual", "horizontal-dubbed":
-			stages = append(stages, StageRenderHorizontal)
+			steps = append(steps, pipelineStep{Stage: StageRenderHorizontal, Output: output, Dubbed: output == "horizontal-dubbed"})
 		case "vertical-bilingual", "vertical-dubbed":
-			stages = append(stages, StageRenderVertical)
+			steps = append(steps, pipelineStep{Stage: StageRenderVertical, Output: output, Dubbed: output == "vertical-dubbed"})
 		case "cover":
-			stages = append(stages, StageCover)
+			steps = append(steps, pipelineStep{Stage: StageCover, Output: output})
 		case "":
 		default:
 			return nil, fmt.Errorf("unsupported output: %s", part)
 		}
 	}
-	return stages, nil
+	return steps, nil
+}
+
+// executionOrder sorts steps into subtitle → tts → render → cover and drops duplicates,
+// keeping the user's order among renders.
+func executionOrder(steps []pipelineStep) []pipelineStep {
+	rank := map[Stage]int{
+		StageSubtitle:         0,
+		StageTTS:              1,
+		StageRenderHorizontal: 2,
+		StageRenderVertical:   2,
+		StageCover:            3,
+	}
+	ordered := make([]pipelineStep, 0, len(steps))
+	seen := make(map[string]bool, len(steps))
+	for _, step := range steps {
+		if seen[step.Output] {
+			continue
+		}
+		seen[step.Output] = true
+		ordered = append(ordered, step)
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return rank[ordered[i].Stage] < rank[ordered[j].Stage]
+	})
+	return ordered
+}
+
+// RunPipeline runs the planned stages in order against one workdir and manifest.
+// Stages the manifest already marks OK are skipped and the first failure stops the run.
+// Progress, the current stage, and a heartbeat are written to the manifest run section.
+func RunPipeline(ctx context.Context, svc StageService, req PipelineRequest) (Response, error) {
+	start := time.Now()
+	steps, err := planSteps(req.Outputs)
+	if err != nil {
+		return pipelineFailureResponse(req, nil, ErrorKindUsage, "invalid_outputs", err, start), err
+	}
+	steps = executionOrder(steps)
+	if len(steps) == 0 {
+		err := errors.New("pipeline requires at least one output")
+		return pipelineFailureResponse(req, nil, ErrorKindUsage, "outputs_required", err, start), err
+	}
+	if err := ResolvePipelineWorkdir(&req); err != nil {
+		return pipelineFailureResponse(req, nil, ErrorKindUsage, "resolve_workdir_failed", err, start), err
+	}
+
+	tracker := startRunTracker(req, len(steps))
+	resp, err := runSteps(ctx, svc, req, steps, tracker, start)
+	tracker.finish(resp)
+	return resp, err
+}
+
+func runSteps(ctx context.Context, svc StageService, req PipelineRequest, steps []pipelineStep, tracker *runTracker, start time.Time) (Response, error) {
+	results := make([]StageResult, 0, len(steps))
+	var last Response
+	for _, step := range steps {
+		if err := ctx.Err(); err != nil {
+			return pipelineFailureResponse(req, results, ErrorKindRetryable, ErrorCodeCancelled, err, start), err
+		}
+		stepStart := time.Now()
+		manifest, err := LoadManifest(req.Workdir)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return pipelineFailureResponse(req, results, ErrorKindInternal, "load_manifest_failed", err, start), err
+		}
+		if manifest != nil && stepDone(req, step, manifest) {
+			result := StageResult{Stage: step.Stage, Output: step.Output, OK: true, Skipped: true}
+			results = append(results, result)
+			tracker.stageFinished(result)
+			continue
+		}
+
+		tracker.stageStarted(step)
+		resp, err := runLanguageSteps(ctx, svc, req, step, manifest)
+		if err == nil {
+			resp, err = runPipelineStep(ctx, svc, req, step)
+		}
+		if ctx.Err() != nil && !resp.OK {
+			err = ctx.Err()
+			resp.Error = &Error{
+				Kind:      ErrorKindRetryable,
+				Code:      ErrorCodeCancelled,
+				Message:   err.Error(),
+				Retryable: true,
+			}
+		}
+		if err != nil && resp.Error == nil {
+			resp.OK = false
+			resp.Error = &Error{
+				Kind:      ErrorKindRetryable,
+				Code:      "stage_failed",
+				Message:   err.Error(),
+				Retryable: true,
+			}
+		}
+		result := StageResult{
+			Stage:      step.Stage,
+			Output:     step.Output,
+			OK:         err == nil && resp.OK,
+			Error:      resp.Error,
+			StartedAt:  stepStart.UTC().Format(time.RFC3339),
+			FinishedAt: timestamp(),
+			DurationMS: time.Since(stepStart).Milliseconds(),
+		}
+		results = append(results, result)
+		tracker.stageFinished(result)
+		last = resp
+		if !result.OK {
+			if err == nil && resp.Error != nil {
+				err = resp.Error
+			}
+			return pipelineResponse(req, results, last, start), err
+		}
+	}
+	return pipelineResponse(req, results, last, start), nil
+}
+
+// ResolvePipelineWorkdir fills in the pipeline workdir and task ID, reusing the manifest task ID when present.
+func ResolvePipelineWorkdir(req *PipelineRequest) error {
+	if req.Workdir == "" && strings.TrimSpace(req.Input) == "" {
+		return errors.New("pipeline requires input or --workdir")
+	}
+	if req.TaskID == "" && req.Workdir != "" {
+		if manifest, err := LoadManifest(req.Workdir); err == nil && manifest.TaskID != "" {
+			req.TaskID = manifest.TaskID
+		}
+	}
+	taskID, workdir, err := ResolveWorkdir(req.Input, req.Workdir)
+	if err != nil {
+		return err
+	}
+	if req.TaskID == "" {
+		req.TaskID = taskID
+	}
+	req.Workdir = workdir
+	return nil
+}
+
+func stepDone(req PipelineRequest, step pipelineStep, manifest *Manifest) bool {
+	if !manifest.Stages[string(step.Stage)].OK {
+		return false
+	}
+	switch step.Stage {
+	case StageRenderHorizontal:
+		return manifest.Outputs.HorizontalVideo == renderOutput(pipelineRenderRequest(req, step))
+	case StageRenderVertical:
+		return manifest.Outputs.VerticalVideo == renderOutput(pipelineRenderRequest(req, step))
+	default:
+		return true
+	}
+}
+
+func runPipelineStep(ctx context.Context, svc StageService, req PipelineRequest, step pipelineStep) (Response, error) {
+	switch step.Stage {
+	case StageSubtitle:
+		subtitleReq := req.Subtitle
+		subtitleReq.Input = req.Input
+		subtitleReq.Workdir = req.Workdir
+		subtitleReq.TaskID = req.TaskID
+		if strings.TrimSpace(subtitleReq.Input) == "" {
+			err := errors.New("pipeline subtitle output requires input")
+			return subtitleFailureResponse(subtitleReq, nil, ErrorKindUsage, "input_required", err), err
+		}
+		return GenerateSubtitles(ctx, svc, subtitleReq)
+	case StageTTS:
+		ttsReq := req.TTS
+		ttsReq.Workdir = req.Workdir
+		ttsReq.TaskID = req.TaskID
+		return GenerateTTS(ctx, svc, ttsReq)
+	case StageRenderHorizontal, StageRenderVertical:
+		return Render(ctx, svc, pipelineRenderRequest(req, step))
+	case StageCover:
+		coverReq := req.Cover
+		coverReq.Workdir = req.Workdir
+		coverReq.TaskID = req.TaskID
+		return GenerateCover(ctx, svc, coverReq)
+	default:
+		err := fmt.Errorf("unsupported pipeline stage: %s", step.Stage)
+		return Response{OK: false, Stage: step.Stage, Error: &Error{Kind: ErrorKindUsage, Code: "unsupported_stage", Message: err.Error()}}, err
+	}
+}
+
+// runLanguageSteps runs TTS and render for the additional target languages of a multi-language task,
+// each in its own language workdir, before the first language so the stage is only marked done once every language is.
+func runLanguageSteps(ctx context.Context, svc StageService, req PipelineRequest, step pipelineStep, manifest *Manifest) (Response, error) {
+	if manifest == nil || len(manifest.TargetLanguages) < 2 || (step.Stage != StageTTS && step.Stage != StageRenderHorizontal && step.Stage != StageRenderVertical) {
+		return Response{}, nil
+	}
+	for _, lang := range manifest.TargetLanguages[1:] {
+		langReq := req
+		langReq.Workdir = service.LanguageTaskBasePath(req.Workdir, types.StandardLanguageCode(lang))
+		// explicit subtitle inputs belong to the first language
+		langReq.TTS.InputSRT = ""
+		langReq.Render.Subtitle = ""
+		langManifest, err := LoadManifest(langReq.Workdir)
+		if err != nil {
+			return Response{OK: false, Stage: step.Stage, Workdir: langReq.Workdir, Error: &Error{Kind: ErrorKindInternal, Code: "load_language_manifest_failed", Message: err.Error()}}, err
+		}
+		if stepDone(langReq, step, langManifest) {
+			continue
+		}
+		resp, err := runPipelineStep(ctx, svc, langReq, step)
+		if err == nil && !resp.OK {
+			err = fmt.Errorf("%s %s failed", lang, step.Output)
+		}
+		if err != nil {
+			return resp, err
+		}
+	}
+	return Response{}, refreshLanguageOutputs(req.Workdir)
+}
+
+// refreshLanguageOutputs copies the outputs recorded in each language workdir into the task manifest.
+func refreshLanguageOutputs(workdir string) error {
+	manifest, err := LoadManifest(workdir)
+	if err != nil {
+		return err
+	}
+	if manifest.Languages == nil {
+		manifest.Languages = LanguageOutputs{}
+	}
+	for _, lang := range manifest.TargetLanguages[1:] {
+		if langManifest, err := LoadManifest(service.LanguageTaskBasePath(workdir, types.StandardLanguageCode(lang))); err == nil {
+			manifest.Languages[lang] = langManifest.Outputs
+		}
+	}
+	return manifest.Save()
+}
+
+func pipelineRenderRequest(req PipelineRequest, step pipelineStep) RenderRequest {
+	renderReq := req.Render
+	renderReq.Workdir = req.Workdir
+	renderReq.TaskID = req.TaskID
+	renderReq.Horizontal = step.Stage == StageRenderHorizontal
+	renderReq.Dubbed = step.Dubbed
+	return renderReq
+}
+
+func pipelineFailureResponse(req PipelineRequest, results []StageResult, kind ErrorKind, code string, err error, start time.Time) Response {
+	resp := pipelineResponse(req, results, Response{}, start)
+	resp.OK = false
+	resp.Error = &Error{
+		Kind:      kind,
+		Code:      code,
+		Message:   err.Error(),
+		Retryable: kind == ErrorKindRetryable,
+	}
+	return resp
+}
+
+func pipelineResponse(req PipelineRequest, results []StageResult, last Response, start time.Time) Response {
+	resp := Response{
+		OK:            true,
+		Stage:         StagePipeline,
+		Workdir:       req.Workdir,
+		TaskID:        req.TaskID,
+		CaptionSource: last.CaptionSource,
+		Stages:        results,
+		DurationMS:    time.Since(start).Milliseconds(),
+	}
+	for _, result := range results {
+		if !result.OK {
+			resp.OK = false
+			resp.Error = result.Error
+		}
+	}
+	if req.Workdir == "" {
+		return resp
+	}
+	if manifest, err := LoadManifest(req.Workdir); err == nil {
+		resp.TaskID = manifest.TaskID
+		resp.Outputs = manifest.Outputs
+		resp.Languages = manifest.Languages
+		resp.Warnings = manifest.Warnings
+		resp.FailedIndexes = manifest.FailedIndexes
+		if resp.CaptionSource == "" && manifest.CaptionSource != "" {
+			resp.CaptionSource = CaptionSource(manifest.CaptionSource)
+		}
+	}
+	return resp
 }
@@ -1,6 +1,12 @@
 package pipeline
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"krillin-ai/internal/service"
+	"path/filepath"
+	"testing"
+)
 
 func TestPlanOutputsMapsToStages(t *testing.T) {
 	got, err := PlanOutputs("subtitle,tts,horizontal-bilingual,horizontal-dubbed,vertical-bilingual,vertical-dubbed")
@@ -31,3 +37,130 @@ func TestPlanOutputsRejectsUnsupportedOutput(t *testing.T) {
 		t.Fatalf("PlanOutputs() error = nil, want error")
 	}
 }
+
+type pipelineFakeService struct {
+	renderFakeService
+	renderErr error
+}
+
+func (f *pipelineFakeService) RenderVideo(ctx context.Context, req service.RenderVideoRequest) (string, error) {
+	f.calls = append(f.calls, "render")
+	if f.renderErr != nil {
+		return "", f.renderErr
+	}
+	return f.renderFakeService.RenderVideo(ctx, req)
+}
+
+func TestRunPipelineRunsStagesInOrder(t *testing.T) {
+	dir := t.TempDir()
+	fake := &pipelineFakeService{}
+	fake.coverImageB64 = "aW1n"
+	resp, err := RunPipeline(context.Background(), fake, PipelineRequest{
+		Input:   "local:demo.mp4",
+		Workdir: dir,
+		TaskID:  "demo",
+		Subtitle: SubtitleRequest{
+			OriginLang:    "en",
+			TargetLang:    "zh_cn",
+			CaptionSource: CaptionSourceWhisper,
+		},
+		Cover:   CoverRequest{Prompt: "cover"},
+		Outputs: "cover,horizontal-bilingual,subtitle",
+	})
+	if err != nil {
+		t.Fatalf("RunPipeline() error = %v", err)
+	}
+	if !resp.OK || resp.Stage != StagePipeline {
+		t.Fatalf("resp = %#v, want ok pipeline response", resp)
+	}
+	if got := fake.calls; len(got) != 4 || got[0] != "prepare" || got[1] != "audio" || got[2] != "render" || got[3] != "cover-image" {
+		t.Fatalf("calls = %v", got)
+	}
+	if len(resp.Stages) != 3 || resp.Stages[0].Stage != StageSubtitle || resp.Stages[2].Stage != StageCover {
+		t.Fatalf("Stages = %#v", resp.Stages)
+	}
+	if resp.Outputs.HorizontalVideo != filepath.Join(dir, "horizontal_bilingual.mp4") {
+		t.Fatalf("HorizontalVideo = %q", resp.Outputs.HorizontalVideo)
+	}
+}
+
+func TestRunPipelineSkipsStagesMarkedOK(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManifest("demo", dir)
+	m.MarkStage(StageSubtitle, true, "")
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+	fake := &pipelineFakeService{}
+	resp, err := RunPipeline(context.Background(), fake, PipelineRequest{
+		Workdir: dir,
+		Outputs: "subtitle,horizontal-bilingual",
+	})
+	if err != nil {
+		t.Fatalf("RunPipeline() error = %v", err)
+	}
+	if resp.TaskID != "demo" {
+		t.Fatalf("TaskID = %q, want manifest task id", resp.TaskID)
+	}
+	if !resp.Stages[0].Skipped || resp.Stages[1].Skipped {
+		t.Fatalf("Stages = %#v, want only subtitle skipped", resp.Stages)
+	}
+	if got := fake.calls; len(got) != 1 || got[0] != "render" {
+		t.Fatalf("calls = %v, want only render", got)
+	}
+}
+
+func TestRunPipelineStopsAtFirstFailure(t *testing.T) {
+	dir := t.TempDir()
+	fake := &pipelineFakeService{renderErr: errors.New("ffmpeg failed")}
+	fake.coverImageB64 = "aW1n"
+	resp, err := RunPipeline(context.Background(), fake, PipelineRequest{
+		Workdir: dir,
+		TaskID:  "demo",
+		Cover:   CoverRequest{Prompt: "cover"},
+		Outputs: "vertical-dubbed,cover",
+	})
+	if err == nil {
+		t.Fatal("RunPipeline() error = nil, want render failure")
+	}
+	if resp.OK || resp.Error == nil || resp.Error.Code != "render_video_failed" {
+		t.Fatalf("resp = %#v, want render_video_failed", resp)
+	}
+	if len(resp.Stages) != 1 || resp.Stages[0].Stage != StageRenderVertical || resp.Stages[0].OK {
+		t.Fatalf("Stages = %#v, want single failed render stage", resp.Stages)
+	}
+	for _, call := range fake.calls {
+		if call == "cover-image" {
+			t.Fatalf("cover ran after render failure: %v", fake.calls)
+		}
+	}
+}
+
+func TestRunPipelineRunsTTSForEveryTargetLanguage(t *testing.T) {
+	dir := t.TempDir()
+	fake := &pipelineFakeService{}
+	resp, err := RunPipeline(context.Background(), fake, PipelineRequest{
+		Input:   "local:demo.mp4",
+		Workdir: dir,
+		TaskID:  "demo",
+		Subtitle: SubtitleRequest{
+			OriginLang:    "en",
+			TargetLang:    "zh_cn,ja",
+			CaptionSource: CaptionSourceWhisper,
+		},
+		Outputs: "subtitle,tts",
+	})
+	if err != nil {
+		t.Fatalf("RunPipeline() error = %v", err)
+	}
+	jaDir := service.LanguageTaskBasePath(dir, "ja")
+	if got := fake.speechWorkdirs; len(got) != 2 || got[0] != jaDir || got[1] != dir {
+		t.Fatalf("speech workdirs = %v, want [%s %s]", got, jaDir, dir)
+	}
+	if got := resp.Languages["ja"].TTSAudio; filepath.Dir(got) != jaDir {
+		t.Fatalf("ja TTSAudio = %q, want under %s", got, jaDir)
+	}
+	if got := resp.Languages["zh_cn"].TTSAudio; filepath.Dir(got) != dir {
+		t.Fatalf("zh_cn TTSAudio = %q, want under %s", got, dir)
+	}
+}
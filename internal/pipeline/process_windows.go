@@ -0,0 +1,15 @@
+//go:build windows
+
+package pipeline
+
+import "os"
+
+// processAlive reports whether a process with pid exists; FindProcess opens a handle and fails once it has exited.
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	_ = process.Release()
+	return true
+}
@@ -0,0 +1,238 @@
+package pipeline
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+type RunState string
+
+const (
+	RunStateQueued    RunState = "queued"
+	RunStateRunning   RunState = "running"
+	RunStateSucceeded RunState = "succeeded"
+	RunStateFailed    RunState = "failed"
+	RunStateStale     RunState = "stale"
+	RunStatePending   RunState = "pending"
+	RunStateCancelled RunState = "cancelled"
+)
+
+// ErrorCodeCancelled marks a run interrupted by SIGINT/SIGTERM; it can be resumed by re-running the pipeline.
+const ErrorCodeCancelled = "cancelled"
+
+// HeartbeatInterval is how often a running pipeline refreshes run.heartbeat in the manifest.
+var HeartbeatInterval = 10 * time.Second
+
+// StaleAfter is how long a queued or running pipeline may go without a heartbeat before status reports it stale.
+var StaleAfter = 6 * HeartbeatInterval
+
+// RunStatus is the pipeline progress section of the manifest.
+type RunStatus struct {
+	State         RunState      `json:"state"`
+	Async         bool          `json:"async,omitempty"`
+	PID           int           `json:"pid,omitempty"`
+	Outputs       string        `json:"outputs,omitempty"`
+	CurrentStage  Stage         `json:"current_stage,omitempty"`
+	CurrentOutput string        `json:"current_output,omitempty"`
+	Completed     int           `json:"completed"`
+	Total         int           `json:"total"`
+	Percent       int           `json:"percent"`
+	StartedAt     string        `json:"started_at,omitempty"`
+	Heartbeat     string        `json:"heartbeat,omitempty"`
+	FinishedAt    string        `json:"finished_at,omitempty"`
+	LogFile       string        `json:"log_file,omitempty"`
+	Error         *Error        `json:"error,omitempty"`
+	Stages        []StageResult `json:"stages,omitempty"`
+}
+
+// SaveRunStatus replaces the run section of the manifest in workdir, creating the manifest when missing.
+func SaveRunStatus(workdir, taskID string, run RunStatus) error {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	manifest, err := LoadManifest(workdir)
+	if errors.Is(err, os.ErrNotExist) {
+		manifest = NewManifest(taskID, workdir)
+	} else if err != nil {
+		return err
+	}
+	manifest.Workdir = workdir
+	if manifest.TaskID == "" {
+		manifest.TaskID = taskID
+	}
+	manifest.Run = &run
+	return manifest.write()
+}
+
+// RecordRunPID stores the async worker PID in a queued run section. A worker that
+// already started has written its own PID and state, which are left untouched.
+func RecordRunPID(workdir string, pid int) error {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	manifest, err := LoadManifest(workdir)
+	if err != nil {
+		return err
+	}
+	if manifest.Run == nil || manifest.Run.State != RunStateQueued || manifest.Run.PID != 0 {
+		return nil
+	}
+	manifest.Run.PID = pid
+	return manifest.write()
+}
+
+// ReadStatus loads the manifest in workdir and reports the run state, marking
+// queued or running pipelines without a recent heartbeat, or whose async worker
+// has exited, as stale.
+func ReadStatus(workdir string, now time.Time) (*Manifest, RunStatus, error) {
+	manifest, err := LoadManifest(workdir)
+	if err != nil {
+		return nil, RunStatus{}, err
+	}
+	if manifest.Run == nil {
+		return manifest, statusFromStages(manifest), nil
+	}
+	run := *manifest.Run
+	if run.State == RunStateQueued || run.State == RunStateRunning {
+		heartbeat, err := time.Parse(time.RFC3339, run.Heartbeat)
+		if err != nil || now.Sub(heartbeat) > StaleAfter || (run.Async && run.PID > 0 && !processAlive(run.PID)) {
+			run.State = RunStateStale
+		}
+	}
+	return manifest, run, nil
+}
+
+func statusFromStages(manifest *Manifest) RunStatus {
+	run := RunStatus{State: RunStatePending, Total: len(manifest.Stages)}
+	for name, stage := range manifest.Stages {
+		if !stage.OK {
+			run.State = RunStateFailed
+			run.Error = &Error{Kind: ErrorKindRetryable, Code: "stage_failed", Message: stage.Error, Retryable: true}
+			run.CurrentStage = Stage(name)
+			continue
+		}
+		run.Completed++
+	}
+	if run.Total > 0 && run.State != RunStateFailed {
+		run.State = RunStateSucceeded
+	}
+	if run.Total > 0 {
+		run.Percent = run.Completed * 100 / run.Total
+	}
+	return run
+}
+
+// runTracker mirrors pipeline progress into the manifest run section and keeps its heartbeat fresh.
+type runTracker struct {
+	mu      sync.Mutex
+	workdir string
+	taskID  string
+	run     RunStatus
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func startRunTracker(req PipelineRequest, total int) *runTracker {
+	now := timestamp()
+	t := &runTracker{
+		workdir: req.Workdir,
+		taskID:  req.TaskID,
+		run: RunStatus{
+			State:     RunStateRunning,
+			Async:     req.Async,
+			PID:       os.Getpid(),
+			Outputs:   req.Outputs,
+			Total:     total,
+			StartedAt: now,
+			Heartbeat: now,
+		},
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	if existing, err := LoadManifest(req.Workdir); err == nil && existing.Run != nil {
+		t.run.LogFile = existing.Run.LogFile
+		if existing.Run.State == RunStateQueued && existing.Run.StartedAt != "" {
+			t.run.StartedAt = existing.Run.StartedAt
+		}
+	}
+	t.save()
+	go t.beat()
+	return t
+}
+
+func (t *runTracker) beat() {
+	defer close(t.done)
+	ticker := time.NewTicker(HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-ticker.C:
+			t.update(func(run *RunStatus) {})
+		}
+	}
+}
+
+func (t *runTracker) stageStarted(step pipelineStep) {
+	if t == nil {
+		return
+	}
+	t.update(func(run *RunStatus) {
+		run.CurrentStage = step.Stage
+		run.CurrentOutput = step.Output
+	})
+}
+
+func (t *runTracker) stageFinished(result StageResult) {
+	if t == nil {
+		return
+	}
+	t.update(func(run *RunStatus) {
+		run.Stages = append(run.Stages, result)
+		if result.OK {
+			run.Completed++
+		}
+		if run.Total > 0 {
+			run.Percent = run.Completed * 100 / run.Total
+		}
+	})
+}
+
+func (t *runTracker) finish(resp Response) {
+	if t == nil {
+		return
+	}
+	close(t.stop)
+	<-t.done
+	t.update(func(run *RunStatus) {
+		run.State = RunStateSucceeded
+		if !resp.OK {
+			run.State = RunStateFailed
+			if resp.Error != nil && resp.Error.Code == ErrorCodeCancelled {
+				run.State = RunStateCancelled
+			}
+			run.Error = resp.Error
+		} else {
+			run.CurrentStage = ""
+			run.CurrentOutput = ""
+		}
+		run.FinishedAt = timestamp()
+	})
+}
+
+func (t *runTracker) update(fn func(*RunStatus)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fn(&t.run)
+	t.run.Heartbeat = timestamp()
+	t.save()
+}
+
+func (t *runTracker) save() {
+	_ = SaveRunStatus(t.workdir, t.taskID, t.run)
+}
+
+func timestamp() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,133 @@
+package pipeline
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestSaveKeepsRunStatusWrittenByTracker(t *testing.T) {
+	dir := t.TempDir()
+	stale := NewManifest("demo", dir)
+	if err := SaveRunStatus(dir, "demo", RunStatus{State: RunStateRunning, CurrentStage: StageTTS}); err != nil {
+		t.Fatalf("SaveRunStatus() error = %v", err)
+	}
+	stale.MarkStage(StageSubtitle, true, "")
+	if err := stale.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	loaded, err := LoadManifest(dir)
+	if err != nil {
+		t.Fatalf("LoadManifest() error = %v", err)
+	}
+	if loaded.Run == nil || loaded.Run.CurrentStage != StageTTS {
+		t.Fatalf("Run = %#v, want tracker run status kept", loaded.Run)
+	}
+	if !loaded.Stages[string(StageSubtitle)].OK {
+		t.Fatalf("subtitle stage not saved")
+	}
+}
+
+func TestReadStatusReportsStaleHeartbeat(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	run := RunStatus{State: RunStateRunning, Heartbeat: now.Add(-StaleAfter - time.Second).Format(time.RFC3339)}
+	if err := SaveRunStatus(dir, "demo", run); err != nil {
+		t.Fatal(err)
+	}
+	_, got, err := ReadStatus(dir, now)
+	if err != nil {
+		t.Fatalf("ReadStatus() error = %v", err)
+	}
+	if got.State != RunStateStale {
+		t.Fatalf("State = %s, want stale", got.State)
+	}
+
+	run.Heartbeat = now.Add(-time.Second).Format(time.RFC3339)
+	if err := SaveRunStatus(dir, "demo", run); err != nil {
+		t.Fatal(err)
+	}
+	_, got, err = ReadStatus(dir, now)
+	if err != nil {
+		t.Fatalf("ReadStatus() error = %v", err)
+	}
+	if got.State != RunStateRunning {
+		t.Fatalf("State = %s, want running", got.State)
+	}
+}
+
+func TestReadStatusChecksAsyncWorkerPID(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	run := RunStatus{State: RunStateQueued, Async: true, Heartbeat: now.UTC().Format(time.RFC3339)}
+	if err := SaveRunStatus(dir, "demo", run); err != nil {
+		t.Fatal(err)
+	}
+	if err := RecordRunPID(dir, os.Getpid()); err != nil {
+		t.Fatalf("RecordRunPID() error = %v", err)
+	}
+	if _, got, err := ReadStatus(dir, now); err != nil || got.State != RunStateQueued || got.PID != os.Getpid() {
+		t.Fatalf("ReadStatus() = %+v, %v, want queued run with pid", got, err)
+	}
+
+	// a worker that has already exited leaves the run stale even with a fresh heartbeat
+	exited := exec.Command(os.Args[0], "-test.run=^$")
+	if err := exited.Run(); err != nil {
+		t.Fatal(err)
+	}
+	run.PID = exited.Process.Pid
+	if err := SaveRunStatus(dir, "demo", run); err != nil {
+		t.Fatal(err)
+	}
+	if _, got, err := ReadStatus(dir, now); err != nil || got.State != RunStateStale {
+		t.Fatalf("ReadStatus() = %+v, %v, want stale", got, err)
+	}
+}
+
+func TestRunPipelineRecordsRunStatus(t *testing.T) {
+	dir := t.TempDir()
+	fake := &pipelineFakeService{renderErr: context.DeadlineExceeded}
+	if _, err := RunPipeline(context.Background(), fake, PipelineRequest{
+		Workdir: dir,
+		TaskID:  "demo",
+		Outputs: "horizontal-bilingual",
+	}); err == nil {
+		t.Fatal("RunPipeline() error = nil, want render failure")
+	}
+	_, run, err := ReadStatus(dir, time.Now())
+	if err != nil {
+		t.Fatalf("ReadStatus() error = %v", err)
+	}
+	if run.State != RunStateFailed || run.CurrentStage != StageRenderHorizontal {
+		t.Fatalf("run = %#v, want failed at render-horizontal", run)
+	}
+	if len(run.Stages) != 1 || run.Stages[0].StartedAt == "" || run.Stages[0].FinishedAt == "" {
+		t.Fatalf("run.Stages = %#v, want timed render stage", run.Stages)
+	}
+	if run.FinishedAt == "" || run.Error == nil {
+		t.Fatalf("run = %#v, want finished_at and error", run)
+	}
+}
+
+func TestRunPipelineRecordsCancelledRun(t *testing.T) {
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	resp, err := RunPipeline(ctx, &pipelineFakeService{}, PipelineRequest{
+		Workdir: dir,
+		TaskID:  "demo",
+		Outputs: "horizontal-bilingual",
+	})
+	if err == nil || resp.Error == nil || resp.Error.Code != ErrorCodeCancelled {
+		t.Fatalf("RunPipeline() = %#v, %v, want cancelled error", resp.Error, err)
+	}
+	_, run, err := ReadStatus(dir, time.Now())
+	if err != nil {
+		t.Fatalf("ReadStatus() error = %v", err)
+	}
+	if run.State != RunStateCancelled || run.FinishedAt == "" {
+		t.Fatalf("run = %#v, want finished cancelled run", run)
+	}
+}
@@ -10,6 +10,7 @@ import (
 type StageService interface {
 	PrepareMedia(context.Context, *types.SubtitleTaskStepParam) error
 	GenerateSubtitlesFromAudio(context.Context, *types.SubtitleTaskStepParam) error
+	GenerateLanguageSubtitles(ctx context.Context, primary, stepParam *types.SubtitleTaskStepParam, youtubeReq *service.YoutubeSubtitleReq) error
 	GenerateSpeechFromSRT(context.Context, *types.SubtitleTaskStepParam) error
 	FinalizeSubtitleResults(context.Context, *types.SubtitleTaskStepParam) error
 	DownloadYouTubeSubtitle(context.Context, *service.YoutubeSubtitleReq) (string, error)
@@ -34,6 +35,10 @@ func (a *ServiceAdapter) GenerateSubtitlesFromAudio(ctx context.Context, p *type
 	return a.svc.GenerateSubtitlesFromAudio(ctx, p)
 }
 
+func (a *ServiceAdapter) GenerateLanguageSubtitles(ctx context.Context, primary, p *types.SubtitleTaskStepParam, r *service.YoutubeSubtitleReq) error {
+	return a.svc.GenerateLanguageSubtitles(ctx, primary, p, r)
+}
+
 func (a *ServiceAdapter) GenerateSpeechFromSRT(ctx context.Context, p *types.SubtitleTaskStepParam) error {
 	return a.svc.GenerateSpeechFromSRT(ctx, p)
 }
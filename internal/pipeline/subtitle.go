@@ -2,11 +2,14 @@ package pipeline
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"krillin-ai/internal/service"
 	subtitlestyle "krillin-ai/internal/subtitle_style"
 	"krillin-ai/internal/types"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
@@ -17,12 +20,17 @@ type SubtitleRequest struct {
 	Workdir        string
 	TaskID         string
 	OriginLang     string
-	TargetLang     string
+	TargetLang     string // 目标语言，多个时以逗号分隔，转录只做一次，各语言分别翻译
 	UserLang       string
 	CaptionSource  CaptionSource
 	BilingualTop   bool
 	MaxWordOneLine int
 	SubtitleStyle  *subtitlestyle.StyleSet
+	Resume         bool     // 复用 workdir 中与当前设置一致的分段转录和翻译结果
+	Vocabulary     []string // 转录热词，与配置中的默认热词合并
+	Prompt         string   // 转录背景说明，如视频标题
+	NoCache        bool     // 不使用跨任务共享的转录缓存
+	GlossaryFile   string   // 翻译术语表文件（CSV或TOML），与配置中的项目术语表合并
 }
 
 func GenerateSubtitles(ctx context.Context, svc StageService, req SubtitleRequest) (Response, error) {
@@ -38,19 +46,41 @@ func GenerateSubtitles(ctx context.Context, svc StageService, req SubtitleReques
 	manifest.Workdir = req.Workdir
 	manifest.InputURL = req.Input
 	manifest.OriginLanguage = req.OriginLang
-	manifest.TargetLanguage = req.TargetLang
+	if service.IsAutoOriginLanguage(req.OriginLang) {
+		manifest.OriginLanguage = string(types.LanguageNameAuto)
+	}
+	manifest.OriginLanguageDetected = false
+	manifest.TargetLanguage = primaryTargetLang(req)
+	manifest.TargetLanguages = nil
+	manifest.Languages = nil
+	if targetLangs := types.ParseLanguageList(req.TargetLang); len(targetLangs) > 1 {
+		for _, lang := range targetLangs {
+			manifest.TargetLanguages = append(manifest.TargetLanguages, string(lang))
+		}
+	}
 	manifest.CaptionSource = string(req.CaptionSource)
 	if err := manifest.ApplyDefaultOutputs(); err != nil {
 		return subtitleFailureResponse(req, manifest, ErrorKindInternal, "apply_outputs_failed", err), err
 	}
 
 	stepParam := subtitleStepParam(req)
+	if stepParam.Glossary, err = service.NewGlossary(req.GlossaryFile, nil, types.StandardLanguageCode(primaryTargetLang(req))); err != nil {
+		return failSubtitleStage(req, manifest, ErrorKindUsage, "load_glossary_failed", err)
+	}
+	if isYouTubeInput(req.Input) && req.CaptionSource != CaptionSourceWhisper && !stepParam.VttSwitch {
+		// 平台字幕需要按源语言选择字幕轨，源语言自动识别时只能转录
+		if req.CaptionSource != CaptionSourceAny {
+			err := errors.New("platform captions require --origin-lang")
+			return failSubtitleStage(req, manifest, ErrorKindUsage, "origin_lang_required", err)
+		}
+		manifest.Warnings = append(manifest.Warnings, "源语言为自动识别，跳过平台字幕，直接转录")
+	}
 	if err := svc.PrepareMedia(ctx, stepParam); err != nil {
 		return failSubtitleStage(req, manifest, ErrorKindRetryable, "prepare_media_failed", err)
 	}
 
-	if isYouTubeInput(req.Input) && req.CaptionSource != CaptionSourceWhisper {
-		youtubeReq := subtitleYouTubeReq(req, stepParam.TaskPtr)
+	if stepParam.VttSwitch {
+		youtubeReq := subtitleYouTubeReq(req, stepParam)
 		vttFile, err := svc.DownloadYouTubeSubtitle(ctx, youtubeReq)
 		if err == nil {
 			youtubeReq.VttFile = vttFile
@@ -61,6 +91,9 @@ func GenerateSubtitles(ctx context.Context, svc StageService, req SubtitleReques
 			if err := prepareOriginalMediaForRendering(ctx, svc, stepParam); err != nil {
 				return failSubtitleStage(req, manifest, ErrorKindRetryable, "prepare_media_for_render_failed", err)
 			}
+			if err := generateLanguageSubtitles(ctx, svc, req, manifest, stepParam, youtubeReq); err != nil {
+				return failSubtitleStage(req, manifest, ErrorKindRetryable, "language_subtitle_failed", err)
+			}
 			return saveSubtitleSuccess(manifest, req, CaptionSource("youtube_vtt"))
 		}
 		if req.CaptionSource != CaptionSourceAny {
@@ -77,9 +110,97 @@ func GenerateSubtitles(ctx context.Context, svc StageService, req SubtitleReques
 		return failSubtitleStage(req, manifest, ErrorKindRetryable, "audio_transcription_failed", err)
 	}
 	manifest.CaptionSource = string(CaptionSourceWhisper)
+	manifest.OriginLanguage = string(stepParam.OriginLanguage)
+	manifest.OriginLanguageDetected = stepParam.OriginLanguageDetected
+	manifest.Transcriptions = transcriptionProviders(req.Workdir)
+	manifest.Outputs.ReviewJSON, manifest.Outputs.ReviewHTML = "", ""
+	if reviewJSON := filepath.Join(req.Workdir, types.SubtitleTaskReviewJsonFileName); regularFileExists(reviewJSON) {
+		manifest.Outputs.ReviewJSON = reviewJSON
+		manifest.Outputs.ReviewHTML = filepath.Join(req.Workdir, types.SubtitleTaskReviewHtmlFileName)
+	}
+	if err := generateLanguageSubtitles(ctx, svc, req, manifest, stepParam, nil); err != nil {
+		return failSubtitleStage(req, manifest, ErrorKindRetryable, "language_subtitle_failed", err)
+	}
 	return saveSubtitleSuccess(manifest, req, CaptionSourceWhisper)
 }
 
+// generateLanguageSubtitles 为第二个及之后的目标语言生成字幕，复用第一个目标语言的转录结果或平台字幕。
+// 每个语言的目录中写入单独的清单，配音和合成视频时可以直接把该目录作为 --workdir
+func generateLanguageSubtitles(ctx context.Context, svc StageService, req SubtitleRequest, manifest *Manifest, primary *types.SubtitleTaskStepParam, youtubeReq *service.YoutubeSubtitleReq) error {
+	if len(manifest.TargetLanguages) < 2 {
+		return nil
+	}
+	manifest.Languages = LanguageOutputs{}
+	for _, lang := range manifest.TargetLanguages[1:] {
+		stepParam, err := service.NewLanguageStepParam(primary, types.StandardLanguageCode(lang))
+		if err != nil {
+			return err
+		}
+		if err = svc.GenerateLanguageSubtitles(ctx, primary, stepParam, youtubeReq); err != nil {
+			return fmt.Errorf("%s: %w", lang, err)
+		}
+		langManifest := NewManifest(req.TaskID, stepParam.TaskBasePath)
+		langManifest.InputURL = req.Input
+		langManifest.OriginLanguage = string(primary.OriginLanguage)
+		langManifest.OriginLanguageDetected = primary.OriginLanguageDetected
+		langManifest.TargetLanguage = lang
+		langManifest.CaptionSource = manifest.CaptionSource
+		if err = langManifest.ApplyDefaultOutputs(); err != nil {
+			return err
+		}
+		// 源视频和音频只在任务目录中保存一份
+		langManifest.Outputs.OriginVideo = manifest.Outputs.OriginVideo
+		langManifest.Outputs.OriginAudio = manifest.Outputs.OriginAudio
+		if reviewJSON := filepath.Join(stepParam.TaskBasePath, types.SubtitleTaskReviewJsonFileName); regularFileExists(reviewJSON) {
+			langManifest.Outputs.ReviewJSON = reviewJSON
+			langManifest.Outputs.ReviewHTML = filepath.Join(stepParam.TaskBasePath, types.SubtitleTaskReviewHtmlFileName)
+		}
+		if report := filepath.Join(stepParam.TaskBasePath, types.SubtitleTaskGlossaryReportFileName); regularFileExists(report) {
+			langManifest.Outputs.GlossaryReport = report
+		}
+		langManifest.MarkStage(StageSubtitle, true, "")
+		if err = langManifest.Save(); err != nil {
+			return err
+		}
+		manifest.Languages[lang] = langManifest.Outputs
+	}
+	return nil
+}
+
+// primaryTargetLang 多个目标语言中的第一个，其产物直接放在工作目录下
+func primaryTargetLang(req SubtitleRequest) string {
+	if targetLangs := types.ParseLanguageList(req.TargetLang); len(targetLangs) > 0 {
+		return string(targetLangs[0])
+	}
+	return req.TargetLang
+}
+
+func regularFileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// transcriptionProviders reads which transcriber produced each segment transcription in the workdir.
+func transcriptionProviders(workdir string) map[string]string {
+	files, _ := filepath.Glob(filepath.Join(workdir, strings.Replace(types.SubtitleTaskAudioTranscriptionDataPersistenceFileNamePattern, "%d", "*", 1)))
+	providers := make(map[string]string, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var transcription types.TranscriptionData
+		if err := json.Unmarshal(data, &transcription); err != nil || transcription.Provider == "" {
+			continue
+		}
+		providers[filepath.Base(file)] = transcription.Provider
+	}
+	if len(providers) == 0 {
+		return nil
+	}
+	return providers
+}
+
 func subtitleManifest(req SubtitleRequest) (*Manifest, error) {
 	manifest, err := LoadManifest(req.Workdir)
 	if err == nil {
@@ -91,6 +212,19 @@ func subtitleManifest(req SubtitleRequest) (*Manifest, error) {
 	return nil, err
 }
 
+// ParseVocabulary 解析命令行传入的热词，用逗号或换行分隔
+func ParseVocabulary(s string) []string {
+	var vocabulary []string
+	for _, word := range strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == '，' || r == '\n'
+	}) {
+		if word = strings.TrimSpace(word); word != "" {
+			vocabulary = append(vocabulary, word)
+		}
+	}
+	return vocabulary
+}
+
 func subtitleStepParam(req SubtitleRequest) *types.SubtitleTaskStepParam {
 	userLang := req.UserLang
 	if userLang == "" {
@@ -104,6 +238,10 @@ func subtitleStepParam(req SubtitleRequest) *types.SubtitleTaskStepParam {
 	if maxWordOneLine <= 0 {
 		maxWordOneLine = defaultSubtitleMaxWordOneLine
 	}
+	originLang := types.StandardLanguageCode(req.OriginLang)
+	if service.IsAutoOriginLanguage(req.OriginLang) {
+		originLang = types.LanguageNameAuto
+	}
 
 	taskPtr := &types.SubtitleTask{
 		TaskId:   req.TaskID,
@@ -116,13 +254,16 @@ func subtitleStepParam(req SubtitleRequest) *types.SubtitleTaskStepParam {
 		TaskBasePath:           req.Workdir,
 		Link:                   req.Input,
 		SubtitleResultType:     resultType,
-		OriginLanguage:         types.StandardLanguageCode(req.OriginLang),
-		TargetLanguage:         types.StandardLanguageCode(req.TargetLang),
+		OriginLanguage:         originLang,
+		TargetLanguage:         types.StandardLanguageCode(primaryTargetLang(req)),
 		UserUILanguage:         types.StandardLanguageCode(userLang),
 		MaxWordOneLine:         maxWordOneLine,
-		VttSwitch:              isYouTubeInput(req.Input) && req.CaptionSource != CaptionSourceWhisper,
+		VttSwitch:              isYouTubeInput(req.Input) && req.CaptionSource != CaptionSourceWhisper && originLang != types.LanguageNameAuto,
 		EmbedSubtitleVideoType: "none",
 		SubtitleStyle:          req.SubtitleStyle,
+		Resume:                 req.Resume,
+		TranscriptionHint:      service.NewTranscriptionHint(req.Vocabulary, req.Prompt),
+		NoTranscriptionCache:   req.NoCache,
 	}
 }
 
@@ -132,19 +273,24 @@ func prepareOriginalMediaForRendering(ctx context.Context, svc StageService, ste
 	return svc.PrepareMedia(ctx, stepParam)
 }
 
-func subtitleYouTubeReq(req SubtitleRequest, taskPtr *types.SubtitleTask) *service.YoutubeSubtitleReq {
+func subtitleYouTubeReq(req SubtitleRequest, stepParam *types.SubtitleTaskStepParam) *service.YoutubeSubtitleReq {
 	return &service.YoutubeSubtitleReq{
 		TaskBasePath:        req.Workdir,
 		TaskId:              req.TaskID,
 		URL:                 req.Input,
 		OriginLanguage:      req.OriginLang,
-		TargetLanguage:      req.TargetLang,
-		TaskPtr:             taskPtr,
+		TargetLanguage:      primaryTargetLang(req),
+		TaskPtr:             stepParam.TaskPtr,
 		TargetLanguageFirst: req.BilingualTop,
+		Glossary:            stepParam.Glossary.ForLanguage(stepParam.TargetLanguage),
 	}
 }
 
 func saveSubtitleSuccess(manifest *Manifest, req SubtitleRequest, captionSource CaptionSource) (Response, error) {
+	manifest.Outputs.GlossaryReport = ""
+	if report := filepath.Join(req.Workdir, types.SubtitleTaskGlossaryReportFileName); regularFileExists(report) {
+		manifest.Outputs.GlossaryReport = report
+	}
 	manifest.MarkStage(StageSubtitle, true, "")
 	if err := manifest.Save(); err != nil {
 		return subtitleFailureResponse(req, manifest, ErrorKindInternal, "save_manifest_failed", err), err
@@ -183,6 +329,7 @@ func subtitleResponse(ok bool, req SubtitleRequest, manifest *Manifest, captionS
 		resp.Workdir = manifest.Workdir
 		resp.TaskID = manifest.TaskID
 		resp.Outputs = manifest.Outputs
+		resp.Languages = manifest.Languages
 		resp.Warnings = manifest.Warnings
 		if manifest.CaptionSource != "" {
 			resp.CaptionSource = CaptionSource(manifest.CaptionSource)
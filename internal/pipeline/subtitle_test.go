@@ -7,20 +7,24 @@ import (
 	subtitlestyle "krillin-ai/internal/subtitle_style"
 	"krillin-ai/internal/types"
 	pkgimage "krillin-ai/pkg/image"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
 type fakeStageService struct {
-	downloadErr       error
-	processErr        error
-	calls             []string
-	prepareVTT        []bool
-	prepareEmbedTypes []string
-	lastPrepare       *types.SubtitleTaskStepParam
-	lastSpeech        *types.SubtitleTaskStepParam
-	lastCoverPrompt   string
-	lastCoverSize     string
-	coverImageB64     string
+	downloadErr         error
+	processErr          error
+	calls               []string
+	prepareVTT          []bool
+	prepareEmbedTypes   []string
+	lastPrepare         *types.SubtitleTaskStepParam
+	lastSpeech          *types.SubtitleTaskStepParam
+	speechWorkdirs      []string
+	languageYouTubeReqs []*service.YoutubeSubtitleReq
+	lastCoverPrompt     string
+	lastCoverSize       string
+	coverImageB64       string
 }
 
 func (f *fakeStageService) PrepareMedia(_ context.Context, p *types.SubtitleTaskStepParam) error {
@@ -36,9 +40,16 @@ func (f *fakeStageService) GenerateSubtitlesFromAudio(context.Context, *types.Su
 	return nil
 }
 
+func (f *fakeStageService) GenerateLanguageSubtitles(_ context.Context, _, p *types.SubtitleTaskStepParam, r *service.YoutubeSubtitleReq) error {
+	f.calls = append(f.calls, "language-"+string(p.TargetLanguage))
+	f.languageYouTubeReqs = append(f.languageYouTubeReqs, r)
+	return nil
+}
+
 func (f *fakeStageService) GenerateSpeechFromSRT(_ context.Context, p *types.SubtitleTaskStepParam) error {
 	f.calls = append(f.calls, "speech")
 	f.lastSpeech = p
+	f.speechWorkdirs = append(f.speechWorkdirs, p.TaskBasePath)
 	return nil
 }
 
@@ -227,3 +238,86 @@ func TestGenerateSubtitlesWhisperSkipsYouTubeDownload(t *testing.T) {
 		t.Fatalf("prepare VttSwitch values = %v, want [false]", got)
 	}
 }
+
+func TestGenerateSubtitlesRecordsTranscriptionProviders(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"audio_transcription_data_0.json": `{"Text":"hello","Provider":"openai"}`,
+		"audio_transcription_data_1.json": `{"Text":"world","Provider":"fasterwhisper"}`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := SubtitleRequest{
+		Input:         "local:demo.mp4",
+		Workdir:       dir,
+		TaskID:        "demo",
+		OriginLang:    "en",
+		TargetLang:    "zh_cn",
+		CaptionSource: CaptionSourceWhisper,
+	}
+	if _, err := GenerateSubtitles(context.Background(), &fakeStageService{}, req); err != nil {
+		t.Fatalf("GenerateSubtitles() error = %v", err)
+	}
+
+	manifest, err := LoadManifest(dir)
+	if err != nil {
+		t.Fatalf("LoadManifest() error = %v", err)
+	}
+	if manifest.Transcriptions["audio_transcription_data_0.json"] != "openai" || manifest.Transcriptions["audio_transcription_data_1.json"] != "fasterwhisper" {
+		t.Fatalf("Transcriptions = %v", manifest.Transcriptions)
+	}
+}
+
+func TestGenerateSubtitlesFansOutAdditionalTargetLanguages(t *testing.T) {
+	dir := t.TempDir()
+	fake := &fakeStageService{}
+	req := SubtitleRequest{
+		Input:         "local:demo.mp4",
+		Workdir:       dir,
+		TaskID:        "demo",
+		OriginLang:    "en",
+		TargetLang:    "zh_cn, ja,zh_cn",
+		CaptionSource: CaptionSourceWhisper,
+	}
+	resp, err := GenerateSubtitles(context.Background(), fake, req)
+	if err != nil {
+		t.Fatalf("GenerateSubtitles() error = %v", err)
+	}
+	if got := fake.calls; len(got) != 3 || got[0] != "prepare" || got[1] != "audio" || got[2] != "language-ja" {
+		t.Fatalf("calls = %v, want one transcription and a ja translation", got)
+	}
+	if got := fake.languageYouTubeReqs; len(got) != 1 || got[0] != nil {
+		t.Fatalf("language YouTube requests = %v, want [nil]", got)
+	}
+	if got := fake.lastPrepare.TargetLanguage; got != "zh_cn" {
+		t.Fatalf("primary TargetLanguage = %q, want zh_cn", got)
+	}
+
+	manifest, err := LoadManifest(dir)
+	if err != nil {
+		t.Fatalf("LoadManifest() error = %v", err)
+	}
+	if manifest.TargetLanguage != "zh_cn" || len(manifest.TargetLanguages) != 2 || manifest.TargetLanguages[1] != "ja" {
+		t.Fatalf("TargetLanguage = %q, TargetLanguages = %v", manifest.TargetLanguage, manifest.TargetLanguages)
+	}
+	if _, ok := resp.Languages["zh_cn"]; !ok {
+		t.Fatalf("Languages = %v, want zh_cn entry", resp.Languages)
+	}
+	jaDir := service.LanguageTaskBasePath(dir, "ja")
+	if got := resp.Languages["ja"].TargetSRT; filepath.Dir(got) != jaDir {
+		t.Fatalf("ja TargetSRT = %q, want under %s", got, jaDir)
+	}
+
+	langManifest, err := LoadManifest(jaDir)
+	if err != nil {
+		t.Fatalf("LoadManifest(ja) error = %v", err)
+	}
+	if langManifest.TargetLanguage != "ja" || langManifest.Outputs.OriginVideo != manifest.Outputs.OriginVideo {
+		t.Fatalf("ja manifest = %#v", langManifest)
+	}
+	if !langManifest.Stages[string(StageSubtitle)].OK {
+		t.Fatalf("ja subtitle stage not marked done: %#v", langManifest.Stages)
+	}
+}
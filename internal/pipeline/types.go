@@ -5,14 +5,16 @@ import "encoding/json"
 type Stage string
 
 const (
-	StageSubtitle         Stage = "subtitle"
-	StageTTS              Stage = "tts"
-	StageRenderHorizontal Stage = "render-horizontal"
-	StageRenderVertical   Stage = "render-vertical"
-	StageCover            Stage = "cover"
-	StagePipeline         Stage = "pipeline"
-	StageUpdate           Stage = "update"
-	StageVoices           Stage = "voices"
+	StageSubtitle          Stage = "subtitle"
+	StageTTS               Stage = "tts"
+	StageRenderHorizontal  Stage = "render-horizontal"
+	StageRenderVertical    Stage = "render-vertical"
+	StageCover             Stage = "cover"
+	StagePipeline          Stage = "pipeline"
+	StageUpdate            Stage = "update"
+	StageVoices            Stage = "voices"
+	StageStatusQuery       Stage = "status"
+	StageTranslationMemory Stage = "tm"
 )
 
 type CaptionSource string
@@ -89,8 +91,14 @@ type Outputs struct {
 	FinalCoverPrompt    string `json:"cover_prompt,omitempty"`
 	OriginText          string `json:"origin_text,omitempty"`
 	TargetText          string `json:"target_text,omitempty"`
+	ReviewJSON          string `json:"review_json,omitempty"` // 低置信度字幕复核清单，转录服务未提供置信度时为空
+	ReviewHTML          string `json:"review_html,omitempty"`
+	GlossaryReport      string `json:"glossary_report,omitempty"` // 重试后仍未按术语表翻译的句子，没有时为空
 }
 
+// LanguageOutputs 按目标语言记录的产物，key为语言代码
+type LanguageOutputs map[string]Outputs
+
 type Voice struct {
 	Code     string `json:"code"`
 	Name     string `json:"name,omitempty"`
@@ -109,8 +117,11 @@ type Response struct {
 	Inputs        map[string]string `json:"inputs,omitempty"`
 	Voices        []Voice           `json:"voices,omitempty"`
 	Outputs       Outputs           `json:"outputs,omitempty"`
+	Languages     LanguageOutputs   `json:"languages,omitempty"`
 	Warnings      []string          `json:"warnings,omitempty"`
 	FailedIndexes []int             `json:"failed_indexes,omitempty"`
+	Stages        []StageResult     `json:"stages,omitempty"`
+	Run           *RunStatus        `json:"run,omitempty"`
 	Error         *Error            `json:"error,omitempty"`
 	DurationMS    int64             `json:"duration_ms,omitempty"`
 }
@@ -1,6 +1,7 @@
 package router
 
 import (
+	"krillin-ai/config"
 	"krillin-ai/internal/handler"
 	"krillin-ai/static"
 	"net/http"
@@ -12,14 +13,23 @@ func SetupRouter(r *gin.Engine) {
 	api := r.Group("/api")
 
 	hdl := handler.NewHandler()
+	auth := handler.NewAuth(config.Conf.Server)
+	task := api.Group("", auth.Require(config.ScopeTask))
 	{
-		api.POST("/capability/subtitleTask", hdl.StartSubtitleTask)
-		api.GET("/capability/subtitleTask", hdl.GetSubtitleTask)
-		api.POST("/file", hdl.UploadFile)
-		api.GET("/file/*filepath", hdl.DownloadFile)
-		api.HEAD("/file/*filepath", hdl.DownloadFile)
-		api.GET("/config", hdl.GetConfig)
-		api.POST("/config", hdl.UpdateConfig)
+		task.POST("/capability/subtitleTask", hdl.StartSubtitleTask)
+		task.GET("/capability/subtitleTask", hdl.GetSubtitleTask)
+		task.DELETE("/capability/subtitleTask", hdl.CancelSubtitleTask) // 取消任务，保留任务记录和目录
+		task.GET("/capability/subtitleTask/events", hdl.StreamSubtitleTaskEvents)
+		task.GET("/capability/subtitleTasks", hdl.ListSubtitleTasks)
+		task.DELETE("/capability/subtitleTasks", hdl.DeleteSubtitleTask) // 删除已结束的任务及其目录
+		task.POST("/file", hdl.UploadFile)
+		task.GET("/file/*filepath", hdl.DownloadFile)
+		task.HEAD("/file/*filepath", hdl.DownloadFile)
+	}
+	settings := api.Group("", auth.Require(config.ScopeConfig))
+	{
+		settings.GET("/config", hdl.GetConfig)
+		settings.POST("/config", hdl.UpdateConfig)
 	}
 
 	r.GET("/", func(c *gin.Context) {
@@ -0,0 +1,47 @@
+package router
+
+import (
+	"encoding/json"
+	"krillin-ai/internal/storage"
+	"krillin-ai/internal/types"
+	"krillin-ai/log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSubtitleTaskCancelAndDeleteRoutes(t *testing.T) {
+	log.InitLogger()
+	previous := storage.SubtitleTasks
+	storage.SubtitleTasks = storage.NewMemoryTaskStore()
+	t.Cleanup(func() { storage.SubtitleTasks = previous })
+	_ = storage.SubtitleTasks.Save(&types.SubtitleTask{TaskId: "route_demo", Status: types.SubtitleTaskStatusSuccess})
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	SetupRouter(engine)
+	request := func(method, path string) (int, map[string]any) {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		var body map[string]any
+		_ = json.Unmarshal(w.Body.Bytes(), &body)
+		return w.Code, body
+	}
+
+	// DELETE subtitleTask只取消任务，已结束的任务和输出保持不变
+	if _, body := request(http.MethodDelete, "/api/capability/subtitleTask?taskId=route_demo"); body["error"] != float64(-1) {
+		t.Fatalf("cancel response = %v, want error for finished task", body)
+	}
+	if _, err := storage.SubtitleTasks.Get("route_demo"); err != nil {
+		t.Fatalf("cancel removed the task: %v", err)
+	}
+
+	if _, body := request(http.MethodDelete, "/api/capability/subtitleTasks?taskId=route_demo"); body["error"] != float64(0) {
+		t.Fatalf("delete response = %v", body)
+	}
+	if _, err := storage.SubtitleTasks.Get("route_demo"); err == nil {
+		t.Fatal("task still stored after delete")
+	}
+}
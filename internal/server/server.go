@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"krillin-ai/config"
 	"krillin-ai/internal/router"
+	"krillin-ai/internal/service"
+	"krillin-ai/internal/storage"
 	"krillin-ai/log"
 	"net/http"
 
@@ -15,6 +17,17 @@ import (
 var BackEnd *http.Server
 
 func StartBackend() error {
+	interrupted, err := storage.InitSubtitleTaskStore(config.Conf.Server.TaskStore, config.Conf.Server.TaskStoreDir)
+	if err != nil {
+		log.GetLogger().Error("初始化任务存储失败", zap.Error(err))
+		return err
+	}
+	if interrupted > 0 {
+		log.GetLogger().Warn("已将中断的任务标记为失败", zap.Int("count", interrupted))
+	}
+	retentionCtx, stopRetention := context.WithCancel(context.Background())
+	defer stopRetention()
+	service.StartTaskRetention(retentionCtx, config.Conf.Server.Retention)
 	gin.SetMode(gin.ReleaseMode)
 	engine := gin.Default()
 	router.SetupRouter(engine)
@@ -24,7 +37,7 @@ func StartBackend() error {
 	}
 	log.GetLogger().Info("服务启动", zap.String("host", config.Conf.Server.Host), zap.Int("port", config.Conf.Server.Port))
 	// return engine.Run(fmt.Sprintf("%s:%d", config.Conf.Server.Host, config.Conf.Server.Port))
-	err := BackEnd.ListenAndServe()
+	err = BackEnd.ListenAndServe()
 	if err != nil && err != http.ErrServerClosed {
 		log.GetLogger().Error("服务启动失败", zap.Error(err))
 		return err
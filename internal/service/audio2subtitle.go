@@ -9,7 +9,9 @@ import (
 	"krillin-ai/config"
 	"krillin-ai/internal/types"
 	"krillin-ai/log"
+	"krillin-ai/pkg/diarization"
 	"krillin-ai/pkg/util"
+	"maps"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -32,8 +34,9 @@ func filterStandaloneQuotes(text string) string {
 
 // 翻译结果数据结构
 type TranslatedItem struct {
-	OriginText     string
-	TranslatedText string
+	OriginText         string
+	TranslatedText     string
+	GlossaryViolations []types.GlossaryTerm `json:",omitempty"` // 重试后译文仍未遵守的术语
 }
 
 // 泛型数据结构，用于携带ID的数据
@@ -110,7 +113,7 @@ func (s Service) audioToSubtitle(ctx context.Context, stepParam *types.SubtitleT
 //	return nil
 //}
 
-func (s Service) transcribeAudio(id int, audioFilePath string, language string, taskBasePath string) (transcriptionData *types.TranscriptionData, err error) {
+func (s Service) transcribeAudio(ctx context.Context, id int, audioFilePath string, language string, hint types.TranscriptionHint, taskBasePath string, noCache bool, speakers *diarization.Registry) (transcriptionData *types.TranscriptionData, err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			err = fmt.Errorf("audioToSubtitle transcribeAudio panic recovered: %v", r)
@@ -120,13 +123,28 @@ func (s Service) transcribeAudio(id int, audioFilePath string, language string,
 	if language == "zh_cn" {
 		language = "zh" // 切换一下
 	}
-	transcriptionData, err = s.Transcriber.Transcription(audioFilePath, language, taskBasePath)
+	transcriptionData, err = s.transcribeWithCache(ctx, audioFilePath, language, hint, taskBasePath, noCache)
 
 	if err != nil {
 		return nil, fmt.Errorf("audioToSubtitle transcribeAudio Transcription err: %w", err)
 	}
+	if err = s.filterHallucinations(ctx, id, audioFilePath, taskBasePath, language, transcriptionData); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		log.GetLogger().Warn("audioToSubtitle transcribeAudio filter hallucinations failed", zap.Any("audioFilePath", audioFilePath), zap.Error(err))
+	}
+	if err = s.diarizeTranscription(ctx, audioFilePath, taskBasePath, transcriptionData, speakers); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		// 说话人识别失败不影响字幕生成
+		log.GetLogger().Warn("audioToSubtitle transcribeAudio diarize failed", zap.Any("audioFilePath", audioFilePath), zap.Error(err))
+	}
 
-	_ = util.SaveToDisk(transcriptionData, filepath.Join(taskBasePath, fmt.Sprintf(types.SubtitleTaskAudioTranscriptionDataPersistenceFileNamePattern, id)))
+	transcriptionFile := filepath.Join(taskBasePath, fmt.Sprintf(types.SubtitleTaskAudioTranscriptionDataPersistenceFileNamePattern, id))
+	_ = util.SaveToDisk(transcriptionData, transcriptionFile)
+	log.GetLogger().Info("audioToSubtitle transcribeAudio saved", zap.String("file", transcriptionFile), zap.String("provider", transcriptionData.Provider))
 
 	if transcriptionData.Text == "" {
 		log.GetLogger().Info("audioToSubtitle transcribeAudio TranscriptionData.Text is empty", zap.Any("audioFilePath", audioFilePath), zap.Any("taskBasePath", taskBasePath))
@@ -143,8 +161,12 @@ func IsSplitUseSpace(language types.StandardLanguageCode) bool {
 	return false
 }
 
-func (s Service) splitTextAndTranslateV2(basePath, inputText string, originLang, targetLang types.StandardLanguageCode, enableModalFilter bool, id int) ([]*TranslatedItem, error) {
-	sentences := util.SplitTextSentences(inputText, config.Conf.App.MaxSentenceLength)
+func (s Service) splitTextAndTranslateV2(ctx context.Context, basePath, inputText string, originLang, targetLang types.StandardLanguageCode, enableModalFilter bool, glossary types.Glossary, id int) ([]*TranslatedItem, error) {
+	// 开启说话人识别时不同说话人的话已用换行分开，分别断句
+	var sentences []string
+	for _, turn := range strings.Split(inputText, speakerTurnSeparator) {
+		sentences = append(sentences, util.SplitTextSentences(turn, config.Conf.App.MaxSentenceLength)...)
+	}
 	if len(sentences) == 0 {
 		return []*TranslatedItem{}, nil
 	}
@@ -169,7 +191,7 @@ func (s Service) splitTextAndTranslateV2(basePath, inputText string, originLang,
 		}
 
 		// 递归拆分长句子直到满足长度要求，保持顺序
-		splitSentences, err := s.splitSentenceRecursively(sentence, 0, 5) // 最多5层递归
+		splitSentences, err := s.splitSentenceRecursively(ctx, sentence, 0, 5) // 最多5层递归
 		if err != nil {
 			log.GetLogger().Error("splitSentenceRecursively error", zap.Error(err), zap.Any("sentence", sentence))
 			// 如果拆分失败，直接添加原句子
@@ -185,6 +207,7 @@ func (s Service) splitTextAndTranslateV2(basePath, inputText string, originLang,
 		signal  = make(chan struct{}, config.Conf.App.TranslateParallelNum) // 控制最大并发数
 		wg      sync.WaitGroup
 		results = make([]*TranslatedItem, len(sentences))
+		memory  = openTranslationMemory()
 		// errChan = make(chan error, 1)
 		// mutex   sync.Mutex
 	)
@@ -196,6 +219,23 @@ func (s Service) splitTextAndTranslateV2(basePath, inputText string, originLang,
 		go func(index int, originText string) {
 			defer wg.Done()
 			defer func() { <-signal }()
+			if ctx.Err() != nil {
+				return
+			}
+
+			// 翻译记忆库中有相同的句子时直接复用，相近的句子作为参考译文
+			match, matched := lookupTranslationMemory(memory, glossary, originLang, targetLang, originText)
+			if matched && match.Exact {
+				results[index] = &TranslatedItem{
+					OriginText:     originText,
+					TranslatedText: match.Target,
+				}
+				return
+			}
+			var reference string
+			if matched {
+				reference = translationMemoryPromptSection(match)
+			}
 
 			contextSentenceNum := 3
 
@@ -226,9 +266,9 @@ func (s Service) splitTextAndTranslateV2(basePath, inputText string, originLang,
 				}
 			}
 
-			prompt := fmt.Sprintf(types.SplitTextWithContextPrompt, types.GetStandardLanguageName(targetLang), previousSentences, originText, nextSentences)
+			prompt := fmt.Sprintf(types.SplitTextWithContextPrompt, types.GetStandardLanguageName(targetLang), glossary.PromptSection(originText)+reference, previousSentences, originText, nextSentences)
 
-			translatedText, err := s.ChatCompleter.ChatCompletion(prompt)
+			translatedText, violations, err := translateWithGlossary(ctx, s.ChatCompleter, glossary, prompt, originText)
 			if err != nil {
 				log.GetLogger().Error("splitTextAndTranslateV2 llm translate error", zap.Error(err), zap.Any("original text", originText))
 				results[index] = &TranslatedItem{
@@ -236,10 +276,10 @@ func (s Service) splitTextAndTranslateV2(basePath, inputText string, originLang,
 					TranslatedText: originText,
 				}
 			} else {
-				translatedText = strings.TrimSpace(translatedText)
 				results[index] = &TranslatedItem{
-					OriginText:     originText,
-					TranslatedText: translatedText,
+					OriginText:         originText,
+					TranslatedText:     translatedText,
+					GlossaryViolations: violations,
 				}
 			}
 		}(i, sentence)
@@ -247,6 +287,9 @@ func (s Service) splitTextAndTranslateV2(basePath, inputText string, originLang,
 
 	wg.Wait()
 	// close(errChan)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	return results, nil
 }
@@ -260,23 +303,24 @@ func (s Service) audioToSrt(ctx context.Context, stepParam *types.SubtitleTaskSt
 	}()
 
 	log.GetLogger().Info("audioToSubtitle.audioToSrt start", zap.Any("taskId", stepParam.TaskId))
+	defer releaseSpeakerRegistry(stepParam.TaskId)
 
 	// 1. 获取分割点
-	timePoints, err := s.getSplitPointsForAudio(stepParam)
+	timePoints, err := s.getSplitPointsForAudio(ctx, stepParam)
 	if err != nil {
 		return err
 	}
-
-	// 2. 处理音频分段和转录以及翻译
-	audioSegments, err := s.processAudioSegments(ctx, stepParam, timePoints)
-	if err != nil {
+	if err := s.resolveOriginLanguage(ctx, stepParam, timePoints); err != nil {
 		return err
 	}
 
-	// 3. 合并字幕文件
-	if err := s.mergeSubtitleFiles(stepParam, audioSegments, len(timePoints)-1); err != nil {
+	// 2. 处理音频分段和转录以及翻译，合并字幕文件
+	if err := s.segmentsToSrt(ctx, stepParam, timePoints, nil); err != nil {
 		return err
 	}
+	if err := mergeHallucinationReports(stepParam.TaskBasePath, timePoints); err != nil {
+		log.GetLogger().Warn("audioToSubtitle audioToSrt merge hallucination reports err", zap.Any("taskId", stepParam.TaskId), zap.Error(err))
+	}
 
 	// 更新字幕任务信息
 	stepParam.TaskPtr.ProcessPct = 90
@@ -285,9 +329,39 @@ func (s Service) audioToSrt(ctx context.Context, stepParam *types.SubtitleTaskSt
 	return nil
 }
 
+// segmentsToSrt 转录、翻译各分段并合并为完整字幕，transcriptions中已有的分段直接使用其转录结果
+func (s Service) segmentsToSrt(ctx context.Context, stepParam *types.SubtitleTaskStepParam, timePoints []float64, transcriptions map[int]*types.TranscriptionData) error {
+	audioSegments, err := s.processAudioSegments(ctx, stepParam, timePoints, transcriptions)
+	if err != nil {
+		return err
+	}
+
+	if err := s.mergeSubtitleFiles(stepParam, audioSegments, len(timePoints)-1); err != nil {
+		return err
+	}
+	if _, err := mergeReviewReports(stepParam.TaskBasePath, len(timePoints)-1); err != nil {
+		log.GetLogger().Warn("audioToSubtitle audioToSrt merge review reports err", zap.Any("taskId", stepParam.TaskId), zap.Error(err))
+	}
+	if err := mergeGlossaryReport(stepParam.TaskBasePath, len(timePoints)-1); err != nil {
+		log.GetLogger().Warn("audioToSubtitle audioToSrt merge glossary report err", zap.Any("taskId", stepParam.TaskId), zap.Error(err))
+	}
+	if items, err := loadTranslatedItems(stepParam.TaskBasePath, len(timePoints)-1); err != nil {
+		log.GetLogger().Warn("audioToSubtitle audioToSrt load translation data err", zap.Any("taskId", stepParam.TaskId), zap.Error(err))
+	} else {
+		recordTranslationMemory(stepParam.TaskId, stepParam.OriginLanguage, stepParam.TargetLanguage, items)
+	}
+	return nil
+}
+
 // 获取音频分割点
-func (s Service) getSplitPointsForAudio(stepParam *types.SubtitleTaskStepParam) ([]float64, error) {
-	timePoints, err := GetSplitPoints(stepParam.AudioFilePath, float64(config.Conf.App.SegmentDuration)*60)
+func (s Service) getSplitPointsForAudio(ctx context.Context, stepParam *types.SubtitleTaskStepParam) ([]float64, error) {
+	if timePoints, ok := resumeTimePoints(stepParam); ok {
+		log.GetLogger().Info("audioToSubtitle getSplitPointsForAudio reuse checkpoint", zap.Any("taskId", stepParam.TaskId), zap.Any("timePoints", timePoints))
+		stepParam.TaskPtr.ProcessPct = 15
+		return timePoints, nil
+	}
+	publishTaskStage(stepParam.TaskPtr, TaskStageSplit)
+	timePoints, err := GetSplitPoints(ctx, stepParam.AudioFilePath, float64(config.Conf.App.SegmentDuration)*60)
 	if err != nil {
 		log.GetLogger().Error("audioToSubtitle getSplitPointsForAudio err", zap.Any("taskId", stepParam.TaskId), zap.Error(err))
 		return nil, fmt.Errorf("audioToSubtitle getSplitPointsForAudio err: %w", err)
@@ -299,8 +373,8 @@ func (s Service) getSplitPointsForAudio(stepParam *types.SubtitleTaskStepParam)
 	return timePoints, nil
 }
 
-// 处理音频分段和转录
-func (s Service) processAudioSegments(ctx context.Context, stepParam *types.SubtitleTaskStepParam, timePoints []float64) ([]AudioSegment, error) {
+// 处理音频分段和转录，transcriptions为其他目标语言已完成的转录结果，对应分段不再切分和转录
+func (s Service) processAudioSegments(ctx context.Context, stepParam *types.SubtitleTaskStepParam, timePoints []float64, transcriptions map[int]*types.TranscriptionData) ([]AudioSegment, error) {
 	segmentNum := len(timePoints) - 1
 
 	pendingSplitQueue := make(chan DataWithId[[2]float64], segmentNum)
@@ -310,7 +384,20 @@ func (s Service) processAudioSegments(ctx context.Context, stepParam *types.Subt
 	pendingTranslationQueue := make(chan DataWithId[string], segmentNum)
 	translatedQueue := make(chan DataWithId[[]*TranslatedItem], segmentNum)
 
-	eg, ctx := errgroup.WithContext(ctx)
+	// 读取可复用的分段产物，并记录本次设置供下次断点续跑校验
+	var previous *resumeCheckpoint
+	if stepParam.Resume {
+		previous, _ = loadResumeCheckpoint(stepParam.TaskBasePath)
+	}
+	checkpoint := newResumeCheckpoint(stepParam, timePoints)
+	artifacts := loadSegmentArtifacts(stepParam, previous, checkpoint)
+	maps.Copy(artifacts.transcriptions, transcriptions)
+	if err := saveResumeCheckpoint(stepParam.TaskBasePath, checkpoint); err != nil {
+		log.GetLogger().Warn("audioToSubtitle processAudioSegments save checkpoint err", zap.Any("taskId", stepParam.TaskId), zap.Error(err))
+		publishTaskWarning(stepParam.TaskPtr, TaskStageSplit, "保存断点续跑信息失败，任务中断后将无法复用已完成的分段")
+	}
+
+	eg, workerCtx := errgroup.WithContext(ctx)
 
 	// 构造音频片段切片
 	audioSegments := make([]AudioSegment, segmentNum)
@@ -324,16 +411,16 @@ func (s Service) processAudioSegments(ctx context.Context, stepParam *types.Subt
 	}
 
 	// 启动分割协程
-	s.startSplitWorkers(ctx, eg, stepParam, pendingSplitQueue, splitResultQueue)
+	s.startSplitWorkers(workerCtx, eg, stepParam, artifacts, pendingSplitQueue, splitResultQueue)
 
 	// 启动转录协程
-	s.startTranscribeWorkers(ctx, eg, stepParam, pendingTranscriptionQueue, transcribedQueue)
+	s.startTranscribeWorkers(workerCtx, eg, stepParam, artifacts, pendingTranscriptionQueue, transcribedQueue)
 
 	// 启动翻译协程
-	s.startTranslateWorker(ctx, eg, stepParam, pendingTranslationQueue, translatedQueue)
+	s.startTranslateWorker(workerCtx, eg, stepParam, artifacts, pendingTranslationQueue, translatedQueue)
 
 	// 处理结果协程
-	s.startResultHandler(ctx, eg, stepParam, segmentNum, timePoints, audioSegments,
+	s.startResultHandler(workerCtx, eg, stepParam, segmentNum, timePoints, audioSegments,
 		splitResultQueue, pendingTranscriptionQueue, transcribedQueue,
 		pendingTranslationQueue, translatedQueue, pendingSplitQueue)
 
@@ -341,12 +428,16 @@ func (s Service) processAudioSegments(ctx context.Context, stepParam *types.Subt
 		log.GetLogger().Error("audioToSubtitle processAudioSegments errgroup wait err", zap.Any("taskId", stepParam.TaskId), zap.Error(err))
 		return nil, fmt.Errorf("audioToSubtitle processAudioSegments errgroup wait err: %w", err)
 	}
+	// 任务被取消时各协程直接退出，分段结果不完整
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	return audioSegments, nil
 }
 
 // 启动分割工作协程
-func (s Service) startSplitWorkers(ctx context.Context, eg *errgroup.Group, stepParam *types.SubtitleTaskStepParam,
+func (s Service) startSplitWorkers(ctx context.Context, eg *errgroup.Group, stepParam *types.SubtitleTaskStepParam, artifacts *segmentArtifacts,
 	pendingSplitQueue chan DataWithId[[2]float64], splitResultQueue chan DataWithId[string]) {
 
 	for range runtime.NumCPU() {
@@ -359,14 +450,17 @@ func (s Service) startSplitWorkers(ctx context.Context, eg *errgroup.Group, step
 					if !ok {
 						return nil
 					}
-					log.GetLogger().Info("Begin split audio", zap.Any("taskId", stepParam.TaskId), zap.Any("splitId", splitItem.Id))
-					// 分割音频
 					outputFileName := filepath.Join(stepParam.TaskBasePath, fmt.Sprintf(types.SubtitleTaskSplitAudioFileNamePattern, splitItem.Id))
-					err := ClipAudio(stepParam.AudioFilePath, outputFileName, splitItem.Data[0], splitItem.Data[1])
-					if err != nil {
-						return fmt.Errorf("audioToSubtitle audioToSrt ClipAudio err: %w", err)
+					// 已有转录结果的分段不需要再切分音频
+					if _, ok := artifacts.transcriptions[splitItem.Id]; !ok {
+						log.GetLogger().Info("Begin split audio", zap.Any("taskId", stepParam.TaskId), zap.Any("splitId", splitItem.Id))
+						// 分割音频
+						err := ClipAudio(ctx, stepParam.AudioFilePath, outputFileName, splitItem.Data[0], splitItem.Data[1])
+						if err != nil {
+							return fmt.Errorf("audioToSubtitle audioToSrt ClipAudio err: %w", err)
+						}
+						log.GetLogger().Info("Split audio completed", zap.Any("taskId", stepParam.TaskId), zap.Any("splitId", splitItem.Id))
 					}
-					log.GetLogger().Info("Split audio completed", zap.Any("taskId", stepParam.TaskId), zap.Any("splitId", splitItem.Id))
 
 					// 发送分割结果
 					splitResultQueue <- DataWithId[string]{
@@ -380,7 +474,7 @@ func (s Service) startSplitWorkers(ctx context.Context, eg *errgroup.Group, step
 }
 
 // 启动转录工作协程
-func (s Service) startTranscribeWorkers(ctx context.Context, eg *errgroup.Group, stepParam *types.SubtitleTaskStepParam,
+func (s Service) startTranscribeWorkers(ctx context.Context, eg *errgroup.Group, stepParam *types.SubtitleTaskStepParam, artifacts *segmentArtifacts,
 	pendingTranscriptionQueue chan DataWithId[string], transcribedQueue chan DataWithId[*types.TranscriptionData]) {
 
 	for range config.Conf.App.TranscribeParallelNum {
@@ -393,6 +487,14 @@ func (s Service) startTranscribeWorkers(ctx context.Context, eg *errgroup.Group,
 					if !ok {
 						return nil
 					}
+					if cached, ok := artifacts.transcriptions[audioFileItem.Id]; ok {
+						log.GetLogger().Info("Reuse transcription result", zap.Any("taskId", stepParam.TaskId), zap.Any("splitId", audioFileItem.Id))
+						transcribedQueue <- DataWithId[*types.TranscriptionData]{
+							Data: cached,
+							Id:   audioFileItem.Id,
+						}
+						continue
+					}
 					var (
 						err               error
 						transcriptionData *types.TranscriptionData
@@ -400,8 +502,8 @@ func (s Service) startTranscribeWorkers(ctx context.Context, eg *errgroup.Group,
 					log.GetLogger().Info("Begin transcribe", zap.Any("taskId", stepParam.TaskId), zap.Any("splitId", audioFileItem.Id))
 					// 语音转文字
 					for range config.Conf.App.TranscribeMaxAttempts {
-						transcriptionData, err = s.transcribeAudio(audioFileItem.Id, audioFileItem.Data, string(stepParam.OriginLanguage), stepParam.TaskBasePath)
-						if err == nil {
+						transcriptionData, err = s.transcribeAudio(ctx, audioFileItem.Id, audioFileItem.Data, string(stepParam.OriginLanguage), stepParam.TranscriptionHint, stepParam.TaskBasePath, stepParam.NoTranscriptionCache, taskSpeakerRegistry(stepParam))
+						if err == nil || ctx.Err() != nil {
 							break
 						}
 					}
@@ -422,7 +524,7 @@ func (s Service) startTranscribeWorkers(ctx context.Context, eg *errgroup.Group,
 }
 
 // 启动翻译工作协程
-func (s Service) startTranslateWorker(ctx context.Context, eg *errgroup.Group, stepParam *types.SubtitleTaskStepParam,
+func (s Service) startTranslateWorker(ctx context.Context, eg *errgroup.Group, stepParam *types.SubtitleTaskStepParam, artifacts *segmentArtifacts,
 	pendingTranslationQueue chan DataWithId[string], translatedQueue chan DataWithId[[]*TranslatedItem]) {
 
 	eg.Go(func() error {
@@ -436,21 +538,26 @@ func (s Service) startTranslateWorker(ctx context.Context, eg *errgroup.Group, s
 				}
 				var translatedResults []*TranslatedItem
 				var err error
-				// 翻译文本
-				log.GetLogger().Info("Begin to translate", zap.Any("taskId", stepParam.TaskId), zap.Any("splitId", translateItem.Id))
-				for range config.Conf.App.TranslateMaxAttempts {
-					translatedResults, err = s.splitTextAndTranslateV2(stepParam.TaskBasePath, translateItem.Data, stepParam.OriginLanguage, stepParam.TargetLanguage, stepParam.EnableModalFilter, translateItem.Id)
-					if err == nil {
-						break
+				if cached, ok := artifacts.translations[translateItem.Id]; ok {
+					log.GetLogger().Info("Reuse translation result", zap.Any("taskId", stepParam.TaskId), zap.Any("splitId", translateItem.Id))
+					translatedResults = cached
+				} else {
+					// 翻译文本
+					log.GetLogger().Info("Begin to translate", zap.Any("taskId", stepParam.TaskId), zap.Any("splitId", translateItem.Id))
+					for range config.Conf.App.TranslateMaxAttempts {
+						translatedResults, err = s.splitTextAndTranslateV2(ctx, stepParam.TaskBasePath, translateItem.Data, stepParam.OriginLanguage, stepParam.TargetLanguage, stepParam.EnableModalFilter, stepParam.Glossary.ForLanguage(stepParam.TargetLanguage), translateItem.Id)
+						if err == nil || ctx.Err() != nil {
+							break
+						}
 					}
+					if err != nil {
+						return fmt.Errorf("audioToSubtitle audioToSrt splitTextAndTranslate err: %w", err)
+					}
+					_ = util.SaveToDisk(translatedResults, filepath.Join(stepParam.TaskBasePath, fmt.Sprintf(types.SubtitleTaskTranslationDataPersistenceFileNamePattern, translateItem.Id)))
+					log.GetLogger().Info("Translate completed", zap.Any("taskId", stepParam.TaskId), zap.Any("splitId", translateItem.Id))
 				}
-				if err != nil {
-					return fmt.Errorf("audioToSubtitle audioToSrt splitTextAndTranslate err: %w", err)
-				}
-				_ = util.SaveToDisk(translatedResults, filepath.Join(stepParam.TaskBasePath, fmt.Sprintf(types.SubtitleTaskTranslationDataPersistenceFileNamePattern, translateItem.Id)))
-				log.GetLogger().Info("Translate completed", zap.Any("taskId", stepParam.TaskId), zap.Any("splitId", translateItem.Id))
 				// 二次分割长句
-				splitResults, err := s.splitTranslateItem(translatedResults)
+				splitResults, err := s.splitTranslateItem(ctx, translatedResults)
 				if err != nil {
 					// 不中断
 					log.GetLogger().Error("audioToSubtitle audioToSrt splitTranslateItem err", zap.Any("taskId", stepParam.TaskId), zap.Any("splitId", translateItem.Id), zap.Error(err))
@@ -488,6 +595,8 @@ func (s Service) startResultHandler(ctx context.Context, eg *errgroup.Group, ste
 		processPct := 15.0
 		// 完成的任务数量
 		completedTasks := 0
+		transcribedNum, translatedNum := 0, 0
+		publishTaskStage(stepParam.TaskPtr, TaskStageTranscribe)
 		for {
 			select {
 			case <-ctx.Done():
@@ -507,17 +616,21 @@ func (s Service) startResultHandler(ctx context.Context, eg *errgroup.Group, ste
 				// 更新字幕任务信息
 				processPct += taskWeight * TRANSCRIBE_WEIGHT
 				stepParam.TaskPtr.ProcessPct = uint8(processPct)
+				transcribedNum++
+				publishTaskProgress(stepParam.TaskPtr, TaskStageTranscribe, transcribedNum, segmentNum)
 				// 处理转录结果
 				audioSegments[transcribedItem.Id].TranscriptionData = transcribedItem.Data
 				// 发送翻译任务
 				pendingTranslationQueue <- DataWithId[string]{
-					Data: transcribedItem.Data.Text,
+					Data: speakerTurnText(transcribedItem.Data),
 					Id:   transcribedItem.Id,
 				}
 			case translatedItems := <-translatedQueue:
 				// 更新字幕任务信息
 				processPct += taskWeight * TRANSLATE_WEIGHT
 				stepParam.TaskPtr.ProcessPct = uint8(processPct)
+				translatedNum++
+				publishTaskProgress(stepParam.TaskPtr, TaskStageTranslate, translatedNum, segmentNum)
 				// 处理翻译结果，保存不带时间戳的原始字幕
 				originNoTsSrtFileName := filepath.Join(stepParam.TaskBasePath, fmt.Sprintf(types.SubtitleTaskSplitSrtNoTimestampFileNamePattern, translatedItems.Id))
 				originNoTsSrtFile, err := os.Create(originNoTsSrtFileName)
@@ -726,6 +839,36 @@ func splitSrt(stepParam *types.SubtitleTaskStepParam) error {
 		stepParam.SubtitleInfos = append(stepParam.SubtitleInfos, subtitleInfo)
 	}
 
+	// 添加低置信度字幕复核清单
+	reviewFilePath := filepath.Join(stepParam.TaskBasePath, types.SubtitleTaskReviewHtmlFileName)
+	if _, err = os.Stat(reviewFilePath); err == nil {
+		subtitleInfo = types.SubtitleFileInfo{
+			Path:               reviewFilePath,
+			LanguageIdentifier: "review",
+		}
+		if stepParam.UserUILanguage == types.LanguageNameEnglish {
+			subtitleInfo.Name = "Low-confidence Review"
+		} else if stepParam.UserUILanguage == types.LanguageNameSimplifiedChinese {
+			subtitleInfo.Name = "低置信度字幕复核清单"
+		}
+		stepParam.SubtitleInfos = append(stepParam.SubtitleInfos, subtitleInfo)
+	}
+
+	// 添加未按术语表翻译的句子报告
+	glossaryReportPath := filepath.Join(stepParam.TaskBasePath, types.SubtitleTaskGlossaryReportFileName)
+	if _, err = os.Stat(glossaryReportPath); err == nil {
+		subtitleInfo = types.SubtitleFileInfo{
+			Path:               glossaryReportPath,
+			LanguageIdentifier: "glossary",
+		}
+		if stepParam.UserUILanguage == types.LanguageNameEnglish {
+			subtitleInfo.Name = "Glossary Report"
+		} else if stepParam.UserUILanguage == types.LanguageNameSimplifiedChinese {
+			subtitleInfo.Name = "术语表检查报告"
+		}
+		stepParam.SubtitleInfos = append(stepParam.SubtitleInfos, subtitleInfo)
+	}
+
 	// 供生成配音使用
 	stepParam.TtsSourceFilePath = targetSRTPathForDubbing(stepParam.TaskBasePath)
 
@@ -1117,6 +1260,13 @@ func generateSrtWithTimestamps(srtBlocks []*util.SrtBlock, tsOffset float64, wor
 		lastTs = ts
 	}
 
+	if config.Conf.Transcribe.Diarization.Labels != "none" && hasSpeakers(words) {
+		labelSpeakers(srtBlocks, shortOriginSrtMap, words, tsOffset)
+	}
+	if err := saveSegmentReview(stepParam.TaskBasePath, segmentIdx, newSrtBlocks, words, tsOffset); err != nil {
+		log.GetLogger().Warn("audioToSubtitle generateSrtWithTimestamps save review err", zap.Any("taskId", stepParam.TaskId), zap.Int("segment", segmentIdx), zap.Error(err))
+	}
+
 	// 保存带时间戳的原始字幕
 	finalBilingualSrtFileName := fmt.Sprintf("%s/%s", stepParam.TaskBasePath, fmt.Sprintf(types.SubtitleTaskSplitBilingualSrtFileNamePattern, segmentIdx))
 	finalBilingualSrtFile, err := os.Create(finalBilingualSrtFileName)
@@ -1291,7 +1441,7 @@ func calcLength(text string) float64 {
 }
 
 // splitTranslateItem 根据字符权重和最大长度分割长句
-func (s Service) splitTranslateItem(items []*TranslatedItem) ([]*TranslatedItem, error) {
+func (s Service) splitTranslateItem(ctx context.Context, items []*TranslatedItem) ([]*TranslatedItem, error) {
 	var result []*TranslatedItem
 	maxLength := config.Conf.App.MaxSentenceLength + 30
 
@@ -1304,7 +1454,7 @@ func (s Service) splitTranslateItem(items []*TranslatedItem) ([]*TranslatedItem,
 
 		// 调用大模型进行分割
 		log.GetLogger().Info("splitTranslateItem long sentence detected, need split", zap.Any("item", item))
-		splitItems, err := s.splitLongSentence(item)
+		splitItems, err := s.splitLongSentence(ctx, item)
 		if err != nil {
 			log.GetLogger().Error("splitTranslateItem splitLongSentence error", zap.Error(err), zap.Any("item", item))
 			return nil, fmt.Errorf("split long sentence error: %w", err)
@@ -1316,10 +1466,10 @@ func (s Service) splitTranslateItem(items []*TranslatedItem) ([]*TranslatedItem,
 }
 
 // splitLongSentence 使用大模型分割长句并保持原文和译文对齐
-func (s Service) splitLongSentence(item *TranslatedItem) ([]*TranslatedItem, error) {
+func (s Service) splitLongSentence(ctx context.Context, item *TranslatedItem) ([]*TranslatedItem, error) {
 	prompt := fmt.Sprintf(types.SplitLongSentencePrompt, item.OriginText, item.TranslatedText)
 
-	response, err := s.ChatCompleter.ChatCompletion(prompt)
+	response, err := s.ChatCompleter.ChatCompletion(ctx, types.ChatRequest{UseCase: config.LlmUseCaseSplit, Prompt: prompt, JSONSchema: types.SentenceAlignSchema})
 	if err != nil {
 		return nil, fmt.Errorf("chat completion error: %w", err)
 	}
@@ -1347,7 +1497,7 @@ func (s Service) splitLongSentence(item *TranslatedItem) ([]*TranslatedItem, err
 	return splitItems, nil
 }
 
-func (s Service) splitOriginLongSentence(sentence string) ([]string, error) {
+func (s Service) splitOriginLongSentence(ctx context.Context, sentence string) ([]string, error) {
 	prompt := fmt.Sprintf(types.SplitOriginLongSentencePrompt, sentence)
 	if len(sentence) > 200 {
 		prompt = fmt.Sprintf(types.SplitLongTextByMeaningPrompt, sentence)
@@ -1358,9 +1508,12 @@ func (s Service) splitOriginLongSentence(sentence string) ([]string, error) {
 	shortSentences := make([]string, 0)
 	// 尝试调用3次
 	for i := range 3 {
-		response, err = s.ChatCompleter.ChatCompletion(prompt)
+		response, err = s.ChatCompleter.ChatCompletion(ctx, types.ChatRequest{UseCase: config.LlmUseCaseSplit, Prompt: prompt, JSONSchema: types.ShortSentencesSchema})
 		if err != nil {
 			log.GetLogger().Error("splitOriginLongSentence chat completion error", zap.Error(err), zap.String("sentence", sentence), zap.Any("time", i))
+			if !types.IsRetryableChatError(err) {
+				break
+			}
 			continue
 		}
 		var splitResult struct {
@@ -1389,7 +1542,7 @@ func (s Service) splitOriginLongSentence(sentence string) ([]string, error) {
 }
 
 // splitSentenceRecursively 递归拆分句子，保持顺序
-func (s Service) splitSentenceRecursively(sentence string, depth int, maxDepth int) ([]string, error) {
+func (s Service) splitSentenceRecursively(ctx context.Context, sentence string, depth int, maxDepth int) ([]string, error) {
 	// 防止无限递归
 	if depth >= maxDepth {
 		log.GetLogger().Warn("reached max split depth", zap.Any("sentence", sentence), zap.Int("depth", depth))
@@ -1403,7 +1556,7 @@ func (s Service) splitSentenceRecursively(sentence string, depth int, maxDepth i
 
 	// 调用大模型进行分割
 	log.GetLogger().Info("use llm split origin long sentence", zap.Any("sentence", sentence), zap.Int("depth", depth))
-	splitItems, err := s.splitOriginLongSentence(sentence)
+	splitItems, err := s.splitOriginLongSentence(ctx, sentence)
 	if err != nil {
 		log.GetLogger().Error("splitSentenceRecursively splitLongSentence error", zap.Error(err), zap.Any("sentence", sentence), zap.Int("depth", depth))
 		return []string{sentence}, nil // 返回原句子而不是错误
@@ -1417,7 +1570,7 @@ func (s Service) splitSentenceRecursively(sentence string, depth int, maxDepth i
 	// 递归处理每个拆分结果，保持顺序
 	var result []string
 	for _, item := range splitItems {
-		subResults, err := s.splitSentenceRecursively(item, depth+1, maxDepth)
+		subResults, err := s.splitSentenceRecursively(ctx, item, depth+1, maxDepth)
 		if err != nil {
 			log.GetLogger().Error("splitSentenceRecursively recursive error", zap.Error(err), zap.Any("item", item), zap.Int("depth", depth))
 			result = append(result, item) // 如果递归失败，添加原项
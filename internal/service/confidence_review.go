@@ -0,0 +1,222 @@
+package service
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"krillin-ai/config"
+	"krillin-ai/internal/types"
+	"krillin-ai/pkg/util"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// reviewCue 需要人工复核的低置信度字幕
+type reviewCue struct {
+	Index         int      `json:"index"` // 在最终双语字幕中的序号
+	Start         float64  `json:"start"`
+	End           float64  `json:"end"`
+	Timestamp     string   `json:"timestamp"`
+	OriginText    string   `json:"origin_text"`
+	TargetText    string   `json:"target_text,omitempty"`
+	AvgConfidence float64  `json:"avg_confidence"`
+	MinConfidence float64  `json:"min_confidence"`
+	LowWords      []string `json:"low_words,omitempty"` // 置信度低于min_word_confidence的单词
+}
+
+type reviewReport struct {
+	MinAvgConfidence  float64     `json:"min_avg_confidence"`
+	MinWordConfidence float64     `json:"min_word_confidence"`
+	Cues              []reviewCue `json:"cues"`
+}
+
+// annotateConfidence 用时间上与字幕块重叠的单词计算字幕块的平均和最低置信度
+func annotateConfidence(srtBlocks []*util.SrtBlock, words []types.Word, tsOffset float64) {
+	for _, block := range srtBlocks {
+		block.AvgConfidence, block.MinConfidence = 0, 0
+		matched := blockConfidentWords(block, words, tsOffset)
+		for _, word := range matched {
+			block.AvgConfidence += word.Confidence
+			if block.MinConfidence == 0 || word.Confidence < block.MinConfidence {
+				block.MinConfidence = word.Confidence
+			}
+		}
+		if len(matched) > 0 {
+			block.AvgConfidence /= float64(len(matched))
+		}
+	}
+}
+
+// blockConfidentWords 与字幕块时间重叠且带置信度的单词
+func blockConfidentWords(block *util.SrtBlock, words []types.Word, tsOffset float64) []types.Word {
+	blockStart, blockEnd, ok := blockRange(block, tsOffset)
+	if !ok {
+		return nil
+	}
+	var matched []types.Word
+	for _, word := range words {
+		if word.Confidence <= 0 {
+			continue
+		}
+		if math.Min(word.End, blockEnd)-math.Max(word.Start, blockStart) > 0 {
+			matched = append(matched, word)
+		}
+	}
+	return matched
+}
+
+// saveSegmentReview 保存分段中需要复核的字幕，序号为字幕块在分段双语字幕中的位置。转录服务未提供置信度时不保存
+func saveSegmentReview(taskBasePath string, segmentIdx int, srtBlocks []*util.SrtBlock, words []types.Word, tsOffset float64) error {
+	cfg := config.Conf.Transcribe.Review
+	reportFile := filepath.Join(taskBasePath, fmt.Sprintf(types.SubtitleTaskReviewFileNamePattern, segmentIdx))
+	_ = os.Remove(reportFile) // 断点续跑时清理上次的结果
+	if !cfg.Enable {
+		return nil
+	}
+	annotateConfidence(srtBlocks, words, tsOffset)
+	report := reviewReport{MinAvgConfidence: cfg.MinAvgConfidence, MinWordConfidence: cfg.MinWordConfidence, Cues: make([]reviewCue, 0)}
+	hasConfidence := false
+	for i, block := range srtBlocks {
+		if block.AvgConfidence == 0 {
+			continue
+		}
+		hasConfidence = true
+		if block.AvgConfidence >= cfg.MinAvgConfidence && block.MinConfidence >= cfg.MinWordConfidence {
+			continue
+		}
+		start, end, _ := blockRange(block, 0)
+		cue := reviewCue{
+			Index:         i + 1,
+			Start:         start,
+			End:           end,
+			Timestamp:     block.Timestamp,
+			OriginText:    block.OriginLanguageSentence,
+			TargetText:    block.TargetLanguageSentence,
+			AvgConfidence: block.AvgConfidence,
+			MinConfidence: block.MinConfidence,
+		}
+		for _, word := range blockConfidentWords(block, words, tsOffset) {
+			if word.Confidence < cfg.MinWordConfidence {
+				cue.LowWords = append(cue.LowWords, word.Text)
+			}
+		}
+		report.Cues = append(report.Cues, cue)
+	}
+	if !hasConfidence {
+		return nil
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(reportFile, data, 0644)
+}
+
+// mergeReviewReports 合并各分段的复核清单，按合并后双语字幕的编号重新编号，生成review.json和review.html。
+// 没有任何分段带置信度时返回false
+func mergeReviewReports(taskBasePath string, segmentNum int) (bool, error) {
+	cfg := config.Conf.Transcribe.Review
+	// 断点续跑时清理上次的结果
+	_ = os.Remove(filepath.Join(taskBasePath, types.SubtitleTaskReviewJsonFileName))
+	_ = os.Remove(filepath.Join(taskBasePath, types.SubtitleTaskReviewHtmlFileName))
+	if !cfg.Enable {
+		return false, nil
+	}
+	merged := reviewReport{MinAvgConfidence: cfg.MinAvgConfidence, MinWordConfidence: cfg.MinWordConfidence, Cues: make([]reviewCue, 0)}
+	found := false
+	offset := 0
+	for i := range segmentNum {
+		var report reviewReport
+		err := loadJSONArtifact(filepath.Join(taskBasePath, fmt.Sprintf(types.SubtitleTaskReviewFileNamePattern, i)), &report)
+		if err != nil && !os.IsNotExist(err) {
+			return false, err
+		}
+		if err == nil {
+			found = true
+			for _, cue := range report.Cues {
+				cue.Index += offset
+				merged.Cues = append(merged.Cues, cue)
+			}
+		}
+		cues, err := countSrtCues(filepath.Join(taskBasePath, fmt.Sprintf(types.SubtitleTaskSplitBilingualSrtFileNamePattern, i)))
+		if err != nil {
+			return false, err
+		}
+		offset += cues
+	}
+	if !found {
+		return false, nil
+	}
+	data, err := json.MarshalIndent(merged, "", "  ")
+	if err != nil {
+		return false, err
+	}
+	if err = os.WriteFile(filepath.Join(taskBasePath, types.SubtitleTaskReviewJsonFileName), data, 0644); err != nil {
+		return false, err
+	}
+	file, err := os.Create(filepath.Join(taskBasePath, types.SubtitleTaskReviewHtmlFileName))
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	return true, reviewHtmlTemplate.Execute(file, merged)
+}
+
+// countSrtCues 按util.MergeSrtFiles的编号方式统计字幕条数，分段文件不存在时为0
+func countSrtCues(file string) (int, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer f.Close()
+	count := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if util.IsNumber(scanner.Text()) {
+			count++
+		}
+	}
+	return count, scanner.Err()
+}
+
+var reviewHtmlTemplate = template.Must(template.New("review").Funcs(template.FuncMap{
+	"percent": func(v float64) string { return fmt.Sprintf("%.0f%%", v*100) },
+	"join":    strings.Join,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>字幕复核清单</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", "PingFang SC", sans-serif; margin: 24px; color: #222; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border-bottom: 1px solid #e5e5e5; padding: 8px; text-align: left; vertical-align: top; }
+th { background: #f6f6f6; }
+.time { font-family: monospace; white-space: nowrap; }
+.low { color: #c0392b; font-weight: bold; }
+.target { color: #666; }
+</style>
+</head>
+<body>
+<h2>字幕复核清单</h2>
+<p>共 {{len .Cues}} 条字幕需要复核：平均置信度低于 {{percent .MinAvgConfidence}}，或有单词置信度低于 {{percent .MinWordConfidence}}。</p>
+<table>
+<tr><th>#</th><th>时间</th><th>字幕</th><th>平均</th><th>最低</th><th>低置信度单词</th></tr>
+{{range .Cues}}<tr>
+<td>{{.Index}}</td>
+<td class="time">{{.Timestamp}}</td>
+<td>{{.OriginText}}{{if .TargetText}}<div class="target">{{.TargetText}}</div>{{end}}</td>
+<td>{{percent .AvgConfidence}}</td>
+<td class="low">{{percent .MinConfidence}}</td>
+<td class="low">{{join .LowWords ", "}}</td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`))
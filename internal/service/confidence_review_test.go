@@ -0,0 +1,108 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"krillin-ai/config"
+	"krillin-ai/internal/types"
+	"krillin-ai/pkg/util"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAnnotateConfidenceUsesOverlappingWords(t *testing.T) {
+	blocks := []*util.SrtBlock{
+		{Timestamp: "00:00:10,000 --> 00:00:11,000"},
+		{Timestamp: "00:00:11,000 --> 00:00:12,000"},
+	}
+	words := []types.Word{
+		{Text: "hello", Start: 0.1, End: 0.5, Confidence: 0.9},
+		{Text: "world", Start: 0.5, End: 0.9, Confidence: 0.5},
+		{Text: "again", Start: 1.1, End: 1.5}, // 没有置信度的单词不参与计算
+	}
+	annotateConfidence(blocks, words, 10)
+
+	if blocks[0].AvgConfidence < 0.699 || blocks[0].AvgConfidence > 0.701 || blocks[0].MinConfidence != 0.5 {
+		t.Fatalf("block 0 confidence = %v/%v", blocks[0].AvgConfidence, blocks[0].MinConfidence)
+	}
+	if blocks[1].AvgConfidence != 0 || blocks[1].MinConfidence != 0 {
+		t.Fatalf("block 1 confidence = %v/%v, want 0", blocks[1].AvgConfidence, blocks[1].MinConfidence)
+	}
+}
+
+func TestReviewReportsFlagLowConfidenceCues(t *testing.T) {
+	original := config.Conf.Transcribe
+	defer func() { config.Conf.Transcribe = original }()
+	config.Conf.Transcribe.Review = config.ReviewConfig{Enable: true, MinAvgConfidence: 0.6, MinWordConfidence: 0.3}
+
+	dir := t.TempDir()
+	// 第一个分段有两条字幕
+	if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf(types.SubtitleTaskSplitBilingualSrtFileNamePattern, 0)),
+		[]byte("1\n00:00:00,000 --> 00:00:01,000\nfirst\n第一\n\n2\n00:00:01,000 --> 00:00:02,000\nsecond\n第二\n\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveSegmentReview(dir, 0, []*util.SrtBlock{
+		{Timestamp: "00:00:00,000 --> 00:00:01,000", OriginLanguageSentence: "first"},
+		{Timestamp: "00:00:01,000 --> 00:00:02,000", OriginLanguageSentence: "second"},
+	}, []types.Word{
+		{Text: "first", Start: 0, End: 1, Confidence: 0.95},
+		{Text: "second", Start: 1, End: 2, Confidence: 0.9},
+	}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// 第二个分段从60秒开始，只有一个单词置信度过低
+	if err := saveSegmentReview(dir, 1, []*util.SrtBlock{
+		{Timestamp: "00:01:00,000 --> 00:01:02,000", OriginLanguageSentence: "krill in", TargetLanguageSentence: "磷虾"},
+	}, []types.Word{
+		{Text: "krill", Start: 0, End: 1, Confidence: 0.95},
+		{Text: "in", Start: 1, End: 2, Confidence: 0.2},
+	}, 60); err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err := mergeReviewReports(dir, 2)
+	if err != nil || !ok {
+		t.Fatalf("mergeReviewReports() = %v, %v", ok, err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, types.SubtitleTaskReviewJsonFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report reviewReport
+	if err = json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Cues) != 1 {
+		t.Fatalf("cues = %+v, want one", report.Cues)
+	}
+	cue := report.Cues[0]
+	// 序号要加上第一个分段的字幕条数，和合并后的双语字幕一致
+	if cue.Index != 3 || cue.Start != 60 || cue.End != 62 || strings.Join(cue.LowWords, ",") != "in" || cue.TargetText != "磷虾" {
+		t.Fatalf("cue = %+v", cue)
+	}
+	html, err := os.ReadFile(filepath.Join(dir, types.SubtitleTaskReviewHtmlFileName))
+	if err != nil || !strings.Contains(string(html), "krill in") {
+		t.Fatalf("review.html = %q, %v", html, err)
+	}
+}
+
+func TestSaveSegmentReviewSkipsWithoutConfidence(t *testing.T) {
+	original := config.Conf.Transcribe
+	defer func() { config.Conf.Transcribe = original }()
+	config.Conf.Transcribe.Review = config.ReviewConfig{Enable: true, MinAvgConfidence: 0.6, MinWordConfidence: 0.3}
+
+	dir := t.TempDir()
+	blocks := []*util.SrtBlock{{Timestamp: "00:00:00,000 --> 00:00:01,000", OriginLanguageSentence: "hello"}}
+	if err := saveSegmentReview(dir, 0, blocks, []types.Word{{Text: "hello", Start: 0, End: 1}}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := mergeReviewReports(dir, 1); err != nil || ok {
+		t.Fatalf("mergeReviewReports() = %v, %v, want no report", ok, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, types.SubtitleTaskReviewHtmlFileName)); !os.IsNotExist(err) {
+		t.Fatalf("review.html should not exist, err = %v", err)
+	}
+}
@@ -0,0 +1,341 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"krillin-ai/config"
+	"krillin-ai/internal/storage"
+	"krillin-ai/internal/types"
+	"krillin-ai/log"
+	"krillin-ai/pkg/diarization"
+	"krillin-ai/pkg/util"
+	"math"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+const (
+	utteranceGap         = 0.6  // 单词间停顿超过该秒数视为新的语音片段
+	speakerTurnSeparator = "\n" // 送去断句翻译的文本中，不同说话人之间用换行分隔
+)
+
+var (
+	speakerRegistries  sync.Map // task id -> *diarization.Registry
+	speakerLabelRegexp = regexp.MustCompile(`^\[S(\d+)\]\s*`)
+	// speakerAssColors 烧录字幕时S2开始依次使用的颜色(ASS为BGR顺序)，S1保持样式默认颜色
+	speakerAssColors = []string{"&H00FFFF&", "&HFFFF00&", "&H80FF80&", "&HFF80FF&", "&H0080FF&"}
+)
+
+// taskSpeakerRegistry 获取任务的说话人登记表，未开启说话人识别时返回nil，断点续跑时从任务目录恢复
+func taskSpeakerRegistry(stepParam *types.SubtitleTaskStepParam) *diarization.Registry {
+	cfg := config.Conf.Transcribe.Diarization
+	if !cfg.Enable {
+		return nil
+	}
+	if registry, ok := speakerRegistries.Load(stepParam.TaskId); ok {
+		return registry.(*diarization.Registry)
+	}
+	registry := diarization.NewRegistry(cfg.Threshold, cfg.MaxSpeakers)
+	if stepParam.Resume {
+		if loaded, err := diarization.LoadRegistry(filepath.Join(stepParam.TaskBasePath, types.SubtitleTaskSpeakerRegistryFileName)); err == nil {
+			loaded.Threshold, loaded.MaxSpeakers = cfg.Threshold, cfg.MaxSpeakers
+			registry = loaded
+		}
+	}
+	actual, _ := speakerRegistries.LoadOrStore(stepParam.TaskId, registry)
+	return actual.(*diarization.Registry)
+}
+
+func releaseSpeakerRegistry(taskId string) {
+	speakerRegistries.Delete(taskId)
+}
+
+// diarizeTranscription 给单词标注说话人。whisperx已给出分段内的说话人时沿用其分组，否则按声音特征本地聚类，
+// 再通过登记表映射为整条视频统一的S1、S2...
+func (s Service) diarizeTranscription(ctx context.Context, audioFile, taskBasePath string, data *types.TranscriptionData, registry *diarization.Registry) error {
+	if registry == nil || len(data.Words) == 0 {
+		return nil
+	}
+	pcm, err := decodePCM(ctx, audioFile)
+	if err != nil {
+		return fmt.Errorf("diarizeTranscription decode audio err: %w", err)
+	}
+	cfg := config.Conf.Transcribe.Diarization
+	groups := utteranceGroups(data.Words)
+	embeddings := make([][]float64, len(groups))
+	weights := make([]float64, len(groups))
+	for i, group := range groups {
+		span := diarization.Span{Start: data.Words[group[0]].Start, End: data.Words[group[len(group)-1]].End}
+		embeddings[i] = diarization.Embed(pcm, span)
+		weights[i] = span.Duration()
+	}
+
+	localLabels := make([]int, len(groups))
+	if hasSpeakers(data.Words) {
+		ids := make(map[string]int)
+		for i, group := range groups {
+			speaker := data.Words[group[0]].Speaker
+			if _, ok := ids[speaker]; !ok {
+				ids[speaker] = len(ids)
+			}
+			localLabels[i] = ids[speaker]
+		}
+	} else {
+		localLabels = diarization.Cluster(embeddings, cfg.Threshold, cfg.MinSpeakers, cfg.MaxSpeakers)
+	}
+
+	// 每个本地说话人取所有片段特征的加权中心，匹配到全局说话人
+	members := make(map[int][]int)
+	for i, label := range localLabels {
+		if label >= 0 {
+			members[label] = append(members[label], i)
+		}
+	}
+	globalLabels := make(map[int]string)
+	for label := 0; label < len(localLabels); label++ { // 按本地编号顺序登记，保证先出现的说话人编号靠前
+		indexes, ok := members[label]
+		if !ok {
+			continue
+		}
+		var (
+			groupEmbeddings [][]float64
+			groupWeights    []float64
+			totalWeight     float64
+		)
+		for _, i := range indexes {
+			groupEmbeddings = append(groupEmbeddings, embeddings[i])
+			groupWeights = append(groupWeights, weights[i])
+			totalWeight += weights[i]
+		}
+		if centroid := diarization.Centroid(groupEmbeddings, groupWeights); centroid != nil {
+			globalLabels[label] = registry.Assign(centroid, totalWeight)
+		}
+	}
+
+	groupSpeakers := make([]string, len(groups))
+	for i, label := range localLabels {
+		groupSpeakers[i] = globalLabels[label]
+	}
+	fillMissingSpeakers(groupSpeakers)
+	for i, group := range groups {
+		for _, w := range group {
+			data.Words[w].Speaker = groupSpeakers[i]
+		}
+	}
+	if err := registry.Save(filepath.Join(taskBasePath, types.SubtitleTaskSpeakerRegistryFileName)); err != nil {
+		log.GetLogger().Warn("diarizeTranscription save speaker registry failed", zap.Error(err))
+	}
+	return nil
+}
+
+// decodePCM 将音频解码为16k单声道采样
+func decodePCM(ctx context.Context, audioFile string) ([]float64, error) {
+	release, err := acquireFFmpeg(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	cmd := exec.CommandContext(ctx, storage.FfmpegPath, "-i", audioFile, "-f", "s16le", "-ac", "1", "-ar", strconv.Itoa(diarization.SampleRate), "-")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		log.GetLogger().Error("decodePCM ffmpeg err", zap.String("audioFile", audioFile), zap.String("output", stderr.String()), zap.Error(err))
+		return nil, err
+	}
+	return diarization.PCMFromS16LE(output), nil
+}
+
+// utteranceGroups 按停顿和已有的说话人标签把单词分成连续的语音片段，返回每个片段的单词下标
+func utteranceGroups(words []types.Word) [][]int {
+	var groups [][]int
+	for i, word := range words {
+		if i == 0 || word.Start-words[i-1].End > utteranceGap || word.Speaker != words[i-1].Speaker {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], i)
+	}
+	return groups
+}
+
+// fillMissingSpeakers 过短无法识别的片段沿用前一个片段的说话人，开头的沿用后一个
+func fillMissingSpeakers(speakers []string) {
+	for i := 1; i < len(speakers); i++ {
+		if speakers[i] == "" {
+			speakers[i] = speakers[i-1]
+		}
+	}
+	for i := len(speakers) - 2; i >= 0; i-- {
+		if speakers[i] == "" {
+			speakers[i] = speakers[i+1]
+		}
+	}
+}
+
+func hasSpeakers(words []types.Word) bool {
+	for _, word := range words {
+		if word.Speaker != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// speakerTurnText 在转录文本中说话人切换的位置插入换行，保证断句时不会把两个人的话合成一句
+func speakerTurnText(data *types.TranscriptionData) string {
+	if !hasSpeakers(data.Words) {
+		return data.Text
+	}
+	text := data.Text
+	var (
+		cuts []int
+		prev string
+	)
+	for i, pos := range wordOffsets(text, data.Words) {
+		word := data.Words[i]
+		if pos < 0 {
+			continue
+		}
+		if prev != "" && word.Speaker != "" && word.Speaker != prev {
+			cuts = append(cuts, pos)
+		}
+		if word.Speaker != "" {
+			prev = word.Speaker
+		}
+	}
+	if len(cuts) == 0 {
+		return text
+	}
+	turns := make([]string, 0, len(cuts)+1)
+	last := 0
+	for _, cut := range cuts {
+		turns = append(turns, strings.TrimSpace(text[last:cut]))
+		last = cut
+	}
+	turns = append(turns, strings.TrimSpace(text[last:]))
+	return strings.Join(turns, speakerTurnSeparator)
+}
+
+// wordOffsets 按顺序在转录文本中定位每个单词，返回单词在文本中的字节偏移，找不到的为-1
+func wordOffsets(text string, words []types.Word) []int {
+	haystack := strings.ToLower(text)
+	caseInsensitive := len(haystack) == len(text)
+	if !caseInsensitive {
+		haystack = text
+	}
+	offsets := make([]int, len(words))
+	cursor := 0
+	for i, word := range words {
+		offsets[i] = -1
+		needle := strings.TrimSpace(word.Text)
+		if caseInsensitive {
+			needle = strings.ToLower(needle)
+		}
+		if needle == "" {
+			continue
+		}
+		idx := strings.Index(haystack[cursor:], needle)
+		if idx < 0 {
+			continue
+		}
+		offsets[i] = cursor + idx
+		cursor = offsets[i] + len(needle)
+	}
+	return offsets
+}
+
+// blockSpeaker 取与字幕块时间重叠最多的说话人
+func blockSpeaker(block *util.SrtBlock, words []types.Word, tsOffset float64) string {
+	blockStart, blockEnd, ok := blockRange(block, tsOffset)
+	if !ok {
+		return ""
+	}
+	overlaps := make(map[string]float64)
+	var best string
+	for _, word := range words {
+		if word.Speaker == "" {
+			continue
+		}
+		overlap := math.Min(word.End, blockEnd) - math.Max(word.Start, blockStart)
+		if overlap <= 0 {
+			continue
+		}
+		overlaps[word.Speaker] += overlap
+		if best == "" || overlaps[word.Speaker] > overlaps[best] {
+			best = word.Speaker
+		}
+	}
+	return best
+}
+
+// blockRange 解析字幕块的时间轴，返回相对于分段开头的起止时间
+func blockRange(block *util.SrtBlock, tsOffset float64) (float64, float64, bool) {
+	parts := strings.Split(block.Timestamp, " --> ")
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	start, err := parseSrtTime(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, false
+	}
+	end, err := parseSrtTime(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return 0, 0, false
+	}
+	return start.Seconds() - tsOffset, end.Seconds() - tsOffset, true
+}
+
+// labelSpeakers 在字幕块及其短句前加上[S1]这样的说话人标签
+func labelSpeakers(srtBlocks []*util.SrtBlock, shortOriginSrtMap map[int][]util.SrtBlock, words []types.Word, tsOffset float64) {
+	for _, block := range srtBlocks {
+		speaker := blockSpeaker(block, words, tsOffset)
+		if speaker == "" {
+			continue
+		}
+		label := "[" + speaker + "] "
+		block.OriginLanguageSentence = label + block.OriginLanguageSentence
+		block.TargetLanguageSentence = label + block.TargetLanguageSentence
+		shortBlocks := shortOriginSrtMap[block.Index]
+		for i := range shortBlocks {
+			shortBlocks[i].OriginLanguageSentence = label + shortBlocks[i].OriginLanguageSentence
+		}
+	}
+}
+
+// speakerAssLines labels为ass时去掉字幕行中的说话人标签，返回颜色覆盖标签；其它方式原样返回
+func speakerAssLines(lines []string) ([]string, string) {
+	if config.Conf.Transcribe.Diarization.Labels != "ass" {
+		return lines, ""
+	}
+	var override string
+	stripped := make([]string, len(lines))
+	for i, line := range lines {
+		var lineOverride string
+		stripped[i], lineOverride = splitSpeakerAssOverride(line)
+		if override == "" {
+			override = lineOverride
+		}
+	}
+	return stripped, override
+}
+
+// splitSpeakerAssOverride 去掉字幕行开头的[S1]标签，返回对应说话人的ASS颜色覆盖标签
+func splitSpeakerAssOverride(line string) (string, string) {
+	match := speakerLabelRegexp.FindStringSubmatch(line)
+	if match == nil {
+		return line, ""
+	}
+	text := line[len(match[0]):]
+	index, _ := strconv.Atoi(match[1])
+	if index <= 1 {
+		return text, ""
+	}
+	return text, fmt.Sprintf("{\\c%s}", speakerAssColors[(index-2)%len(speakerAssColors)])
+}
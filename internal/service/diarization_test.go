@@ -0,0 +1,78 @@
+package service
+
+import (
+	"krillin-ai/config"
+	"krillin-ai/internal/types"
+	"krillin-ai/pkg/util"
+	"testing"
+)
+
+func TestSpeakerTurnTextSplitsAtSpeakerChanges(t *testing.T) {
+	data := &types.TranscriptionData{
+		Text: " How are you? Fine, thanks. And you?",
+		Words: []types.Word{
+			{Text: "How", Speaker: "S1"}, {Text: "are", Speaker: "S1"}, {Text: "you", Speaker: "S1"},
+			{Text: "Fine", Speaker: "S2"}, {Text: "thanks", Speaker: "S2"},
+			{Text: "And", Speaker: "S1"}, {Text: "you", Speaker: "S1"},
+		},
+	}
+	want := "How are you?\nFine, thanks.\nAnd you?"
+	if got := speakerTurnText(data); got != want {
+		t.Fatalf("speakerTurnText() = %q, want %q", got, want)
+	}
+
+	data.Words = []types.Word{{Text: "How"}}
+	if got := speakerTurnText(data); got != data.Text {
+		t.Fatalf("speakerTurnText() without speakers = %q", got)
+	}
+}
+
+func TestUtteranceGroupsAndFillMissingSpeakers(t *testing.T) {
+	words := []types.Word{
+		{Start: 0, End: 0.5}, {Start: 0.6, End: 1}, // 停顿很短，同一片段
+		{Start: 2, End: 2.5}, // 停顿超过阈值
+		{Start: 2.6, End: 3, Speaker: "SPEAKER_01"},
+	}
+	groups := utteranceGroups(words)
+	if len(groups) != 3 || len(groups[0]) != 2 || groups[2][0] != 3 {
+		t.Fatalf("groups = %v", groups)
+	}
+
+	speakers := []string{"", "S1", "", "S2", ""}
+	fillMissingSpeakers(speakers)
+	if speakers[0] != "S1" || speakers[2] != "S1" || speakers[4] != "S2" {
+		t.Fatalf("speakers = %v", speakers)
+	}
+}
+
+func TestLabelSpeakersUsesMajorityOverlap(t *testing.T) {
+	words := []types.Word{
+		{Text: "hi", Start: 0, End: 0.4, Speaker: "S1"},
+		{Text: "there", Start: 0.4, End: 2, Speaker: "S2"},
+	}
+	blocks := []*util.SrtBlock{{Index: 1, Timestamp: "00:00:10,000 --> 00:00:12,000", OriginLanguageSentence: "hi there", TargetLanguageSentence: "你好"}}
+	short := map[int][]util.SrtBlock{1: {{Index: 1, OriginLanguageSentence: "hi there"}}}
+	labelSpeakers(blocks, short, words, 10)
+	if blocks[0].OriginLanguageSentence != "[S2] hi there" || blocks[0].TargetLanguageSentence != "[S2] 你好" || short[1][0].OriginLanguageSentence != "[S2] hi there" {
+		t.Fatalf("labelled blocks = %+v, short = %+v", blocks[0], short[1])
+	}
+}
+
+func TestSpeakerAssLines(t *testing.T) {
+	backup := config.Conf.Transcribe.Diarization.Labels
+	defer func() { config.Conf.Transcribe.Diarization.Labels = backup }()
+
+	config.Conf.Transcribe.Diarization.Labels = "ass"
+	lines, override := speakerAssLines([]string{"[S2] 你好", "[S2] hello"})
+	if lines[0] != "你好" || lines[1] != "hello" || override != "{\\c&H00FFFF&}" {
+		t.Fatalf("lines = %v, override = %q", lines, override)
+	}
+	if _, override := speakerAssLines([]string{"[S1] hello"}); override != "" {
+		t.Fatalf("S1 override = %q, want default style", override)
+	}
+
+	config.Conf.Transcribe.Diarization.Labels = "prefix"
+	if lines, _ := speakerAssLines([]string{"[S2] hello"}); lines[0] != "[S2] hello" {
+		t.Fatalf("prefix mode should keep labels, got %v", lines)
+	}
+}
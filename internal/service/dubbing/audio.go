@@ -1,6 +1,7 @@
 package dubbing
 
 import (
+	"context"
 	"fmt"
 	"krillin-ai/internal/storage"
 	"math"
@@ -11,9 +12,23 @@ import (
 )
 
 func defaultFFmpegRunner(args []string) error {
-	cmd := exec.Command(storage.FfmpegPath, args...)
+	return runFFmpeg(context.Background(), args)
+}
+
+// contextFFmpegRunner returns a runner whose ffmpeg processes are killed when ctx is cancelled.
+func contextFFmpegRunner(ctx context.Context) CommandRunner {
+	return func(args []string) error {
+		return runFFmpeg(ctx, args)
+	}
+}
+
+func runFFmpeg(ctx context.Context, args []string) error {
+	cmd := exec.CommandContext(ctx, storage.FfmpegPath, args...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		return fmt.Errorf("ffmpeg error: %w, output: %s", err, string(output))
 	}
 	return nil
@@ -6,11 +6,13 @@ import (
 )
 
 var (
-	parenNoisePattern = regexp.MustCompile(`(?i)[(（][^()（）]*(music|applause|laughter|laugh|noise|sound|silence|inaudible|掌声|音乐|笑声|噪音|静音)[^()（）]*[)）]`)
-	spacePattern      = regexp.MustCompile(`\s+`)
+	speakerLabelPattern = regexp.MustCompile(`\[S\d+\]`)
+	parenNoisePattern   = regexp.MustCompile(`(?i)[(（][^()（）]*(music|applause|laughter|laugh|noise|sound|silence|inaudible|掌声|音乐|笑声|噪音|静音)[^()（）]*[)）]`)
+	spacePattern        = regexp.MustCompile(`\s+`)
 )
 
 func CleanTextForSpeech(text string) string {
+	text = speakerLabelPattern.ReplaceAllString(text, "")
 	text = parenNoisePattern.ReplaceAllString(text, "")
 	text = strings.ReplaceAll(text, "&", "")
 	text = strings.ReplaceAll(text, "®", "")
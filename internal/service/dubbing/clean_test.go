@@ -52,3 +52,9 @@ func TestIsSilenceOnlyTextDoesNotTreatMixedSpeechAsSilence(t *testing.T) {
 		}
 	}
 }
+
+func TestCleanTextForSpeechRemovesSpeakerLabels(t *testing.T) {
+	if got := CleanTextForSpeech("[S2] 你好，欢迎收听"); got != "你好，欢迎收听" {
+		t.Fatalf("CleanTextForSpeech() = %q", got)
+	}
+}
@@ -3,6 +3,7 @@ package dubbing
 import (
 	"context"
 	"fmt"
+	"krillin-ai/config"
 	"krillin-ai/internal/types"
 	"strings"
 )
@@ -16,10 +17,11 @@ func NewLLMOptimizer(chat types.ChatCompleter) *LLMOptimizer {
 }
 
 func (o *LLMOptimizer) Optimize(ctx context.Context, text string, availableSeconds float64, reason string) (string, error) {
-	if ctx != nil {
-		if err := ctx.Err(); err != nil {
-			return "", err
-		}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
 	}
 	if o == nil || o.chat == nil {
 		return text, nil
@@ -34,7 +36,7 @@ func (o *LLMOptimizer) Optimize(ctx context.Context, text string, availableSecon
 
 字幕：
 %s`, availableSeconds, reason, text)
-	resp, err := o.chat.ChatCompletion(prompt)
+	resp, err := o.chat.ChatCompletion(ctx, types.ChatRequest{UseCase: config.LlmUseCaseDubbing, Prompt: prompt})
 	if err != nil {
 		return "", err
 	}
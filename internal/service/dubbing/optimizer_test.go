@@ -2,17 +2,19 @@ package dubbing
 
 import (
 	"context"
+	"krillin-ai/config"
+	"krillin-ai/internal/types"
 	"testing"
 )
 
 type fakeChat struct {
 	response string
-	query    string
+	req      types.ChatRequest
 	err      error
 }
 
-func (f *fakeChat) ChatCompletion(query string) (string, error) {
-	f.query = query
+func (f *fakeChat) ChatCompletion(ctx context.Context, req types.ChatRequest) (string, error) {
+	f.req = req
 	return f.response, f.err
 }
 
@@ -26,7 +28,7 @@ func TestLLMOptimizerReturnsSingleLineTrimmedText(t *testing.T) {
 	if got != "更自然的说法" {
 		t.Fatalf("Optimize() = %q", got)
 	}
-	if chat.query == "" {
+	if chat.req.Prompt == "" || chat.req.UseCase != config.LlmUseCaseDubbing {
 		t.Fatalf("expected optimizer to call chat")
 	}
 }
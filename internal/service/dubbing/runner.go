@@ -28,12 +28,6 @@ func NewRunner(deps Dependencies) *Runner {
 	if deps.Config.MaxChunkSize <= 0 {
 		deps.Config = DefaultConfig()
 	}
-	if deps.FFmpeg == nil {
-		deps.FFmpeg = defaultFFmpegRunner
-	}
-	if deps.Duration == nil {
-		deps.Duration = util.GetAudioDuration
-	}
 	if deps.OutputAudio == "" && deps.Workdir != "" {
 		deps.OutputAudio = filepath.Join(deps.Workdir, types.TtsResultAudioFileName)
 	}
@@ -50,6 +44,16 @@ func (r *Runner) Run(ctx context.Context) (Result, error) {
 	if err := r.validate(); err != nil {
 		return Result{}, err
 	}
+	ffmpeg := r.deps.FFmpeg
+	if ffmpeg == nil {
+		ffmpeg = contextFFmpegRunner(ctx)
+	}
+	duration := r.deps.Duration
+	if duration == nil {
+		duration = func(file string) (float64, error) {
+			return util.GetAudioDuration(ctx, file)
+		}
+	}
 
 	cues, err := ParseSRTFile(r.deps.InputSRT)
 	if err != nil {
@@ -75,8 +79,11 @@ func (r *Runner) Run(ctx context.Context) (Result, error) {
 	if err != nil {
 		return Result{}, err
 	}
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
 
-	plan, chunks, err = GenerateRawChunkSegments(ctx, r.deps.TTS, plan, chunks, r.deps.Voice, segmentsDir, r.deps.FFmpeg, r.deps.Duration)
+	plan, chunks, err = GenerateRawChunkSegments(ctx, r.deps.TTS, plan, chunks, r.deps.Voice, segmentsDir, ffmpeg, duration, r.deps.Progress)
 	if err != nil {
 		return Result{}, err
 	}
@@ -100,7 +107,10 @@ func (r *Runner) Run(ctx context.Context) (Result, error) {
 	if err := ensureParentDir(r.deps.OutputAudio); err != nil {
 		return Result{}, err
 	}
-	if err := AssembleChunkAudio(fitted, fittedChunks, segmentsDir, r.deps.OutputAudio, r.deps.FFmpeg); err != nil {
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+	if err := AssembleChunkAudio(fitted, fittedChunks, segmentsDir, r.deps.OutputAudio, ffmpeg); err != nil {
 		return Result{}, err
 	}
 	if err := ensureNonEmptyFile(r.deps.OutputAudio, "output audio"); err != nil {
@@ -110,7 +120,7 @@ func (r *Runner) Run(ctx context.Context) (Result, error) {
 	if err := ensureParentDir(r.deps.OutputVideo); err != nil {
 		return Result{}, err
 	}
-	if err := r.deps.FFmpeg(buildMuxArgs(r.deps.InputVideo, r.deps.OutputAudio, r.deps.OutputVideo)); err != nil {
+	if err := ffmpeg(buildMuxArgs(r.deps.InputVideo, r.deps.OutputAudio, r.deps.OutputVideo)); err != nil {
 		return Result{}, err
 	}
 	if err := ensureNonEmptyFile(r.deps.OutputVideo, "output video"); err != nil {
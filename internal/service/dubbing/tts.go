@@ -40,7 +40,7 @@ func GenerateRawSegments(ctx context.Context, tts types.Ttser, plan []PlanItem,
 			if tts == nil {
 				return nil, errors.New("tts is required for non-silence text")
 			}
-			if err := retryTTS(tts, plan[i].SpokenText, voice, output, 3); err != nil {
+			if err := retryTTS(ctx, tts, plan[i].SpokenText, voice, output, 3); err != nil {
 				return nil, fmt.Errorf("tts segment %d failed: %w", plan[i].Index, err)
 			}
 		}
@@ -55,7 +55,7 @@ func GenerateRawSegments(ctx context.Context, tts types.Ttser, plan []PlanItem,
 	return plan, nil
 }
 
-func GenerateRawChunkSegments(ctx context.Context, tts types.Ttser, plan []PlanItem, chunks []Chunk, voice, dir string, run CommandRunner, duration DurationProbe) ([]PlanItem, []Chunk, error) {
+func GenerateRawChunkSegments(ctx context.Context, tts types.Ttser, plan []PlanItem, chunks []Chunk, voice, dir string, run CommandRunner, duration DurationProbe, progress ChunkProgress) ([]PlanItem, []Chunk, error) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
@@ -91,7 +91,7 @@ func GenerateRawChunkSegments(ctx context.Context, tts types.Ttser, plan []PlanI
 			if tts == nil {
 				return nil, nil, errors.New("tts is required for non-silence text")
 			}
-			if err := retryTTS(tts, text, voice, output, 3); err != nil {
+			if err := retryTTS(ctx, tts, text, voice, output, 3); err != nil {
 				return nil, nil, fmt.Errorf("tts chunk %d failed: %w", outChunks[i].ID, err)
 			}
 		}
@@ -101,6 +101,9 @@ func GenerateRawChunkSegments(ctx context.Context, tts types.Ttser, plan []PlanI
 			return nil, nil, fmt.Errorf("measure chunk %d duration failed for %s: %w", outChunks[i].ID, output, err)
 		}
 		outChunks[i].ActualDuration = dur
+		if progress != nil {
+			progress(i+1, len(outChunks))
+		}
 	}
 
 	return outPlan, outChunks, nil
@@ -120,17 +123,20 @@ func chunkSpeechText(plan []PlanItem, chunk Chunk) (string, error) {
 	return strings.Join(parts, " "), nil
 }
 
-func retryTTS(tts types.Ttser, text, voice, output string, attempts int) error {
+func retryTTS(ctx context.Context, tts types.Ttser, text, voice, output string, attempts int) error {
 	if attempts <= 0 {
 		return fmt.Errorf("attempts must be > 0: %d", attempts)
 	}
 
 	var last error
 	for i := 0; i < attempts; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if err := os.Remove(output); err != nil && !errors.Is(err, os.ErrNotExist) {
 			return fmt.Errorf("remove stale output %s: %w", output, err)
 		}
-		last = tts.Text2Speech(text, voice, output)
+		last = tts.Text2Speech(ctx, text, voice, output)
 		if last == nil {
 			if _, err := os.Stat(output); err == nil {
 				return nil
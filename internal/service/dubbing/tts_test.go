@@ -16,7 +16,7 @@ type fakeTTS struct {
 	writeOnReturn bool
 }
 
-func (f *fakeTTS) Text2Speech(text, voice, outputFile string) error {
+func (f *fakeTTS) Text2Speech(_ context.Context, text, voice, outputFile string) error {
 	f.calls++
 	f.texts = append(f.texts, text)
 	if f.calls <= f.failures {
@@ -50,7 +50,7 @@ func TestGenerateRawSegmentsRetriesAndWritesFiles(t *testing.T) {
 }
 
 func TestRetryTTSRejectsNonPositiveAttempts(t *testing.T) {
-	err := retryTTS(&fakeTTS{}, "hello", "voice", filepath.Join(t.TempDir(), "out.wav"), 0)
+	err := retryTTS(context.Background(), &fakeTTS{}, "hello", "voice", filepath.Join(t.TempDir(), "out.wav"), 0)
 	if err == nil || !strings.Contains(err.Error(), "attempts must be > 0") {
 		t.Fatalf("retryTTS() error = %v, want attempts validation", err)
 	}
@@ -63,7 +63,7 @@ func TestRetryTTSDoesNotTreatStaleOutputAsSuccess(t *testing.T) {
 	}
 
 	tts := &fakeTTS{writeOnReturn: false}
-	err := retryTTS(tts, "hello", "voice", output, 1)
+	err := retryTTS(context.Background(), tts, "hello", "voice", output, 1)
 	if err == nil {
 		t.Fatal("retryTTS() error = nil, want missing output error")
 	}
@@ -102,11 +102,14 @@ func TestGenerateRawChunkSegmentsCallsTTSOncePerChunk(t *testing.T) {
 		{ID: 2, Items: []int{2}, Start: 6, End: 8},
 	}
 
+	var progress [][2]int
 	gotPlan, gotChunks, err := GenerateRawChunkSegments(context.Background(), tts, plan, chunks, "voice", dir, nil, func(path string) (float64, error) {
 		if strings.Contains(path, "chunk_1.wav") {
 			return 3.2, nil
 		}
 		return 1.1, nil
+	}, func(done, total int) {
+		progress = append(progress, [2]int{done, total})
 	})
 	if err != nil {
 		t.Fatalf("GenerateRawChunkSegments() error = %v", err)
@@ -114,6 +117,9 @@ func TestGenerateRawChunkSegmentsCallsTTSOncePerChunk(t *testing.T) {
 	if tts.calls != 2 {
 		t.Fatalf("TTS calls = %d, want one call per chunk", tts.calls)
 	}
+	if len(progress) != 2 || progress[1] != [2]int{2, 2} {
+		t.Fatalf("progress = %v, want one report per chunk", progress)
+	}
 	if got := tts.texts[0]; got != "我认为学习速记是一项技能 它能够改变你的人生。" {
 		t.Fatalf("first TTS text = %q", got)
 	}
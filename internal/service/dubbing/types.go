@@ -87,6 +87,9 @@ type Report struct {
 type CommandRunner func(args []string) error
 type DurationProbe func(path string) (float64, error)
 
+// ChunkProgress is called after each chunk's raw TTS audio is ready.
+type ChunkProgress func(done, total int)
+
 type Dependencies struct {
 	TTS         types.Ttser
 	Chat        types.ChatCompleter
@@ -100,6 +103,7 @@ type Dependencies struct {
 	Config      Config
 	FFmpeg      CommandRunner
 	Duration    DurationProbe
+	Progress    ChunkProgress
 }
 
 type TextOptimizer interface {
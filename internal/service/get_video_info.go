@@ -32,7 +32,7 @@ func (s Service) getVideoInfo(ctx context.Context, stepParam *types.SubtitleTask
 			titleCmdArgs = append(titleCmdArgs, "--ffmpeg-location", storage.FfmpegPath)
 			descriptionCmdArgs = append(descriptionCmdArgs, "--ffmpeg-location", storage.FfmpegPath)
 		}
-		cmd := exec.Command(storage.YtdlpPath, titleCmdArgs...)
+		cmd := exec.CommandContext(ctx, storage.YtdlpPath, titleCmdArgs...)
 		var output []byte
 		output, err = cmd.CombinedOutput()
 		if err != nil {
@@ -41,7 +41,7 @@ func (s Service) getVideoInfo(ctx context.Context, stepParam *types.SubtitleTask
 			// 不需要整个流程退出
 		}
 		title = string(output)
-		cmd = exec.Command(storage.YtdlpPath, descriptionCmdArgs...)
+		cmd = exec.CommandContext(ctx, storage.YtdlpPath, descriptionCmdArgs...)
 		output, err = cmd.CombinedOutput()
 		if err != nil {
 			log.GetLogger().Error("getVideoInfo yt-dlp error", zap.Any("stepParam", stepParam), zap.String("output", string(output)), zap.Error(err))
@@ -51,7 +51,10 @@ func (s Service) getVideoInfo(ctx context.Context, stepParam *types.SubtitleTask
 		log.GetLogger().Debug("getVideoInfo title and description", zap.String("title", title), zap.String("description", description))
 		// 翻译
 		var result string
-		result, err = s.ChatCompleter.ChatCompletion(fmt.Sprintf(types.TranslateVideoTitleAndDescriptionPrompt, types.GetStandardLanguageName(stepParam.TargetLanguage), title+"####"+description))
+		result, err = s.ChatCompleter.ChatCompletion(ctx, types.ChatRequest{
+			UseCase: config.LlmUseCaseTranslate,
+			Prompt:  fmt.Sprintf(types.TranslateVideoTitleAndDescriptionPrompt, types.GetStandardLanguageName(stepParam.TargetLanguage), title+"####"+description),
+		})
 		if err != nil {
 			log.GetLogger().Error("getVideoInfo openai chat completion error", zap.Any("stepParam", stepParam), zap.Error(err))
 		}
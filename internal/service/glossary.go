@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"krillin-ai/config"
+	"krillin-ai/internal/types"
+	"krillin-ai/log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"go.uber.org/zap"
+)
+
+// glossaryMaxAttempts 译文不符合术语表时最多请求的次数
+const glossaryMaxAttempts = 3
+
+// glossaryViolation 重试后仍未按术语表翻译的句子，写入glossary_report.json供人工处理
+type glossaryViolation struct {
+	OriginText     string               `json:"origin_text"`
+	TranslatedText string               `json:"translated_text"`
+	Terms          []types.GlossaryTerm `json:"terms"`
+}
+
+// glossaryReport 收集一个任务中不符合术语表的句子，可并发写入
+type glossaryReport struct {
+	mu         sync.Mutex
+	violations []glossaryViolation
+}
+
+func (r *glossaryReport) add(originText, translatedText string, terms []types.GlossaryTerm) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.violations = append(r.violations, glossaryViolation{OriginText: originText, TranslatedText: translatedText, Terms: terms})
+}
+
+func (r *glossaryReport) list() []glossaryViolation {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]glossaryViolation(nil), r.violations...)
+}
+
+// NewGlossary 依次合并项目配置的术语表、任务指定的术语表文件和任务提交的术语，同一目标语言的同一术语以后者为准。
+// 没有指定目标语言的译文视为第一个目标语言primaryLang的译法，不用于附加目标语言；保留原文的术语对所有目标语言生效
+func NewGlossary(file string, terms types.Glossary, primaryLang types.StandardLanguageCode) (types.Glossary, error) {
+	var sources []types.Glossary
+	for _, path := range []string{config.Conf.App.Glossary, file} {
+		if path == "" {
+			continue
+		}
+		glossary, err := LoadGlossary(path)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, glossary)
+	}
+	sources = append(sources, terms)
+
+	var merged types.Glossary
+	index := make(map[string]int)
+	for _, glossary := range sources {
+		for _, term := range glossary {
+			term.Source = strings.TrimSpace(term.Source)
+			term.Target = strings.TrimSpace(term.Target)
+			term.TargetLanguage = strings.TrimSpace(term.TargetLanguage)
+			if term.Source == "" {
+				continue
+			}
+			if term.Target == "" && !term.DoNotTranslate {
+				return nil, fmt.Errorf("术语 %q 没有填写译文，保留原文请设置do_not_translate", term.Source)
+			}
+			if term.TargetLanguage == "" && !term.DoNotTranslate {
+				term.TargetLanguage = string(primaryLang)
+			}
+			key := strings.ToLower(term.Source) + "\x00" + strings.ToLower(term.TargetLanguage)
+			if i, ok := index[key]; ok {
+				merged[i] = term
+				continue
+			}
+			index[key] = len(merged)
+			merged = append(merged, term)
+		}
+	}
+	return merged, nil
+}
+
+// LoadGlossary 按扩展名读取CSV或TOML格式的术语表。
+// CSV每行为 source,target,case_sensitive,do_not_translate,target_language，后三列可省略，首行可以是表头；TOML为 [[terms]] 列表
+func LoadGlossary(path string) (types.Glossary, error) {
+	var (
+		glossary types.Glossary
+		err      error
+	)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		glossary, err = loadCsvGlossary(path)
+	case ".toml":
+		var file struct {
+			Terms types.Glossary `toml:"terms"`
+		}
+		_, err = toml.DecodeFile(path, &file)
+		glossary = file.Terms
+	default:
+		return nil, fmt.Errorf("不支持的术语表格式: %s，仅支持.csv和.toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取术语表 %s 失败: %w", path, err)
+	}
+	return glossary, nil
+}
+
+func loadCsvGlossary(path string) (types.Glossary, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	var glossary types.Glossary
+	for i, record := range records {
+		record[0] = strings.TrimPrefix(record[0], "\ufeff") // Excel导出的CSV带BOM
+		if i == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "source") {
+			continue
+		}
+		term := types.GlossaryTerm{Source: record[0]}
+		if len(record) > 1 {
+			term.Target = record[1]
+		}
+		if len(record) > 2 {
+			if term.CaseSensitive, err = parseGlossaryFlag(record[2]); err != nil {
+				return nil, fmt.Errorf("第%d行 case_sensitive: %w", i+1, err)
+			}
+		}
+		if len(record) > 3 {
+			if term.DoNotTranslate, err = parseGlossaryFlag(record[3]); err != nil {
+				return nil, fmt.Errorf("第%d行 do_not_translate: %w", i+1, err)
+			}
+		}
+		if len(record) > 4 {
+			term.TargetLanguage = record[4]
+		}
+		glossary = append(glossary, term)
+	}
+	return glossary, nil
+}
+
+func parseGlossaryFlag(s string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "":
+		return false, nil
+	case "y", "yes":
+		return true, nil
+	case "n", "no":
+		return false, nil
+	}
+	return strconv.ParseBool(strings.TrimSpace(s))
+}
+
+// translateWithGlossary 译文没有按术语表翻译时带着提醒重试，仍不符合时返回最后一次的译文和未遵守的术语
+func translateWithGlossary(ctx context.Context, chatCompleter types.ChatCompleter, glossary types.Glossary, prompt, originText string) (string, []types.GlossaryTerm, error) {
+	var (
+		translatedText string
+		violations     []types.GlossaryTerm
+	)
+	for attempt := range glossaryMaxAttempts {
+		response, err := chatCompleter.ChatCompletion(ctx, types.ChatRequest{UseCase: config.LlmUseCaseTranslate, Prompt: prompt})
+		if err != nil {
+			if translatedText != "" {
+				break
+			}
+			return "", nil, err
+		}
+		translatedText = strings.TrimSpace(response)
+		if violations = glossary.Violations(originText, translatedText); len(violations) == 0 {
+			return translatedText, nil, nil
+		}
+		log.GetLogger().Warn("译文不符合术语表，重试", zap.Int("attempt", attempt+1), zap.String("originText", originText),
+			zap.String("translatedText", translatedText), zap.Any("terms", violations))
+		prompt = glossary.RetryPrompt(prompt, translatedText, violations)
+	}
+	return translatedText, violations, nil
+}
+
+// saveGlossaryReport 保存不符合术语表的句子，没有时删除旧的报告
+func saveGlossaryReport(taskBasePath string, violations []glossaryViolation) error {
+	reportFile := filepath.Join(taskBasePath, types.SubtitleTaskGlossaryReportFileName)
+	if len(violations) == 0 {
+		if err := os.Remove(reportFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(violations, "", "  ")
+	if err != nil {
+		return err
+	}
+	log.GetLogger().Warn("部分句子未按术语表翻译，请人工检查", zap.String("report", reportFile), zap.Int("count", len(violations)))
+	return os.WriteFile(reportFile, data, 0644)
+}
+
+// mergeGlossaryReport 从各分段持久化的翻译结果中汇总不符合术语表的句子，断点续跑复用的分段也会包含在内
+func mergeGlossaryReport(taskBasePath string, segmentNum int) error {
+	items, err := loadTranslatedItems(taskBasePath, segmentNum)
+	if err != nil {
+		return err
+	}
+	var violations []glossaryViolation
+	for _, item := range items {
+		if len(item.GlossaryViolations) > 0 {
+			violations = append(violations, glossaryViolation{OriginText: item.OriginText, TranslatedText: item.TranslatedText, Terms: item.GlossaryViolations})
+		}
+	}
+	return saveGlossaryReport(taskBasePath, violations)
+}
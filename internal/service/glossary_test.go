@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"krillin-ai/config"
+	"krillin-ai/internal/types"
+	"krillin-ai/log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// scriptedChat 按顺序返回预设的回复，并记录收到的提示词
+type scriptedChat struct {
+	replies []string
+	prompts []string
+}
+
+func (c *scriptedChat) ChatCompletion(ctx context.Context, req types.ChatRequest) (string, error) {
+	c.prompts = append(c.prompts, req.Prompt)
+	reply := c.replies[0]
+	if len(c.replies) > 1 {
+		c.replies = c.replies[1:]
+	}
+	return reply, nil
+}
+
+func TestNewGlossaryMergesProjectAndTaskTerms(t *testing.T) {
+	original := config.Conf.App.Glossary
+	defer func() { config.Conf.App.Glossary = original }()
+
+	dir := t.TempDir()
+	config.Conf.App.Glossary = filepath.Join(dir, "project.csv")
+	if err := os.WriteFile(config.Conf.App.Glossary, []byte("\ufeffsource,target,case_sensitive,do_not_translate\nKrillinAI,,true,yes\nsubtitle,字幕\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	taskFile := filepath.Join(dir, "task.toml")
+	if err := os.WriteFile(taskFile, []byte("[[terms]]\nsource = \"Subtitle\"\ntarget = \"字幕文件\"\n\n[[terms]]\nsource = \"dubbing\"\ntarget = \"配音\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	glossary, err := NewGlossary(taskFile, types.Glossary{{Source: "dubbing", Target: "译制"}}, "zh_cn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := types.Glossary{
+		{Source: "KrillinAI", CaseSensitive: true, DoNotTranslate: true},
+		{Source: "Subtitle", Target: "字幕文件", TargetLanguage: "zh_cn"},
+		{Source: "dubbing", Target: "译制", TargetLanguage: "zh_cn"},
+	}
+	got, _ := json.Marshal(glossary)
+	wantJson, _ := json.Marshal(want)
+	if string(got) != string(wantJson) {
+		t.Fatalf("NewGlossary() = %s, want %s", got, wantJson)
+	}
+
+	if _, err = NewGlossary("", types.Glossary{{Source: "ffmpeg"}}, "zh_cn"); err == nil {
+		t.Fatal("term without target should be rejected")
+	}
+	if _, err = LoadGlossary(filepath.Join(dir, "terms.txt")); err == nil {
+		t.Fatal("unsupported extension should be rejected")
+	}
+}
+
+func TestNewGlossaryKeepsTermsPerTargetLanguage(t *testing.T) {
+	original := config.Conf.App.Glossary
+	defer func() { config.Conf.App.Glossary = original }()
+	config.Conf.App.Glossary = filepath.Join(t.TempDir(), "project.csv")
+	if err := os.WriteFile(config.Conf.App.Glossary, []byte("Apple,苹果\nApple,アップル,,,ja\nKrillinAI,,true,true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	glossary, err := NewGlossary("", nil, "zh_cn")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	origin := "KrillinAI runs on an Apple laptop"
+	for _, tc := range []struct {
+		lang       types.StandardLanguageCode
+		translated string
+		terms      int
+	}{
+		{lang: "zh_cn", translated: "KrillinAI在苹果笔记本上运行", terms: 2},
+		{lang: "ja", translated: "KrillinAIはアップルのノートPCで動く", terms: 2},
+		// 没有法语译法时只检查保留原文的术语，不要求出现中文
+		{lang: "fr", translated: "KrillinAI fonctionne sur un portable Apple", terms: 1},
+	} {
+		terms := glossary.ForLanguage(tc.lang)
+		if matched := terms.Matches(origin); len(matched) != tc.terms {
+			t.Fatalf("%s: Matches() = %+v, want %d terms", tc.lang, matched, tc.terms)
+		}
+		if violations := terms.Violations(origin, tc.translated); len(violations) != 0 {
+			t.Fatalf("%s: Violations() = %+v", tc.lang, violations)
+		}
+	}
+}
+
+func TestTranslateWithGlossaryRetriesThenFlags(t *testing.T) {
+	log.InitLogger()
+	glossary := types.Glossary{{Source: "KrillinAI", DoNotTranslate: true}}
+
+	chat := &scriptedChat{replies: []string{"磷虾AI很好用", "KrillinAI很好用"}}
+	translated, violations, err := translateWithGlossary(context.Background(), chat, glossary, "prompt", "KrillinAI is handy")
+	if err != nil || translated != "KrillinAI很好用" || len(violations) != 0 {
+		t.Fatalf("translateWithGlossary() = %q, %v, %v", translated, violations, err)
+	}
+	if len(chat.prompts) != 2 || !strings.Contains(chat.prompts[1], "did not follow the glossary") {
+		t.Fatalf("prompts = %q", chat.prompts)
+	}
+
+	chat = &scriptedChat{replies: []string{"磷虾AI很好用"}}
+	translated, violations, err = translateWithGlossary(context.Background(), chat, glossary, "prompt", "KrillinAI is handy")
+	if err != nil || translated != "磷虾AI很好用" || len(violations) != 1 || len(chat.prompts) != glossaryMaxAttempts {
+		t.Fatalf("translateWithGlossary() = %q, %v, %v after %d calls", translated, violations, err, len(chat.prompts))
+	}
+}
+
+func TestTranslatorFlagsGlossaryViolations(t *testing.T) {
+	log.InitLogger()
+	chat := &scriptedChat{replies: []string{"磷虾AI让翻译更简单"}}
+	translator := (&Translator{chatCompleter: chat}).WithGlossary(types.Glossary{{Source: "KrillinAI", DoNotTranslate: true}})
+
+	translated, err := translator.translateWithRetry(context.Background(), "prompt", "KrillinAI makes translation easy", types.LanguageNameEnglish, types.LanguageNameSimplifiedChinese)
+	if err != nil || translated != "磷虾AI让翻译更简单" {
+		t.Fatalf("translateWithRetry() = %q, %v", translated, err)
+	}
+	violations := translator.GlossaryViolations()
+	if len(violations) != 1 || violations[0].Terms[0].Source != "KrillinAI" {
+		t.Fatalf("GlossaryViolations() = %+v", violations)
+	}
+
+	dir := t.TempDir()
+	if err = saveGlossaryReport(dir, violations); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, types.SubtitleTaskGlossaryReportFileName)); err != nil {
+		t.Fatal(err)
+	}
+	// 重新翻译后没有问题时删除旧报告
+	if err = saveGlossaryReport(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, types.SubtitleTaskGlossaryReportFileName)); !os.IsNotExist(err) {
+		t.Fatalf("stale report should be removed, err = %v", err)
+	}
+}
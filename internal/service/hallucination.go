@@ -0,0 +1,366 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"krillin-ai/config"
+	"krillin-ai/internal/types"
+	"krillin-ai/log"
+	"krillin-ai/pkg/util"
+	"krillin-ai/pkg/vad"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"go.uber.org/zap"
+)
+
+const (
+	hallucinationRepeat    = "repeat"    // 短语循环重复
+	hallucinationBlocklist = "blocklist" // 命中屏蔽短语
+	hallucinationSilence   = "silence"   // 文字落在没有人声的区间
+	hallucinationRate      = "rate"      // 语速异常
+
+	maxLoopNgram        = 8   // 检测循环重复的最长短语词数
+	blocklistCoverage   = 0.6 // 屏蔽短语占整个片段的比例超过该值时才过滤，避免误伤正常句子
+	minSpeechOverlap    = 0.2 // 片段与人声区间的重叠比例低于该值视为落在静音上
+	maxSilenceFlagged   = 0.5 // 静音判定命中超过该比例的单词时认为语音检测不可靠，放弃该项判定
+	minRateUnits        = 4   // 片段至少有这么多词才判断语速
+	slowSpanDuration    = 5.0 // 超过该时长的片段才判断语速过慢
+	minWordsPerSecond   = 0.2 // 低于该语速视为一个词被拉长覆盖了整段音乐或静音
+	cjkUnitsPerRune     = 0.5 // 中日韩文字每两个字按一个词计算语速
+	defaultBlocklistKey = "*" // 对所有语言生效的屏蔽短语
+)
+
+// builtinHallucinationPhrases whisper类模型在静音和片尾处常见的编造内容，key为语言代码的主标签
+var builtinHallucinationPhrases = map[string][]string{
+	defaultBlocklistKey: {"amara.org"},
+	"en": {
+		"thanks for watching", "thank you for watching", "thank you so much for watching",
+		"please subscribe", "like and subscribe", "subtitles by the amara.org community",
+	},
+	"zh": {
+		"字幕由Amara.org社区提供", "请不吝点赞 订阅 转发 打赏支持明镜与点点栏目",
+		"谢谢观看", "感谢观看", "中文字幕志愿者", "字幕志愿者", "优优独播剧场",
+	},
+	"ja": {"ご視聴ありがとうございました", "チャンネル登録お願いします"},
+	"ko": {"시청해주셔서 감사합니다", "구독과 좋아요 부탁드립니다"},
+}
+
+// hallucinationSpan 一段被判定为幻觉内容的连续单词，时间为分段内的相对时间，合并报告时换算为整段音频的时间
+type hallucinationSpan struct {
+	Segment int     `json:"segment"`
+	Reason  string  `json:"reason"`
+	Start   float64 `json:"start"`
+	End     float64 `json:"end"`
+	Text    string  `json:"text"`
+}
+
+type hallucinationReport struct {
+	Action string              `json:"action"`
+	Spans  []hallucinationSpan `json:"spans"`
+}
+
+// filterHallucinations 检测转录结果中的幻觉内容，按配置删除或标记，并把可疑片段写入分段报告
+func (s Service) filterHallucinations(ctx context.Context, id int, audioFile, taskBasePath, language string, data *types.TranscriptionData) error {
+	cfg := config.Conf.Transcribe.Hallucination
+	if !cfg.Enable || len(data.Words) == 0 {
+		return nil
+	}
+	regions, err := detectSpeechRegions(ctx, audioFile)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// 拿不到人声区间时跳过静音判定，其余判定照常进行
+		log.GetLogger().Warn("filterHallucinations detect speech regions err", zap.String("audioFile", audioFile), zap.Error(err))
+		regions = nil
+	}
+	if data.Language != "" {
+		language = data.Language
+	}
+	reasons := detectHallucinations(data.Words, language, regions, err == nil, cfg)
+	spans := hallucinationSpans(data.Words, reasons, id)
+	if cfg.Action == "drop" {
+		dropHallucinations(data, reasons)
+	} else {
+		for i, reason := range reasons {
+			data.Words[i].Flag = reason
+		}
+	}
+	if len(spans) > 0 {
+		log.GetLogger().Info("filterHallucinations flagged", zap.String("audioFile", audioFile), zap.String("action", cfg.Action), zap.Any("spans", spans))
+	}
+	reportFile := filepath.Join(taskBasePath, fmt.Sprintf(types.SubtitleTaskHallucinationReportFileNamePattern, id))
+	return util.SaveToDisk(hallucinationReport{Action: cfg.Action, Spans: spans}, reportFile)
+}
+
+// detectHallucinations 返回每个单词被判定为幻觉内容的原因，正常的单词为空字符串。
+// checkSilence为false时不做静音判定
+func detectHallucinations(words []types.Word, language string, regions []vad.Region, checkSilence bool, cfg config.HallucinationConfig) []string {
+	reasons := make([]string, len(words))
+	normalized := make([]string, len(words))
+	for i, word := range words {
+		normalized[i] = normalizeHallucinationText(word.Text)
+	}
+	flagRepeatLoops(normalized, reasons, cfg.MaxRepeat)
+
+	phrases := hallucinationPhrases(language, cfg.Blocklist)
+	var silent []int
+	for _, group := range utteranceGroups(words) {
+		first, last := words[group[0]], words[group[len(group)-1]]
+		duration := last.End - first.Start
+
+		var text strings.Builder
+		units := 0.0
+		for _, i := range group {
+			text.WriteString(normalized[i])
+			units += speechUnits(normalized[i])
+		}
+		if matchesBlocklist(text.String(), phrases) {
+			flagGroup(reasons, group, hallucinationBlocklist)
+			continue
+		}
+		if checkSilence && duration > 0 && speechOverlap(regions, first.Start, last.End)/duration < minSpeechOverlap {
+			silent = append(silent, group...)
+			continue
+		}
+		if duration > 0 && ((units >= minRateUnits && units/duration > cfg.MaxWordsPerSecond) ||
+			(duration >= slowSpanDuration && units/duration < minWordsPerSecond)) {
+			flagGroup(reasons, group, hallucinationRate)
+		}
+	}
+	// 大部分内容都落在静音上时更可能是语音检测失效（如人声被背景音乐盖住），不按静音过滤
+	if float64(len(silent)) <= float64(len(words))*maxSilenceFlagged {
+		flagGroup(reasons, silent, hallucinationSilence)
+	}
+	return reasons
+}
+
+// flagRepeatLoops 标记连续重复超过maxRepeat次的短语，保留第一次出现，单个词允许重复两倍次数
+func flagRepeatLoops(normalized []string, reasons []string, maxRepeat int) {
+	for i := 0; i < len(normalized); {
+		bestN, bestReps := 0, 0
+		for n := 1; n <= maxLoopNgram && i+n <= len(normalized); n++ {
+			reps := 1
+			for i+(reps+1)*n <= len(normalized) && sameWords(normalized[i:i+n], normalized[i+reps*n:i+(reps+1)*n]) {
+				reps++
+			}
+			limit := maxRepeat
+			if n == 1 {
+				limit *= 2
+			}
+			if reps > limit && reps*n > bestReps*bestN {
+				bestN, bestReps = n, reps
+			}
+		}
+		if bestN == 0 {
+			i++
+			continue
+		}
+		for j := i + bestN; j < i+bestN*bestReps; j++ {
+			if reasons[j] == "" {
+				reasons[j] = hallucinationRepeat
+			}
+		}
+		i += bestN * bestReps
+	}
+}
+
+func sameWords(a, b []string) bool {
+	for i := range a {
+		if a[i] == "" || a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func flagGroup(reasons []string, indexes []int, reason string) {
+	for _, i := range indexes {
+		if reasons[i] == "" {
+			reasons[i] = reason
+		}
+	}
+}
+
+// hallucinationPhrases 合并内置和配置的屏蔽短语，语言代码只取主标签，如zh_cn按zh处理
+func hallucinationPhrases(language string, extra map[string][]string) []string {
+	base := baseLanguage(language)
+	var phrases []string
+	for _, source := range []map[string][]string{builtinHallucinationPhrases, extra} {
+		for key, list := range source {
+			if key != defaultBlocklistKey && baseLanguage(key) != base {
+				continue
+			}
+			for _, phrase := range list {
+				if normalized := normalizeHallucinationText(phrase); normalized != "" {
+					phrases = append(phrases, normalized)
+				}
+			}
+		}
+	}
+	return phrases
+}
+
+func baseLanguage(language string) string {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if i := strings.IndexAny(language, "_-"); i >= 0 {
+		language = language[:i]
+	}
+	return language
+}
+
+func matchesBlocklist(text string, phrases []string) bool {
+	if text == "" {
+		return false
+	}
+	total := float64(len([]rune(text)))
+	for _, phrase := range phrases {
+		if strings.Contains(text, phrase) && float64(len([]rune(phrase))) >= total*blocklistCoverage {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeHallucinationText 只保留字母和数字并转为小写，比较时忽略标点和空格
+func normalizeHallucinationText(text string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, text)
+}
+
+// speechUnits 估算一个词的发音长度，中日韩文字按字计
+func speechUnits(word string) float64 {
+	if word == "" {
+		return 0
+	}
+	cjk := 0
+	for _, r := range word {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			cjk++
+		}
+	}
+	if cjk == 0 {
+		return 1
+	}
+	return float64(cjk) * cjkUnitsPerRune
+}
+
+func speechOverlap(regions []vad.Region, start, end float64) float64 {
+	overlap := 0.0
+	for _, region := range regions {
+		overlap += math.Max(0, math.Min(region.End, end)-math.Max(region.Start, start))
+	}
+	return overlap
+}
+
+// hallucinationSpans 把原因相同的相邻单词合并成报告中的片段
+func hallucinationSpans(words []types.Word, reasons []string, segment int) []hallucinationSpan {
+	spans := make([]hallucinationSpan, 0)
+	for i := 0; i < len(words); i++ {
+		if reasons[i] == "" {
+			continue
+		}
+		j := i
+		texts := []string{}
+		for ; j < len(words) && reasons[j] == reasons[i]; j++ {
+			texts = append(texts, strings.TrimSpace(words[j].Text))
+		}
+		spans = append(spans, hallucinationSpan{
+			Segment: segment,
+			Reason:  reasons[i],
+			Start:   words[i].Start,
+			End:     words[j-1].End,
+			Text:    strings.Join(texts, " "),
+		})
+		i = j - 1
+	}
+	return spans
+}
+
+// dropHallucinations 删除被判定的单词及其在转录文本中对应的内容。
+// 文本中定位不到被删除的单词时，改为用保留的单词重新拼接文本
+func dropHallucinations(data *types.TranscriptionData, reasons []string) {
+	offsets := wordOffsets(data.Text, data.Words)
+	var (
+		text    strings.Builder
+		kept    []types.Word
+		last    int
+		located = true
+	)
+	for i := 0; i < len(data.Words); i++ {
+		if reasons[i] == "" {
+			data.Words[i].Num = len(kept)
+			kept = append(kept, data.Words[i])
+			continue
+		}
+		j := i
+		for j < len(data.Words) && reasons[j] != "" {
+			j++
+		}
+		from, to := offsets[i], len(data.Text)
+		if j < len(data.Words) {
+			to = offsets[j]
+		}
+		if from < last || to < from {
+			located = false
+		} else if located {
+			text.WriteString(data.Text[last:from])
+			last = to
+		}
+		i = j - 1
+	}
+	if len(kept) == len(data.Words) {
+		return
+	}
+	if located {
+		text.WriteString(data.Text[last:])
+		data.Text = strings.TrimSpace(text.String())
+	} else {
+		separator := ""
+		if strings.Contains(data.Text, " ") {
+			separator = " "
+		}
+		texts := make([]string, 0, len(kept))
+		for _, word := range kept {
+			texts = append(texts, strings.TrimSpace(word.Text))
+		}
+		data.Text = strings.Join(texts, separator)
+	}
+	data.Words = kept
+}
+
+// mergeHallucinationReports 把各分段的报告合并到任务目录，时间换算为整段音频的时间
+func mergeHallucinationReports(taskBasePath string, timePoints []float64) error {
+	if !config.Conf.Transcribe.Hallucination.Enable {
+		return nil
+	}
+	merged := hallucinationReport{Action: config.Conf.Transcribe.Hallucination.Action, Spans: make([]hallucinationSpan, 0)}
+	for id := range len(timePoints) - 1 {
+		var report hallucinationReport
+		if err := loadJSONArtifact(filepath.Join(taskBasePath, fmt.Sprintf(types.SubtitleTaskHallucinationReportFileNamePattern, id)), &report); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		for _, span := range report.Spans {
+			span.Start += timePoints[id]
+			span.End += timePoints[id]
+			merged.Spans = append(merged.Spans, span)
+		}
+	}
+	data, err := json.MarshalIndent(merged, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(taskBasePath, types.SubtitleTaskHallucinationReportFileName), data, 0644)
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"krillin-ai/config"
+	"krillin-ai/internal/types"
+	"krillin-ai/pkg/vad"
+	"strings"
+	"testing"
+)
+
+func testHallucinationConfig() config.HallucinationConfig {
+	return config.HallucinationConfig{Enable: true, Action: "drop", MaxRepeat: 3, MaxWordsPerSecond: 7}
+}
+
+// timedWords 按顺序生成单词，每个词0.4秒，texts中的空字符串表示1秒的停顿
+func timedWords(start float64, texts ...string) []types.Word {
+	var words []types.Word
+	for _, text := range texts {
+		if text == "" {
+			start += 1
+			continue
+		}
+		words = append(words, types.Word{Num: len(words), Text: text, Start: start, End: start + 0.4})
+		start += 0.4
+	}
+	return words
+}
+
+func TestDetectHallucinationsFlagsRepeatLoops(t *testing.T) {
+	texts := []string{"we", "go"}
+	for range 6 {
+		texts = append(texts, "I'm", "going", "home.")
+	}
+	words := timedWords(0, texts...)
+	reasons := detectHallucinations(words, "en", nil, false, testHallucinationConfig())
+	for i, reason := range reasons {
+		want := ""
+		if i >= 5 { // 保留第一次出现的短语
+			want = hallucinationRepeat
+		}
+		if reason != want {
+			t.Fatalf("reasons = %v", reasons)
+		}
+	}
+
+	// 少量重复是正常说话
+	words = timedWords(0, "no", "no", "no", "no", "no")
+	for _, reason := range detectHallucinations(words, "en", nil, false, testHallucinationConfig()) {
+		if reason != "" {
+			t.Fatalf("short repetition flagged: %v", reason)
+		}
+	}
+}
+
+func TestDetectHallucinationsFlagsBlocklistAndSilence(t *testing.T) {
+	cfg := testHallucinationConfig()
+	cfg.Blocklist = map[string][]string{"en": {"brought to you by"}}
+	words := timedWords(0, "so", "that's", "it", "", "Thanks", "for", "watching!", "", "brought", "to", "you", "by", "", "hello", "there")
+	regions := []vad.Region{{Start: 0, End: 1.2}, {Start: 2.2, End: 3.4}, {Start: 4.4, End: 6}}
+	reasons := detectHallucinations(words, "en_us", regions, true, cfg)
+	want := []string{"", "", "", hallucinationBlocklist, hallucinationBlocklist, hallucinationBlocklist,
+		hallucinationBlocklist, hallucinationBlocklist, hallucinationBlocklist, hallucinationBlocklist, hallucinationSilence, hallucinationSilence}
+	if strings.Join(reasons, ",") != strings.Join(want, ",") {
+		t.Fatalf("reasons = %v, want %v", reasons, want)
+	}
+
+	// 内置列表不包含"transcribed by"这类通用开头，正常的短句不会被过滤
+	words = timedWords(0, "transcribed", "by", "hand")
+	if reasons = detectHallucinations(words, "en", nil, false, testHallucinationConfig()); strings.Join(reasons, "") != "" {
+		t.Fatalf("short utterance flagged: %v", reasons)
+	}
+
+	// 语音检测几乎没找到人声时不按静音过滤
+	words = timedWords(0, "so", "that's", "it", "", "hello", "there")
+	reasons = detectHallucinations(words, "en", []vad.Region{{Start: 0, End: 0.1}}, true, cfg)
+	if strings.Join(reasons, "") != "" {
+		t.Fatalf("unreliable silence detection should be ignored, reasons = %v", reasons)
+	}
+}
+
+func TestDetectHallucinationsFlagsAbnormalRate(t *testing.T) {
+	words := []types.Word{
+		{Text: "a", Start: 0, End: 0.1}, {Text: "b", Start: 0.1, End: 0.2}, {Text: "c", Start: 0.2, End: 0.3},
+		{Text: "d", Start: 0.3, End: 0.4}, {Text: "e", Start: 0.4, End: 0.5},
+		{Text: "music", Start: 2, End: 14},
+	}
+	reasons := detectHallucinations(words, "en", nil, false, testHallucinationConfig())
+	for i, reason := range reasons {
+		if reason != hallucinationRate {
+			t.Fatalf("word %d reason = %q", i, reason)
+		}
+	}
+}
+
+func TestDropHallucinationsRemovesTextAndRenumbers(t *testing.T) {
+	data := &types.TranscriptionData{
+		Text:  " Hello there. Thanks for watching. Bye now.",
+		Words: timedWords(0, "Hello", "there", "Thanks", "for", "watching", "Bye", "now"),
+	}
+	dropHallucinations(data, []string{"", "", "blocklist", "blocklist", "blocklist", "", ""})
+	if data.Text != "Hello there. Bye now." {
+		t.Fatalf("Text = %q", data.Text)
+	}
+	if len(data.Words) != 4 || data.Words[2].Text != "Bye" || data.Words[2].Num != 2 {
+		t.Fatalf("Words = %+v", data.Words)
+	}
+
+	// 文本中找不到单词时用保留的单词重新拼接
+	data = &types.TranscriptionData{Text: "完全不同的文本", Words: timedWords(0, "a", "b", "c")}
+	dropHallucinations(data, []string{"", "repeat", ""})
+	if data.Text != "ac" || len(data.Words) != 2 {
+		t.Fatalf("fallback Text = %q, Words = %+v", data.Text, data.Words)
+	}
+}
@@ -1,18 +1,23 @@
 package service
 
 import (
+	"fmt"
 	"krillin-ai/config"
 	"krillin-ai/internal/types"
 	"krillin-ai/log"
 	"krillin-ai/pkg/aliyun"
+	"krillin-ai/pkg/anthropic"
 	"krillin-ai/pkg/fasterwhisper"
+	"krillin-ai/pkg/gemini"
 	pkgimage "krillin-ai/pkg/image"
 	"krillin-ai/pkg/localtts"
 	"krillin-ai/pkg/minimax"
+	"krillin-ai/pkg/ollama"
 	"krillin-ai/pkg/openai"
 	"krillin-ai/pkg/whisper"
 	"krillin-ai/pkg/whispercpp"
 	"krillin-ai/pkg/whisperkit"
+	"krillin-ai/pkg/whisperx"
 
 	"go.uber.org/zap"
 )
@@ -32,26 +37,11 @@ func NewService() *Service {
 	var chatCompleter types.ChatCompleter
 	var ttsClient types.Ttser
 
-	switch config.Conf.Transcribe.Provider {
-	case "openai":
-		transcriber = whisper.NewClient(config.Conf.Transcribe.Openai.BaseUrl, config.Conf.Transcribe.Openai.ApiKey, config.Conf.App.Proxy)
-	case "fasterwhisper":
-		transcriber = fasterwhisper.NewFastwhisperProcessor(config.Conf.Transcribe.Fasterwhisper.Model)
-	case "whispercpp":
-		transcriber = whispercpp.NewWhispercppProcessor(config.Conf.Transcribe.Whispercpp.Model)
-	case "whisperkit":
-		transcriber = whisperkit.NewWhisperKitProcessor(config.Conf.Transcribe.Whisperkit.Model)
-	case "aliyun":
-		cc, err := aliyun.NewAsrClient(config.Conf.Transcribe.Aliyun.Speech.AccessKeyId, config.Conf.Transcribe.Aliyun.Speech.AccessKeySecret, config.Conf.Transcribe.Aliyun.Speech.AppKey, true)
-		if err != nil {
-			log.GetLogger().Error("创建阿里云语音识别客户端失败： ", zap.Error(err))
-			return nil
-		}
-		transcriber = cc
-	}
-	log.GetLogger().Info("当前选择的转录源： ", zap.String("transcriber", config.Conf.Transcribe.Provider))
+	transcriber = newTranscriberChain(config.Conf.Transcribe.Provider)
+	log.GetLogger().Info("当前选择的转录源： ", zap.String("transcriber", config.Conf.Transcribe.Provider.String()))
 
-	chatCompleter = openai.NewClient(config.Conf.Llm.BaseUrl, config.Conf.Llm.ApiKey, config.Conf.App.Proxy)
+	chatCompleter = newChatCompleter()
+	log.GetLogger().Info("当前选择的大模型服务： ", zap.String("provider", config.Conf.Llm.Provider), zap.String("model", config.Conf.Llm.Model))
 
 	switch config.Conf.Tts.Provider {
 	case "openai":
@@ -64,9 +54,17 @@ func NewService() *Service {
 		ttsClient = minimax.NewTtsClient(config.Conf.Tts.Minimax.BaseUrl, config.Conf.Tts.Minimax.ApiKey, config.Conf.Tts.Minimax.Model)
 	}
 
+	configureResourceLimits(config.Conf.Server.Concurrency)
+	if transcriber != nil {
+		transcriber = limitedTranscriber{transcriber}
+	}
+	if ttsClient != nil {
+		ttsClient = limitedTtser{ttsClient}
+	}
+
 	s := &Service{
 		Transcriber:      transcriber,
-		ChatCompleter:    chatCompleter,
+		ChatCompleter:    limitedChatCompleter{chatCompleter},
 		TtsClient:        ttsClient,
 		OssClient:        aliyun.NewOssClient(config.Conf.Transcribe.Aliyun.Oss.AccessKeyId, config.Conf.Transcribe.Aliyun.Oss.AccessKeySecret, config.Conf.Transcribe.Aliyun.Oss.Bucket),
 		VoiceCloneClient: aliyun.NewVoiceCloneClient(config.Conf.Tts.Aliyun.Speech.AccessKeyId, config.Conf.Tts.Aliyun.Speech.AccessKeySecret, config.Conf.Tts.Aliyun.Speech.AppKey),
@@ -76,3 +74,42 @@ func NewService() *Service {
 
 	return s
 }
+
+// newChatCompleter 按llm.provider创建大模型服务，未配置时使用兼容openai接口的客户端
+func newChatCompleter() types.ChatCompleter {
+	switch config.Conf.Llm.Provider {
+	case "anthropic":
+		return anthropic.NewClient(config.Conf.Llm.BaseUrl, config.Conf.Llm.ApiKey, config.Conf.Llm.Model)
+	case "gemini":
+		return gemini.NewClient(config.Conf.Llm.BaseUrl, config.Conf.Llm.ApiKey, config.Conf.Llm.Model)
+	case "ollama":
+		return ollama.NewClient(config.Conf.Llm.BaseUrl, config.Conf.Llm.Model)
+	default:
+		return openai.NewClient(config.Conf.Llm.BaseUrl, config.Conf.Llm.ApiKey, config.Conf.App.Proxy)
+	}
+}
+
+// newTranscriber 按名称创建单个转录服务
+func newTranscriber(provider string) (types.Transcriber, error) {
+	switch provider {
+	case "openai":
+		return whisper.NewClient(config.Conf.Transcribe.Openai.BaseUrl, config.Conf.Transcribe.Openai.ApiKey, config.Conf.App.Proxy), nil
+	case "fasterwhisper":
+		return fasterwhisper.NewFastwhisperProcessor(config.Conf.Transcribe.Fasterwhisper.Model), nil
+	case "whispercpp":
+		return whispercpp.NewWhispercppProcessor(config.Conf.Transcribe.Whispercpp.Model, config.Conf.Transcribe.Whispercpp.ServerUrl), nil
+	case "whisperkit":
+		return whisperkit.NewWhisperKitProcessor(config.Conf.Transcribe.Whisperkit.Model), nil
+	case "whisperx":
+		return whisperx.NewWhisperXProcessor(config.Conf.Transcribe.Whisperx.Model), nil
+	case "aliyun":
+		cc, err := aliyun.NewAsrClient(config.Conf.Transcribe.Aliyun.Speech.AccessKeyId, config.Conf.Transcribe.Aliyun.Speech.AccessKeySecret, config.Conf.Transcribe.Aliyun.Speech.AppKey, true)
+		if err != nil {
+			log.GetLogger().Error("创建阿里云语音识别客户端失败： ", zap.Error(err))
+			return nil, err
+		}
+		return cc, nil
+	default:
+		return nil, fmt.Errorf("unsupported transcribe provider: %s", provider)
+	}
+}
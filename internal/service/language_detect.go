@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"krillin-ai/internal/types"
+	"krillin-ai/log"
+	"krillin-ai/pkg/util"
+	"math"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"go.uber.org/zap"
+)
+
+const (
+	languageProbeDuration = 30.0 // 每次试转录的音频时长，单位：秒
+	languageProbeAttempts = 3    // 试转录内容过少（如片头音乐）时往后顺延的次数
+	minProbeLetters       = 10   // 累计识别出这么多字母或文字后才认为结果可信
+)
+
+var ErrOriginLanguageUndetected = errors.New("无法识别视频的源语言，请手动指定源语言")
+
+// IsAutoOriginLanguage 源语言未填写或为auto时需要自动识别
+func IsAutoOriginLanguage(language string) bool {
+	return language == "" || types.StandardLanguageCode(strings.ToLower(language)) == types.LanguageNameAuto
+}
+
+// resolveOriginLanguage 源语言为auto时，从第一段有人声的音频开始截取一小段试转录，
+// 优先使用转录服务返回的语言，没有返回时按文本判断，结果写回任务参数供后续流程使用
+func (s Service) resolveOriginLanguage(ctx context.Context, stepParam *types.SubtitleTaskStepParam, timePoints []float64) error {
+	if stepParam.OriginLanguage != types.LanguageNameAuto {
+		return nil
+	}
+	start, end := timePoints[0], timePoints[len(timePoints)-1]
+	var (
+		texts    []string
+		reported string // 转录服务返回的语言
+	)
+	for attempt := range languageProbeAttempts {
+		probeStart := start + float64(attempt)*languageProbeDuration
+		if probeStart >= end {
+			break
+		}
+		probeFile := filepath.Join(stepParam.TaskBasePath, fmt.Sprintf(types.SubtitleTaskLanguageProbeFileNamePattern, attempt))
+		if err := ClipAudio(ctx, stepParam.AudioFilePath, probeFile, probeStart, math.Min(probeStart+languageProbeDuration, end)); err != nil {
+			return fmt.Errorf("audioToSubtitle resolveOriginLanguage ClipAudio err: %w", err)
+		}
+		// 不带提示词，避免提示词的语言影响识别结果
+		data, err := s.Transcriber.Transcription(ctx, probeFile, "", stepParam.TaskBasePath, types.TranscriptionHint{})
+		if err != nil {
+			return fmt.Errorf("audioToSubtitle resolveOriginLanguage Transcription err: %w", err)
+		}
+		texts = append(texts, data.Text)
+		if data.Language != "" {
+			reported = data.Language
+		}
+		if countLetters(strings.Join(texts, " ")) >= minProbeLetters {
+			break
+		}
+	}
+	language, err := decideOriginLanguage(reported, strings.Join(texts, " "))
+	if err != nil {
+		log.GetLogger().Error("audioToSubtitle resolveOriginLanguage failed", zap.Any("taskId", stepParam.TaskId), zap.String("reported", reported), zap.Strings("texts", texts))
+		return err
+	}
+	log.GetLogger().Info("audioToSubtitle resolveOriginLanguage detected", zap.Any("taskId", stepParam.TaskId), zap.String("reported", reported), zap.Any("language", language))
+	stepParam.OriginLanguage = language
+	stepParam.OriginLanguageDetected = true
+	stepParam.TaskPtr.OriginLanguage = string(language)
+	return nil
+}
+
+// decideOriginLanguage 几乎没有识别出文字时不采信转录服务返回的语言，whisper在静音和音乐上也会给出一个语言
+func decideOriginLanguage(reported, text string) (types.StandardLanguageCode, error) {
+	if countLetters(text) == 0 {
+		return "", ErrOriginLanguageUndetected
+	}
+	if language, ok := util.NormalizeDetectedLanguage(reported, text); ok {
+		return language, nil
+	}
+	if language, ok := util.DetectLanguageFromText(text); ok {
+		return language, nil
+	}
+	return "", ErrOriginLanguageUndetected
+}
+
+func countLetters(text string) int {
+	count := 0
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"errors"
+	"krillin-ai/internal/types"
+	"testing"
+)
+
+func TestDecideOriginLanguage(t *testing.T) {
+	cases := []struct {
+		reported string
+		text     string
+		want     types.StandardLanguageCode
+	}{
+		{"english", "Hello everyone, welcome back.", types.LanguageNameEnglish},
+		{"zh", "這個問題我們後來再說", types.LanguageNameTraditionalChinese},
+		{"", "今日はいい天気ですね", types.LanguageNameJapanese},
+		{"xx", "Bonjour, je ne sais pas", types.LanguageNameFrench},
+	}
+	for _, c := range cases {
+		got, err := decideOriginLanguage(c.reported, c.text)
+		if err != nil || got != c.want {
+			t.Errorf("decideOriginLanguage(%q, %q) = %q, %v, want %q", c.reported, c.text, got, err, c.want)
+		}
+	}
+
+	// 只有音乐或静音时，不采信转录服务给出的语言
+	if _, err := decideOriginLanguage("en", " ♪ ... "); !errors.Is(err, ErrOriginLanguageUndetected) {
+		t.Fatalf("err = %v, want ErrOriginLanguageUndetected", err)
+	}
+}
+
+func TestIsAutoOriginLanguage(t *testing.T) {
+	for language, want := range map[string]bool{"": true, "auto": true, "AUTO": true, "en": false} {
+		if got := IsAutoOriginLanguage(language); got != want {
+			t.Errorf("IsAutoOriginLanguage(%q) = %v", language, got)
+		}
+	}
+}
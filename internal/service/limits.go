@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"krillin-ai/config"
+	"krillin-ai/internal/types"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// 进程内全局的资源并发上限，所有任务共享；为nil表示不限制
+var (
+	transcribeSem *semaphore.Weighted
+	llmSem        *semaphore.Weighted
+	ttsSem        *semaphore.Weighted
+	ffmpegSem     *semaphore.Weighted
+)
+
+func newLimit(n int) *semaphore.Weighted {
+	if n <= 0 {
+		return nil
+	}
+	return semaphore.NewWeighted(int64(n))
+}
+
+// configureResourceLimits 按配置重建各资源的并发上限
+func configureResourceLimits(c config.Concurrency) {
+	transcribeSem = newLimit(c.Transcribe)
+	llmSem = newLimit(c.Llm)
+	ttsSem = newLimit(c.Tts)
+	ffmpegSem = newLimit(c.Ffmpeg)
+}
+
+// acquireLimit 占用一个并发名额，返回释放函数
+func acquireLimit(ctx context.Context, sem *semaphore.Weighted) (func(), error) {
+	if sem == nil {
+		return func() {}, nil
+	}
+	if err := sem.Acquire(ctx, 1); err != nil {
+		return nil, err
+	}
+	return func() { sem.Release(1) }, nil
+}
+
+func acquireFFmpeg(ctx context.Context) (func(), error) {
+	return acquireLimit(ctx, ffmpegSem)
+}
+
+type limitedTranscriber struct {
+	types.Transcriber
+}
+
+func (t limitedTranscriber) Transcription(ctx context.Context, audioFile, language, wordDir string, hint types.TranscriptionHint) (*types.TranscriptionData, error) {
+	release, err := acquireLimit(ctx, transcribeSem)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return t.Transcriber.Transcription(ctx, audioFile, language, wordDir, hint)
+}
+
+type limitedChatCompleter struct {
+	types.ChatCompleter
+}
+
+func (c limitedChatCompleter) ChatCompletion(ctx context.Context, req types.ChatRequest) (string, error) {
+	release, err := acquireLimit(ctx, llmSem)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	return c.ChatCompleter.ChatCompletion(ctx, req)
+}
+
+type limitedTtser struct {
+	types.Ttser
+}
+
+func (t limitedTtser) Text2Speech(ctx context.Context, text string, voice string, outputFile string) error {
+	release, err := acquireLimit(ctx, ttsSem)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return t.Ttser.Text2Speech(ctx, text, voice, outputFile)
+}
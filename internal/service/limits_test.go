@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+)
+
+type countingTtser struct{ calls int }
+
+func (t *countingTtser) Text2Speech(context.Context, string, string, string) error {
+	t.calls++
+	return nil
+}
+
+func TestLimitedTtserStopsWaitingWhenTaskCancelled(t *testing.T) {
+	original := ttsSem
+	ttsSem = semaphore.NewWeighted(1)
+	t.Cleanup(func() { ttsSem = original })
+	// 名额被其他任务占满
+	if !ttsSem.TryAcquire(1) {
+		t.Fatal("TryAcquire() = false")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	inner := &countingTtser{}
+	done := make(chan error, 1)
+	go func() {
+		done <- limitedTtser{Ttser: inner}.Text2Speech(ctx, "hello", "voice", "out.wav")
+	}()
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) || inner.calls != 0 {
+			t.Fatalf("Text2Speech() error = %v, calls = %d, want canceled without synthesis", err, inner.calls)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Text2Speech() still waiting for a tts slot after cancel")
+	}
+}
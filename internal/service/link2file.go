@@ -24,14 +24,20 @@ func (s Service) linkToFile(ctx context.Context, stepParam *types.SubtitleTaskSt
 	audioPath := fmt.Sprintf("%s/%s", stepParam.TaskBasePath, types.SubtitleTaskAudioFileName)
 	videoPath := fmt.Sprintf("%s/%s", stepParam.TaskBasePath, types.SubtitleTaskVideoFileName)
 	stepParam.TaskPtr.ProcessPct = 3
+	publishTaskStage(stepParam.TaskPtr, TaskStageDownload)
 	if strings.Contains(link, "local:") {
 		// 本地文件
 		videoPath = strings.ReplaceAll(link, "local:", "")
-		cmd := exec.Command(storage.FfmpegPath, "-i", videoPath, "-vn", "-ar", "44100", "-ac", "2", "-ab", "192k", "-f", "mp3", audioPath)
-		output, err = cmd.CombinedOutput()
-		if err != nil {
-			log.GetLogger().Error("generateAudioSubtitles.linkToFile ffmpeg error", zap.Any("step param", stepParam), zap.String("output", string(output)), zap.Error(err))
-			return fmt.Errorf("generateAudioSubtitles.linkToFile ffmpeg error: %w", err)
+		if stepParam.Resume && pathExists(audioPath) {
+			// 断点续跑时复用已提取的音频，保证分段和已有产物一致
+			log.GetLogger().Info("linkToFile reuse extracted audio", zap.String("audioPath", audioPath))
+		} else {
+			cmd := exec.CommandContext(ctx, storage.FfmpegPath, "-i", videoPath, "-vn", "-ar", "44100", "-ac", "2", "-ab", "192k", "-f", "mp3", "-y", audioPath)
+			output, err = cmd.CombinedOutput()
+			if err != nil {
+				log.GetLogger().Error("generateAudioSubtitles.linkToFile ffmpeg error", zap.Any("step param", stepParam), zap.String("output", string(output)), zap.Error(err))
+				return fmt.Errorf("generateAudioSubtitles.linkToFile ffmpeg error: %w", err)
+			}
 		}
 	} else if strings.Contains(link, "youtube.com") {
 		var videoId string
@@ -58,7 +64,7 @@ func (s Service) linkToFile(ctx context.Context, stepParam *types.SubtitleTaskSt
 			if storage.FfmpegPath != "ffmpeg" {
 				cmdArgs = append(cmdArgs, "--ffmpeg-location", storage.FfmpegPath)
 			}
-			cmd := exec.Command(storage.YtdlpPath, cmdArgs...)
+			cmd := exec.CommandContext(ctx, storage.YtdlpPath, cmdArgs...)
 			output, err = cmd.CombinedOutput()
 			if err != nil {
 				log.GetLogger().Error("linkToFile download audio yt-dlp error", zap.Any("step param", stepParam), zap.String("output", string(output)), zap.Error(err))
@@ -78,7 +84,7 @@ func (s Service) linkToFile(ctx context.Context, stepParam *types.SubtitleTaskSt
 		if storage.FfmpegPath != "ffmpeg" {
 			cmdArgs = append(cmdArgs, "--ffmpeg-location", storage.FfmpegPath)
 		}
-		cmd := exec.Command(storage.YtdlpPath, cmdArgs...)
+		cmd := exec.CommandContext(ctx, storage.YtdlpPath, cmdArgs...)
 		output, err = cmd.CombinedOutput()
 		if err != nil {
 			log.GetLogger().Error("linkToFile download audio yt-dlp error", zap.Any("step param", stepParam), zap.String("output", string(output)), zap.Error(err))
@@ -100,7 +106,7 @@ func (s Service) linkToFile(ctx context.Context, stepParam *types.SubtitleTaskSt
 		if storage.FfmpegPath != "ffmpeg" {
 			cmdArgs = append(cmdArgs, "--ffmpeg-location", storage.FfmpegPath)
 		}
-		cmd := exec.Command(storage.YtdlpPath, cmdArgs...)
+		cmd := exec.CommandContext(ctx, storage.YtdlpPath, cmdArgs...)
 		output, err = cmd.CombinedOutput()
 		if err != nil {
 			log.GetLogger().Error("linkToFile download video yt-dlp error", zap.Any("step param", stepParam), zap.String("output", string(output)), zap.Error(err))
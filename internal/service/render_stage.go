@@ -66,7 +66,13 @@ func renderSubtitleFile(ctx context.Context, req RenderVideoRequest) (string, er
 		stepParam = &types.SubtitleTaskStepParam{TaskBasePath: req.Workdir}
 		req.StepParam = stepParam
 	}
-	preparedReq, err := prepareSubtitleRenderLayout(req, getResolution, convertToVertical)
+	convert := func(inputVideo, outputVideo, majorTitle, minorTitle string) error {
+		return convertToVertical(ctx, inputVideo, outputVideo, majorTitle, minorTitle)
+	}
+	probe := func(inputVideo string) (int, int, error) {
+		return getResolution(ctx, inputVideo)
+	}
+	preparedReq, err := prepareSubtitleRenderLayout(req, probe, convert)
 	if err != nil {
 		return "", fmt.Errorf("renderSubtitleFile prepare subtitle layout error: %w", err)
 	}
@@ -75,6 +81,11 @@ func renderSubtitleFile(ctx context.Context, req RenderVideoRequest) (string, er
 		return "", fmt.Errorf("renderSubtitleFile srtToAss error: %w", err)
 	}
 	args, _ := buildEmbedSubtitleArgs(req)
+	release, err := acquireFFmpeg(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
 	cmd := exec.CommandContext(ctx, storage.FfmpegPath, args...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"krillin-ai/config"
+	"krillin-ai/internal/dto"
+	"krillin-ai/internal/storage"
+	"krillin-ai/internal/types"
+	"krillin-ai/log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// resumeCheckpoint 持久化到 step_param.gob，记录生成分段产物时的设置，断点续跑时据此判断产物能否复用
+type resumeCheckpoint struct {
+	OriginLanguage     types.StandardLanguageCode
+	TargetLanguage     types.StandardLanguageCode
+	TranscribeProvider string
+	TranscribeModel    string
+	LlmModel           string
+	EnableModalFilter  bool
+	Diarization        bool
+	Hallucination      string // 幻觉内容过滤方式，未开启时为空
+	TranscribePrompt   string // 转录提示词，热词和背景说明变化后需要重新转录
+	Glossary           string // 翻译术语表摘要，术语变化后需要重新翻译
+	TimePoints         []float64
+}
+
+// segmentArtifacts 可复用的分段转录和翻译结果，key为分段id
+type segmentArtifacts struct {
+	transcriptions map[int]*types.TranscriptionData
+	translations   map[int][]*TranslatedItem
+}
+
+// resumableTaskId 校验待恢复的任务，返回沿用的任务id
+func resumableTaskId(req dto.StartVideoSubtitleTaskReq) (string, error) {
+	taskId := req.ResumeTaskId
+	if taskId != filepath.Base(taskId) || strings.Contains(taskId, "..") {
+		return "", errors.New("待恢复的任务id不合法")
+	}
+	if task, err := storage.SubtitleTasks.Get(taskId); err == nil {
+		if task.Status == types.SubtitleTaskStatusProcessing {
+			return "", errors.New("任务正在处理中，无需恢复")
+		}
+		if task.VideoSrc != "" && task.VideoSrc != req.Url {
+			return "", errors.New("待恢复任务的视频链接与本次请求不一致")
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tasksDir, taskId)); err != nil {
+		return "", errors.New("待恢复的任务目录不存在")
+	}
+	return taskId, nil
+}
+
+func newResumeCheckpoint(stepParam *types.SubtitleTaskStepParam, timePoints []float64) resumeCheckpoint {
+	return resumeCheckpoint{
+		OriginLanguage:     stepParam.OriginLanguage,
+		TargetLanguage:     stepParam.TargetLanguage,
+		TranscribeProvider: config.Conf.Transcribe.Provider.String(),
+		TranscribeModel:    transcribeModelNames(),
+		LlmModel:           config.Conf.Llm.Model,
+		EnableModalFilter:  stepParam.EnableModalFilter,
+		Diarization:        config.Conf.Transcribe.Diarization.Enable,
+		Hallucination:      hallucinationAction(),
+		TranscribePrompt:   stepParam.TranscriptionHint.Prompt(),
+		Glossary:           stepParam.Glossary.ForLanguage(stepParam.TargetLanguage).Digest(),
+		TimePoints:         timePoints,
+	}
+}
+
+// transcribeModelNames 转录服务链中各服务使用的模型，顺序与provider一致
+func transcribeModelNames() string {
+	models := make([]string, 0, len(config.Conf.Transcribe.Provider))
+	for _, provider := range config.Conf.Transcribe.Provider {
+		models = append(models, transcribeModelName(provider))
+	}
+	return strings.Join(models, ",")
+}
+
+func transcribeModelName(provider string) string {
+	switch provider {
+	case "openai":
+		return config.Conf.Transcribe.Openai.Model
+	case "fasterwhisper":
+		return config.Conf.Transcribe.Fasterwhisper.Model
+	case "whisperkit":
+		return config.Conf.Transcribe.Whisperkit.Model
+	case "whispercpp":
+		if config.Conf.Transcribe.Whispercpp.ServerUrl != "" {
+			return config.Conf.Transcribe.Whispercpp.ServerUrl
+		}
+		return config.Conf.Transcribe.Whispercpp.Model
+	case "whisperx":
+		return fmt.Sprintf("%s/%s/align=%t", config.Conf.Transcribe.Whisperx.Model, config.Conf.Transcribe.Whisperx.ComputeTypeFor(config.Conf.Transcribe.EnableGpuAcceleration), config.Conf.Transcribe.Whisperx.Align)
+	default:
+		return ""
+	}
+}
+
+func hallucinationAction() string {
+	if !config.Conf.Transcribe.Hallucination.Enable {
+		return ""
+	}
+	return config.Conf.Transcribe.Hallucination.Action
+}
+
+// transcriptionMatches 转录产物依赖源语言、转录服务、说话人识别、幻觉过滤设置和转录提示词，分段时间点由调用方另行比较
+func (c resumeCheckpoint) transcriptionMatches(other resumeCheckpoint) bool {
+	return c.OriginLanguage == other.OriginLanguage &&
+		c.TranscribeProvider == other.TranscribeProvider &&
+		c.TranscribeModel == other.TranscribeModel &&
+		c.Diarization == other.Diarization &&
+		c.Hallucination == other.Hallucination &&
+		c.TranscribePrompt == other.TranscribePrompt
+}
+
+// translationMatches 翻译产物还依赖目标语言、大模型、语气词过滤设置和术语表
+func (c resumeCheckpoint) translationMatches(other resumeCheckpoint) bool {
+	return c.transcriptionMatches(other) &&
+		c.TargetLanguage == other.TargetLanguage &&
+		c.LlmModel == other.LlmModel &&
+		c.EnableModalFilter == other.EnableModalFilter &&
+		c.Glossary == other.Glossary
+}
+
+func saveResumeCheckpoint(taskBasePath string, checkpoint resumeCheckpoint) error {
+	file, err := os.Create(filepath.Join(taskBasePath, types.SubtitleTaskStepParamGobPersistenceFileName))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return gob.NewEncoder(file).Encode(checkpoint)
+}
+
+func loadResumeCheckpoint(taskBasePath string) (*resumeCheckpoint, error) {
+	file, err := os.Open(filepath.Join(taskBasePath, types.SubtitleTaskStepParamGobPersistenceFileName))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var checkpoint resumeCheckpoint
+	if err := gob.NewDecoder(file).Decode(&checkpoint); err != nil {
+		return nil, err
+	}
+	return &checkpoint, nil
+}
+
+// resumeTimePoints 断点续跑时优先复用上次的分段时间点，保证分段id和已有产物一一对应
+func resumeTimePoints(stepParam *types.SubtitleTaskStepParam) ([]float64, bool) {
+	if !stepParam.Resume {
+		return nil, false
+	}
+	previous, err := loadResumeCheckpoint(stepParam.TaskBasePath)
+	if err != nil {
+		log.GetLogger().Info("resume checkpoint not found, start from scratch", zap.Any("taskId", stepParam.TaskId), zap.Error(err))
+		return nil, false
+	}
+	// 源语言为auto时沿用上次识别出的语言，不再重新识别
+	if stepParam.OriginLanguage == types.LanguageNameAuto && previous.OriginLanguage != "" && previous.OriginLanguage != types.LanguageNameAuto {
+		stepParam.OriginLanguage = previous.OriginLanguage
+		stepParam.OriginLanguageDetected = true
+		stepParam.TaskPtr.OriginLanguage = string(previous.OriginLanguage)
+	}
+	current := newResumeCheckpoint(stepParam, nil)
+	if !previous.transcriptionMatches(current) || len(previous.TimePoints) < 2 {
+		log.GetLogger().Warn("resume checkpoint does not match current settings, start from scratch",
+			zap.Any("taskId", stepParam.TaskId), zap.Any("previous", previous), zap.Any("current", current))
+		return nil, false
+	}
+	return previous.TimePoints, true
+}
+
+// loadSegmentArtifacts 读取与当前设置一致的分段产物，解析失败的分段会被重新处理
+func loadSegmentArtifacts(stepParam *types.SubtitleTaskStepParam, previous *resumeCheckpoint, current resumeCheckpoint) *segmentArtifacts {
+	artifacts := &segmentArtifacts{
+		transcriptions: make(map[int]*types.TranscriptionData),
+		translations:   make(map[int][]*TranslatedItem),
+	}
+	if !stepParam.Resume || previous == nil || !previous.transcriptionMatches(current) || !slices.Equal(previous.TimePoints, current.TimePoints) {
+		return artifacts
+	}
+	reuseTranslation := previous.translationMatches(current)
+	for id := range len(current.TimePoints) - 1 {
+		var transcriptionData types.TranscriptionData
+		transcriptionFile := filepath.Join(stepParam.TaskBasePath, fmt.Sprintf(types.SubtitleTaskAudioTranscriptionDataPersistenceFileNamePattern, id))
+		if err := loadJSONArtifact(transcriptionFile, &transcriptionData); err != nil {
+			continue
+		}
+		artifacts.transcriptions[id] = &transcriptionData
+		if !reuseTranslation {
+			continue
+		}
+		var translatedItems []*TranslatedItem
+		translationFile := filepath.Join(stepParam.TaskBasePath, fmt.Sprintf(types.SubtitleTaskTranslationDataPersistenceFileNamePattern, id))
+		if err := loadJSONArtifact(translationFile, &translatedItems); err != nil {
+			continue
+		}
+		artifacts.translations[id] = translatedItems
+	}
+	log.GetLogger().Info("resume segment artifacts loaded", zap.Any("taskId", stepParam.TaskId),
+		zap.Int("segments", len(current.TimePoints)-1), zap.Int("transcribed", len(artifacts.transcriptions)),
+		zap.Int("translated", len(artifacts.translations)), zap.Bool("reuseTranslation", reuseTranslation))
+	return artifacts
+}
+
+func loadJSONArtifact(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"fmt"
+	"krillin-ai/config"
+	"krillin-ai/internal/types"
+	"krillin-ai/log"
+	"krillin-ai/pkg/util"
+	"path/filepath"
+	"testing"
+)
+
+func writeResumeArtifacts(t *testing.T, dir string, segments int) {
+	t.Helper()
+	for id := range segments {
+		transcription := &types.TranscriptionData{Language: "en", Text: fmt.Sprintf("segment %d", id)}
+		if err := util.SaveToDisk(transcription, filepath.Join(dir, fmt.Sprintf(types.SubtitleTaskAudioTranscriptionDataPersistenceFileNamePattern, id))); err != nil {
+			t.Fatalf("save transcription: %v", err)
+		}
+		translation := []*TranslatedItem{{OriginText: transcription.Text, TranslatedText: fmt.Sprintf("片段 %d", id)}}
+		if err := util.SaveToDisk(translation, filepath.Join(dir, fmt.Sprintf(types.SubtitleTaskTranslationDataPersistenceFileNamePattern, id))); err != nil {
+			t.Fatalf("save translation: %v", err)
+		}
+	}
+}
+
+func TestLoadSegmentArtifactsReusesMatchingSegments(t *testing.T) {
+	log.InitLogger()
+	dir := t.TempDir()
+	stepParam := &types.SubtitleTaskStepParam{TaskBasePath: dir, Resume: true, OriginLanguage: "en", TargetLanguage: "zh_cn"}
+	timePoints := []float64{0, 300, 600, 900}
+	previous := newResumeCheckpoint(stepParam, timePoints)
+	if err := saveResumeCheckpoint(dir, previous); err != nil {
+		t.Fatalf("saveResumeCheckpoint() error = %v", err)
+	}
+	writeResumeArtifacts(t, dir, 2) // 第3段未完成
+
+	loaded, err := loadResumeCheckpoint(dir)
+	if err != nil {
+		t.Fatalf("loadResumeCheckpoint() error = %v", err)
+	}
+	artifacts := loadSegmentArtifacts(stepParam, loaded, newResumeCheckpoint(stepParam, timePoints))
+	if len(artifacts.transcriptions) != 2 || len(artifacts.translations) != 2 {
+		t.Fatalf("reused %d transcriptions and %d translations, want 2 and 2", len(artifacts.transcriptions), len(artifacts.translations))
+	}
+	if artifacts.translations[1][0].TranslatedText != "片段 1" {
+		t.Fatalf("translation = %+v", artifacts.translations[1][0])
+	}
+	if _, ok := artifacts.transcriptions[2]; ok {
+		t.Fatal("unfinished segment should not be reused")
+	}
+}
+
+func TestLoadSegmentArtifactsChecksSettings(t *testing.T) {
+	log.InitLogger()
+	dir := t.TempDir()
+	stepParam := &types.SubtitleTaskStepParam{TaskBasePath: dir, Resume: true, OriginLanguage: "en", TargetLanguage: "zh_cn"}
+	timePoints := []float64{0, 300, 600}
+	previous := newResumeCheckpoint(stepParam, timePoints)
+	writeResumeArtifacts(t, dir, 2)
+
+	retarget := *stepParam
+	retarget.TargetLanguage = "ja"
+	artifacts := loadSegmentArtifacts(&retarget, &previous, newResumeCheckpoint(&retarget, timePoints))
+	if len(artifacts.transcriptions) != 2 || len(artifacts.translations) != 0 {
+		t.Fatalf("target language change: reused %d transcriptions and %d translations, want 2 and 0", len(artifacts.transcriptions), len(artifacts.translations))
+	}
+
+	originalProvider := config.Conf.Transcribe.Provider
+	t.Cleanup(func() { config.Conf.Transcribe.Provider = originalProvider })
+	config.Conf.Transcribe.Provider = config.ProviderChain{"aliyun"}
+	artifacts = loadSegmentArtifacts(stepParam, &previous, newResumeCheckpoint(stepParam, timePoints))
+	if len(artifacts.transcriptions) != 0 || len(artifacts.translations) != 0 {
+		t.Fatalf("provider change: reused %d transcriptions and %d translations, want none", len(artifacts.transcriptions), len(artifacts.translations))
+	}
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"krillin-ai/config"
+	"krillin-ai/internal/storage"
+	"krillin-ai/internal/types"
+	"krillin-ai/log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// tasksDir 服务端任务工作目录的根目录
+const tasksDir = "./tasks"
+
+// taskWorkdir 返回任务目录，任务id不合法时返回错误，避免删除到任务根目录之外
+func taskWorkdir(taskId string) (string, error) {
+	if taskId == "" || taskId != filepath.Base(taskId) || strings.Contains(taskId, "..") {
+		return "", errors.New("任务id不合法")
+	}
+	return filepath.Join(tasksDir, taskId), nil
+}
+
+// DeleteTask 删除已结束的任务记录及其任务目录
+func (s Service) DeleteTask(taskId string) error {
+	taskPtr, err := storage.SubtitleTasks.Get(taskId)
+	if err != nil {
+		return err
+	}
+	if taskPtr.Status == types.SubtitleTaskStatusProcessing {
+		return errors.New("任务处理中，请先取消任务")
+	}
+	return deleteTask(taskId)
+}
+
+func deleteTask(taskId string) error {
+	workdir, err := taskWorkdir(taskId)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(workdir); err != nil {
+		return err
+	}
+	taskSaveMu.Lock()
+	defer taskSaveMu.Unlock()
+	return storage.SubtitleTasks.Delete(taskId)
+}
+
+// StartTaskRetention 按配置周期性清理已结束任务的目录，ctx结束后停止
+func StartTaskRetention(ctx context.Context, retention config.Retention) {
+	if retention.IntervalMinutes <= 0 || (retention.MaxAgeHours <= 0 && retention.MaxTotalSizeMb <= 0 && !retention.KeepFinalOutputsOnly) {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(time.Duration(retention.IntervalMinutes) * time.Minute)
+		defer ticker.Stop()
+		for {
+			pruneTasks(retention, time.Now())
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// pruneTasks 依次执行：删除过期任务、精简成功任务的中间文件、按总大小从最早的任务开始删除
+func pruneTasks(retention config.Retention, now time.Time) {
+	tasks, err := storage.SubtitleTasks.List()
+	if err != nil {
+		log.GetLogger().Error("pruneTasks list tasks err", zap.Error(err))
+		return
+	}
+	var deleted, pruned int
+	var freed int64
+	remaining := make([]*types.SubtitleTask, 0, len(tasks))
+	for _, task := range tasks {
+		if task.Status == types.SubtitleTaskStatusProcessing {
+			remaining = append(remaining, task)
+			continue
+		}
+		if retention.MaxAgeHours > 0 && now.Sub(time.Unix(task.UpdateTime, 0)) > time.Duration(retention.MaxAgeHours)*time.Hour {
+			size := taskDirSize(task.TaskId)
+			if err := deleteTask(task.TaskId); err != nil {
+				log.GetLogger().Warn("pruneTasks delete expired task err", zap.String("taskId", task.TaskId), zap.Error(err))
+				remaining = append(remaining, task)
+				continue
+			}
+			deleted++
+			freed += size
+			continue
+		}
+		if retention.KeepFinalOutputsOnly && task.Status == types.SubtitleTaskStatusSuccess {
+			size, err := pruneIntermediates(task)
+			if err != nil {
+				log.GetLogger().Warn("pruneTasks prune intermediates err", zap.String("taskId", task.TaskId), zap.Error(err))
+			}
+			if size > 0 {
+				pruned++
+				freed += size
+			}
+		}
+		remaining = append(remaining, task)
+	}
+
+	if retention.MaxTotalSizeMb > 0 {
+		limit := int64(retention.MaxTotalSizeMb) << 20
+		sizes := make(map[string]int64, len(remaining))
+		var total int64
+		for _, task := range remaining {
+			sizes[task.TaskId] = taskDirSize(task.TaskId)
+			total += sizes[task.TaskId]
+		}
+		// List按创建时间倒序，从末尾开始删除最早的任务
+		for i := len(remaining) - 1; i >= 0 && total > limit; i-- {
+			task := remaining[i]
+			if task.Status == types.SubtitleTaskStatusProcessing {
+				continue
+			}
+			if err := deleteTask(task.TaskId); err != nil {
+				log.GetLogger().Warn("pruneTasks delete task over size limit err", zap.String("taskId", task.TaskId), zap.Error(err))
+				continue
+			}
+			total -= sizes[task.TaskId]
+			freed += sizes[task.TaskId]
+			deleted++
+		}
+	}
+	if deleted > 0 || pruned > 0 {
+		log.GetLogger().Info("任务目录清理完成", zap.Int("deleted", deleted), zap.Int("pruned", pruned), zap.Int64("freedBytes", freed))
+	}
+}
+
+// finalOutputs 任务需要保留的最终产物，路径相对于任务目录
+func finalOutputs(task *types.SubtitleTask) map[string]bool {
+	keep := map[string]bool{
+		"output":                               true,
+		types.TtsResultAudioFileName:           true,
+		types.SubtitleTaskVideoWithTtsFileName: true,
+		// 附加目标语言的目录不切分音频，中间产物只有少量字幕和json文件，整体保留
+		types.SubtitleTaskLanguagesDirName: true,
+	}
+	workdir, _ := taskWorkdir(task.TaskId)
+	urls := []string{task.SpeechDownloadUrl}
+	for _, info := range task.SubtitleInfos {
+		urls = append(urls, info.DownloadUrl)
+	}
+	for _, url := range urls {
+		path := strings.TrimPrefix(url, "/api/file/")
+		if rel, err := filepath.Rel(workdir, path); err == nil && !strings.HasPrefix(rel, "..") {
+			keep[filepath.ToSlash(rel)] = true
+		}
+	}
+	return keep
+}
+
+// pruneIntermediates 删除任务目录中最终产物以外的文件，返回释放的字节数
+func pruneIntermediates(task *types.SubtitleTask) (int64, error) {
+	workdir, err := taskWorkdir(task.TaskId)
+	if err != nil {
+		return 0, err
+	}
+	entries, err := os.ReadDir(workdir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	keep := finalOutputs(task)
+	var freed int64
+	for _, entry := range entries {
+		if keep[entry.Name()] {
+			continue
+		}
+		path := filepath.Join(workdir, entry.Name())
+		size := pathSize(path)
+		if err := os.RemoveAll(path); err != nil {
+			return freed, err
+		}
+		freed += size
+	}
+	return freed, nil
+}
+
+func taskDirSize(taskId string) int64 {
+	workdir, err := taskWorkdir(taskId)
+	if err != nil {
+		return 0
+	}
+	return pathSize(workdir)
+}
+
+func pathSize(path string) int64 {
+	var size int64
+	_ = filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && !d.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"fmt"
+	"krillin-ai/config"
+	"krillin-ai/internal/dto"
+	"krillin-ai/internal/storage"
+	"krillin-ai/internal/types"
+	"krillin-ai/log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func useTempTasks(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd() error = %v", err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("Chdir() error = %v", err)
+	}
+	previous := storage.SubtitleTasks
+	storage.SubtitleTasks = storage.NewMemoryTaskStore()
+	t.Cleanup(func() {
+		storage.SubtitleTasks = previous
+		_ = os.Chdir(wd)
+	})
+}
+
+func writeTaskFile(t *testing.T, taskId, name string, size int) {
+	t.Helper()
+	path := filepath.Join(tasksDir, taskId, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+}
+
+func TestPruneTasksAppliesRetentionPolicy(t *testing.T) {
+	log.InitLogger()
+	useTempTasks(t)
+	now := time.Now()
+
+	// Save会刷新更新时间，过期任务直接写入任务记录文件
+	storeDir := t.TempDir()
+	expired := fmt.Sprintf(`{"task_id":"expired","status":%d,"update_time":%d}`, types.SubtitleTaskStatusFailed, now.Add(-48*time.Hour).Unix())
+	if err := os.WriteFile(filepath.Join(storeDir, "expired.json"), []byte(expired), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	store, err := storage.NewFileTaskStore(storeDir)
+	if err != nil {
+		t.Fatalf("NewFileTaskStore() error = %v", err)
+	}
+	storage.SubtitleTasks = store
+	done := &types.SubtitleTask{TaskId: "done", Status: types.SubtitleTaskStatusSuccess,
+		SubtitleInfos: []types.SubtitleInfo{{DownloadUrl: "/api/file/tasks/done/bilingual_srt_replaced.srt"}}}
+	running := &types.SubtitleTask{TaskId: "running", Status: types.SubtitleTaskStatusProcessing}
+	for _, task := range []*types.SubtitleTask{done, running} {
+		_ = storage.SubtitleTasks.Save(task)
+	}
+	writeTaskFile(t, "expired", types.SubtitleTaskAudioFileName, 10)
+	writeTaskFile(t, "done", "split_audio_001.mp3", 10)
+	writeTaskFile(t, "done", types.SubtitleTaskVideoFileName, 10)
+	writeTaskFile(t, "done", "bilingual_srt_replaced.srt", 10)
+	writeTaskFile(t, "done", filepath.Join("output", types.SubtitleTaskHorizontalEmbedVideoFileName), 10)
+	writeTaskFile(t, "running", "split_audio_001.mp3", 10)
+
+	pruneTasks(config.Retention{MaxAgeHours: 24, KeepFinalOutputsOnly: true}, now)
+
+	if _, err := storage.SubtitleTasks.Get("expired"); err == nil {
+		t.Fatal("expired task record not deleted")
+	}
+	if _, err := os.Stat(filepath.Join(tasksDir, "expired")); !os.IsNotExist(err) {
+		t.Fatalf("expired workdir still exists: %v", err)
+	}
+	for name, want := range map[string]bool{
+		"done/split_audio_001.mp3":                                      false,
+		"done/" + types.SubtitleTaskVideoFileName:                       false,
+		"done/bilingual_srt_replaced.srt":                               true,
+		"done/output/" + types.SubtitleTaskHorizontalEmbedVideoFileName: true,
+		"running/split_audio_001.mp3":                                   true,
+	} {
+		_, err := os.Stat(filepath.Join(tasksDir, name))
+		if got := err == nil; got != want {
+			t.Fatalf("%s exists = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestPruneTasksEnforcesTotalSize(t *testing.T) {
+	log.InitLogger()
+	useTempTasks(t)
+	for i, taskId := range []string{"oldest", "middle", "newest"} {
+		_ = storage.SubtitleTasks.Save(&types.SubtitleTask{TaskId: taskId, Status: types.SubtitleTaskStatusSuccess, CreateTime: int64(i + 1)})
+		writeTaskFile(t, taskId, types.SubtitleTaskAudioFileName, 600<<10)
+	}
+
+	pruneTasks(config.Retention{MaxTotalSizeMb: 1}, time.Now())
+
+	data, err := (Service{}).ListTasks(dto.ListVideoSubtitleTasksReq{})
+	if err != nil {
+		t.Fatalf("ListTasks() error = %v", err)
+	}
+	if data.Total != 1 || data.Tasks[0].TaskId != "newest" {
+		t.Fatalf("remaining tasks = %#v, want only newest", data.Tasks)
+	}
+}
+
+func TestListTasksPagesAndFilters(t *testing.T) {
+	useTempTasks(t)
+	for i := range 5 {
+		status := types.SubtitleTaskStatusSuccess
+		if i%2 == 0 {
+			status = types.SubtitleTaskStatusFailed
+		}
+		_ = storage.SubtitleTasks.Save(&types.SubtitleTask{TaskId: string(rune('a' + i)), Status: status, CreateTime: int64(i)})
+	}
+	data, err := (Service{}).ListTasks(dto.ListVideoSubtitleTasksReq{Page: 2, PageSize: 2, Status: []uint8{types.SubtitleTaskStatusFailed}})
+	if err != nil {
+		t.Fatalf("ListTasks() error = %v", err)
+	}
+	if data.Total != 3 || len(data.Tasks) != 1 || data.Tasks[0].TaskId != "a" {
+		t.Fatalf("ListTasks() = total %d, tasks %#v", data.Total, data.Tasks)
+	}
+}
@@ -1,14 +1,20 @@
 package service
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"krillin-ai/config"
 	"krillin-ai/internal/storage"
+	"krillin-ai/log"
 	"krillin-ai/pkg/util"
+	"krillin-ai/pkg/vad"
 	"math"
 	"os/exec"
 	"runtime"
 
+	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -19,13 +25,15 @@ const (
 	TOLERANCE_DURATION     = 8   // 容忍的时间误差
 	MIN_DURATION           = 10  // 最小音频时长
 	MIN_SEGMENT_DURATION   = 20  // 最小分割时长
+
+	minSplitGap = 0.2 // 分割点所在的静音至少要这么长，否则退回按能量查找
 )
 
-func buildFFmpegCmd(input string, start, end float64) (*exec.Cmd, error) {
+func buildFFmpegCmd(ctx context.Context, input string, start, end float64) (*exec.Cmd, error) {
 	if start < 0 || end <= start {
 		return nil, fmt.Errorf("invalid start or end time: start=%f, end=%f", start, end)
 	}
-	cmd := exec.Command(
+	cmd := exec.CommandContext(ctx,
 		storage.FfmpegPath,
 		"-y",
 		"-ss", fmt.Sprintf("%.3f", start), // 起始时间
@@ -41,8 +49,8 @@ func buildFFmpegCmd(input string, start, end float64) (*exec.Cmd, error) {
 	return cmd, nil
 }
 
-func getQuietestTimePoint(input string, start, end float64) (second float64, err error) {
-	cmd, err := buildFFmpegCmd(input, start, end)
+func getQuietestTimePoint(ctx context.Context, input string, start, end float64) (second float64, err error) {
+	cmd, err := buildFFmpegCmd(ctx, input, start, end)
 	if err != nil {
 		return 0, fmt.Errorf("failed to build ffmpeg command: %w", err)
 	}
@@ -103,28 +111,36 @@ func getQuietestTimePoint(input string, start, end float64) (second float64, err
 	return float64(minEnergyIndex)/SAMPLE_RATE + start, nil
 }
 
-func GetSplitPoints(input string, segmentDuration float64) ([]float64, error) {
+func GetSplitPoints(ctx context.Context, input string, segmentDuration float64) ([]float64, error) {
 	if segmentDuration < MIN_SEGMENT_DURATION {
 		return nil, fmt.Errorf("segment duration must be greater than %v seconds", MIN_SEGMENT_DURATION)
 	}
 
-	audioDuration, err := util.GetAudioDuration(input)
+	audioDuration, err := util.GetAudioDuration(ctx, input)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get audio duration: %w", err)
 	}
-	segmentNum := int(math.Ceil(audioDuration / segmentDuration))
-	timePoints := make([]float64, segmentNum+1)
-	for i := range segmentNum {
-		timePoints[i] = float64(i) * segmentDuration
+	var regions []vad.Region
+	if config.Conf.App.Vad.Enable {
+		regions, err = detectSpeechRegions(ctx, input)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			// 语音检测失败时退回只按能量找切分点
+			log.GetLogger().Warn("GetSplitPoints detect speech regions err, fallback to energy", zap.String("input", input), zap.Error(err))
+		}
 	}
+	timePoints, unresolved := planSplitPoints(regions, audioDuration, segmentDuration, config.Conf.App.Vad.TrimSilence)
+	segmentNum := len(timePoints) - 1
 	eg := errgroup.Group{}
 	eg.SetLimit(runtime.NumCPU())
-	for i := 1; i < segmentNum; i++ {
+	for _, i := range unresolved {
 		i := i
 		eg.Go(func() error {
-			start := timePoints[i] - TOLERANCE_DURATION
-			end := timePoints[i] + TOLERANCE_DURATION
-			timePoint, err := getQuietestTimePoint(input, start, end)
+			start := math.Max(timePoints[i]-TOLERANCE_DURATION, timePoints[0])
+			end := math.Min(timePoints[i]+TOLERANCE_DURATION, timePoints[segmentNum])
+			timePoint, err := getQuietestTimePoint(ctx, input, start, end)
 			if err != nil {
 				return fmt.Errorf("failed to get quietest time point: %w", err)
 			}
@@ -136,18 +152,86 @@ func GetSplitPoints(input string, segmentDuration float64) ([]float64, error) {
 		return nil, fmt.Errorf("failed to get quietest time points: %w", err)
 	}
 	// 如果最后一个片段短于最小分割时长，则将其合并到前一个片段
-	if audioDuration-timePoints[segmentNum-1] < MIN_DURATION {
-		timePoints = timePoints[:segmentNum]
+	if segmentNum > 1 && timePoints[segmentNum]-timePoints[segmentNum-1] < MIN_DURATION {
+		timePoints = append(timePoints[:segmentNum-1], timePoints[segmentNum])
 	}
-	timePoints[len(timePoints)-1] = audioDuration
 	return timePoints, nil
 }
 
-func ClipAudio(input, output string, start, end float64) error {
+// planSplitPoints 根据语音段规划分割点。首尾超过trimSilence的静音被裁掉，
+// 每个分割点取目标时间附近重叠最长的静音段的中点；附近没有静音的分割点下标通过unresolved返回，由调用方按能量查找
+func planSplitPoints(regions []vad.Region, audioDuration, segmentDuration, trimSilence float64) (timePoints []float64, unresolved []int) {
+	start, end := 0.0, audioDuration
+	if len(regions) > 0 && trimSilence > 0 {
+		if regions[0].Start > trimSilence {
+			start = regions[0].Start
+		}
+		if last := regions[len(regions)-1].End; audioDuration-last > trimSilence {
+			end = last
+		}
+	}
+	segmentNum := max(int(math.Ceil((end-start)/segmentDuration)), 1)
+	timePoints = make([]float64, segmentNum+1)
+	for i := range segmentNum {
+		timePoints[i] = start + float64(i)*segmentDuration
+	}
+	timePoints[segmentNum] = end
+
+	gaps := vad.Gaps(regions, audioDuration)
+	for i := 1; i < segmentNum; i++ {
+		windowStart := math.Max(timePoints[i]-TOLERANCE_DURATION, start)
+		windowEnd := math.Min(timePoints[i]+TOLERANCE_DURATION, end)
+		best := vad.Region{}
+		for _, gap := range gaps {
+			clipped := vad.Region{Start: math.Max(gap.Start, windowStart), End: math.Min(gap.End, windowEnd)}
+			if clipped.Duration() > best.Duration() {
+				best = clipped
+			}
+		}
+		if len(regions) == 0 || best.Duration() < minSplitGap {
+			unresolved = append(unresolved, i)
+			continue
+		}
+		timePoints[i] = (best.Start + best.End) / 2
+	}
+	return timePoints, unresolved
+}
+
+// detectSpeechRegions 将整段音频解码后流式送入语音检测，返回语音段
+func detectSpeechRegions(ctx context.Context, input string) ([]vad.Region, error) {
+	opts := vad.DefaultOptions()
+	opts.ThresholdDb = config.Conf.App.Vad.ThresholdDb
+	detector := vad.NewDetector(opts)
+	cmd := exec.CommandContext(ctx,
+		storage.FfmpegPath,
+		"-i", input,
+		"-f", "s16le",
+		"-ar", fmt.Sprintf("%d", vad.SampleRate),
+		"-ac", "1",
+		"-af", "highpass=f=80",
+		"pipe:1",
+	)
+	var stderr bytes.Buffer
+	cmd.Stdout = detector
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg command run failed: [%s] %w: %s", cmd.String(), err, stderr.String())
+	}
+	regions := detector.Regions()
+	speech := 0.0
+	for _, region := range regions {
+		speech += region.Duration()
+	}
+	log.GetLogger().Info("detectSpeechRegions completed", zap.String("input", input), zap.Int("regions", len(regions)),
+		zap.Float64("speechSeconds", speech), zap.Float64("audioSeconds", detector.Duration()))
+	return regions, nil
+}
+
+func ClipAudio(ctx context.Context, input, output string, start, end float64) error {
 	if start < 0 || end <= start {
 		return fmt.Errorf("invalid start or end time: start=%f, end=%f", start, end)
 	}
-	cmd := exec.Command(
+	cmd := exec.CommandContext(ctx,
 		storage.FfmpegPath,
 		"-y",
 		"-ss", fmt.Sprintf("%.3f", start), // 起始时间
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"krillin-ai/pkg/vad"
+	"slices"
+	"testing"
+)
+
+func TestPlanSplitPointsCutsInSilenceAndTrimsEdges(t *testing.T) {
+	regions := []vad.Region{
+		{Start: 30, End: 95},
+		{Start: 97, End: 150}, // 目标分割点90附近只有95-97的停顿
+		{Start: 151, End: 200},
+	}
+	timePoints, unresolved := planSplitPoints(regions, 260, 60, 2)
+	if want := []float64{30, 96, 150.5, 200}; !slices.Equal(timePoints, want) || len(unresolved) != 0 {
+		t.Fatalf("timePoints = %v, unresolved = %v, want %v", timePoints, unresolved, want)
+	}
+
+	// 不裁剪首尾时按整段音频规划
+	timePoints, _ = planSplitPoints(regions, 260, 60, 0)
+	if timePoints[0] != 0 || timePoints[len(timePoints)-1] != 260 {
+		t.Fatalf("untrimmed timePoints = %v", timePoints)
+	}
+}
+
+func TestPlanSplitPointsReportsPointsWithoutSilence(t *testing.T) {
+	timePoints, unresolved := planSplitPoints([]vad.Region{{Start: 0, End: 130}}, 130, 60, 2)
+	if !slices.Equal(unresolved, []int{1, 2}) || !slices.Equal(timePoints, []float64{0, 60, 120, 130}) {
+		t.Fatalf("timePoints = %v, unresolved = %v", timePoints, unresolved)
+	}
+
+	// 没有检测结果时全部退回按能量查找，且不裁剪
+	timePoints, unresolved = planSplitPoints(nil, 130, 60, 2)
+	if !slices.Equal(unresolved, []int{1, 2}) || timePoints[0] != 0 || timePoints[3] != 130 {
+		t.Fatalf("timePoints = %v, unresolved = %v", timePoints, unresolved)
+	}
+}
@@ -59,6 +59,7 @@ func (s Service) srtFileToSpeech(ctx context.Context, stepParam *types.SubtitleT
 		outputVideo = filepath.Join(stepParam.TaskBasePath, types.SubtitleTaskVideoWithTtsFileName)
 	}
 
+	publishTaskStage(stepParam.TaskPtr, TaskStageTts)
 	runner := dubbing.NewRunner(dubbing.Dependencies{
 		TTS:         s.TtsClient,
 		Chat:        s.ChatCompleter,
@@ -80,11 +81,17 @@ func (s Service) srtFileToSpeech(ctx context.Context, stepParam *types.SubtitleT
 			RewriteMaxAttempts:  config.Conf.Dubbing.RewriteMaxAttempts,
 			Estimator:           config.Conf.Dubbing.Estimator,
 		},
+		Progress: func(done, total int) {
+			publishTaskProgress(stepParam.TaskPtr, TaskStageTts, done, total)
+		},
 	})
 	result, err := runner.Run(ctx)
 	if err != nil {
 		return fmt.Errorf("srtFileToSpeech dubbing runner error: %w", err)
 	}
+	for _, warning := range result.Report.Warnings {
+		publishTaskWarning(stepParam.TaskPtr, TaskStageTts, warning)
+	}
 	stepParam.TtsResultFilePath = result.Audio
 	stepParam.VideoWithTtsFilePath = result.Video
 	if stepParam.TaskPtr != nil {
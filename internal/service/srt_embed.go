@@ -43,8 +43,9 @@ const (
 func (s Service) embedSubtitles(ctx context.Context, stepParam *types.SubtitleTaskStepParam) error {
 	var err error
 	if stepParam.EmbedSubtitleVideoType == "horizontal" || stepParam.EmbedSubtitleVideoType == "vertical" || stepParam.EmbedSubtitleVideoType == "all" {
+		publishTaskStage(stepParam.TaskPtr, TaskStageRender)
 		var width, height int
-		width, height, err = getResolution(stepParam.InputVideoPath)
+		width, height, err = getResolution(ctx, stepParam.InputVideoPath)
 		if err != nil {
 			log.GetLogger().Error("embedSubtitles getResolution error", zap.Any("step param", stepParam), zap.Error(err))
 			return fmt.Errorf("embedSubtitles getResolution error: %w", err)
@@ -57,7 +58,7 @@ func (s Service) embedSubtitles(ctx context.Context, stepParam *types.SubtitleTa
 				return nil
 			}
 			log.GetLogger().Info("合成视频：横屏")
-			err = embedSubtitles(stepParam, true, stepParam.EnableTts)
+			err = embedSubtitles(ctx, stepParam, true, stepParam.EnableTts)
 			if err != nil {
 				log.GetLogger().Error("embedSubtitles embedSubtitles error", zap.Any("step param", stepParam), zap.Error(err))
 				return fmt.Errorf("embedSubtitles embedSubtitles error: %w", err)
@@ -67,7 +68,7 @@ func (s Service) embedSubtitles(ctx context.Context, stepParam *types.SubtitleTa
 			if width > height {
 				// 生成竖屏视频
 				transferredVerticalVideoPath := filepath.Join(stepParam.TaskBasePath, types.SubtitleTaskTransferredVerticalVideoFileName)
-				err = convertToVertical(stepParam.InputVideoPath, transferredVerticalVideoPath, stepParam.VerticalVideoMajorTitle, stepParam.VerticalVideoMinorTitle)
+				err = convertToVertical(ctx, stepParam.InputVideoPath, transferredVerticalVideoPath, stepParam.VerticalVideoMajorTitle, stepParam.VerticalVideoMinorTitle)
 				if err != nil {
 					log.GetLogger().Error("embedSubtitles convertToVertical error", zap.Any("step param", stepParam), zap.Error(err))
 					return fmt.Errorf("embedSubtitles convertToVertical error: %w", err)
@@ -75,7 +76,7 @@ func (s Service) embedSubtitles(ctx context.Context, stepParam *types.SubtitleTa
 				stepParam.InputVideoPath = transferredVerticalVideoPath
 			}
 			log.GetLogger().Info("合成视频：竖屏")
-			err = embedSubtitles(stepParam, false, stepParam.EnableTts)
+			err = embedSubtitles(ctx, stepParam, false, stepParam.EnableTts)
 			if err != nil {
 				log.GetLogger().Error("embedSubtitles embedSubtitles error", zap.Any("step param", stepParam), zap.Error(err))
 				return fmt.Errorf("embedSubtitles embedSubtitles error: %w", err)
@@ -752,6 +753,7 @@ func srtToAss(inputSRT, outputASS string, isHorizontal bool, stepParam *types.Su
 			if len(subtitleLines) == 0 {
 				continue
 			}
+			subtitleLines, speakerOverride := speakerAssLines(subtitleLines)
 			//var majorTextLanguage types.StandardLanguageCode
 			//if stepParam.SubtitleResultType == types.SubtitleResultTypeBilingualTranslationOnTop { // 一定是bilingual
 			//	majorTextLanguage = stepParam.TargetLanguage
@@ -765,13 +767,13 @@ func srtToAss(inputSRT, outputASS string, isHorizontal bool, stepParam *types.Su
 			startFormatted := formatTimestamp(startTime)
 			endFormatted := formatTimestamp(endTime)
 			if len(subtitleLines) == 1 {
-				majorText := joinASSLines(wrapSubtitleForASS(subtitleLines[0], screenStyle.Major, stepParam))
+				majorText := speakerOverride + joinASSLines(wrapSubtitleForASS(subtitleLines[0], screenStyle.Major, stepParam))
 				combinedText := fmt.Sprintf("%s{\\an%d}{\\rMajor}%s", majorTags, majorAlignment, majorText)
 				_, _ = assFile.WriteString(fmt.Sprintf("Dialogue: 0,%s,%s,Major,,0,0,0,,%s\n", startFormatted, endFormatted, combinedText))
 				continue
 			}
-			majorText := joinASSLines(wrapSubtitleForASS(subtitleLines[0], screenStyle.Major, stepParam))
-			minorText := joinASSLines(wrapSubtitleForASS(subtitleLines[1], screenStyle.Minor, stepParam))
+			majorText := speakerOverride + joinASSLines(wrapSubtitleForASS(subtitleLines[0], screenStyle.Major, stepParam))
+			minorText := speakerOverride + joinASSLines(wrapSubtitleForASS(subtitleLines[1], screenStyle.Minor, stepParam))
 			combinedText := fmt.Sprintf("%s{\\an%d}{\\rMajor}%s\\N%s{\\an%d}{\\rMinor}%s",
 				majorTags, majorAlignment, majorText,
 				minorTags, minorAlignment, minorText)
@@ -816,11 +818,12 @@ func srtToAss(inputSRT, outputASS string, isHorizontal bool, stepParam *types.Su
 			if len(subtitleLines) == 0 {
 				continue
 			}
+			subtitleLines, speakerOverride := speakerAssLines(subtitleLines)
 			if len(subtitleLines) > 1 {
 				startFormatted := formatTimestamp(startTime)
 				endFormatted := formatTimestamp(endTime)
-				majorText := joinASSLines(wrapSubtitleForASS(subtitleLines[0], screenStyle.Major, stepParam))
-				minorText := joinASSLines(wrapSubtitleForASS(subtitleLines[1], screenStyle.Minor, stepParam))
+				majorText := speakerOverride + joinASSLines(wrapSubtitleForASS(subtitleLines[0], screenStyle.Major, stepParam))
+				minorText := speakerOverride + joinASSLines(wrapSubtitleForASS(subtitleLines[1], screenStyle.Minor, stepParam))
 				combinedText := fmt.Sprintf("%s{\\an%d}{\\rMajor}%s\\N%s{\\an%d}{\\rMinor}%s",
 					majorTags, majorAlignment, majorText,
 					minorTags, minorAlignment, minorText)
@@ -845,9 +848,10 @@ func srtToAss(inputSRT, outputASS string, isHorizontal bool, stepParam *types.Su
 					}
 					startFormatted := formatTimestamp(iStart)
 					endFormatted := formatTimestamp(iEnd)
-					combinedText := fmt.Sprintf("%s{\\an%d}{\\rMajor}%s",
+					combinedText := fmt.Sprintf("%s{\\an%d}{\\rMajor}%s%s",
 						majorTags,
 						majorAlignment,
+						speakerOverride,
 						line)
 					_, _ = assFile.WriteString(fmt.Sprintf("Dialogue: 0,%s,%s,Major,,0,0,0,,%s\n", startFormatted, endFormatted, combinedText))
 				}
@@ -855,7 +859,7 @@ func srtToAss(inputSRT, outputASS string, isHorizontal bool, stepParam *types.Su
 				// 处理英文字幕
 				startFormatted := formatTimestamp(startTime)
 				endFormatted := formatTimestamp(endTime)
-				cleanedText := joinASSLines(wrapSubtitleForASS(content, screenStyle.Minor, stepParam))
+				cleanedText := speakerOverride + joinASSLines(wrapSubtitleForASS(content, screenStyle.Minor, stepParam))
 				combinedText := fmt.Sprintf("%s{\\an%d}{\\rMinor}%s",
 					minorTags,
 					minorAlignment,
@@ -867,7 +871,7 @@ func srtToAss(inputSRT, outputASS string, isHorizontal bool, stepParam *types.Su
 	return nil
 }
 
-func embedSubtitles(stepParam *types.SubtitleTaskStepParam, isHorizontal bool, withTts bool) error {
+func embedSubtitles(ctx context.Context, stepParam *types.SubtitleTaskStepParam, isHorizontal bool, withTts bool) error {
 	outputFileName := types.SubtitleTaskVerticalEmbedVideoFileName
 	if isHorizontal {
 		outputFileName = types.SubtitleTaskHorizontalEmbedVideoFileName
@@ -877,7 +881,7 @@ func embedSubtitles(stepParam *types.SubtitleTaskStepParam, isHorizontal bool, w
 		input = stepParam.VideoWithTtsFilePath
 	}
 
-	_, err := renderSubtitleFile(context.Background(), RenderVideoRequest{
+	_, err := renderSubtitleFile(ctx, RenderVideoRequest{
 		Workdir:      stepParam.TaskBasePath,
 		InputVideo:   input,
 		SubtitleFile: stepParam.BilingualSrtFilePath,
@@ -955,7 +959,7 @@ func pathExists(path string) bool {
 	return err == nil
 }
 
-func getResolution(inputVideo string) (int, int, error) {
+func getResolution(ctx context.Context, inputVideo string) (int, int, error) {
 	// 获取视频信息
 	cmdArgs := []string{
 		"-v", "error",
@@ -964,7 +968,7 @@ func getResolution(inputVideo string) (int, int, error) {
 		"-of", "csv=s=x:p=0",
 		inputVideo,
 	}
-	cmd := exec.Command(storage.FfprobePath, cmdArgs...)
+	cmd := exec.CommandContext(ctx, storage.FfprobePath, cmdArgs...)
 	var out bytes.Buffer
 	cmd.Stdout = &out
 	cmd.Stderr = &out
@@ -989,7 +993,7 @@ func getResolution(inputVideo string) (int, int, error) {
 	return width, height, nil
 }
 
-func convertToVertical(inputVideo, outputVideo, majorTitle, minorTitle string) error {
+func convertToVertical(ctx context.Context, inputVideo, outputVideo, majorTitle, minorTitle string) error {
 	if _, err := os.Stat(outputVideo); err == nil {
 		log.GetLogger().Info("竖屏视频已存在", zap.String("outputVideo", outputVideo))
 		return nil
@@ -1013,11 +1017,17 @@ func convertToVertical(inputVideo, outputVideo, majorTitle, minorTitle string) e
 		"-y",
 		outputVideo,
 	}
-	cmd := exec.Command(storage.FfmpegPath, cmdArgs...)
+	release, err := acquireFFmpeg(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	cmd := exec.CommandContext(ctx, storage.FfmpegPath, cmdArgs...)
 	var output []byte
 	output, err = cmd.CombinedOutput()
 	if err != nil {
 		log.GetLogger().Error("视频转竖屏失败", zap.String("output", string(output)), zap.Error(err))
+		_ = os.Remove(outputVideo) // 避免残缺的竖屏视频在下次合成时被当作已完成复用
 		return err
 	}
 
@@ -13,7 +13,9 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/samber/lo"
 	"go.uber.org/zap"
@@ -33,15 +35,43 @@ func (s Service) StartSubtitleTask(req dto.StartVideoSubtitleTaskReq) (*dto.Star
 			return nil, fmt.Errorf("链接不合法")
 		}
 	}
+	if err := validateCallbackUrl(req.CallbackUrl); err != nil {
+		return nil, err
+	}
+	if IsAutoOriginLanguage(req.OriginLanguage) {
+		req.OriginLanguage = string(types.LanguageNameAuto)
+	}
 	// 生成任务id
 	seperates := strings.Split(req.Url, "/")
 	taskId := fmt.Sprintf("%s_%s", util.SanitizePathName(string([]rune(strings.ReplaceAll(seperates[len(seperates)-1], " ", ""))[:16])), util.GenerateRandStringWithUpperLowerNum(4))
 	taskId = strings.ReplaceAll(taskId, "=", "") // 等于号影响ffmpeg处理
 	taskId = strings.ReplaceAll(taskId, "?", "") // 问号影响ffmpeg处理
+	// 断点续跑时沿用原任务id和任务目录
+	resume := req.ResumeTaskId != ""
+	if resume {
+		resumeTaskId, err := resumableTaskId(req)
+		if err != nil {
+			return nil, err
+		}
+		taskId = resumeTaskId
+	}
+	// 多个目标语言时以第一个为主，其产物仍放在任务目录下
+	targetLangs := req.TargetLang
+	var targetLang string
+	if len(targetLangs) > 0 {
+		targetLang = targetLangs[0]
+	}
+	if len(targetLangs) > 1 && slices.Contains(targetLangs, "none") {
+		return nil, errors.New("目标语言为none时不能同时指定其他目标语言")
+	}
+	glossary, glossaryErr := NewGlossary("", req.Glossary, types.StandardLanguageCode(targetLang))
+	if glossaryErr != nil {
+		return nil, glossaryErr
+	}
 	// 构造任务所需参数
 	var resultType types.SubtitleResultType
 	// 根据入参选项确定要返回的字幕类型
-	if req.TargetLang == "none" {
+	if targetLang == "none" {
 		resultType = types.SubtitleResultTypeOriginOnly
 	} else {
 		if req.Bilingual == types.SubtitleTaskBilingualYes {
@@ -69,7 +99,7 @@ func (s Service) StartSubtitleTask(req dto.StartVideoSubtitleTaskReq) (*dto.Star
 	var err error
 	ctx := context.Background()
 	// 创建字幕任务文件夹
-	taskBasePath := filepath.Join("./tasks", taskId)
+	taskBasePath := filepath.Join(tasksDir, taskId)
 	if _, err = os.Stat(taskBasePath); os.IsNotExist(err) {
 		// 不存在则创建
 		err = os.MkdirAll(filepath.Join(taskBasePath, "output"), os.ModePerm)
@@ -80,11 +110,22 @@ func (s Service) StartSubtitleTask(req dto.StartVideoSubtitleTaskReq) (*dto.Star
 
 	// 创建任务
 	taskPtr := &types.SubtitleTask{
-		TaskId:   taskId,
-		VideoSrc: req.Url,
-		Status:   types.SubtitleTaskStatusProcessing,
+		TaskId:         taskId,
+		VideoSrc:       req.Url,
+		Status:         types.SubtitleTaskStatusProcessing,
+		OriginLanguage: req.OriginLanguage,
+		TargetLanguage: strings.Join(targetLangs, ","),
+		CreateTime:     time.Now().Unix(),
+		CallbackUrl:    req.CallbackUrl,
+		CallbackSecret: req.CallbackSecret,
+	}
+	if existing, err := storage.SubtitleTasks.Get(taskId); err == nil && existing.CreateTime > 0 {
+		taskPtr.CreateTime = existing.CreateTime
+	}
+	if err = storage.SubtitleTasks.Save(taskPtr); err != nil {
+		log.GetLogger().Error("StartVideoSubtitleTask save task err", zap.Any("req", req), zap.Error(err))
+		return nil, errors.New("保存任务失败")
 	}
-	storage.SubtitleTasks.Store(taskId, taskPtr)
 
 	// 处理声音克隆源
 	var voiceCloneAudioUrl string
@@ -112,13 +153,22 @@ func (s Service) StartSubtitleTask(req dto.StartVideoSubtitleTaskReq) (*dto.Star
 		VoiceCloneAudioUrl:      voiceCloneAudioUrl,
 		ReplaceWordsMap:         replaceWordsMap,
 		OriginLanguage:          types.StandardLanguageCode(req.OriginLanguage),
-		TargetLanguage:          types.StandardLanguageCode(req.TargetLang),
+		TargetLanguage:          types.StandardLanguageCode(targetLang),
 		UserUILanguage:          types.StandardLanguageCode(req.Language),
 		EmbedSubtitleVideoType:  req.EmbedSubtitleVideoType,
 		VerticalVideoMajorTitle: req.VerticalMajorTitle,
 		VerticalVideoMinorTitle: req.VerticalMinorTitle,
 		MaxWordOneLine:          12, // 默认值
 		VttSwitch:               req.VttSwitch,
+		Resume:                  resume,
+		TranscriptionHint:       NewTranscriptionHint(req.Vocabulary, req.TranscribePrompt),
+		NoTranscriptionCache:    req.NoCache,
+		Glossary:                glossary,
+	}
+	if stepParam.OriginLanguage == types.LanguageNameAuto && stepParam.VttSwitch {
+		// 平台字幕需要按源语言选择字幕轨，自动识别源语言时直接转录
+		log.GetLogger().Info("origin language is auto, skip platform subtitles", zap.String("taskId", taskId))
+		stepParam.VttSwitch = false
 	}
 	if req.OriginLanguageWordOneLine != 0 {
 		stepParam.MaxWordOneLine = req.OriginLanguageWordOneLine
@@ -126,7 +176,20 @@ func (s Service) StartSubtitleTask(req dto.StartVideoSubtitleTaskReq) (*dto.Star
 
 	log.GetLogger().Info("current task info", zap.String("taskId", taskId), zap.Any("param", stepParam))
 
-	go func() {
+	// 每个任务单独的context，取消任务时终止所有阶段及其子进程
+	ctx, cancel := context.WithCancel(ctx)
+	registerTaskCancel(taskId, cancel)
+	// 任务进入队列，由worker按提交顺序执行
+	if err := defaultTaskQueue().submit(taskId, func() {
+		defer func() {
+			unregisterTaskCancel(taskId)
+			keepCancelledStatus(ctx, taskPtr)
+			cancel()
+			finishTaskProgress(taskId)
+			saveTask(taskPtr)
+			publishTaskFinished(taskPtr)
+			go notifyTaskCallback(taskPtr.Clone())
+		}()
 		defer func() {
 			if r := recover(); r != nil {
 				const size = 64 << 10
@@ -138,11 +201,10 @@ func (s Service) StartSubtitleTask(req dto.StartVideoSubtitleTaskReq) (*dto.Star
 		}()
 		// 新版流程：链接->本地音频文件->视频信息获取（若有）->本地字幕文件->语言合成->视频合成->字幕文件链接生成
 		log.GetLogger().Info("video subtitle start task", zap.String("taskId", taskId))
-		err = s.linkToFile(ctx, &stepParam)
+		err := s.linkToFile(ctx, &stepParam)
 		if err != nil {
 			log.GetLogger().Error("StartVideoSubtitleTask linkToFile err", zap.Any("req", req), zap.Error(err))
-			stepParam.TaskPtr.Status = types.SubtitleTaskStatusFailed
-			stepParam.TaskPtr.FailReason = err.Error()
+			failTask(ctx, stepParam.TaskPtr, err)
 			return
 		}
 		// 暂时不加视频信息
@@ -154,10 +216,23 @@ func (s Service) StartSubtitleTask(req dto.StartVideoSubtitleTaskReq) (*dto.Star
 		//	return
 		//}
 
+		// 多目标语言时，附加语言沿用第一个目标语言的转录结果或平台字幕，翻译、配音和视频合成按语言分别进行
+		languageParams := make([]*types.SubtitleTaskStepParam, 0, len(targetLangs))
+		for _, lang := range targetLangs[min(1, len(targetLangs)):] {
+			languageParam, err := NewLanguageStepParam(&stepParam, types.StandardLanguageCode(lang))
+			if err != nil {
+				log.GetLogger().Error("StartVideoSubtitleTask NewLanguageStepParam err", zap.Any("req", req), zap.Error(err))
+				failTask(ctx, stepParam.TaskPtr, err)
+				return
+			}
+			languageParams = append(languageParams, languageParam)
+		}
+
 		// 针对YouTube视频优先尝试使用yt-dlp下载字幕
+		var youtubeReq *YoutubeSubtitleReq
 		if strings.Contains(req.Url, "youtube.com") && stepParam.VttSwitch {
 			log.GetLogger().Info("Start Process youtube video with vtt", zap.String("taskId", taskId))
-			req := &YoutubeSubtitleReq{
+			youtubeReq = &YoutubeSubtitleReq{
 				TaskBasePath:        stepParam.TaskBasePath,
 				TaskId:              taskId,
 				OriginLanguage:      string(stepParam.OriginLanguage),
@@ -165,124 +240,245 @@ func (s Service) StartSubtitleTask(req dto.StartVideoSubtitleTaskReq) (*dto.Star
 				URL:                 req.Url,
 				TaskPtr:             stepParam.TaskPtr,
 				TargetLanguageFirst: config.Conf.App.TargetLanguageFirst,
+				Glossary:            stepParam.Glossary.ForLanguage(stepParam.TargetLanguage),
 			}
 
 			// 先下载VTT字幕
-			vttFile, err := s.YouTubeSubtitleSrv.downloadYouTubeSubtitle(ctx, req)
+			vttFile, err := s.YouTubeSubtitleSrv.downloadYouTubeSubtitle(ctx, youtubeReq)
 			if err != nil {
 				// 下载失败，回退到音频转录方式
 				log.GetLogger().Warn("Failed to download YouTube subtitles, falling back to audio transcription",
 					zap.String("taskId", taskId), zap.Error(err))
-				stepParam.TaskPtr.Status = types.SubtitleTaskStatusFailed
-				stepParam.TaskPtr.FailReason = err.Error()
+				failTask(ctx, stepParam.TaskPtr, err)
 				return
 			}
-			req.VttFile = vttFile
-
-			// 检测VTT格式类型
-			hasWordTimestamps := true // 默认假设有单词级时间戳
-			if config.Conf.App.EnableBlockVttBatch {
-				// 只有启用了新功能才进行格式检测
-				detected, detectErr := s.YouTubeSubtitleSrv.DetectVttFormat(vttFile)
-				if detectErr != nil {
-					log.GetLogger().Warn("VTT格式检测失败，使用默认处理方式",
-						zap.String("taskId", taskId), zap.Error(detectErr))
-				} else {
-					hasWordTimestamps = detected
-				}
-			}
-
-			var srtFile string
-			if hasWordTimestamps {
-				// 使用原有的word-level处理流程（完全不变）
-				log.GetLogger().Info("使用word-level VTT处理流程", zap.String("taskId", taskId))
-				srtFile, err = s.YouTubeSubtitleSrv.processYouTubeSubtitle(ctx, req)
-			} else {
-				// 使用新的block-level处理流程
-				log.GetLogger().Info("使用block-level VTT处理流程", zap.String("taskId", taskId))
-				srtFile, err = s.YouTubeSubtitleSrv.ProcessBlockLevelVtt(ctx, req)
-			}
+			youtubeReq.VttFile = vttFile
 
+			err = s.youtubeToSubtitle(ctx, &stepParam, youtubeReq)
 			if err != nil {
-				// 处理字幕失败，回退到音频转录方式
-				log.GetLogger().Warn("Failed to process YouTube subtitles, falling back to audio transcription",
-					zap.String("taskId", taskId), zap.Error(err))
-				stepParam.TaskPtr.Status = types.SubtitleTaskStatusFailed
-				stepParam.TaskPtr.FailReason = err.Error()
+				failTask(ctx, stepParam.TaskPtr, err)
 				return
 			}
-
-			stepParam.BilingualSrtFilePath = srtFile
-			err = splitSrt(&stepParam)
-			if err != nil {
-				return
-			}
-			stepParam.TaskPtr.ProcessPct = 95
 		} else {
 			// 非YouTube视频，使用原来的音频转录流程
 			err = s.audioToSubtitle(ctx, &stepParam)
 			if err != nil {
 				log.GetLogger().Error("StartVideoSubtitleTask audioToSubtitle err", zap.Any("req", req), zap.Error(err))
-				stepParam.TaskPtr.Status = types.SubtitleTaskStatusFailed
-				stepParam.TaskPtr.FailReason = err.Error()
+				failTask(ctx, stepParam.TaskPtr, err)
 				return
 			}
 		}
 		err = s.srtFileToSpeech(ctx, &stepParam)
 		if err != nil {
 			log.GetLogger().Error("StartVideoSubtitleTask srtFileToSpeech err", zap.Any("req", req), zap.Error(err))
-			stepParam.TaskPtr.Status = types.SubtitleTaskStatusFailed
-			stepParam.TaskPtr.FailReason = err.Error()
+			failTask(ctx, stepParam.TaskPtr, err)
 			return
 		}
 		err = s.embedSubtitles(ctx, &stepParam)
 		if err != nil {
 			log.GetLogger().Error("StartVideoSubtitleTask embedSubtitles err", zap.Any("req", req), zap.Error(err))
-			stepParam.TaskPtr.Status = types.SubtitleTaskStatusFailed
-			stepParam.TaskPtr.FailReason = err.Error()
+			failTask(ctx, stepParam.TaskPtr, err)
 			return
 		}
-		err = s.uploadSubtitles(ctx, &stepParam)
+		for i, languageParam := range languageParams {
+			err = s.GenerateLanguageSubtitles(ctx, &stepParam, languageParam, youtubeReq)
+			if err != nil {
+				log.GetLogger().Error("StartVideoSubtitleTask GenerateLanguageSubtitles err", zap.Any("req", req), zap.String("targetLanguage", string(languageParam.TargetLanguage)), zap.Error(err))
+				failTask(ctx, stepParam.TaskPtr, err)
+				return
+			}
+			err = s.srtFileToSpeech(ctx, languageParam)
+			if err != nil {
+				log.GetLogger().Error("StartVideoSubtitleTask srtFileToSpeech err", zap.Any("req", req), zap.String("targetLanguage", string(languageParam.TargetLanguage)), zap.Error(err))
+				failTask(ctx, stepParam.TaskPtr, err)
+				return
+			}
+			err = s.embedSubtitles(ctx, languageParam)
+			if err != nil {
+				log.GetLogger().Error("StartVideoSubtitleTask embedSubtitles err", zap.Any("req", req), zap.String("targetLanguage", string(languageParam.TargetLanguage)), zap.Error(err))
+				failTask(ctx, stepParam.TaskPtr, err)
+				return
+			}
+			publishTaskProgress(stepParam.TaskPtr, TaskStageTranslate, i+1, len(languageParams))
+		}
+		err = s.uploadSubtitles(ctx, &stepParam, languageParams...)
 		if err != nil {
 			log.GetLogger().Error("StartVideoSubtitleTask uploadSubtitles err", zap.Any("req", req), zap.Error(err))
-			stepParam.TaskPtr.Status = types.SubtitleTaskStatusFailed
-			stepParam.TaskPtr.FailReason = err.Error()
+			failTask(ctx, stepParam.TaskPtr, err)
 			return
 		}
 
 		log.GetLogger().Info("video subtitle task end", zap.String("taskId", taskId))
-	}()
+	}); err != nil {
+		unregisterTaskCancel(taskId)
+		cancel()
+		taskPtr.Status = types.SubtitleTaskStatusFailed
+		taskPtr.FailReason = err.Error()
+		saveTask(taskPtr)
+		return nil, err
+	}
 
 	return &dto.StartVideoSubtitleTaskResData{
 		TaskId: taskId,
 	}, nil
 }
 
+// youtubeToSubtitle 把已下载的平台字幕处理为双语字幕并拆分为单语字幕
+func (s Service) youtubeToSubtitle(ctx context.Context, stepParam *types.SubtitleTaskStepParam, req *YoutubeSubtitleReq) error {
+	// 检测VTT格式类型
+	hasWordTimestamps := true // 默认假设有单词级时间戳
+	if config.Conf.App.EnableBlockVttBatch {
+		// 只有启用了新功能才进行格式检测
+		detected, detectErr := s.YouTubeSubtitleSrv.DetectVttFormat(req.VttFile)
+		if detectErr != nil {
+			log.GetLogger().Warn("VTT格式检测失败，使用默认处理方式",
+				zap.String("taskId", stepParam.TaskId), zap.Error(detectErr))
+		} else {
+			hasWordTimestamps = detected
+		}
+	}
+
+	var (
+		srtFile string
+		err     error
+	)
+	publishTaskStage(stepParam.TaskPtr, TaskStageTranslate)
+	if hasWordTimestamps {
+		// 使用原有的word-level处理流程（完全不变）
+		log.GetLogger().Info("使用word-level VTT处理流程", zap.String("taskId", stepParam.TaskId))
+		srtFile, err = s.YouTubeSubtitleSrv.processYouTubeSubtitle(ctx, req)
+	} else {
+		// 使用新的block-level处理流程
+		log.GetLogger().Info("使用block-level VTT处理流程", zap.String("taskId", stepParam.TaskId))
+		srtFile, err = s.YouTubeSubtitleSrv.ProcessBlockLevelVtt(ctx, req)
+	}
+	if err != nil {
+		log.GetLogger().Warn("Failed to process YouTube subtitles",
+			zap.String("taskId", stepParam.TaskId), zap.Error(err))
+		return err
+	}
+
+	stepParam.BilingualSrtFilePath = srtFile
+	if err = splitSrt(stepParam); err != nil {
+		return err
+	}
+	stepParam.TaskPtr.ProcessPct = 95
+	return nil
+}
+
 func (s Service) GetTaskStatus(req dto.GetVideoSubtitleTaskReq) (*dto.GetVideoSubtitleTaskResData, error) {
-	task, ok := storage.SubtitleTasks.Load(req.TaskId)
-	if !ok || task == nil {
-		return nil, errors.New("任务不存在")
+	taskPtr, err := storage.SubtitleTasks.Get(req.TaskId)
+	if err != nil {
+		return nil, err
 	}
-	taskPtr := task.(*types.SubtitleTask)
 	if taskPtr.Status == types.SubtitleTaskStatusFailed {
 		return nil, fmt.Errorf("任务失败，原因：%s", taskPtr.FailReason)
 	}
+	if taskPtr.Status == types.SubtitleTaskStatusCancelled {
+		return nil, errors.New(taskCancelledReason)
+	}
+	return taskResult(taskPtr), nil
+}
+
+func taskResult(taskPtr *types.SubtitleTask) *dto.GetVideoSubtitleTaskResData {
 	return &dto.GetVideoSubtitleTaskResData{
 		TaskId:         taskPtr.TaskId,
 		ProcessPercent: taskPtr.ProcessPct,
+		QueuePosition:  defaultTaskQueue().position(taskPtr.TaskId),
 		VideoInfo: &dto.VideoInfo{
 			Title:                 taskPtr.Title,
 			Description:           taskPtr.Description,
 			TranslatedTitle:       taskPtr.TranslatedTitle,
 			TranslatedDescription: taskPtr.TranslatedDescription,
 		},
-		SubtitleInfo: lo.Map(taskPtr.SubtitleInfos, func(item types.SubtitleInfo, _ int) *dto.SubtitleInfo {
-			return &dto.SubtitleInfo{
-				Name:        item.Name,
-				DownloadUrl: item.DownloadUrl,
-			}
-		}),
+		SubtitleInfo:      toSubtitleInfoDtos(taskPtr.SubtitleInfos),
 		TargetLanguage:    taskPtr.TargetLanguage,
 		SpeechDownloadUrl: taskPtr.SpeechDownloadUrl,
+		Languages:         toLanguageResults(taskPtr),
+	}
+}
+
+func (s Service) ListTasks(req dto.ListVideoSubtitleTasksReq) (*dto.ListVideoSubtitleTasksResData, error) {
+	tasks, err := storage.SubtitleTasks.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(req.Status) > 0 {
+		tasks = lo.Filter(tasks, func(task *types.SubtitleTask, _ int) bool {
+			return lo.Contains(req.Status, task.Status)
+		})
+	}
+	page, pageSize := normalizePaging(req.Page, req.PageSize)
+	total := len(tasks)
+	start := min((page-1)*pageSize, total)
+	items := lo.Map(tasks[start:min(start+pageSize, total)], func(task *types.SubtitleTask, _ int) *dto.SubtitleTaskItem {
+		return &dto.SubtitleTaskItem{
+			TaskId:            task.TaskId,
+			VideoSrc:          task.VideoSrc,
+			Status:            task.Status,
+			FailReason:        task.FailReason,
+			ProcessPercent:    task.ProcessPct,
+			QueuePosition:     defaultTaskQueue().position(task.TaskId),
+			SubtitleInfo:      toSubtitleInfoDtos(task.SubtitleInfos),
+			SpeechDownloadUrl: task.SpeechDownloadUrl,
+			CallbackAttempts: lo.Map(task.CallbackAttempts, func(item types.CallbackAttempt, _ int) *dto.CallbackAttempt {
+				return &dto.CallbackAttempt{
+					Attempt:    item.Attempt,
+					Time:       item.Time,
+					StatusCode: item.StatusCode,
+					Error:      item.Error,
+					Success:    item.Success,
+				}
+			}),
+			CreateTime: task.CreateTime,
+			UpdateTime: task.UpdateTime,
+		}
+	})
+	return &dto.ListVideoSubtitleTasksResData{
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+		Tasks:    items,
 	}, nil
 }
+
+const (
+	defaultTaskPageSize = 20
+	maxTaskPageSize     = 100
+)
+
+func normalizePaging(page, pageSize int) (int, int) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = defaultTaskPageSize
+	}
+	return page, min(pageSize, maxTaskPageSize)
+}
+
+// toLanguageResults 多目标语言任务按目标语言分组的下载地址，只有一个目标语言时返回nil
+func toLanguageResults(taskPtr *types.SubtitleTask) []*dto.LanguageResult {
+	languages := types.ParseLanguageList(taskPtr.TargetLanguage)
+	if len(languages) < 2 {
+		return nil
+	}
+	return lo.Map(languages, func(lang types.StandardLanguageCode, _ int) *dto.LanguageResult {
+		return &dto.LanguageResult{
+			Language: string(lang),
+			SubtitleInfo: toSubtitleInfoDtos(lo.Filter(taskPtr.SubtitleInfos, func(item types.SubtitleInfo, _ int) bool {
+				return item.Language == string(lang)
+			})),
+			SpeechDownloadUrl: taskPtr.SpeechDownloadUrls[string(lang)],
+		}
+	})
+}
+
+func toSubtitleInfoDtos(infos []types.SubtitleInfo) []*dto.SubtitleInfo {
+	return lo.Map(infos, func(item types.SubtitleInfo, _ int) *dto.SubtitleInfo {
+		return &dto.SubtitleInfo{
+			Name:        item.Name,
+			DownloadUrl: item.DownloadUrl,
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"krillin-ai/internal/types"
+	"krillin-ai/log"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+)
+
+// LanguageTaskBasePath 附加目标语言的任务目录，第一个目标语言的产物仍直接放在任务目录下
+func LanguageTaskBasePath(taskBasePath string, lang types.StandardLanguageCode) string {
+	return filepath.Join(taskBasePath, types.SubtitleTaskLanguagesDirName, string(lang))
+}
+
+// NewLanguageStepParam 以第一个目标语言的任务参数为基础，构造附加目标语言的参数。需在第一个目标语言配音、合成视频前调用，
+// 此时视频路径等参数还未被这些步骤修改。附加语言使用单独的任务信息，其进度不覆盖整个任务的进度
+func NewLanguageStepParam(primary *types.SubtitleTaskStepParam, lang types.StandardLanguageCode) (*types.SubtitleTaskStepParam, error) {
+	stepParam := *primary
+	stepParam.TaskBasePath = LanguageTaskBasePath(primary.TaskBasePath, lang)
+	if err := os.MkdirAll(filepath.Join(stepParam.TaskBasePath, "output"), os.ModePerm); err != nil {
+		return nil, fmt.Errorf("NewLanguageStepParam MkdirAll err: %w", err)
+	}
+	stepParam.TargetLanguage = lang
+	stepParam.TaskPtr = &types.SubtitleTask{
+		Status:         types.SubtitleTaskStatusProcessing,
+		TargetLanguage: string(lang),
+	}
+	stepParam.BilingualSrtFilePath = ""
+	stepParam.ShortOriginMixedSrtFilePath = ""
+	stepParam.SubtitleInfos = nil
+	stepParam.TtsSourceFilePath = ""
+	stepParam.TtsResultFilePath = ""
+	stepParam.VideoWithTtsFilePath = ""
+	return &stepParam, nil
+}
+
+// GenerateLanguageSubtitles 为附加目标语言生成字幕，需在第一个目标语言的字幕生成后调用。
+// youtubeReq为第一个目标语言使用的平台字幕请求，不为空时复用已下载的平台字幕，否则复用任务目录中的分段转录结果，只做翻译
+func (s Service) GenerateLanguageSubtitles(ctx context.Context, primary, stepParam *types.SubtitleTaskStepParam, youtubeReq *YoutubeSubtitleReq) error {
+	log.GetLogger().Info("GenerateLanguageSubtitles start", zap.String("taskId", stepParam.TaskId), zap.String("targetLanguage", string(stepParam.TargetLanguage)))
+	stepParam.OriginLanguage = primary.OriginLanguage
+	stepParam.OriginLanguageDetected = primary.OriginLanguageDetected
+	if youtubeReq != nil && youtubeReq.VttFile != "" {
+		req := *youtubeReq
+		req.TaskBasePath = stepParam.TaskBasePath
+		req.OriginLanguage = string(stepParam.OriginLanguage)
+		req.TargetLanguage = string(stepParam.TargetLanguage)
+		req.TaskPtr = stepParam.TaskPtr
+		req.Glossary = stepParam.Glossary.ForLanguage(stepParam.TargetLanguage)
+		return s.youtubeToSubtitle(ctx, stepParam, &req)
+	}
+
+	timePoints, transcriptions, err := loadTranscribedSegments(primary.TaskBasePath)
+	if err != nil {
+		return fmt.Errorf("GenerateLanguageSubtitles loadTranscribedSegments err: %w", err)
+	}
+	if err = s.segmentsToSrt(ctx, stepParam, timePoints, transcriptions); err != nil {
+		return fmt.Errorf("GenerateLanguageSubtitles segmentsToSrt err: %w", err)
+	}
+	if err = splitSrt(stepParam); err != nil {
+		return fmt.Errorf("GenerateLanguageSubtitles splitSrt err: %w", err)
+	}
+	log.GetLogger().Info("GenerateLanguageSubtitles end", zap.String("taskId", stepParam.TaskId), zap.String("targetLanguage", string(stepParam.TargetLanguage)))
+	return nil
+}
+
+// loadTranscribedSegments 读取任务目录中的分段时间点和各分段的转录结果
+func loadTranscribedSegments(taskBasePath string) ([]float64, map[int]*types.TranscriptionData, error) {
+	checkpoint, err := loadResumeCheckpoint(taskBasePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(checkpoint.TimePoints) < 2 {
+		return nil, nil, errors.New("分段时间点缺失")
+	}
+	transcriptions := make(map[int]*types.TranscriptionData, len(checkpoint.TimePoints)-1)
+	for id := range len(checkpoint.TimePoints) - 1 {
+		var transcriptionData types.TranscriptionData
+		transcriptionFile := filepath.Join(taskBasePath, fmt.Sprintf(types.SubtitleTaskAudioTranscriptionDataPersistenceFileNamePattern, id))
+		if err = loadJSONArtifact(transcriptionFile, &transcriptionData); err != nil {
+			return nil, nil, fmt.Errorf("读取分段%d的转录结果失败: %w", id, err)
+		}
+		transcriptions[id] = &transcriptionData
+	}
+	return checkpoint.TimePoints, transcriptions, nil
+}
+
+// languageSubtitleName 多目标语言任务中在文件名称后标注所属的目标语言，目标语言单语字幕的名称已包含语言，不再标注
+func languageSubtitleName(info types.SubtitleFileInfo, lang types.StandardLanguageCode) string {
+	if info.LanguageIdentifier == string(lang) {
+		return info.Name
+	}
+	return fmt.Sprintf("%s (%s)", info.Name, types.GetStandardLanguageName(lang))
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"krillin-ai/internal/types"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewLanguageStepParamUsesSeparateWorkdirAndTask(t *testing.T) {
+	base := t.TempDir()
+	primaryTask := &types.SubtitleTask{TaskId: "demo", TargetLanguage: "zh_cn,ja"}
+	primary := &types.SubtitleTaskStepParam{
+		TaskId:               "demo",
+		TaskPtr:              primaryTask,
+		TaskBasePath:         base,
+		TargetLanguage:       "zh_cn",
+		InputVideoPath:       filepath.Join(base, "origin_video.mp4"),
+		BilingualSrtFilePath: filepath.Join(base, "bilingual_srt.srt"),
+		SubtitleInfos:        []types.SubtitleFileInfo{{Name: "bilingual", Path: filepath.Join(base, "bilingual_srt.srt")}},
+	}
+
+	stepParam, err := NewLanguageStepParam(primary, "ja")
+	if err != nil {
+		t.Fatalf("NewLanguageStepParam() error = %v", err)
+	}
+	wantDir := filepath.Join(base, types.SubtitleTaskLanguagesDirName, "ja")
+	if stepParam.TaskBasePath != wantDir || stepParam.TargetLanguage != "ja" {
+		t.Fatalf("TaskBasePath = %q, TargetLanguage = %q", stepParam.TaskBasePath, stepParam.TargetLanguage)
+	}
+	if _, err = os.Stat(filepath.Join(wantDir, "output")); err != nil {
+		t.Fatalf("output dir not created: %v", err)
+	}
+	// 视频等源文件与第一个目标语言共用，字幕产物各自生成
+	if stepParam.InputVideoPath != primary.InputVideoPath || stepParam.BilingualSrtFilePath != "" || stepParam.SubtitleInfos != nil {
+		t.Fatalf("stepParam = %#v", stepParam)
+	}
+	if stepParam.TaskPtr == primaryTask || stepParam.TaskPtr.TaskId != "" {
+		t.Fatalf("TaskPtr should be a separate task without id, got %#v", stepParam.TaskPtr)
+	}
+	if primary.TaskBasePath != base || primary.TargetLanguage != "zh_cn" || len(primary.SubtitleInfos) != 1 {
+		t.Fatalf("primary param modified: %#v", primary)
+	}
+}
+
+func TestToLanguageResultsGroupsSubtitlesByLanguage(t *testing.T) {
+	taskPtr := &types.SubtitleTask{
+		TargetLanguage: "zh_cn,ja",
+		SubtitleInfos: []types.SubtitleInfo{
+			{Name: "双语字幕", DownloadUrl: "/a", Language: "zh_cn"},
+			{Name: "双语字幕 (日文)", DownloadUrl: "/b", Language: "ja"},
+			{Name: "日文字幕", DownloadUrl: "/c", Language: "ja"},
+		},
+		SpeechDownloadUrls: map[string]string{"ja": "/ja.wav"},
+	}
+	results := toLanguageResults(taskPtr)
+	if len(results) != 2 || results[0].Language != "zh_cn" || results[1].Language != "ja" {
+		t.Fatalf("results = %#v", results)
+	}
+	if len(results[0].SubtitleInfo) != 1 || len(results[1].SubtitleInfo) != 2 || results[1].SubtitleInfo[1].DownloadUrl != "/c" {
+		t.Fatalf("subtitle infos not grouped by language: %#v, %#v", results[0].SubtitleInfo, results[1].SubtitleInfo)
+	}
+	if results[0].SpeechDownloadUrl != "" || results[1].SpeechDownloadUrl != "/ja.wav" {
+		t.Fatalf("speech urls = %q, %q", results[0].SpeechDownloadUrl, results[1].SpeechDownloadUrl)
+	}
+
+	if got := toLanguageResults(&types.SubtitleTask{TargetLanguage: "zh_cn"}); got != nil {
+		t.Fatalf("single language results = %#v, want nil", got)
+	}
+}
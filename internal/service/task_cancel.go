@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"krillin-ai/internal/storage"
+	"krillin-ai/internal/types"
+	"krillin-ai/log"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+const taskCancelledReason = "任务已取消"
+
+var (
+	taskCancels        sync.Map // task id -> context.CancelFunc，仅包含本进程中正在运行的任务
+	taskCancelRequests sync.Map // task id -> struct{}，已请求取消但仍在收尾的任务
+)
+
+func registerTaskCancel(taskId string, cancel context.CancelFunc) {
+	taskCancels.Store(taskId, cancel)
+}
+
+func unregisterTaskCancel(taskId string) {
+	taskCancels.Delete(taskId)
+	taskCancelRequests.Delete(taskId)
+}
+
+func isTaskCancelRequested(taskId string) bool {
+	_, ok := taskCancelRequests.Load(taskId)
+	return ok
+}
+
+// failTask 标记任务失败；任务context已取消时标记为已取消
+func failTask(ctx context.Context, taskPtr *types.SubtitleTask, err error) {
+	if keepCancelledStatus(ctx, taskPtr) {
+		return
+	}
+	taskPtr.Status = types.SubtitleTaskStatusFailed
+	taskPtr.FailReason = err.Error()
+}
+
+// keepCancelledStatus 任务context已取消时标记为已取消，没有响应取消而执行完成的阶段不会把状态改回成功
+func keepCancelledStatus(ctx context.Context, taskPtr *types.SubtitleTask) bool {
+	if ctx.Err() == nil {
+		return false
+	}
+	taskPtr.Status = types.SubtitleTaskStatusCancelled
+	taskPtr.FailReason = taskCancelledReason
+	return true
+}
+
+// CancelTask 取消正在处理的任务，终止其所有阶段和子进程
+func (s Service) CancelTask(taskId string) error {
+	taskPtr, err := storage.SubtitleTasks.Get(taskId)
+	if err != nil {
+		return err
+	}
+	if taskPtr.Status != types.SubtitleTaskStatusProcessing {
+		return errors.New("任务已结束，无法取消")
+	}
+	cancel, ok := taskCancels.Load(taskId)
+	if !ok {
+		return errors.New("任务不在运行中，无法取消")
+	}
+	log.GetLogger().Info("取消任务", zap.String("taskId", taskId))
+	cancel.(context.CancelFunc)()
+	taskSaveMu.Lock()
+	taskCancelRequests.Store(taskId, struct{}{})
+	taskPtr.Status = types.SubtitleTaskStatusCancelled
+	taskPtr.FailReason = taskCancelledReason
+	saveTask(taskPtr)
+	taskSaveMu.Unlock()
+	if defaultTaskQueue().remove(taskId) {
+		// 尚未开始执行的任务不会再运行，这里直接收尾
+		unregisterTaskCancel(taskId)
+		publishTaskFinished(taskPtr)
+		go notifyTaskCallback(taskPtr.Clone())
+	}
+	return nil
+}
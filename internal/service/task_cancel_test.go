@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"krillin-ai/internal/storage"
+	"krillin-ai/internal/types"
+	"krillin-ai/log"
+	"testing"
+)
+
+func TestCancelTaskStopsRunningTask(t *testing.T) {
+	log.InitLogger()
+	previous := storage.SubtitleTasks
+	storage.SubtitleTasks = storage.NewMemoryTaskStore()
+	t.Cleanup(func() { storage.SubtitleTasks = previous })
+
+	task := &types.SubtitleTask{TaskId: "cancel_demo", Status: types.SubtitleTaskStatusProcessing}
+	_ = storage.SubtitleTasks.Save(task)
+	ctx, cancel := context.WithCancel(context.Background())
+	registerTaskCancel(task.TaskId, cancel)
+	defer unregisterTaskCancel(task.TaskId)
+
+	if err := (Service{}).CancelTask(task.TaskId); err != nil {
+		t.Fatalf("CancelTask() error = %v", err)
+	}
+	if ctx.Err() == nil {
+		t.Fatal("task context not cancelled")
+	}
+	saved, err := storage.SubtitleTasks.Get(task.TaskId)
+	if err != nil || saved.Status != types.SubtitleTaskStatusCancelled {
+		t.Fatalf("saved task = %#v, %v, want cancelled", saved, err)
+	}
+	// 任务收尾前，处理中的进度不会覆盖已取消状态
+	persistTaskProgress(task, true)
+	if saved, _ = storage.SubtitleTasks.Get(task.TaskId); saved.Status != types.SubtitleTaskStatusCancelled {
+		t.Fatalf("Status = %d after progress save, want cancelled", saved.Status)
+	}
+	// 取消后各阶段返回的错误不应覆盖已取消状态
+	failTask(ctx, task, errors.New("signal: killed"))
+	saveTask(task)
+	if task.Status != types.SubtitleTaskStatusCancelled || task.FailReason != taskCancelledReason {
+		t.Fatalf("task = %#v, want cancelled reason kept", task)
+	}
+	if err := (Service{}).CancelTask(task.TaskId); err == nil {
+		t.Fatal("CancelTask() on finished task error = nil")
+	}
+	// 没有响应取消的阶段执行完成后，收尾时仍保持已取消状态
+	task.Status, task.FailReason = types.SubtitleTaskStatusSuccess, ""
+	keepCancelledStatus(ctx, task)
+	if task.Status != types.SubtitleTaskStatusCancelled || task.FailReason != taskCancelledReason {
+		t.Fatalf("task = %#v, want cancelled after a stage ignored cancellation", task)
+	}
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"krillin-ai/internal/dto"
+	"krillin-ai/internal/storage"
+	"krillin-ai/internal/types"
+	"sync"
+	"time"
+)
+
+// 进度流中的事件类型
+const (
+	TaskEventSnapshot  = "snapshot"
+	TaskEventStage     = "stage"
+	TaskEventProgress  = "progress"
+	TaskEventWarning   = "warning"
+	TaskEventSucceeded = "succeeded"
+	TaskEventFailed    = "failed"
+	TaskEventCancelled = "cancelled"
+)
+
+// 任务所处的阶段
+const (
+	TaskStageQueued     = "queued"
+	TaskStageDownload   = "download"
+	TaskStageSplit      = "split"
+	TaskStageTranscribe = "transcribe"
+	TaskStageTranslate  = "translate"
+	TaskStageTts        = "tts"
+	TaskStageRender     = "render"
+	TaskStageUpload     = "upload"
+)
+
+// taskEventBuffer 每个订阅者缓冲的事件数，消费过慢时丢弃中间的进度事件，结束事件总会送达
+const taskEventBuffer = 64
+
+type taskEventHub struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan *dto.SubtitleTaskEvent]struct{}
+}
+
+var taskEvents = &taskEventHub{subscribers: make(map[string]map[chan *dto.SubtitleTaskEvent]struct{})}
+
+func (h *taskEventHub) subscribe(taskId string) (chan *dto.SubtitleTaskEvent, func()) {
+	ch := make(chan *dto.SubtitleTaskEvent, taskEventBuffer)
+	h.mu.Lock()
+	if h.subscribers[taskId] == nil {
+		h.subscribers[taskId] = make(map[chan *dto.SubtitleTaskEvent]struct{})
+	}
+	h.subscribers[taskId][ch] = struct{}{}
+	h.mu.Unlock()
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.subscribers[taskId], ch)
+		if len(h.subscribers[taskId]) == 0 {
+			delete(h.subscribers, taskId)
+		}
+	}
+}
+
+// publish 推送事件，结束事件推送后关闭订阅者的channel
+func (h *taskEventHub) publish(event *dto.SubtitleTaskEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	terminal := IsTerminalTaskEvent(event.Type)
+	for ch := range h.subscribers[event.TaskId] {
+		select {
+		case ch <- event:
+		default:
+			if !terminal {
+				continue
+			}
+			// 缓冲已满时丢弃最早的一条事件给结束事件腾出位置，只有持锁的publish会写入，腾出后一定能写入
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- event
+		}
+		if terminal {
+			close(ch)
+		}
+	}
+	if terminal {
+		delete(h.subscribers, event.TaskId)
+	}
+}
+
+// IsTerminalTaskEvent 任务结束后不会再有新的事件
+func IsTerminalTaskEvent(eventType string) bool {
+	return eventType == TaskEventSucceeded || eventType == TaskEventFailed || eventType == TaskEventCancelled
+}
+
+func publishTaskEvent(taskPtr *types.SubtitleTask, event dto.SubtitleTaskEvent) {
+	if taskPtr == nil {
+		return
+	}
+	event.TaskId = taskPtr.TaskId
+	event.ProcessPercent = taskPtr.ProcessPct
+	event.Time = time.Now().Unix()
+	taskEvents.publish(&event)
+	// 阶段切换时立即保存，进度按间隔保存
+	persistTaskProgress(taskPtr, event.Type == TaskEventStage)
+}
+
+func publishTaskStage(taskPtr *types.SubtitleTask, stage string) {
+	publishTaskEvent(taskPtr, dto.SubtitleTaskEvent{Type: TaskEventStage, Stage: stage})
+}
+
+func publishTaskProgress(taskPtr *types.SubtitleTask, stage string, current, total int) {
+	publishTaskEvent(taskPtr, dto.SubtitleTaskEvent{Type: TaskEventProgress, Stage: stage, Current: current, Total: total})
+}
+
+func publishTaskWarning(taskPtr *types.SubtitleTask, stage, message string) {
+	publishTaskEvent(taskPtr, dto.SubtitleTaskEvent{Type: TaskEventWarning, Stage: stage, Message: message})
+}
+
+// publishTaskFinished 按任务最终状态推送结果或失败原因
+func publishTaskFinished(taskPtr *types.SubtitleTask) {
+	if taskPtr == nil {
+		return
+	}
+	publishTaskEvent(taskPtr, finishedTaskEvent(taskPtr))
+}
+
+func finishedTaskEvent(taskPtr *types.SubtitleTask) dto.SubtitleTaskEvent {
+	switch taskPtr.Status {
+	case types.SubtitleTaskStatusSuccess:
+		return dto.SubtitleTaskEvent{Type: TaskEventSucceeded, Result: taskResult(taskPtr)}
+	case types.SubtitleTaskStatusCancelled:
+		return dto.SubtitleTaskEvent{Type: TaskEventCancelled, Message: taskPtr.FailReason}
+	default:
+		return dto.SubtitleTaskEvent{Type: TaskEventFailed, Message: taskPtr.FailReason}
+	}
+}
+
+// FinishedTaskEvent 任务是否已结束及其最终事件，用于推送进度的连接在保活时确认任务状态；任务记录已被删除时事件为nil
+func (s Service) FinishedTaskEvent(taskId string) (*dto.SubtitleTaskEvent, bool) {
+	taskPtr, err := storage.SubtitleTasks.Get(taskId)
+	if err != nil {
+		return nil, true
+	}
+	if taskPtr.Status == types.SubtitleTaskStatusProcessing {
+		return nil, false
+	}
+	event := finishedTaskEvent(taskPtr)
+	event.TaskId = taskPtr.TaskId
+	event.ProcessPercent = taskPtr.ProcessPct
+	event.Time = time.Now().Unix()
+	return &event, true
+}
+
+// SubscribeTaskEvents 订阅任务进度，先返回当前状态快照；任务已结束时快照即最终事件，不返回channel
+func (s Service) SubscribeTaskEvents(taskId string) (*dto.SubtitleTaskEvent, <-chan *dto.SubtitleTaskEvent, func(), error) {
+	// 先订阅再读状态，避免错过两者之间产生的结束事件
+	events, unsubscribe := taskEvents.subscribe(taskId)
+	taskPtr, err := storage.SubtitleTasks.Get(taskId)
+	if err != nil {
+		unsubscribe()
+		return nil, nil, nil, err
+	}
+	snapshot := dto.SubtitleTaskEvent{Type: TaskEventSnapshot}
+	if taskPtr.Status != types.SubtitleTaskStatusProcessing {
+		unsubscribe()
+		snapshot = finishedTaskEvent(taskPtr)
+		events = nil
+	} else if position := defaultTaskQueue().position(taskId); position > 0 {
+		snapshot.Stage = TaskStageQueued
+		snapshot.QueuePosition = position
+	}
+	snapshot.TaskId = taskPtr.TaskId
+	snapshot.ProcessPercent = taskPtr.ProcessPct
+	snapshot.Time = time.Now().Unix()
+	return &snapshot, events, unsubscribe, nil
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"krillin-ai/internal/storage"
+	"krillin-ai/internal/types"
+	"testing"
+)
+
+func TestSubscribeTaskEventsStreamsUntilFinished(t *testing.T) {
+	previous := storage.SubtitleTasks
+	storage.SubtitleTasks = storage.NewMemoryTaskStore()
+	t.Cleanup(func() { storage.SubtitleTasks = previous })
+
+	task := &types.SubtitleTask{TaskId: "events_demo", Status: types.SubtitleTaskStatusProcessing, ProcessPct: 10}
+	_ = storage.SubtitleTasks.Save(task)
+	snapshot, events, unsubscribe, err := (Service{}).SubscribeTaskEvents(task.TaskId)
+	if err != nil {
+		t.Fatalf("SubscribeTaskEvents() error = %v", err)
+	}
+	defer unsubscribe()
+	if snapshot.Type != TaskEventSnapshot || snapshot.ProcessPercent != 10 || events == nil {
+		t.Fatalf("snapshot = %#v, want processing snapshot with events", snapshot)
+	}
+
+	publishTaskProgress(task, TaskStageTranscribe, 1, 3)
+	task.Status = types.SubtitleTaskStatusSuccess
+	saveTask(task)
+	publishTaskFinished(task)
+	progress := <-events
+	if progress.Type != TaskEventProgress || progress.Stage != TaskStageTranscribe || progress.Current != 1 || progress.Total != 3 {
+		t.Fatalf("progress event = %#v", progress)
+	}
+	finished := <-events
+	if finished.Type != TaskEventSucceeded || finished.Result == nil || !IsTerminalTaskEvent(finished.Type) {
+		t.Fatalf("finished event = %#v, want succeeded with result", finished)
+	}
+
+	snapshot, events, _, err = (Service{}).SubscribeTaskEvents(task.TaskId)
+	if err != nil || snapshot.Type != TaskEventSucceeded || events != nil {
+		t.Fatalf("SubscribeTaskEvents() on finished task = %#v, %v, %v", snapshot, events, err)
+	}
+}
+
+func TestPublishDeliversTerminalEventWhenBufferFull(t *testing.T) {
+	previous := storage.SubtitleTasks
+	storage.SubtitleTasks = storage.NewMemoryTaskStore()
+	t.Cleanup(func() { storage.SubtitleTasks = previous })
+
+	task := &types.SubtitleTask{TaskId: "events_slow", Status: types.SubtitleTaskStatusProcessing}
+	_ = storage.SubtitleTasks.Save(task)
+	_, events, unsubscribe, err := (Service{}).SubscribeTaskEvents(task.TaskId)
+	if err != nil {
+		t.Fatalf("SubscribeTaskEvents() error = %v", err)
+	}
+	defer unsubscribe()
+
+	for i := 0; i < taskEventBuffer+10; i++ {
+		publishTaskProgress(task, TaskStageTranslate, i, taskEventBuffer+10)
+	}
+	task.Status = types.SubtitleTaskStatusFailed
+	saveTask(task)
+	publishTaskFinished(task)
+
+	var last string
+	count := 0
+	for event := range events {
+		last = event.Type
+		count++
+	}
+	if last != TaskEventFailed || count != taskEventBuffer {
+		t.Fatalf("received %d events ending with %q, want full buffer ending with failed", count, last)
+	}
+	if event, finished := (Service{}).FinishedTaskEvent(task.TaskId); !finished || event.Type != TaskEventFailed {
+		t.Fatalf("FinishedTaskEvent() = %#v, %v", event, finished)
+	}
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"errors"
+	"krillin-ai/config"
+	"krillin-ai/log"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+var ErrTaskQueueFull = errors.New("任务队列已满，请稍后再试")
+
+type queuedTask struct {
+	taskId string
+	run    func()
+}
+
+// taskQueue 有界的任务等待队列，固定数量的worker按提交顺序取出任务执行
+type taskQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending []*queuedTask
+	maxSize int
+}
+
+var (
+	subtitleTaskQueue     *taskQueue
+	subtitleTaskQueueOnce sync.Once
+)
+
+// defaultTaskQueue 首次使用时按 [server] 配置启动worker
+func defaultTaskQueue() *taskQueue {
+	subtitleTaskQueueOnce.Do(func() {
+		subtitleTaskQueue = newTaskQueue(config.Conf.Server.Workers, config.Conf.Server.QueueSize)
+	})
+	return subtitleTaskQueue
+}
+
+func newTaskQueue(workers, maxSize int) *taskQueue {
+	q := &taskQueue{maxSize: maxSize}
+	q.cond = sync.NewCond(&q.mu)
+	for range max(workers, 1) {
+		go q.work()
+	}
+	return q
+}
+
+func (q *taskQueue) submit(taskId string, run func()) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.maxSize > 0 && len(q.pending) >= q.maxSize {
+		return ErrTaskQueueFull
+	}
+	q.pending = append(q.pending, &queuedTask{taskId: taskId, run: run})
+	log.GetLogger().Info("任务已进入队列", zap.String("taskId", taskId), zap.Int("position", len(q.pending)))
+	q.cond.Signal()
+	return nil
+}
+
+// position 返回任务在等待队列中的位置，从1开始；已开始执行或不在队列中返回0
+func (q *taskQueue) position(taskId string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, task := range q.pending {
+		if task.taskId == taskId {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// remove 将尚未开始执行的任务移出队列
+func (q *taskQueue) remove(taskId string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, task := range q.pending {
+		if task.taskId == taskId {
+			q.pending = append(q.pending[:i], q.pending[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (q *taskQueue) work() {
+	for {
+		q.mu.Lock()
+		for len(q.pending) == 0 {
+			q.cond.Wait()
+		}
+		task := q.pending[0]
+		q.pending = q.pending[1:]
+		q.mu.Unlock()
+		task.run()
+	}
+}
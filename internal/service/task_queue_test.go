@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"krillin-ai/log"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+)
+
+func TestTaskQueueReportsPositionAndRejectsWhenFull(t *testing.T) {
+	log.InitLogger()
+	q := newTaskQueue(1, 2)
+	block := make(chan struct{})
+	started := make(chan struct{})
+	if err := q.submit("running", func() { close(started); <-block }); err != nil {
+		t.Fatalf("submit(running) error = %v", err)
+	}
+	<-started
+	for _, id := range []string{"first", "second"} {
+		if err := q.submit(id, func() {}); err != nil {
+			t.Fatalf("submit(%s) error = %v", id, err)
+		}
+	}
+	if err := q.submit("third", func() {}); !errors.Is(err, ErrTaskQueueFull) {
+		t.Fatalf("submit(third) error = %v, want ErrTaskQueueFull", err)
+	}
+	if got := q.position("running"); got != 0 {
+		t.Fatalf("position(running) = %d, want 0", got)
+	}
+	if got := q.position("second"); got != 2 {
+		t.Fatalf("position(second) = %d, want 2", got)
+	}
+	if !q.remove("first") {
+		t.Fatal("remove(first) = false")
+	}
+	if got := q.position("second"); got != 1 {
+		t.Fatalf("position(second) after remove = %d, want 1", got)
+	}
+	close(block)
+}
+
+func TestAcquireLimitHonorsContext(t *testing.T) {
+	sem := semaphore.NewWeighted(1)
+	release, err := acquireLimit(context.Background(), sem)
+	if err != nil {
+		t.Fatalf("acquireLimit() error = %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := acquireLimit(ctx, sem); err == nil {
+		t.Fatal("acquireLimit() on exhausted limit error = nil")
+	}
+	release()
+	if release, err := acquireLimit(context.Background(), nil); err != nil {
+		t.Fatalf("acquireLimit(nil) error = %v", err)
+	} else {
+		release()
+	}
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"krillin-ai/internal/storage"
+	"krillin-ai/internal/types"
+	"krillin-ai/log"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// taskPersistInterval 任务处理中把进度写入任务仓库的最短间隔
+var taskPersistInterval = 5 * time.Second
+
+var (
+	// taskSaveMu 保证已取消的任务不会被处理中的进度覆盖，已删除的任务不会被回调记录写回
+	taskSaveMu sync.Mutex
+	// lastTaskSaves task id -> 上次保存进度的时间
+	lastTaskSaves sync.Map
+)
+
+func saveTask(taskPtr *types.SubtitleTask) {
+	if err := storage.SubtitleTasks.Save(taskPtr); err != nil {
+		log.GetLogger().Error("保存任务状态失败", zap.String("taskId", taskPtr.TaskId), zap.Error(err))
+	}
+}
+
+// persistTaskProgress 保存处理中任务的进度，force为false时距上次保存不足taskPersistInterval则跳过。
+// 只能由处理任务的goroutine调用：任务仓库保存的是副本，任务本身只有处理它的goroutine读写
+func persistTaskProgress(taskPtr *types.SubtitleTask, force bool) {
+	if taskPtr.TaskId == "" || taskPtr.Status != types.SubtitleTaskStatusProcessing {
+		return
+	}
+	now := time.Now()
+	if last, ok := lastTaskSaves.Load(taskPtr.TaskId); ok && !force && now.Sub(last.(time.Time)) < taskPersistInterval {
+		return
+	}
+	taskSaveMu.Lock()
+	defer taskSaveMu.Unlock()
+	if isTaskCancelRequested(taskPtr.TaskId) {
+		return
+	}
+	lastTaskSaves.Store(taskPtr.TaskId, now)
+	saveTask(taskPtr)
+}
+
+// finishTaskProgress 任务结束后清理进度保存记录
+func finishTaskProgress(taskId string) {
+	lastTaskSaves.Delete(taskId)
+}
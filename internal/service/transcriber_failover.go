@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"krillin-ai/internal/types"
+	"krillin-ai/log"
+
+	"go.uber.org/zap"
+)
+
+type namedTranscriber struct {
+	name string
+	types.Transcriber
+}
+
+// failoverTranscriber 按顺序调用转录服务，遇到可重试的错误时回退到下一个，并在结果中记录实际使用的服务
+type failoverTranscriber struct {
+	transcribers []namedTranscriber
+}
+
+// newTranscriberChain 创建配置中的转录服务链，创建失败的服务会被跳过，全部失败时返回nil
+func newTranscriberChain(providers []string) types.Transcriber {
+	chain := failoverTranscriber{}
+	for _, provider := range providers {
+		transcriber, err := newTranscriber(provider)
+		if err != nil {
+			log.GetLogger().Error("创建转录服务失败", zap.String("provider", provider), zap.Error(err))
+			continue
+		}
+		chain.transcribers = append(chain.transcribers, namedTranscriber{name: provider, Transcriber: transcriber})
+	}
+	if len(chain.transcribers) == 0 {
+		return nil
+	}
+	return chain
+}
+
+func (t failoverTranscriber) Transcription(ctx context.Context, audioFile, language, wordDir string, hint types.TranscriptionHint) (*types.TranscriptionData, error) {
+	var errs []error
+	for i, transcriber := range t.transcribers {
+		data, err := transcriber.Transcription(ctx, audioFile, language, wordDir, hint)
+		if err == nil {
+			data.Provider = transcriber.name
+			if i > 0 {
+				log.GetLogger().Info("转录服务回退成功", zap.String("provider", transcriber.name), zap.String("audioFile", audioFile))
+			}
+			return data, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", transcriber.name, err))
+		if !isRetryableTranscribeError(ctx, err) {
+			break
+		}
+		if i+1 < len(t.transcribers) {
+			log.GetLogger().Warn("转录服务失败，回退到下一个转录服务", zap.String("provider", transcriber.name),
+				zap.String("next", t.transcribers[i+1].name), zap.String("audioFile", audioFile), zap.Error(err))
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// isRetryableTranscribeError 任务取消、音频文件本身不存在或接口明确返回不可重试的错误（鉴权失败、参数错误等）时不再回退，
+// 其余错误（限流、网络、上传失败等）都尝试回退
+func isRetryableTranscribeError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var transcribeErr *types.TranscribeError
+	if errors.As(err, &transcribeErr) {
+		return transcribeErr.Retryable
+	}
+	return !errors.Is(err, fs.ErrNotExist)
+}
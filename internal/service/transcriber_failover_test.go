@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"krillin-ai/internal/types"
+	"krillin-ai/log"
+	"os"
+	"testing"
+)
+
+type stubTranscriber struct {
+	err   error
+	calls int
+}
+
+func (s *stubTranscriber) Transcription(context.Context, string, string, string, types.TranscriptionHint) (*types.TranscriptionData, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &types.TranscriptionData{Text: "hello"}, nil
+}
+
+func TestFailoverTranscriberFallsBackOnRetryableError(t *testing.T) {
+	log.InitLogger()
+	primary := &stubTranscriber{err: types.NewTranscribeError("openai", 429, "rate limited")}
+	fallback := &stubTranscriber{}
+	chain := failoverTranscriber{transcribers: []namedTranscriber{{"openai", primary}, {"fasterwhisper", fallback}}}
+
+	data, err := chain.Transcription(context.Background(), "a.mp3", "en", t.TempDir(), types.TranscriptionHint{})
+	if err != nil {
+		t.Fatalf("Transcription() error = %v", err)
+	}
+	if data.Provider != "fasterwhisper" || primary.calls != 1 || fallback.calls != 1 {
+		t.Fatalf("provider = %q, calls = %d/%d", data.Provider, primary.calls, fallback.calls)
+	}
+}
+
+func TestFailoverTranscriberStopsOnNonRetryableError(t *testing.T) {
+	log.InitLogger()
+	fallback := &stubTranscriber{}
+	for name, tc := range map[string]struct {
+		err    error
+		cancel bool
+	}{
+		"missing audio": {err: &os.PathError{Op: "open", Path: "a.mp3", Err: os.ErrNotExist}},
+		"unauthorized":  {err: types.NewTranscribeError("openai", 401, "invalid api key")},
+		"bad request":   {err: types.NewTranscribeError("openai", 400, "unsupported audio format")},
+		"cancelled":     {err: errors.New("signal: killed"), cancel: true},
+	} {
+		ctx, cancel := context.WithCancel(context.Background())
+		if tc.cancel {
+			cancel()
+		}
+		fallback.calls = 0
+		chain := failoverTranscriber{transcribers: []namedTranscriber{{"openai", &stubTranscriber{err: tc.err}}, {"fasterwhisper", fallback}}}
+		if _, err := chain.Transcription(ctx, "a.mp3", "en", t.TempDir(), types.TranscriptionHint{}); !errors.Is(err, tc.err) {
+			t.Fatalf("%s: error = %v, want %v", name, err, tc.err)
+		}
+		if fallback.calls != 0 {
+			t.Fatalf("%s: fallback called %d times", name, fallback.calls)
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"krillin-ai/config"
+	"krillin-ai/internal/types"
+	"krillin-ai/log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// transcriptionCacheVersion 缓存内容格式变化时递增，使旧的缓存失效
+const transcriptionCacheVersion = 1
+
+var (
+	// transcriptionCacheMu 写入缓存和按大小淘汰时加锁，读取依赖原子重命名不加锁
+	transcriptionCacheMu sync.Mutex
+	// transcriptionCacheSizes 各缓存目录的总大小，首次写入时遍历目录统计，之后随写入累加，
+	// 只有超过上限时才重新遍历目录淘汰并校正，其他进程写入或手动删除带来的偏差在校正时消除
+	transcriptionCacheSizes = make(map[string]int64)
+)
+
+// transcribeWithCache 转录前先按分段音频内容查找缓存，命中时不再调用转录服务。
+// 缓存的是转录服务的原始结果，幻觉过滤和说话人识别仍按当前设置处理；noCache时跳过查找，但会用新结果刷新缓存
+func (s Service) transcribeWithCache(ctx context.Context, audioFile, language string, hint types.TranscriptionHint, workDir string, noCache bool) (*types.TranscriptionData, error) {
+	cacheCfg := config.Conf.Transcribe.Cache
+	var key string
+	if cacheCfg.Enable {
+		var err error
+		if key, err = transcriptionCacheKey(audioFile, language, hint); err != nil {
+			log.GetLogger().Warn("计算转录缓存key失败，不使用缓存", zap.String("audioFile", audioFile), zap.Error(err))
+			key = ""
+		}
+	}
+	if key != "" && !noCache {
+		if data, ok := loadCachedTranscription(cacheCfg.Dir, key); ok {
+			log.GetLogger().Info("转录缓存命中", zap.String("audioFile", audioFile), zap.String("key", key), zap.String("provider", data.Provider))
+			return data, nil
+		}
+	}
+	data, err := s.Transcriber.Transcription(ctx, audioFile, language, workDir, hint)
+	if err != nil {
+		return nil, err
+	}
+	if key != "" {
+		if err = storeCachedTranscription(cacheCfg.Dir, key, data, int64(cacheCfg.MaxSizeMb)<<20); err != nil {
+			log.GetLogger().Warn("写入转录缓存失败", zap.String("audioFile", audioFile), zap.Error(err))
+		}
+	}
+	return data, nil
+}
+
+// transcriptionCacheKey 由分段音频内容和影响转录结果的设置计算，配置了多个转录服务时按整个服务链计算
+func transcriptionCacheKey(audioFile, language string, hint types.TranscriptionHint) (string, error) {
+	file, err := os.Open(audioFile)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err = io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	diarization := config.Conf.Transcribe.Diarization
+	settings := []string{
+		fmt.Sprintf("v%d", transcriptionCacheVersion),
+		config.Conf.Transcribe.Provider.String(),
+		transcribeModelNames(),
+		language,
+		fmt.Sprintf("whisperx_diarize=%t", diarization.Enable && diarization.Provider == "whisperx"), // whisperx的说话人识别结果包含在转录结果中
+		hint.Prompt(),
+	}
+	hash.Write([]byte("\x00" + strings.Join(settings, "\x00")))
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func transcriptionCachePath(dir, key string) string {
+	return filepath.Join(dir, key[:2], key+".json")
+}
+
+func loadCachedTranscription(dir, key string) (*types.TranscriptionData, bool) {
+	path := transcriptionCachePath(dir, key)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var data types.TranscriptionData
+	if err = json.Unmarshal(content, &data); err != nil {
+		log.GetLogger().Warn("转录缓存内容损坏，已删除", zap.String("path", path), zap.Error(err))
+		_ = os.Remove(path)
+		return nil, false
+	}
+	// 更新修改时间，按大小淘汰时优先删除最久未使用的结果
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return &data, true
+}
+
+func storeCachedTranscription(dir, key string, data *types.TranscriptionData, maxSize int64) error {
+	content, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	path := transcriptionCachePath(dir, key)
+	if err = os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	transcriptionCacheMu.Lock()
+	defer transcriptionCacheMu.Unlock()
+	total, counted := transcriptionCacheSizes[dir]
+	if !counted {
+		total = transcriptionCacheSize(dir)
+	}
+	if info, err := os.Stat(path); err == nil {
+		total -= info.Size() // 覆盖已有的结果
+	}
+	// 先写临时文件再重命名，避免并发读取到写了一半的内容
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	total += int64(len(content))
+	if maxSize > 0 && total > maxSize {
+		total = evictTranscriptionCache(dir, maxSize)
+	}
+	transcriptionCacheSizes[dir] = total
+	return nil
+}
+
+type transcriptionCacheFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+func listTranscriptionCache(dir string) (files []transcriptionCacheFile, total int64) {
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		files = append(files, transcriptionCacheFile{path: path, size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	return files, total
+}
+
+func transcriptionCacheSize(dir string) int64 {
+	_, total := listTranscriptionCache(dir)
+	return total
+}
+
+// evictTranscriptionCache 缓存总大小超过上限时，从最久未使用的结果开始删除，返回删除后的总大小
+func evictTranscriptionCache(dir string, maxSize int64) int64 {
+	files, total := listTranscriptionCache(dir)
+	if total <= maxSize {
+		return total
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+	var evicted int
+	for _, file := range files {
+		if total <= maxSize {
+			break
+		}
+		if err := os.Remove(file.path); err != nil {
+			continue
+		}
+		total -= file.size
+		evicted++
+	}
+	log.GetLogger().Info("转录缓存超过大小上限，已删除最久未使用的结果", zap.Int("evicted", evicted), zap.Int64("totalBytes", total))
+	return total
+}
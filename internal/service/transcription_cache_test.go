@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"krillin-ai/config"
+	"krillin-ai/internal/types"
+	"krillin-ai/log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTranscribeWithCacheReusesResultAcrossTasks(t *testing.T) {
+	log.InitLogger()
+	original := config.Conf.Transcribe
+	defer func() { config.Conf.Transcribe = original }()
+	config.Conf.Transcribe.Cache = config.TranscriptionCacheConfig{Enable: true, Dir: t.TempDir()}
+
+	audio := filepath.Join(t.TempDir(), "split_audio_001.mp3")
+	if err := os.WriteFile(audio, []byte("segment audio"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stub := &stubTranscriber{}
+	s := Service{Transcriber: stub}
+	for range 2 {
+		data, err := s.transcribeWithCache(context.Background(), audio, "en", types.TranscriptionHint{}, t.TempDir(), false)
+		if err != nil || data.Text != "hello" {
+			t.Fatalf("transcribeWithCache() = %+v, %v", data, err)
+		}
+	}
+	if stub.calls != 1 {
+		t.Fatalf("calls = %d, want cache hit on second run", stub.calls)
+	}
+
+	// 语言或提示词不同时不能复用
+	if _, err := s.transcribeWithCache(context.Background(), audio, "ja", types.TranscriptionHint{}, t.TempDir(), false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.transcribeWithCache(context.Background(), audio, "en", types.TranscriptionHint{Vocabulary: []string{"KrillinAI"}}, t.TempDir(), false); err != nil {
+		t.Fatal(err)
+	}
+	// noCache跳过查找
+	if _, err := s.transcribeWithCache(context.Background(), audio, "en", types.TranscriptionHint{}, t.TempDir(), true); err != nil {
+		t.Fatal(err)
+	}
+	if stub.calls != 4 {
+		t.Fatalf("calls = %d, want 4", stub.calls)
+	}
+}
+
+func TestEvictTranscriptionCacheRemovesLeastRecentlyUsed(t *testing.T) {
+	log.InitLogger()
+	dir := t.TempDir()
+	data := &types.TranscriptionData{Text: strings.Repeat("a", 100)}
+	keys := []string{"aa01", "bb02", "cc03"}
+	for i, key := range keys {
+		if err := storeCachedTranscription(dir, key, data, 0); err != nil {
+			t.Fatal(err)
+		}
+		modTime := time.Now().Add(time.Duration(i-10) * time.Minute)
+		if err := os.Chtimes(transcriptionCachePath(dir, key), modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 读取会刷新使用时间，最早写入的aa01被读取后不应被淘汰
+	if _, ok := loadCachedTranscription(dir, "aa01"); !ok {
+		t.Fatal("cache miss for aa01")
+	}
+	info, err := os.Stat(transcriptionCachePath(dir, "aa01"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	evictTranscriptionCache(dir, 2*info.Size())
+
+	if _, ok := loadCachedTranscription(dir, "bb02"); ok {
+		t.Fatal("bb02 should have been evicted")
+	}
+	for _, key := range []string{"aa01", "cc03"} {
+		if _, ok := loadCachedTranscription(dir, key); !ok {
+			t.Fatalf("%s should have been kept", key)
+		}
+	}
+}
+
+func TestStoreCachedTranscriptionEvictsOnlyOverLimit(t *testing.T) {
+	log.InitLogger()
+	dir := t.TempDir()
+	data := &types.TranscriptionData{Text: strings.Repeat("a", 100)}
+	content, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	maxSize := 2 * int64(len(content))
+	for i, key := range []string{"aa01", "bb02"} {
+		if err = storeCachedTranscription(dir, key, data, maxSize); err != nil {
+			t.Fatal(err)
+		}
+		modTime := time.Now().Add(time.Duration(i-10) * time.Minute)
+		if err = os.Chtimes(transcriptionCachePath(dir, key), modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 覆盖已有结果不增加总大小
+	if err = storeCachedTranscription(dir, "bb02", data, maxSize); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(transcriptionCachePath(dir, "aa01")); err != nil {
+		t.Fatal("aa01 evicted while the cache was within the limit")
+	}
+
+	if err = storeCachedTranscription(dir, "cc03", data, maxSize); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(transcriptionCachePath(dir, "aa01")); err == nil {
+		t.Fatal("aa01 should have been evicted")
+	}
+	if got, want := transcriptionCacheSizes[dir], transcriptionCacheSize(dir); got != want || got > maxSize {
+		t.Fatalf("running size = %d, on disk = %d, max = %d", got, want, maxSize)
+	}
+}
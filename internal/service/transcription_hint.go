@@ -0,0 +1,26 @@
+package service
+
+import (
+	"krillin-ai/config"
+	"krillin-ai/internal/types"
+	"strings"
+)
+
+// NewTranscriptionHint 合并任务提交的热词、背景说明和配置中的默认值。
+// 配置的热词在前，提示词过长时会优先保留任务自己的热词
+func NewTranscriptionHint(vocabulary []string, prompt string) types.TranscriptionHint {
+	hint := types.TranscriptionHint{Context: strings.TrimSpace(prompt)}
+	if hint.Context == "" {
+		hint.Context = strings.TrimSpace(config.Conf.Transcribe.Prompt)
+	}
+	seen := make(map[string]bool)
+	for _, word := range append(append([]string{}, config.Conf.Transcribe.Vocabulary...), vocabulary...) {
+		word = strings.TrimSpace(word)
+		if word == "" || seen[strings.ToLower(word)] {
+			continue
+		}
+		seen[strings.ToLower(word)] = true
+		hint.Vocabulary = append(hint.Vocabulary, word)
+	}
+	return hint
+}
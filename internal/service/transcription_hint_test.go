@@ -0,0 +1,42 @@
+package service
+
+import (
+	"krillin-ai/config"
+	"krillin-ai/internal/types"
+	"strings"
+	"testing"
+)
+
+func TestNewTranscriptionHintMergesConfigDefaults(t *testing.T) {
+	original := config.Conf.Transcribe
+	defer func() { config.Conf.Transcribe = original }()
+	config.Conf.Transcribe.Vocabulary = []string{"KrillinAI", "FFmpeg"}
+	config.Conf.Transcribe.Prompt = "A podcast about video tools."
+
+	hint := NewTranscriptionHint([]string{" ffmpeg", "yt-dlp", ""}, "")
+	if got := strings.Join(hint.Vocabulary, "|"); got != "KrillinAI|FFmpeg|yt-dlp" {
+		t.Fatalf("Vocabulary = %q", got)
+	}
+	if hint.Context != "A podcast about video tools." {
+		t.Fatalf("Context = %q", hint.Context)
+	}
+	if got := hint.Prompt(); got != "A podcast about video tools. KrillinAI, FFmpeg, yt-dlp." {
+		t.Fatalf("Prompt() = %q", got)
+	}
+
+	// 任务填写的背景说明优先于配置
+	if hint = NewTranscriptionHint(nil, "Release notes"); hint.Context != "Release notes" {
+		t.Fatalf("Context = %q", hint.Context)
+	}
+}
+
+func TestTranscriptionHintPromptKeepsTail(t *testing.T) {
+	if prompt := (types.TranscriptionHint{}).Prompt(); prompt != "" {
+		t.Fatalf("empty hint Prompt() = %q", prompt)
+	}
+	hint := types.TranscriptionHint{Context: strings.Repeat("background ", 100), Vocabulary: []string{"KrillinAI"}}
+	prompt := hint.Prompt()
+	if len([]rune(prompt)) > 600 || !strings.HasSuffix(prompt, "KrillinAI.") {
+		t.Fatalf("Prompt() = %q", prompt)
+	}
+}
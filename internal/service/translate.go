@@ -1,12 +1,13 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"krillin-ai/config"
+	"krillin-ai/internal/tm"
 	"krillin-ai/internal/types"
 	"krillin-ai/log"
-	"krillin-ai/pkg/openai"
 	"krillin-ai/pkg/util"
 	"strings"
 	"sync"
@@ -16,16 +17,35 @@ import (
 )
 
 type Translator struct {
-	chatCompleter types.ChatCompleter
+	chatCompleter  types.ChatCompleter
+	glossary       types.Glossary
+	glossaryReport *glossaryReport
+	memory         *tm.Memory
 }
 
 func NewTranslator() *Translator {
 	return &Translator{
-		chatCompleter: openai.NewClient(config.Conf.Llm.BaseUrl, config.Conf.Llm.ApiKey, config.Conf.App.Proxy),
+		chatCompleter: newChatCompleter(),
+		memory:        openTranslationMemory(),
 	}
 }
 
-func (t *Translator) SplitTextAndTranslate(inputText string, originLang, targetLang types.StandardLanguageCode) ([]*TranslatedItem, error) {
+// WithGlossary 返回使用任务术语表的翻译器，重试后仍不符合术语表的句子通过GlossaryViolations获取
+func (t *Translator) WithGlossary(glossary types.Glossary) *Translator {
+	return &Translator{
+		chatCompleter:  t.chatCompleter,
+		glossary:       glossary,
+		glossaryReport: &glossaryReport{},
+		memory:         t.memory,
+	}
+}
+
+// GlossaryViolations 重试后仍未按术语表翻译的句子
+func (t *Translator) GlossaryViolations() []glossaryViolation {
+	return t.glossaryReport.list()
+}
+
+func (t *Translator) SplitTextAndTranslate(ctx context.Context, inputText string, originLang, targetLang types.StandardLanguageCode) ([]*TranslatedItem, error) {
 	sentences := util.SplitTextSentences(inputText, config.Conf.App.MaxSentenceLength)
 	if len(sentences) == 0 {
 		return []*TranslatedItem{}, nil
@@ -46,7 +66,7 @@ func (t *Translator) SplitTextAndTranslate(inputText string, originLang, targetL
 		if sentence == "" {
 			continue
 		}
-		recursiveSplitItems := t.recursiveSplitSentence(sentence, 0)
+		recursiveSplitItems := t.recursiveSplitSentence(ctx, sentence, 0)
 		shortSentences = append(shortSentences, recursiveSplitItems...)
 	}
 
@@ -68,6 +88,20 @@ func (t *Translator) SplitTextAndTranslate(inputText string, originLang, targetL
 			defer wg.Done()
 			defer func() { <-signal }()
 
+			// 翻译记忆库中有相同的句子时直接复用，相近的句子作为参考译文
+			match, matched := lookupTranslationMemory(t.memory, t.glossary, originLang, targetLang, originText)
+			if matched && match.Exact {
+				results[index] = &TranslatedItem{
+					OriginText:     originText,
+					TranslatedText: match.Target,
+				}
+				return
+			}
+			var reference string
+			if matched {
+				reference = translationMemoryPromptSection(match)
+			}
+
 			contextSentenceNum := 3
 
 			// 生成前面3个句子的string
@@ -97,9 +131,9 @@ func (t *Translator) SplitTextAndTranslate(inputText string, originLang, targetL
 				}
 			}
 
-			prompt := fmt.Sprintf(types.SplitTextWithContextPrompt, types.GetStandardLanguageName(targetLang), previousSentences, originText, nextSentences)
+			prompt := fmt.Sprintf(types.SplitTextWithContextPrompt, types.GetStandardLanguageName(targetLang), t.glossary.PromptSection(originText)+reference, previousSentences, originText, nextSentences)
 
-			translatedText, err := t.translateWithRetry(prompt, originText, originLang, targetLang)
+			translatedText, err := t.translateWithRetry(ctx, prompt, originText, originLang, targetLang)
 			if err != nil {
 				log.GetLogger().Error("splitTextAndTranslate llm translate error after retries", zap.Error(err), zap.Any("original text", originText))
 				results[index] = &TranslatedItem{
@@ -120,7 +154,7 @@ func (t *Translator) SplitTextAndTranslate(inputText string, originLang, targetL
 	return results, nil
 }
 
-func (t *Translator) splitOriginLongSentence(sentence string) ([]string, error) {
+func (t *Translator) splitOriginLongSentence(ctx context.Context, sentence string) ([]string, error) {
 	prompt := fmt.Sprintf(types.SplitOriginLongSentencePrompt, sentence)
 
 	var response string
@@ -128,9 +162,12 @@ func (t *Translator) splitOriginLongSentence(sentence string) ([]string, error)
 	shortSentences := make([]string, 0)
 	// 尝试调用3次
 	for i := range 3 {
-		response, err = t.chatCompleter.ChatCompletion(prompt)
+		response, err = t.chatCompleter.ChatCompletion(ctx, types.ChatRequest{UseCase: config.LlmUseCaseSplit, Prompt: prompt, JSONSchema: types.ShortSentencesSchema})
 		if err != nil {
 			log.GetLogger().Error("splitOriginLongSentence chat completion error", zap.Error(err), zap.String("sentence", sentence), zap.Any("time", i))
+			if !types.IsRetryableChatError(err) {
+				break
+			}
 			continue
 		}
 		var splitResult struct {
@@ -165,11 +202,11 @@ func (t *Translator) splitOriginLongSentence(sentence string) ([]string, error)
 
 // RecursiveSplitSentence 递归拆分句子直到满足长度要求（公开方法）
 func (t *Translator) RecursiveSplitSentence(sentence string, depth int) []string {
-	return t.recursiveSplitSentence(sentence, depth)
+	return t.recursiveSplitSentence(context.Background(), sentence, depth)
 }
 
 // recursiveSplitSentence 递归拆分句子直到满足长度要求
-func (t *Translator) recursiveSplitSentence(sentence string, depth int) []string {
+func (t *Translator) recursiveSplitSentence(ctx context.Context, sentence string, depth int) []string {
 	const maxDepth = 5 // 防止无限递归，最多拆分5层
 
 	// 如果句子已经满足长度要求，直接返回
@@ -192,7 +229,7 @@ func (t *Translator) recursiveSplitSentence(sentence string, depth int) []string
 		zap.Int("depth", depth),
 		zap.Int("charCount", util.CountEffectiveChars(sentence)))
 
-	splitItems, err := t.splitOriginLongSentence(sentence)
+	splitItems, err := t.splitOriginLongSentence(ctx, sentence)
 	if err != nil {
 		log.GetLogger().Error("recursive split error, returning original sentence",
 			zap.Error(err),
@@ -217,7 +254,7 @@ func (t *Translator) recursiveSplitSentence(sentence string, depth int) []string
 			continue
 		}
 		// 递归拆分子句
-		subItems := t.recursiveSplitSentence(item, depth+1)
+		subItems := t.recursiveSplitSentence(ctx, item, depth+1)
 		result = append(result, subItems...)
 	}
 
@@ -225,18 +262,29 @@ func (t *Translator) recursiveSplitSentence(sentence string, depth int) []string
 }
 
 // translateWithRetry 带重试和翻译质量检查的翻译方法
-func (t *Translator) translateWithRetry(prompt, originText string, originLang, targetLang types.StandardLanguageCode) (string, error) {
+func (t *Translator) translateWithRetry(ctx context.Context, prompt, originText string, originLang, targetLang types.StandardLanguageCode) (string, error) {
 	const maxRetries = 3
-	var lastErr error
+	var (
+		lastErr error
+		// 只有术语不符合要求的译文，重试后仍不符合时使用它并记录到术语表报告
+		glossaryCandidate  string
+		glossaryViolations []types.GlossaryTerm
+	)
 
 	for attempt := 0; attempt < maxRetries; attempt++ {
-		translatedText, err := t.chatCompleter.ChatCompletion(prompt)
+		translatedText, err := t.chatCompleter.ChatCompletion(ctx, types.ChatRequest{UseCase: config.LlmUseCaseTranslate, Prompt: prompt})
 		if err != nil {
 			lastErr = err
 			log.GetLogger().Warn("translate attempt failed",
 				zap.Error(err),
 				zap.Int("attempt", attempt+1),
 				zap.String("originText", originText))
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			if !types.IsRetryableChatError(err) {
+				break
+			}
 			continue
 		}
 
@@ -253,17 +301,32 @@ func (t *Translator) translateWithRetry(prompt, originText string, originLang, t
 			return translatedText, nil
 		}
 
+		violations := t.glossary.Violations(originText, translatedText)
 		log.GetLogger().Warn("translation quality check failed, retrying",
 			zap.Int("attempt", attempt+1),
 			zap.String("originText", originText),
-			zap.String("translatedText", translatedText))
+			zap.String("translatedText", translatedText),
+			zap.Any("glossaryViolations", violations))
+
+		if len(violations) > 0 && t.meetsTranslationQuality(originText, translatedText, targetLang) {
+			glossaryCandidate, glossaryViolations = translatedText, violations
+		}
 
 		// 为下一次重试修改提示词，增加强调
 		if attempt < maxRetries-1 {
-			prompt = t.enhanceTranslationPrompt(prompt, originText, translatedText, targetLang)
+			if len(violations) > 0 {
+				prompt = t.glossary.RetryPrompt(prompt, translatedText, violations)
+			} else {
+				prompt = t.enhanceTranslationPrompt(prompt, originText, translatedText, targetLang)
+			}
 		}
 	}
 
+	if glossaryCandidate != "" {
+		t.glossaryReport.add(originText, glossaryCandidate, glossaryViolations)
+		return glossaryCandidate, nil
+	}
+
 	if lastErr != nil {
 		return "", lastErr
 	}
@@ -271,8 +334,14 @@ func (t *Translator) translateWithRetry(prompt, originText string, originLang, t
 	return "", fmt.Errorf("translation quality check failed after %d attempts", maxRetries)
 }
 
-// isTranslationValid 检查翻译是否有效
+// isTranslationValid 检查翻译是否有效，且原文中出现的术语都按术语表翻译
 func (t *Translator) isTranslationValid(originText, translatedText string, originLang, targetLang types.StandardLanguageCode) bool {
+	return t.meetsTranslationQuality(originText, translatedText, targetLang) &&
+		len(t.glossary.Violations(originText, translatedText)) == 0
+}
+
+// meetsTranslationQuality 检查译文非空、不是原文、具有目标语言特征且长度合理
+func (t *Translator) meetsTranslationQuality(originText, translatedText string, targetLang types.StandardLanguageCode) bool {
 	// 1. 翻译不能为空
 	if strings.TrimSpace(translatedText) == "" {
 		return false
@@ -425,7 +494,7 @@ func isSentenceEnding(text string) bool {
 }
 
 // BatchTranslateSrtBlocks 批量翻译SRT字幕块（智能分组：按完整句子分组，最多10个块）
-func (t *Translator) BatchTranslateSrtBlocks(blocks []*util.SrtBlock, originLang, targetLang string, taskPtr *types.SubtitleTask) error {
+func (t *Translator) BatchTranslateSrtBlocks(ctx context.Context, blocks []*util.SrtBlock, originLang, targetLang string, taskPtr *types.SubtitleTask) error {
 	if len(blocks) == 0 {
 		return nil
 	}
@@ -438,17 +507,31 @@ func (t *Translator) BatchTranslateSrtBlocks(blocks []*util.SrtBlock, originLang
 	originLangCode := types.StandardLanguageCode(originLang)
 	targetLangCode := types.StandardLanguageCode(targetLang)
 
-	// 统计有内容的字幕块数量
+	// 统计有内容的字幕块数量，翻译记忆库中有相同原文的字幕直接复用译文，相近的记录作为参考译文
 	validBlocksCount := 0
+	reused := make(map[*util.SrtBlock]bool)
+	references := make(map[*util.SrtBlock]tm.Match)
 	for _, block := range blocks {
-		if block.OriginLanguageSentence != "" {
-			validBlocksCount++
+		if block.OriginLanguageSentence == "" {
+			continue
+		}
+		validBlocksCount++
+		match, ok := lookupTranslationMemory(t.memory, t.glossary, originLangCode, targetLangCode, block.OriginLanguageSentence)
+		if !ok {
+			continue
+		}
+		if match.Exact {
+			block.TargetLanguageSentence = match.Target
+			reused[block] = true
+		} else {
+			references[block] = match
 		}
 	}
 
 	log.GetLogger().Info("开始智能批量翻译SRT字幕块",
 		zap.Int("总块数", len(blocks)),
 		zap.Int("有效块数", validBlocksCount),
+		zap.Int("翻译记忆库复用", len(reused)),
 		zap.Int("最大批次大小", maxBatchSize),
 		zap.String("源语言", originLang),
 		zap.String("目标语言", targetLang))
@@ -462,6 +545,14 @@ func (t *Translator) BatchTranslateSrtBlocks(blocks []*util.SrtBlock, originLang
 		if block.OriginLanguageSentence == "" {
 			continue
 		}
+		// 复用译文的字幕不再翻译，但仍作为句子的边界
+		if reused[block] {
+			if isSentenceEnding(block.OriginLanguageSentence) && len(currentSentence) > 0 {
+				sentences = append(sentences, currentSentence)
+				currentSentence = make([]*util.SrtBlock, 0)
+			}
+			continue
+		}
 
 		currentSentence = append(currentSentence, block)
 
@@ -571,6 +662,9 @@ func (t *Translator) BatchTranslateSrtBlocks(blocks []*util.SrtBlock, originLang
 
 	// 分批处理
 	for batchIdx, batch := range batches {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		currentBatchNum := batchIdx + 1
 		log.GetLogger().Info("处理批次",
 			zap.Int("当前批次", currentBatchNum),
@@ -579,11 +673,17 @@ func (t *Translator) BatchTranslateSrtBlocks(blocks []*util.SrtBlock, originLang
 			zap.String("进度", fmt.Sprintf("%d/%d", currentBatchNum, totalBatches)))
 
 		// 构建批量翻译的输入文本
-		var originTexts []string
+		var (
+			originTexts     []string
+			batchReferences []tm.Match
+		)
 		for _, block := range batch {
 			if block.OriginLanguageSentence != "" {
 				originTexts = append(originTexts, block.OriginLanguageSentence)
 			}
+			if match, ok := references[block]; ok {
+				batchReferences = append(batchReferences, match)
+			}
 		}
 
 		if len(originTexts) == 0 {
@@ -591,7 +691,7 @@ func (t *Translator) BatchTranslateSrtBlocks(blocks []*util.SrtBlock, originLang
 		}
 
 		// 调用批量翻译
-		translations, err := t.batchTranslateTexts(originTexts, originLangCode, targetLangCode)
+		translations, err := t.batchTranslateTexts(ctx, originTexts, originLangCode, targetLangCode, batchReferences)
 		if err != nil {
 			log.GetLogger().Error("批量翻译失败，尝试单独翻译",
 				zap.Error(err),
@@ -609,11 +709,14 @@ func (t *Translator) BatchTranslateSrtBlocks(blocks []*util.SrtBlock, originLang
 					zap.Int("块索引", block.Index),
 					zap.String("文本预览", block.OriginLanguageSentence[:min(len(block.OriginLanguageSentence), 50)]))
 
-				translatedText, err := t.translateSingleText(
+				translatedText, err := t.translateSingleText(ctx,
 					block.OriginLanguageSentence,
 					originLangCode,
 					targetLangCode)
 
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
 				if err != nil {
 					log.GetLogger().Error("单独翻译失败，使用原文",
 						zap.Error(err),
@@ -636,10 +739,23 @@ func (t *Translator) BatchTranslateSrtBlocks(blocks []*util.SrtBlock, originLang
 			zap.Int("当前批次", currentBatchNum),
 			zap.Int("翻译数量", len(translations)))
 
-		// 将翻译结果赋值回SRT块
+		// 将翻译结果赋值回SRT块，不符合术语表的字幕单独重试
 		for j, translation := range translations {
-			if j < len(batch) {
-				batch[j].TargetLanguageSentence = translation
+			if j >= len(batch) {
+				continue
+			}
+			batch[j].TargetLanguageSentence = translation
+			violations := t.glossary.Violations(batch[j].OriginLanguageSentence, translation)
+			if len(violations) == 0 {
+				continue
+			}
+			log.GetLogger().Warn("批量译文不符合术语表，单独重试",
+				zap.Int("块索引", batch[j].Index),
+				zap.Any("术语", violations))
+			if translatedText, err := t.translateSingleText(ctx, batch[j].OriginLanguageSentence, originLangCode, targetLangCode); err == nil {
+				batch[j].TargetLanguageSentence = translatedText
+			} else {
+				t.glossaryReport.add(batch[j].OriginLanguageSentence, translation, violations)
 			}
 		}
 
@@ -659,7 +775,8 @@ func (t *Translator) BatchTranslateSrtBlocks(blocks []*util.SrtBlock, originLang
 }
 
 // batchTranslateTexts 批量翻译多个文本（通过单次LLM调用）
-func (t *Translator) batchTranslateTexts(texts []string, originLang, targetLang types.StandardLanguageCode) ([]string, error) {
+// references为翻译记忆库中相近句子的已有译文，附加在提示词中供参考
+func (t *Translator) batchTranslateTexts(ctx context.Context, texts []string, originLang, targetLang types.StandardLanguageCode, references []tm.Match) ([]string, error) {
 	if len(texts) == 0 {
 		return []string{}, nil
 	}
@@ -688,7 +805,7 @@ CRITICAL INSTRUCTIONS:
 7. Do NOT add explanations, interpretations, or extra information
 8. Output ONLY valid JSON, NO markdown code blocks, NO explanations, NO notes
 9. Start directly with { and end with }
-
+%s
 Input subtitles:
 %s
 
@@ -699,6 +816,7 @@ Required JSON format (output ONLY this structure):
 		targetLangName,
 		targetLangName,
 		len(texts),
+		t.glossary.PromptSection(texts...)+translationMemoryPromptSection(references...),
 		textList.String())
 
 	// 调用LLM
@@ -710,13 +828,20 @@ Required JSON format (output ONLY this structure):
 			zap.Int("文本数", len(texts)),
 			zap.Int("尝试次数", attempt+1))
 
-		response, err := t.chatCompleter.ChatCompletion(prompt)
+		response, err := t.chatCompleter.ChatCompletion(ctx, types.ChatRequest{UseCase: config.LlmUseCaseTranslate, Prompt: prompt, JSONSchema: types.BatchTranslationSchema})
 		if err != nil {
 			lastErr = err
+			if !types.IsRetryableChatError(err) {
+				break
+			}
 			log.GetLogger().Warn("批量翻译LLM调用失败，重试",
 				zap.Error(err),
 				zap.Int("尝试次数", attempt+1))
-			time.Sleep(time.Second * time.Duration(attempt+1))
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(time.Second * time.Duration(attempt+1)):
+			}
 			continue
 		}
 
@@ -773,10 +898,13 @@ Required JSON format (output ONLY this structure):
 }
 
 // translateSingleText 翻译单个文本（用作批量翻译失败时的回退）
-func (t *Translator) translateSingleText(text string, originLang, targetLang types.StandardLanguageCode) (string, error) {
-	prompt := fmt.Sprintf(types.SplitTextPrompt, types.GetStandardLanguageName(targetLang), text)
+func (t *Translator) translateSingleText(ctx context.Context, text string, originLang, targetLang types.StandardLanguageCode) (string, error) {
+	prompt := fmt.Sprintf(types.SplitTextPrompt, types.GetStandardLanguageName(targetLang), text) + t.glossary.PromptSection(text)
+	if match, ok := lookupTranslationMemory(t.memory, t.glossary, originLang, targetLang, text); ok {
+		prompt += translationMemoryPromptSection(match)
+	}
 
-	translatedText, err := t.translateWithRetry(prompt, text, originLang, targetLang)
+	translatedText, err := t.translateWithRetry(ctx, prompt, text, originLang, targetLang)
 	if err != nil {
 		return "", fmt.Errorf("单文本翻译失败: %w", err)
 	}
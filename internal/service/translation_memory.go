@@ -0,0 +1,128 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"krillin-ai/config"
+	"krillin-ai/internal/tm"
+	"krillin-ai/internal/types"
+	"krillin-ai/log"
+	"krillin-ai/pkg/util"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+var (
+	translationMemoryMu sync.Mutex
+	// translationMemories 按文件路径共用记忆库实例，修改配置中的路径后打开新的文件
+	translationMemories = make(map[string]*tm.Memory)
+)
+
+// openTranslationMemory 打开配置的翻译记忆库，未启用或打开失败时返回nil，翻译照常进行
+func openTranslationMemory() *tm.Memory {
+	cfg := config.Conf.App.TranslationMemory
+	if !cfg.Enable || cfg.Path == "" {
+		return nil
+	}
+	translationMemoryMu.Lock()
+	defer translationMemoryMu.Unlock()
+	if memory, ok := translationMemories[cfg.Path]; ok {
+		return memory
+	}
+	memory, err := tm.Open(cfg.Path)
+	if err != nil {
+		log.GetLogger().Warn("打开翻译记忆库失败，本次不使用", zap.String("path", cfg.Path), zap.Error(err))
+		return nil
+	}
+	translationMemories[cfg.Path] = memory
+	return memory
+}
+
+// lookupTranslationMemory 查找原文相同或相近的已有译文。源语言未确定时不查找，不符合当前术语表的译文不复用
+func lookupTranslationMemory(memory *tm.Memory, glossary types.Glossary, originLang, targetLang types.StandardLanguageCode, originText string) (tm.Match, bool) {
+	if memory == nil || originLang == "" || originLang == types.LanguageNameAuto {
+		return tm.Match{}, false
+	}
+	match, ok := memory.Lookup(string(originLang), string(targetLang), originText, config.Conf.App.TranslationMemory.FuzzyThreshold)
+	if !ok || len(glossary.Violations(originText, match.Target)) > 0 {
+		return tm.Match{}, false
+	}
+	return match, true
+}
+
+// translationMemoryPromptSection 相近句子的已有译文，附加在提示词中供大模型参考
+func translationMemoryPromptSection(matches ...tm.Match) string {
+	if len(matches) == 0 {
+		return ""
+	}
+	lines := make([]string, 0, len(matches))
+	for _, match := range matches {
+		lines = append(lines, fmt.Sprintf("- %s => %s", match.Source, match.Target))
+	}
+	return "\n**Reference translations**:\nSimilar sentences translated before, keep the wording consistent with them where the meaning is the same:\n" + strings.Join(lines, "\n") + "\n"
+}
+
+// recordTranslationMemory 把任务的译文写入翻译记忆库，未翻译成功或不符合术语表的句子不记录
+func recordTranslationMemory(taskId string, originLang, targetLang types.StandardLanguageCode, items []*TranslatedItem) {
+	memory := openTranslationMemory()
+	if memory == nil || originLang == "" || originLang == types.LanguageNameAuto || targetLang == "" {
+		return
+	}
+	entries := make([]tm.Entry, 0, len(items))
+	for _, item := range items {
+		if item == nil || len(item.GlossaryViolations) > 0 || strings.TrimSpace(item.TranslatedText) == strings.TrimSpace(item.OriginText) {
+			continue
+		}
+		entries = append(entries, tm.Entry{
+			SourceLang: string(originLang),
+			TargetLang: string(targetLang),
+			Source:     item.OriginText,
+			Target:     item.TranslatedText,
+			TaskId:     taskId,
+		})
+	}
+	added, err := memory.Add(entries...)
+	if err != nil {
+		log.GetLogger().Warn("写入翻译记忆库失败", zap.String("taskId", taskId), zap.Error(err))
+		return
+	}
+	log.GetLogger().Info("译文已写入翻译记忆库", zap.String("taskId", taskId), zap.Int("count", added))
+}
+
+// recordSrtBlocksTranslationMemory 把字幕块的译文写入翻译记忆库，violations中的句子不记录
+func recordSrtBlocksTranslationMemory(taskId string, originLang, targetLang types.StandardLanguageCode, blocks []*util.SrtBlock, violations []glossaryViolation) {
+	violated := make(map[string][]types.GlossaryTerm, len(violations))
+	for _, violation := range violations {
+		violated[violation.OriginText] = violation.Terms
+	}
+	items := make([]*TranslatedItem, 0, len(blocks))
+	for _, block := range blocks {
+		items = append(items, &TranslatedItem{
+			OriginText:         block.OriginLanguageSentence,
+			TranslatedText:     block.TargetLanguageSentence,
+			GlossaryViolations: violated[block.OriginLanguageSentence],
+		})
+	}
+	recordTranslationMemory(taskId, originLang, targetLang, items)
+}
+
+// loadTranslatedItems 读取各分段持久化的翻译结果，断点续跑复用的分段也会包含在内
+func loadTranslatedItems(taskBasePath string, segmentNum int) ([]*TranslatedItem, error) {
+	var all []*TranslatedItem
+	for i := range segmentNum {
+		var items []*TranslatedItem
+		err := loadJSONArtifact(filepath.Join(taskBasePath, fmt.Sprintf(types.SubtitleTaskTranslationDataPersistenceFileNamePattern, i)), &items)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		all = append(all, items...)
+	}
+	return all, nil
+}
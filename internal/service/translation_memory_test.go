@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+	"krillin-ai/internal/tm"
+	"krillin-ai/log"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplitTextAndTranslateReusesTranslationMemory(t *testing.T) {
+	log.InitLogger()
+	memory, err := tm.Open(filepath.Join(t.TempDir(), "tm.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = memory.Add(
+		tm.Entry{SourceLang: "en", TargetLang: "zh_cn", Source: "Thanks for watching this video.", Target: "感谢观看本视频。"},
+		tm.Entry{SourceLang: "en", TargetLang: "zh_cn", Source: "Please subscribe to our channel.", Target: "请订阅我们的频道。"},
+	); err != nil {
+		t.Fatal(err)
+	}
+	chat := &scriptedChat{replies: []string{"请订阅这个频道。"}}
+	translator := &Translator{chatCompleter: chat, memory: memory}
+
+	items, err := translator.SplitTextAndTranslate(context.Background(), "Thanks for watching this video. Please subscribe to the channel.", "en", "zh_cn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].TranslatedText != "感谢观看本视频。" || items[1].TranslatedText != "请订阅这个频道。" {
+		t.Fatalf("items = %+v", items)
+	}
+	// 相同的句子不调用大模型，相近的句子带上参考译文
+	if len(chat.prompts) != 1 || !strings.Contains(chat.prompts[0], "Please subscribe to our channel. => 请订阅我们的频道。") {
+		t.Fatalf("prompts = %q", chat.prompts)
+	}
+}
@@ -9,25 +9,38 @@ import (
 	"krillin-ai/pkg/util"
 )
 
-func (s Service) uploadSubtitles(ctx context.Context, stepParam *types.SubtitleTaskStepParam) error {
+// uploadSubtitles 生成字幕和配音的下载地址，languages为多目标语言任务中附加目标语言的任务参数
+func (s Service) uploadSubtitles(ctx context.Context, stepParam *types.SubtitleTaskStepParam, languages ...*types.SubtitleTaskStepParam) error {
 	subtitleInfos := make([]types.SubtitleInfo, 0)
+	speechDownloadUrls := make(map[string]string)
 	var err error
-	for _, info := range stepParam.SubtitleInfos {
-		resultPath := info.Path
-		if len(stepParam.ReplaceWordsMap) > 0 { // 需要进行替换
-			replacedSrcFile := util.AddSuffixToFileName(resultPath, "_replaced")
-			err = util.ReplaceFileContent(resultPath, replacedSrcFile, stepParam.ReplaceWordsMap)
-			if err != nil {
-				log.GetLogger().Error("uploadSubtitles ReplaceFileContent err", zap.Any("stepParam", stepParam), zap.Error(err))
-				return fmt.Errorf("uploadSubtitles ReplaceFileContent err: %w", err)
+	publishTaskStage(stepParam.TaskPtr, TaskStageUpload)
+	for _, param := range append([]*types.SubtitleTaskStepParam{stepParam}, languages...) {
+		for _, info := range param.SubtitleInfos {
+			resultPath := info.Path
+			if len(param.ReplaceWordsMap) > 0 { // 需要进行替换
+				replacedSrcFile := util.AddSuffixToFileName(resultPath, "_replaced")
+				err = util.ReplaceFileContent(resultPath, replacedSrcFile, param.ReplaceWordsMap)
+				if err != nil {
+					log.GetLogger().Error("uploadSubtitles ReplaceFileContent err", zap.Any("stepParam", param), zap.Error(err))
+					return fmt.Errorf("uploadSubtitles ReplaceFileContent err: %w", err)
+				}
+				resultPath = replacedSrcFile
 			}
-			resultPath = replacedSrcFile
+			name := info.Name
+			if len(languages) > 0 {
+				name = languageSubtitleName(info, param.TargetLanguage)
+			}
+			subtitleInfos = append(subtitleInfos, types.SubtitleInfo{
+				TaskId:      param.TaskId,
+				Name:        name,
+				DownloadUrl: "/api/file/" + resultPath,
+				Language:    string(param.TargetLanguage),
+			})
+		}
+		if param.TtsResultFilePath != "" {
+			speechDownloadUrls[string(param.TargetLanguage)] = "/api/file/" + param.TtsResultFilePath
 		}
-		subtitleInfos = append(subtitleInfos, types.SubtitleInfo{
-			TaskId:      stepParam.TaskId,
-			Name:        info.Name,
-			DownloadUrl: "/api/file/" + resultPath,
-		})
 	}
 	// 更新字幕任务信息
 	taskPtr := stepParam.TaskPtr
@@ -38,5 +51,8 @@ func (s Service) uploadSubtitles(ctx context.Context, stepParam *types.SubtitleT
 	if stepParam.TtsResultFilePath != "" {
 		taskPtr.SpeechDownloadUrl = "/api/file/" + stepParam.TtsResultFilePath
 	}
+	if len(speechDownloadUrls) > 0 {
+		taskPtr.SpeechDownloadUrls = speechDownloadUrls
+	}
 	return nil
 }
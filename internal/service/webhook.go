@@ -0,0 +1,196 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"krillin-ai/config"
+	"krillin-ai/internal/dto"
+	"krillin-ai/internal/storage"
+	"krillin-ai/internal/types"
+	"krillin-ai/log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	callbackMaxAttempts     = 5
+	callbackRequestTimeout  = 10 * time.Second
+	callbackSignatureHeader = "X-Krillin-Signature"
+	callbackTimestampHeader = "X-Krillin-Timestamp"
+	callbackEventHeader     = "X-Krillin-Event"
+)
+
+// callbackRetryDelay 首次重试前的等待时间，之后每次翻倍
+var callbackRetryDelay = 2 * time.Second
+
+var callbackHttpClient = newCallbackHttpClient()
+
+// newCallbackHttpClient 在建立连接时检查实际连接的IP，域名解析到内网或重定向到内网地址时同样会被拒绝。
+// 经代理转发时无法检查目标地址，因此回调不使用代理
+func newCallbackHttpClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	dialer := &net.Dialer{
+		Timeout: callbackRequestTimeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			addr, err := netip.ParseAddr(host)
+			if err != nil {
+				return err
+			}
+			return checkCallbackAddr(addr)
+		},
+	}
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: callbackRequestTimeout, Transport: transport}
+}
+
+// checkCallbackAddr 默认禁止回调到本机、内网、链路本地（如169.254.169.254）等非公网地址，server.callback_allowed_networks中的地址除外
+func checkCallbackAddr(addr netip.Addr) error {
+	addr = addr.Unmap()
+	for _, network := range config.Conf.Server.CallbackAllowedNetworks {
+		if prefix, err := config.ParseNetwork(network); err == nil && prefix.Contains(addr) {
+			return nil
+		}
+	}
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() {
+		return fmt.Errorf("callback_url不能指向内网地址 %s", addr)
+	}
+	return nil
+}
+
+func validateCallbackUrl(callbackUrl string) error {
+	if callbackUrl == "" {
+		return nil
+	}
+	u, err := url.Parse(callbackUrl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("callback_url不合法，仅支持http或https地址")
+	}
+	// 域名在投递时按解析结果检查，这里先拒绝明显指向本机或内网的地址
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return checkCallbackAddr(netip.IPv6Loopback())
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return checkCallbackAddr(addr)
+	}
+	return nil
+}
+
+func newCallbackPayload(taskPtr *types.SubtitleTask) dto.SubtitleTaskCallbackPayload {
+	payload := dto.SubtitleTaskCallbackPayload{
+		TaskId:            taskPtr.TaskId,
+		SubtitleInfo:      toSubtitleInfoDtos(taskPtr.SubtitleInfos),
+		SpeechDownloadUrl: taskPtr.SpeechDownloadUrl,
+		Languages:         toLanguageResults(taskPtr),
+		Timestamp:         time.Now().Unix(),
+	}
+	switch taskPtr.Status {
+	case types.SubtitleTaskStatusSuccess:
+		payload.Event, payload.Status = "task.succeeded", "success"
+	case types.SubtitleTaskStatusCancelled:
+		payload.Event, payload.Status = "task.cancelled", "cancelled"
+		payload.FailReason = taskPtr.FailReason
+	default:
+		payload.Event, payload.Status = "task.failed", "failed"
+		payload.FailReason = taskPtr.FailReason
+	}
+	return payload
+}
+
+// signCallback 签名为 HMAC-SHA256(secret, "<timestamp>.<body>") 的十六进制
+func signCallback(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// notifyTaskCallback 任务结束后向callback_url投递结果，失败按指数退避重试，每次投递追加到任务仓库中的任务记录上。
+// taskPtr需为任务的副本，投递期间只读
+func notifyTaskCallback(taskPtr *types.SubtitleTask) {
+	if taskPtr.CallbackUrl == "" {
+		return
+	}
+	payload := newCallbackPayload(taskPtr)
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.GetLogger().Error("notifyTaskCallback marshal payload err", zap.String("taskId", taskPtr.TaskId), zap.Error(err))
+		return
+	}
+	delay := callbackRetryDelay
+	for attempt := 1; attempt <= callbackMaxAttempts; attempt++ {
+		statusCode, err := postCallback(taskPtr.CallbackUrl, taskPtr.CallbackSecret, payload, body)
+		record := types.CallbackAttempt{Attempt: attempt, Time: time.Now().Unix(), StatusCode: statusCode, Success: err == nil}
+		if err != nil {
+			record.Error = err.Error()
+		}
+		recordCallbackAttempt(taskPtr.TaskId, record)
+		if err == nil {
+			log.GetLogger().Info("任务回调成功", zap.String("taskId", taskPtr.TaskId), zap.Int("attempt", attempt))
+			return
+		}
+		log.GetLogger().Warn("任务回调失败", zap.String("taskId", taskPtr.TaskId), zap.Int("attempt", attempt), zap.Error(err))
+		if attempt < callbackMaxAttempts {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+}
+
+// recordCallbackAttempt 把投递记录追加到任务仓库中最新的任务记录上，任务已被删除或清理时不再写回，避免把任务恢复出来
+func recordCallbackAttempt(taskId string, record types.CallbackAttempt) {
+	taskSaveMu.Lock()
+	defer taskSaveMu.Unlock()
+	taskPtr, err := storage.SubtitleTasks.Get(taskId)
+	if err != nil {
+		return
+	}
+	taskPtr.CallbackAttempts = append(taskPtr.CallbackAttempts, record)
+	saveTask(taskPtr)
+}
+
+func postCallback(callbackUrl, secret string, payload dto.SubtitleTaskCallbackPayload, body []byte) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), callbackRequestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callbackUrl, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(payload.Timestamp, 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(callbackEventHeader, payload.Event)
+	req.Header.Set(callbackTimestampHeader, timestamp)
+	if secret != "" {
+		req.Header.Set(callbackSignatureHeader, signCallback(secret, timestamp, body))
+	}
+	resp, err := callbackHttpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("callback responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"encoding/json"
+	"io"
+	"krillin-ai/config"
+	"krillin-ai/internal/dto"
+	"krillin-ai/internal/storage"
+	"krillin-ai/internal/types"
+	"krillin-ai/log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNotifyTaskCallbackRetriesAndSigns(t *testing.T) {
+	log.InitLogger()
+	previousStore, previousDelay := storage.SubtitleTasks, callbackRetryDelay
+	previousNetworks := config.Conf.Server.CallbackAllowedNetworks
+	storage.SubtitleTasks = storage.NewMemoryTaskStore()
+	callbackRetryDelay = time.Millisecond
+	config.Conf.Server.CallbackAllowedNetworks = []string{"127.0.0.1"}
+	t.Cleanup(func() {
+		storage.SubtitleTasks = previousStore
+		callbackRetryDelay = previousDelay
+		config.Conf.Server.CallbackAllowedNetworks = previousNetworks
+	})
+
+	calls := 0
+	var payload dto.SubtitleTaskCallbackPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		want := signCallback("secret", r.Header.Get(callbackTimestampHeader), body)
+		if got := r.Header.Get(callbackSignatureHeader); got != want {
+			t.Errorf("signature = %q, want %q", got, want)
+		}
+		_ = json.Unmarshal(body, &payload)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	task := &types.SubtitleTask{
+		TaskId:            "callback_demo",
+		Status:            types.SubtitleTaskStatusSuccess,
+		SubtitleInfos:     []types.SubtitleInfo{{Name: "bilingual.srt", DownloadUrl: "/api/file/tasks/callback_demo/output/bilingual.srt"}},
+		SpeechDownloadUrl: "/api/file/tasks/callback_demo/output/tts.wav",
+		CallbackUrl:       server.URL,
+		CallbackSecret:    "secret",
+	}
+	_ = storage.SubtitleTasks.Save(task)
+	notifyTaskCallback(task.Clone())
+
+	stored, err := storage.SubtitleTasks.Get(task.TaskId)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if calls != 2 || len(stored.CallbackAttempts) != 2 {
+		t.Fatalf("calls = %d, attempts = %#v, want one retry", calls, stored.CallbackAttempts)
+	}
+	if stored.CallbackAttempts[0].Success || stored.CallbackAttempts[0].StatusCode != http.StatusBadGateway || !stored.CallbackAttempts[1].Success {
+		t.Fatalf("attempts = %#v, want failed then succeeded", stored.CallbackAttempts)
+	}
+	if payload.Status != "success" || payload.TaskId != task.TaskId || len(payload.SubtitleInfo) != 1 || payload.SpeechDownloadUrl == "" {
+		t.Fatalf("payload = %#v", payload)
+	}
+}
+
+func TestNotifyTaskCallbackBlocksInternalAddress(t *testing.T) {
+	log.InitLogger()
+	previousStore, previousDelay := storage.SubtitleTasks, callbackRetryDelay
+	storage.SubtitleTasks = storage.NewMemoryTaskStore()
+	callbackRetryDelay = time.Millisecond
+	t.Cleanup(func() {
+		storage.SubtitleTasks = previousStore
+		callbackRetryDelay = previousDelay
+	})
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer server.Close()
+
+	task := &types.SubtitleTask{TaskId: "callback_internal", Status: types.SubtitleTaskStatusSuccess, CallbackUrl: server.URL}
+	_ = storage.SubtitleTasks.Save(task)
+	notifyTaskCallback(task.Clone())
+
+	if calls != 0 {
+		t.Fatalf("calls = %d, want callback to loopback blocked", calls)
+	}
+	stored, err := storage.SubtitleTasks.Get(task.TaskId)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if len(stored.CallbackAttempts) == 0 || stored.CallbackAttempts[0].Success {
+		t.Fatalf("attempts = %#v, want failed attempts recorded", stored.CallbackAttempts)
+	}
+}
+
+func TestNotifyTaskCallbackDoesNotRestoreDeletedTask(t *testing.T) {
+	log.InitLogger()
+	previousStore := storage.SubtitleTasks
+	previousNetworks := config.Conf.Server.CallbackAllowedNetworks
+	storage.SubtitleTasks = storage.NewMemoryTaskStore()
+	config.Conf.Server.CallbackAllowedNetworks = []string{"127.0.0.1"}
+	t.Cleanup(func() {
+		storage.SubtitleTasks = previousStore
+		config.Conf.Server.CallbackAllowedNetworks = previousNetworks
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	// 任务在投递前已被删除，投递记录不应把任务写回
+	task := &types.SubtitleTask{TaskId: "callback_deleted", Status: types.SubtitleTaskStatusSuccess, CallbackUrl: server.URL}
+	notifyTaskCallback(task)
+
+	if _, err := storage.SubtitleTasks.Get(task.TaskId); err == nil {
+		t.Fatal("deleted task restored by callback attempt")
+	}
+}
+
+func TestValidateCallbackUrl(t *testing.T) {
+	previousNetworks := config.Conf.Server.CallbackAllowedNetworks
+	t.Cleanup(func() { config.Conf.Server.CallbackAllowedNetworks = previousNetworks })
+	config.Conf.Server.CallbackAllowedNetworks = nil
+
+	for _, callbackUrl := range []string{
+		"ftp://example.com/hook", "not a url", "http://",
+		"http://127.0.0.1:8080/hook", "http://169.254.169.254/latest/meta-data", "http://[::1]/",
+		"http://localhost/hook", "http://10.0.0.5/",
+	} {
+		if err := validateCallbackUrl(callbackUrl); err == nil {
+			t.Fatalf("validateCallbackUrl(%q) error = nil", callbackUrl)
+		}
+	}
+	if err := validateCallbackUrl("https://cms.example.com/hooks/krillin"); err != nil {
+		t.Fatalf("validateCallbackUrl() error = %v", err)
+	}
+
+	config.Conf.Server.CallbackAllowedNetworks = []string{"10.0.0.0/8"}
+	if err := validateCallbackUrl("http://10.0.0.5/"); err != nil {
+		t.Fatalf("validateCallbackUrl() with allowlist error = %v", err)
+	}
+}
@@ -38,7 +38,8 @@ type YoutubeSubtitleReq struct {
 	TargetLanguage      string
 	VttFile             string
 	TaskPtr             *types.SubtitleTask
-	TargetLanguageFirst bool // 是否将目标语言放在上面（双语字幕）
+	TargetLanguageFirst bool           // 是否将目标语言放在上面（双语字幕）
+	Glossary            types.Glossary // 翻译术语表
 }
 
 // YouTubeSubtitleService handles all operations related to YouTube subtitles.
@@ -166,7 +167,7 @@ func (s *YouTubeSubtitleService) downloadYouTubeSubtitle(ctx context.Context, re
 			zap.Int("attempt", attempt+1),
 			zap.Int("maxAttempts", maxAttempts))
 
-		cmd := exec.Command(storage.YtdlpPath, cmdArgs...)
+		cmd := exec.CommandContext(ctx, storage.YtdlpPath, cmdArgs...)
 		output, err := cmd.CombinedOutput()
 
 		if err == nil {
@@ -307,7 +308,7 @@ func (s *YouTubeSubtitleService) processYouTubeSubtitle(ctx context.Context, req
 	}
 
 	// 4. 批量翻译生成目标语言SRT（40%-90%进度）
-	err = s.translator.BatchTranslateSrtBlocks(srtBlocks, req.OriginLanguage, req.TargetLanguage, req.TaskPtr)
+	err = s.batchTranslateWithGlossary(ctx, srtBlocks, req)
 	if err != nil {
 		return "", fmt.Errorf("failed to batch translate: %w", err)
 	}
@@ -790,7 +791,7 @@ func (s *YouTubeSubtitleService) splitIntoWordsKeepPunctuation(text string) []st
 }
 
 // ConvertVttToSrt 将VTT转换为SRT格式
-func (s *YouTubeSubtitleService) ConvertVttToSrt(req *YoutubeSubtitleReq, srtFile string) error {
+func (s *YouTubeSubtitleService) ConvertVttToSrt(ctx context.Context, req *YoutubeSubtitleReq, srtFile string) error {
 	// 检查VttFile字段是否存在
 	vttFilePath := req.VttFile
 	if vttFilePath == "" {
@@ -813,7 +814,7 @@ func (s *YouTubeSubtitleService) ConvertVttToSrt(req *YoutubeSubtitleReq, srtFil
 	}
 
 	// 将VttWord转换为SRT格式
-	return s.writeVttWordsToSrt(vttWords, srtFile, req)
+	return s.writeVttWordsToSrt(ctx, vttWords, srtFile, req)
 }
 
 // findVttFileInDirectory 在指定目录中查找VTT文件
@@ -840,7 +841,7 @@ func (s *YouTubeSubtitleService) findVttFileInDirectory(dir string) (string, err
 }
 
 // writeVttWordsToSrt 将VttWord数组写入SRT文件，支持翻译和时间戳生成
-func (s *YouTubeSubtitleService) writeVttWordsToSrt(vttWords []VttWord, srtFile string, req *YoutubeSubtitleReq) error {
+func (s *YouTubeSubtitleService) writeVttWordsToSrt(ctx context.Context, vttWords []VttWord, srtFile string, req *YoutubeSubtitleReq) error {
 	if len(vttWords) == 0 {
 		return fmt.Errorf("no VTT words to write")
 	}
@@ -888,13 +889,14 @@ func (s *YouTubeSubtitleService) writeVttWordsToSrt(vttWords []VttWord, srtFile
 	semaphore := make(chan struct{}, maxConcurrency)
 
 	// 启动并发翻译
+	translator := s.translator.WithGlossary(req.Glossary)
 	for idx, sentence := range sentences {
 		go func(index int, sent Sentence) {
 			// 获取信号量
 			semaphore <- struct{}{}
 			defer func() { <-semaphore }()
 
-			translatedBlocks, err := s.translator.SplitTextAndTranslate(sent.Text, types.StandardLanguageCode(req.OriginLanguage), types.StandardLanguageCode(req.TargetLanguage))
+			translatedBlocks, err := translator.SplitTextAndTranslate(ctx, sent.Text, types.StandardLanguageCode(req.OriginLanguage), types.StandardLanguageCode(req.TargetLanguage))
 			if err != nil {
 				log.GetLogger().Warn("Translation failed, using original text",
 					zap.Int("index", index),
@@ -1004,6 +1006,10 @@ func (s *YouTubeSubtitleService) writeVttWordsToSrt(vttWords []VttWord, srtFile
 	} else {
 		log.GetLogger().Info("生成短字幕完成", zap.String("文件", shortSrtFile))
 	}
+	if err = saveGlossaryReport(req.TaskBasePath, translator.GlossaryViolations()); err != nil {
+		log.GetLogger().Warn("保存术语表报告失败", zap.Error(err))
+	}
+	recordSrtBlocksTranslationMemory(req.TaskId, types.StandardLanguageCode(req.OriginLanguage), types.StandardLanguageCode(req.TargetLanguage), srtBlocks, translator.GlossaryViolations())
 
 	// 最终更新进度到90%（所有操作完成）
 	if req.TaskPtr != nil {
@@ -1016,6 +1022,19 @@ func (s *YouTubeSubtitleService) writeVttWordsToSrt(vttWords []VttWord, srtFile
 	return nil
 }
 
+// batchTranslateWithGlossary 按任务术语表批量翻译字幕块，并保存重试后仍不符合术语表的句子
+func (s *YouTubeSubtitleService) batchTranslateWithGlossary(ctx context.Context, srtBlocks []*util.SrtBlock, req *YoutubeSubtitleReq) error {
+	translator := s.translator.WithGlossary(req.Glossary)
+	if err := translator.BatchTranslateSrtBlocks(ctx, srtBlocks, req.OriginLanguage, req.TargetLanguage, req.TaskPtr); err != nil {
+		return err
+	}
+	if err := saveGlossaryReport(req.TaskBasePath, translator.GlossaryViolations()); err != nil {
+		log.GetLogger().Warn("保存术语表报告失败", zap.String("taskId", req.TaskId), zap.Error(err))
+	}
+	recordSrtBlocksTranslationMemory(req.TaskId, types.StandardLanguageCode(req.OriginLanguage), types.StandardLanguageCode(req.TargetLanguage), srtBlocks, translator.GlossaryViolations())
+	return nil
+}
+
 // Sentence 表示一个完整的句子及其时间信息
 type Sentence struct {
 	Text      string    // 句子文本
@@ -3238,7 +3257,7 @@ func (s *YouTubeSubtitleService) ProcessBlockLevelVtt(ctx context.Context, req *
 	log.GetLogger().Info("解析SRT完成", zap.Int("字幕块数", len(srtBlocks)))
 
 	// 4. 批量翻译（40%-90%的进度在BatchTranslateSrtBlocks内部更新）
-	err = s.translator.BatchTranslateSrtBlocks(srtBlocks, req.OriginLanguage, req.TargetLanguage, req.TaskPtr)
+	err = s.batchTranslateWithGlossary(ctx, srtBlocks, req)
 	if err != nil {
 		return "", fmt.Errorf("批量翻译失败: %w", err)
 	}
@@ -1,7 +1,214 @@
 package storage
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"krillin-ai/internal/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 )
 
-var SubtitleTasks = sync.Map{} // task id -> SubtitleTask，用于接口查询数据
+var ErrTaskNotFound = errors.New("任务不存在")
+
+// TaskStore 字幕任务仓库，HTTP服务通过它查询和持久化任务。
+// 仓库保存和返回的都是副本，处理中的任务只由处理它的goroutine修改，修改后需再次Save
+type TaskStore interface {
+	Save(task *types.SubtitleTask) error
+	Get(taskId string) (*types.SubtitleTask, error)
+	List() ([]*types.SubtitleTask, error)
+	Delete(taskId string) error
+}
+
+var SubtitleTasks TaskStore = NewMemoryTaskStore() // task id -> SubtitleTask，用于接口查询数据
+
+const (
+	TaskStoreMemory = "memory"
+	TaskStoreFile   = "file"
+)
+
+// InitSubtitleTaskStore 按配置创建任务仓库，并把服务中断前仍在处理中的任务标记为失败
+func InitSubtitleTaskStore(kind, dir string) (int, error) {
+	var store TaskStore
+	switch kind {
+	case TaskStoreMemory:
+		store = NewMemoryTaskStore()
+	case TaskStoreFile, "":
+		fileStore, err := NewFileTaskStore(dir)
+		if err != nil {
+			return 0, err
+		}
+		store = fileStore
+	default:
+		return 0, fmt.Errorf("unsupported task store: %s", kind)
+	}
+	interrupted, err := MarkInterruptedTasks(store)
+	if err != nil {
+		return 0, err
+	}
+	SubtitleTasks = store
+	return interrupted, nil
+}
+
+// MarkInterruptedTasks 将仍处于处理中状态的任务标记为失败，返回被标记的任务数
+func MarkInterruptedTasks(store TaskStore) (int, error) {
+	tasks, err := store.List()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, task := range tasks {
+		if task.Status != types.SubtitleTaskStatusProcessing {
+			continue
+		}
+		task.Status = types.SubtitleTaskStatusFailed
+		task.FailReason = "服务重启，任务已中断，可通过 resume_task_id 断点续跑"
+		if err := store.Save(task); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+type MemoryTaskStore struct {
+	tasks sync.Map
+}
+
+func NewMemoryTaskStore() *MemoryTaskStore {
+	return &MemoryTaskStore{}
+}
+
+func (s *MemoryTaskStore) Save(task *types.SubtitleTask) error {
+	task.UpdateTime = time.Now().Unix()
+	s.tasks.Store(task.TaskId, task.Clone())
+	return nil
+}
+
+func (s *MemoryTaskStore) Get(taskId string) (*types.SubtitleTask, error) {
+	task, ok := s.tasks.Load(taskId)
+	if !ok || task == nil {
+		return nil, ErrTaskNotFound
+	}
+	return task.(*types.SubtitleTask).Clone(), nil
+}
+
+func (s *MemoryTaskStore) List() ([]*types.SubtitleTask, error) {
+	var tasks []*types.SubtitleTask
+	s.tasks.Range(func(_, value any) bool {
+		tasks = append(tasks, value.(*types.SubtitleTask).Clone())
+		return true
+	})
+	sortTasks(tasks)
+	return tasks, nil
+}
+
+func (s *MemoryTaskStore) Delete(taskId string) error {
+	s.tasks.Delete(taskId)
+	return nil
+}
+
+// FileTaskStore 每个任务一个json文件的本地仓库，内存中保留各任务最近一次保存的副本
+type FileTaskStore struct {
+	dir   string
+	mu    sync.RWMutex
+	tasks map[string]*types.SubtitleTask
+}
+
+func NewFileTaskStore(dir string) (*FileTaskStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create task store dir error: %w", err)
+	}
+	s := &FileTaskStore{dir: dir, tasks: make(map[string]*types.SubtitleTask)}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read task store dir error: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read task file %s error: %w", entry.Name(), err)
+		}
+		var task types.SubtitleTask
+		if err := json.Unmarshal(data, &task); err != nil {
+			return nil, fmt.Errorf("decode task file %s error: %w", entry.Name(), err)
+		}
+		if task.TaskId == "" {
+			continue
+		}
+		s.tasks[task.TaskId] = &task
+	}
+	return s, nil
+}
+
+func (s *FileTaskStore) Save(task *types.SubtitleTask) error {
+	task.UpdateTime = time.Now().Unix()
+	// 在调用方的goroutine中复制，之后只读写副本
+	snapshot := task.Clone()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tasks[task.TaskId] = snapshot
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := s.taskPath(task.TaskId)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *FileTaskStore) Get(taskId string) (*types.SubtitleTask, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	task, ok := s.tasks[taskId]
+	if !ok {
+		return nil, ErrTaskNotFound
+	}
+	return task.Clone(), nil
+}
+
+func (s *FileTaskStore) List() ([]*types.SubtitleTask, error) {
+	s.mu.RLock()
+	tasks := make([]*types.SubtitleTask, 0, len(s.tasks))
+	for _, task := range s.tasks {
+		tasks = append(tasks, task.Clone())
+	}
+	s.mu.RUnlock()
+	sortTasks(tasks)
+	return tasks, nil
+}
+
+func (s *FileTaskStore) Delete(taskId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tasks, taskId)
+	if err := os.Remove(s.taskPath(taskId)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *FileTaskStore) taskPath(taskId string) string {
+	name := strings.NewReplacer("/", "_", "\\", "_", "..", "_").Replace(taskId)
+	return filepath.Join(s.dir, name+".json")
+}
+
+// sortTasks 按创建时间倒序排列，最新的任务在前
+func sortTasks(tasks []*types.SubtitleTask) {
+	sort.SliceStable(tasks, func(i, j int) bool {
+		if tasks[i].CreateTime != tasks[j].CreateTime {
+			return tasks[i].CreateTime > tasks[j].CreateTime
+		}
+		return tasks[i].TaskId < tasks[j].TaskId
+	})
+}
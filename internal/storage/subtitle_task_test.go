@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"krillin-ai/internal/types"
+	"testing"
+)
+
+func TestFileTaskStoreReloadsTasks(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileTaskStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileTaskStore error: %v", err)
+	}
+	task := &types.SubtitleTask{
+		TaskId:            "demo_ab12",
+		Status:            types.SubtitleTaskStatusSuccess,
+		ProcessPct:        100,
+		SpeechDownloadUrl: "/api/file/tasks/demo_ab12/output/tts_final_audio.wav",
+		SubtitleInfos:     []types.SubtitleInfo{{Name: "bilingual_srt.srt", DownloadUrl: "/api/file/tasks/demo_ab12/output/bilingual_srt.srt"}},
+	}
+	if err := store.Save(task); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+
+	reloaded, err := NewFileTaskStore(dir)
+	if err != nil {
+		t.Fatalf("reload error: %v", err)
+	}
+	got, err := reloaded.Get("demo_ab12")
+	if err != nil {
+		t.Fatalf("Get error: %v", err)
+	}
+	if got.ProcessPct != 100 || got.SpeechDownloadUrl != task.SpeechDownloadUrl {
+		t.Fatalf("unexpected task: %+v", got)
+	}
+	if len(got.SubtitleInfos) != 1 || got.SubtitleInfos[0].Name != "bilingual_srt.srt" {
+		t.Fatalf("unexpected subtitle infos: %+v", got.SubtitleInfos)
+	}
+	if _, err := reloaded.Get("missing"); err != ErrTaskNotFound {
+		t.Fatalf("expected ErrTaskNotFound, got %v", err)
+	}
+}
+
+func TestInitSubtitleTaskStoreMarksInterruptedTasks(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileTaskStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileTaskStore error: %v", err)
+	}
+	_ = store.Save(&types.SubtitleTask{TaskId: "running", Status: types.SubtitleTaskStatusProcessing, ProcessPct: 40, CreateTime: 2})
+	_ = store.Save(&types.SubtitleTask{TaskId: "done", Status: types.SubtitleTaskStatusSuccess, ProcessPct: 100, CreateTime: 1})
+
+	previous := SubtitleTasks
+	t.Cleanup(func() { SubtitleTasks = previous })
+	interrupted, err := InitSubtitleTaskStore(TaskStoreFile, dir)
+	if err != nil {
+		t.Fatalf("InitSubtitleTaskStore error: %v", err)
+	}
+	if interrupted != 1 {
+		t.Fatalf("expected 1 interrupted task, got %d", interrupted)
+	}
+	tasks, err := SubtitleTasks.List()
+	if err != nil {
+		t.Fatalf("List error: %v", err)
+	}
+	if len(tasks) != 2 || tasks[0].TaskId != "running" {
+		t.Fatalf("expected newest task first, got %+v", tasks)
+	}
+	if tasks[0].Status != types.SubtitleTaskStatusFailed || tasks[0].FailReason == "" {
+		t.Fatalf("expected interrupted task marked failed, got %+v", tasks[0])
+	}
+	if tasks[1].Status != types.SubtitleTaskStatusSuccess {
+		t.Fatalf("finished task should be untouched, got %+v", tasks[1])
+	}
+}
+
+func TestTaskStoresKeepCopies(t *testing.T) {
+	fileStore, err := NewFileTaskStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileTaskStore error: %v", err)
+	}
+	for name, store := range map[string]TaskStore{"memory": NewMemoryTaskStore(), "file": fileStore} {
+		task := &types.SubtitleTask{TaskId: "demo", Status: types.SubtitleTaskStatusProcessing, ProcessPct: 10,
+			SubtitleInfos: []types.SubtitleInfo{{Name: "origin.srt"}}}
+		if err := store.Save(task); err != nil {
+			t.Fatalf("%s: Save error: %v", name, err)
+		}
+		// 处理中的任务在下次Save前的修改不影响仓库中的记录
+		task.ProcessPct = 50
+		task.SubtitleInfos[0].Name = "changed.srt"
+		got, _ := store.Get("demo")
+		if got.ProcessPct != 10 || got.SubtitleInfos[0].Name != "origin.srt" {
+			t.Fatalf("%s: stored task changed without Save: %+v", name, got)
+		}
+		got.Status = types.SubtitleTaskStatusFailed
+		if again, _ := store.Get("demo"); again.Status != types.SubtitleTaskStatusProcessing {
+			t.Fatalf("%s: modifying a returned task changed the store: %+v", name, again)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package tm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"krillin-ai/log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/texttheater/golang-levenshtein/levenshtein"
+	"go.uber.org/zap"
+)
+
+// MinSourceChars 原文的有效字符少于该值时不记录也不查找，过短的片段脱离上下文后译法不固定
+const MinSourceChars = 6
+
+// Entry 翻译记忆库中的一条记录
+type Entry struct {
+	SourceLang string    `json:"source_lang"`
+	TargetLang string    `json:"target_lang"`
+	Source     string    `json:"source"`
+	Target     string    `json:"target"`
+	TaskId     string    `json:"task_id,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Match 查找结果，Exact为false时表示原文相近，Similarity为相似度
+type Match struct {
+	Entry
+	Similarity float64
+	Exact      bool
+}
+
+type pair struct {
+	sourceLang, targetLang string
+}
+
+type key struct {
+	pair
+	source string
+}
+
+// Memory 保存在JSON Lines文件中的翻译记忆库，只追加写入，多个进程可以共用同一个文件，同一原文以最后写入的译文为准
+type Memory struct {
+	path    string
+	mu      sync.Mutex
+	entries map[pair]map[string]Entry // 按语言对索引，键为规范化后的原文
+	count   int
+	offset  int64 // 已读入的文件长度，其他进程追加的记录在下次访问时读入
+}
+
+// Open 打开翻译记忆库，文件不存在时在首次写入时创建
+func Open(path string) (*Memory, error) {
+	m := &Memory{path: path}
+	m.reset()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.refresh(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Path 记忆库文件路径
+func (m *Memory) Path() string {
+	return m.path
+}
+
+// Lookup 查找原文相同的记录，没有时返回相似度不低于fuzzyThreshold的最相近的记录，fuzzyThreshold不小于1时只做精确匹配
+func (m *Memory) Lookup(sourceLang, targetLang, source string, fuzzyThreshold float64) (Match, bool) {
+	if m == nil || !Eligible(source) {
+		return Match{}, false
+	}
+	normalized := Normalize(source)
+	srcRune := []rune(normalized)
+	candidates, exact := m.candidates(pair{sourceLang, targetLang}, normalized, len(srcRune), fuzzyThreshold)
+	if exact != nil {
+		return Match{Entry: *exact, Similarity: 1, Exact: true}, true
+	}
+
+	// 编辑距离在锁外计算，记忆库较大时不会阻塞其他任务的查找和写入
+	var (
+		best  Match
+		found bool
+	)
+	for _, entry := range candidates {
+		candidate := []rune(Normalize(entry.Source))
+		longer := max(len(candidate), len(srcRune))
+		distance := levenshtein.DistanceForStrings(srcRune, candidate, levenshtein.DefaultOptionsWithSub)
+		similarity := 1 - float64(distance)/float64(longer)
+		if similarity < fuzzyThreshold || (found && (similarity < best.Similarity || similarity == best.Similarity && entry.UpdatedAt.Before(best.UpdatedAt))) {
+			continue
+		}
+		best, found = Match{Entry: entry, Similarity: similarity}, true
+	}
+	return best, found
+}
+
+// candidates 返回原文完全相同的记录，没有时复制出同一语言对中长度相近、可能达到fuzzyThreshold的记录
+func (m *Memory) candidates(p pair, normalized string, length int, fuzzyThreshold float64) ([]Entry, *Entry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_ = m.refresh()
+	if entry, ok := m.entries[p][normalized]; ok {
+		return nil, &entry
+	}
+	if fuzzyThreshold >= 1 {
+		return nil, nil
+	}
+	var candidates []Entry
+	for source, entry := range m.entries[p] {
+		n := utf8.RuneCountInString(source)
+		longer, shorter := max(n, length), min(n, length)
+		// 编辑距离不小于长度差，长度相差太多时不必计算
+		if longer == 0 || float64(shorter)/float64(longer) < fuzzyThreshold {
+			continue
+		}
+		candidates = append(candidates, entry)
+	}
+	return candidates, nil
+}
+
+// Add 写入记录，原文过短、译文为空或与已有译文相同的记录会被跳过，返回实际写入的条数
+func (m *Memory) Add(entries ...Entry) (int, error) {
+	if m == nil {
+		return 0, nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.refresh(); err != nil {
+		return 0, err
+	}
+
+	var (
+		buf     bytes.Buffer
+		added   int
+		pending = make(map[key]string)
+	)
+	now := time.Now().UTC()
+	for _, entry := range entries {
+		entry.Source = strings.TrimSpace(entry.Source)
+		entry.Target = strings.TrimSpace(entry.Target)
+		if entry.SourceLang == "" || entry.TargetLang == "" || entry.Target == "" || !Eligible(entry.Source) {
+			continue
+		}
+		k := key{pair{entry.SourceLang, entry.TargetLang}, Normalize(entry.Source)}
+		if target, ok := pending[k]; ok {
+			if target == entry.Target {
+				continue
+			}
+		} else if existing, ok := m.entries[k.pair][k.source]; ok && existing.Target == entry.Target {
+			continue
+		}
+		if entry.UpdatedAt.IsZero() {
+			entry.UpdatedAt = now
+		}
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return 0, err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+		pending[k] = entry.Target
+		added++
+	}
+	if added == 0 {
+		return 0, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(m.path), os.ModePerm); err != nil {
+		return 0, err
+	}
+	file, err := os.OpenFile(m.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, err
+	}
+	// 一次写入全部记录，O_APPEND保证与其他进程的写入不会交错覆盖
+	if _, err = file.Write(buf.Bytes()); err != nil {
+		file.Close()
+		return 0, err
+	}
+	if err = file.Close(); err != nil {
+		return 0, err
+	}
+	return added, m.refresh()
+}
+
+// Entries 按语言和原文排序的全部记录
+func (m *Memory) Entries() []Entry {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_ = m.refresh()
+	entries := make([]Entry, 0, m.count)
+	for _, byPair := range m.entries {
+		for _, entry := range byPair {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.SourceLang != b.SourceLang {
+			return a.SourceLang < b.SourceLang
+		}
+		if a.TargetLang != b.TargetLang {
+			return a.TargetLang < b.TargetLang
+		}
+		return a.Source < b.Source
+	})
+	return entries
+}
+
+// refresh 读入文件中新增的记录，文件被截断或替换时重新读取，调用方需持有锁
+func (m *Memory) refresh() error {
+	info, err := os.Stat(m.path)
+	if errors.Is(err, os.ErrNotExist) {
+		m.reset()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Size() == m.offset {
+		return nil
+	}
+	if info.Size() < m.offset {
+		m.reset()
+	}
+
+	file, err := os.Open(m.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err = file.Seek(m.offset, io.SeekStart); err != nil {
+		return err
+	}
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// 没有换行的最后一行可能是其他进程正在写入的内容，下次再读
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		m.offset += int64(len(line))
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var entry Entry
+		if err = json.Unmarshal(line, &entry); err != nil {
+			// 进程在写入中途退出会留下不完整的行，跳过即可
+			log.GetLogger().Warn("跳过翻译记忆库中损坏的记录", zap.String("path", m.path), zap.Error(err))
+			continue
+		}
+		p, source := pair{entry.SourceLang, entry.TargetLang}, Normalize(entry.Source)
+		byPair, ok := m.entries[p]
+		if !ok {
+			byPair = make(map[string]Entry)
+			m.entries[p] = byPair
+		}
+		existing, ok := byPair[source]
+		if ok && existing.UpdatedAt.After(entry.UpdatedAt) {
+			continue
+		}
+		if !ok {
+			m.count++
+		}
+		byPair[source] = entry
+	}
+}
+
+// reset 清空已读入的记录，调用方需持有锁
+func (m *Memory) reset() {
+	m.entries, m.count, m.offset = make(map[pair]map[string]Entry), 0, 0
+}
+
+// Normalize 查找时使用的原文形式：忽略大小写、多余空白和句末的句号逗号，问号叹号会改变语气所以保留
+func Normalize(text string) string {
+	text = strings.ToLower(strings.Join(strings.Fields(text), " "))
+	return strings.TrimRightFunc(text, func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune(".。,，、;；", r)
+	})
+}
+
+// Eligible 原文是否足够长，可以记录和复用
+func Eligible(source string) bool {
+	count := 0
+	for len(source) > 0 {
+		r, size := utf8.DecodeRuneInString(source)
+		source = source[size:]
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			count++
+		}
+	}
+	return count >= MinSourceChars
+}
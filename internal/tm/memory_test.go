@@ -0,0 +1,104 @@
+package tm
+
+import (
+	"fmt"
+	"krillin-ai/log"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestLookupExactAndFuzzy(t *testing.T) {
+	m, err := Open(filepath.Join(t.TempDir(), "tm.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	added, err := m.Add(
+		Entry{SourceLang: "en", TargetLang: "zh_cn", Source: "Welcome to the channel.", Target: "欢迎来到本频道。"},
+		Entry{SourceLang: "en", TargetLang: "zh_cn", Source: "OK", Target: "好的"},
+		Entry{SourceLang: "en", TargetLang: "zh_cn", Source: "welcome to the channel", Target: "欢迎来到本频道。"},
+	)
+	if err != nil || added != 1 {
+		t.Fatalf("Add() = %d, %v, want 1 entry (short and duplicate skipped)", added, err)
+	}
+
+	match, ok := m.Lookup("en", "zh_cn", "  Welcome to the   CHANNEL ", 0.85)
+	if !ok || !match.Exact || match.Target != "欢迎来到本频道。" {
+		t.Fatalf("exact Lookup() = %+v, %v", match, ok)
+	}
+	match, ok = m.Lookup("en", "zh_cn", "Welcome to my channel", 0.8)
+	if !ok || match.Exact || match.Similarity < 0.8 {
+		t.Fatalf("fuzzy Lookup() = %+v, %v", match, ok)
+	}
+	if _, ok = m.Lookup("en", "zh_cn", "Welcome to my channel", 1); ok {
+		t.Fatal("threshold 1 should only match exactly")
+	}
+	if _, ok = m.Lookup("en", "ja", "Welcome to the channel.", 0.85); ok {
+		t.Fatal("different target language should not match")
+	}
+}
+
+func TestMemorySharedBetweenInstances(t *testing.T) {
+	log.InitLogger()
+	path := filepath.Join(t.TempDir(), "tm.jsonl")
+	first, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = first.Add(Entry{SourceLang: "en", TargetLang: "zh_cn", Source: "Thanks for watching", Target: "感谢观看"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = second.Add(Entry{SourceLang: "en", TargetLang: "zh_cn", Source: "Thanks for watching", Target: "感谢收看"}); err != nil {
+		t.Fatal(err)
+	}
+	// 中途退出留下的损坏记录被跳过
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString("{\"source_lang\":\n")
+	file.Close()
+
+	for _, m := range []*Memory{first, second} {
+		match, ok := m.Lookup("en", "zh_cn", "thanks for watching", 1)
+		if !ok || match.Target != "感谢收看" {
+			t.Fatalf("Lookup() = %+v, %v, want latest translation", match, ok)
+		}
+	}
+	if entries := first.Entries(); len(entries) != 1 {
+		t.Fatalf("Entries() = %+v", entries)
+	}
+}
+
+func TestLookupConcurrentWithAdd(t *testing.T) {
+	m, err := Open(filepath.Join(t.TempDir(), "tm.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, _ = m.Add(Entry{SourceLang: "en", TargetLang: "zh_cn", Source: fmt.Sprintf("Sentence number %d here", i), Target: fmt.Sprintf("第%d句", i)})
+		}()
+		go func() {
+			defer wg.Done()
+			m.Lookup("en", "zh_cn", "Sentence number 9 here", 0.8)
+		}()
+	}
+	wg.Wait()
+
+	match, ok := m.Lookup("en", "zh_cn", "Sentence number 9 here", 0.8)
+	if !ok || match.Exact {
+		t.Fatalf("fuzzy Lookup() = %+v, %v", match, ok)
+	}
+	if _, ok = m.Lookup("en", "ja", "Sentence number 1 here", 0.8); ok {
+		t.Fatal("entries of another language pair should not be candidates")
+	}
+}
@@ -0,0 +1,178 @@
+package tm
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// tmxTimeFormat TMX中creationdate、changedate使用的UTC时间格式
+const tmxTimeFormat = "20060102T150405Z"
+
+// tmxTaskIdProp 记录来源任务的自定义属性，TMX规定自定义属性以x-开头
+const tmxTaskIdProp = "x-task-id"
+
+type tmxDocument struct {
+	XMLName xml.Name  `xml:"tmx"`
+	Version string    `xml:"version,attr"`
+	Header  tmxHeader `xml:"header"`
+	Units   []tmxUnit `xml:"body>tu"`
+}
+
+type tmxHeader struct {
+	CreationTool        string `xml:"creationtool,attr"`
+	CreationToolVersion string `xml:"creationtoolversion,attr"`
+	SegType             string `xml:"segtype,attr"`
+	OTmf                string `xml:"o-tmf,attr"`
+	AdminLang           string `xml:"adminlang,attr"`
+	SrcLang             string `xml:"srclang,attr"`
+	DataType            string `xml:"datatype,attr"`
+}
+
+type tmxUnit struct {
+	SrcLang    string       `xml:"srclang,attr,omitempty"`
+	ChangeDate string       `xml:"changedate,attr,omitempty"`
+	Props      []tmxProp    `xml:"prop"`
+	Variants   []tmxVariant `xml:"tuv"`
+}
+
+type tmxProp struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type tmxVariant struct {
+	Lang string `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	// LegacyLang TMX 1.1及更早版本使用不带命名空间的lang属性，只在导入时读取
+	LegacyLang string `xml:"lang,attr,omitempty"`
+	Seg        string `xml:"seg"`
+}
+
+// WriteTMX 把记录按TMX 1.4格式写出，每条记录对应一个翻译单元
+func WriteTMX(w io.Writer, entries []Entry) error {
+	doc := tmxDocument{
+		Version: "1.4",
+		Header: tmxHeader{
+			CreationTool:        "KrillinAI",
+			CreationToolVersion: "1",
+			SegType:             "sentence",
+			OTmf:                "KrillinAI",
+			AdminLang:           "en",
+			SrcLang:             "*all*",
+			DataType:            "plaintext",
+		},
+	}
+	for _, entry := range entries {
+		unit := tmxUnit{
+			SrcLang: tmxLanguage(entry.SourceLang),
+			Variants: []tmxVariant{
+				{Lang: tmxLanguage(entry.SourceLang), Seg: entry.Source},
+				{Lang: tmxLanguage(entry.TargetLang), Seg: entry.Target},
+			},
+		}
+		if !entry.UpdatedAt.IsZero() {
+			unit.ChangeDate = entry.UpdatedAt.UTC().Format(tmxTimeFormat)
+		}
+		if entry.TaskId != "" {
+			unit.Props = append(unit.Props, tmxProp{Type: tmxTaskIdProp, Value: entry.TaskId})
+		}
+		doc.Units = append(doc.Units, unit)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// ReadTMX 读取TMX文件中的翻译单元。单元的srclang（或文件头的srclang）对应的变体作为原文，其余每个语言的变体各生成一条记录，
+// 两者都未指定时以第一个变体为原文
+func ReadTMX(r io.Reader) ([]Entry, error) {
+	var doc tmxDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("解析TMX失败: %w", err)
+	}
+
+	var entries []Entry
+	for _, unit := range doc.Units {
+		if len(unit.Variants) < 2 {
+			continue
+		}
+		srcLang := unit.SrcLang
+		if srcLang == "" || srcLang == "*all*" {
+			srcLang = doc.Header.SrcLang
+		}
+		source := -1
+		for i, variant := range unit.Variants {
+			if srcLang != "*all*" && languageCode(variant.lang()) == languageCode(srcLang) {
+				source = i
+				break
+			}
+		}
+		if source < 0 {
+			source = 0
+		}
+
+		var updatedAt time.Time
+		if unit.ChangeDate != "" {
+			updatedAt, _ = time.Parse(tmxTimeFormat, unit.ChangeDate)
+		}
+		var taskId string
+		for _, prop := range unit.Props {
+			if prop.Type == tmxTaskIdProp {
+				taskId = strings.TrimSpace(prop.Value)
+			}
+		}
+		for i, variant := range unit.Variants {
+			if i == source {
+				continue
+			}
+			entries = append(entries, Entry{
+				SourceLang: languageCode(unit.Variants[source].lang()),
+				TargetLang: languageCode(variant.lang()),
+				Source:     unit.Variants[source].Seg,
+				Target:     variant.Seg,
+				TaskId:     taskId,
+				UpdatedAt:  updatedAt,
+			})
+		}
+	}
+	return entries, nil
+}
+
+func (v tmxVariant) lang() string {
+	if v.Lang != "" {
+		return v.Lang
+	}
+	return v.LegacyLang
+}
+
+// tmxLanguage 把语言代码转换为TMX使用的语言标签，如zh_cn转为zh-CN
+func tmxLanguage(code string) string {
+	if base, region, ok := strings.Cut(code, "_"); ok {
+		return base + "-" + strings.ToUpper(region)
+	}
+	return code
+}
+
+// languageCode 把TMX中的语言标签转换为本项目的语言代码。中文按简繁区分为zh_cn、zh_tw，其他语言去掉地区，如en-US转为en
+func languageCode(tag string) string {
+	code := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(tag)), "-", "_")
+	base, region, _ := strings.Cut(code, "_")
+	if base != "zh" {
+		return base
+	}
+	switch {
+	case strings.HasPrefix(region, "hant"), region == "tw", region == "hk", region == "mo":
+		return "zh_tw"
+	}
+	return "zh_cn"
+}
@@ -0,0 +1,66 @@
+package tm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTMXRoundTrip(t *testing.T) {
+	updatedAt := time.Date(2026, 3, 1, 8, 30, 0, 0, time.UTC)
+	entries := []Entry{
+		{SourceLang: "en", TargetLang: "zh_cn", Source: "Subscribe & like", Target: "订阅并点赞", TaskId: "task1", UpdatedAt: updatedAt},
+		{SourceLang: "ja", TargetLang: "en", Source: "ありがとうございます", Target: "Thank you very much", UpdatedAt: updatedAt},
+	}
+	var buf bytes.Buffer
+	if err := WriteTMX(&buf, entries); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `xml:lang="zh-CN"`) {
+		t.Fatalf("exported TMX should use BCP 47 tags:\n%s", buf.String())
+	}
+
+	got, err := ReadTMX(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(entries) {
+		t.Fatalf("ReadTMX() = %+v", got)
+	}
+	for i := range entries {
+		if got[i] != entries[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, got[i], entries[i])
+		}
+	}
+}
+
+func TestReadTMXFromOtherTools(t *testing.T) {
+	const doc = `<?xml version="1.0" encoding="UTF-8"?>
+<tmx version="1.4">
+  <header creationtool="Other" segtype="sentence" adminlang="en-US" srclang="en-US" datatype="plaintext" o-tmf="x"/>
+  <body>
+    <tu>
+      <tuv xml:lang="de-DE"><seg>Willkommen zurück</seg></tuv>
+      <tuv xml:lang="en-US"><seg>Welcome back</seg></tuv>
+      <tuv lang="zh-Hant"><seg>歡迎回來</seg></tuv>
+    </tu>
+  </body>
+</tmx>`
+	got, err := ReadTMX(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Entry{
+		{SourceLang: "en", TargetLang: "de", Source: "Welcome back", Target: "Willkommen zurück"},
+		{SourceLang: "en", TargetLang: "zh_tw", Source: "Welcome back", Target: "歡迎回來"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ReadTMX() = %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package types
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ChatRequest 一次大模型调用，未设置的系统提示词和采样参数使用[llm]中对应用途的默认值
+type ChatRequest struct {
+	UseCase     string      // 用途，见config.LlmUseCaseTranslate等，决定默认参数
+	System      string      // 系统提示词
+	Prompt      string      // 用户消息
+	Temperature *float64    // nil表示使用默认值
+	MaxTokens   int         // 0表示使用默认值
+	Stop        []string    // 停止序列，nil表示使用默认值
+	JSONSchema  *JSONSchema // 要求按JSON Schema输出，接口不支持结构化输出（llm.json为false）时只依靠提示词约束
+}
+
+// JSONSchema 结构化输出的格式约束
+type JSONSchema struct {
+	Name   string
+	Schema json.RawMessage
+}
+
+// ChatDefaults 某一用途的默认参数，由调用方从[llm]配置中取出传入，字段与config.LlmUseCase一致，可以直接转换
+type ChatDefaults struct {
+	SystemPrompt string
+	Temperature  float64
+	MaxTokens    int
+	Stop         []string
+}
+
+// WithDefaults 用对应用途的默认值补全请求中未设置的参数
+func (r ChatRequest) WithDefaults(defaults ChatDefaults) ChatRequest {
+	if r.System == "" {
+		r.System = defaults.SystemPrompt
+	}
+	if r.Temperature == nil {
+		temperature := defaults.Temperature
+		r.Temperature = &temperature
+	}
+	if r.MaxTokens == 0 {
+		r.MaxTokens = defaults.MaxTokens
+	}
+	if r.Stop == nil {
+		r.Stop = defaults.Stop
+	}
+	return r
+}
+
+// ChatUsage 一次调用消耗的token数
+type ChatUsage struct {
+	InputTokens  int
+	OutputTokens int
+}
+
+// ChatError 大模型接口返回的错误，Retryable表示稍后重试可能成功（限流、超时、服务端错误）
+type ChatError struct {
+	Provider   string
+	StatusCode int
+	Message    string
+	Retryable  bool
+}
+
+func (e *ChatError) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("%s chat completion failed: %s", e.Provider, e.Message)
+	}
+	return fmt.Sprintf("%s chat completion failed, status %d: %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// NewChatError 按HTTP状态码判断是否可重试，408、429和5xx可重试，其余（鉴权失败、参数错误、模型不存在等）重试无意义
+func NewChatError(provider string, statusCode int, message string) *ChatError {
+	return &ChatError{Provider: provider, StatusCode: statusCode, Message: message, Retryable: retryableStatus(statusCode)}
+}
+
+func retryableStatus(statusCode int) bool {
+	return statusCode == http.StatusRequestTimeout || statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError
+}
+
+// IsRetryableChatError 任务取消和接口明确返回不可重试的错误时不再重试，网络错误等其余情况都可重试
+func IsRetryableChatError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var chatErr *ChatError
+	if errors.As(err, &chatErr) {
+		return chatErr.Retryable
+	}
+	return true
+}
+
+// BatchTranslationSchema 批量翻译的输出格式
+var BatchTranslationSchema = &JSONSchema{
+	Name: "batch_translation",
+	Schema: json.RawMessage(`{"type":"object","properties":{"translations":{"type":"array","items":{"type":"object",` +
+		`"properties":{"index":{"type":"integer"},"text":{"type":"string"}},"required":["index","text"],"additionalProperties":false}}},` +
+		`"required":["translations"],"additionalProperties":false}`),
+}
+
+// ShortSentencesSchema 长句拆分的输出格式
+var ShortSentencesSchema = &JSONSchema{
+	Name: "short_sentences",
+	Schema: json.RawMessage(`{"type":"object","properties":{"short_sentences":{"type":"array","items":{"type":"object",` +
+		`"properties":{"text":{"type":"string"}},"required":["text"],"additionalProperties":false}}},` +
+		`"required":["short_sentences"],"additionalProperties":false}`),
+}
+
+// SentenceAlignSchema 原文和译文对齐拆分的输出格式
+var SentenceAlignSchema = &JSONSchema{
+	Name: "sentence_align",
+	Schema: json.RawMessage(`{"type":"object","properties":{"align":{"type":"array","items":{"type":"object",` +
+		`"properties":{"origin_part":{"type":"string"},"translated_part":{"type":"string"}},"required":["origin_part","translated_part"],"additionalProperties":false}}},` +
+		`"required":["align"],"additionalProperties":false}`),
+}
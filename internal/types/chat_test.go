@@ -0,0 +1,42 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestChatRequestWithDefaults(t *testing.T) {
+	defaults := ChatDefaults{SystemPrompt: "system", Temperature: 0.7, MaxTokens: 1024, Stop: []string{"\n\n"}}
+
+	req := ChatRequest{Prompt: "hello"}.WithDefaults(defaults)
+	if req.System != "system" || req.Temperature == nil || *req.Temperature != 0.7 || req.MaxTokens != 1024 || len(req.Stop) != 1 {
+		t.Fatalf("WithDefaults() = %+v", req)
+	}
+
+	// 调用方指定的参数优先，temperature为0也不会被默认值覆盖
+	zero := 0.0
+	req = ChatRequest{Prompt: "hello", System: "custom", Temperature: &zero, MaxTokens: 10, Stop: []string{}}.WithDefaults(defaults)
+	if req.System != "custom" || *req.Temperature != 0 || req.MaxTokens != 10 || len(req.Stop) != 0 {
+		t.Fatalf("WithDefaults() overrode explicit values: %+v", req)
+	}
+}
+
+func TestIsRetryableChatError(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{NewChatError("openai", 429, "rate limited"), true},
+		{NewChatError("openai", 503, "unavailable"), true},
+		{NewChatError("openai", 401, "invalid api key"), false},
+		{fmt.Errorf("translate: %w", NewChatError("gemini", 400, "bad request")), false},
+		{errors.New("connection reset by peer"), true},
+		{fmt.Errorf("stream: %w", context.Canceled), false},
+	} {
+		if got := IsRetryableChatError(tc.err); got != tc.want {
+			t.Errorf("IsRetryableChatError(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
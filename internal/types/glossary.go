@@ -0,0 +1,145 @@
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// GlossaryTerm 术语表中的一条术语，翻译时原文出现Source的句子译文中必须出现Target
+type GlossaryTerm struct {
+	Source         string `json:"source" toml:"source"`
+	Target         string `json:"target" toml:"target"`
+	CaseSensitive  bool   `json:"case_sensitive" toml:"case_sensitive"`                       // 是否区分大小写，原文和译文都按此规则匹配
+	DoNotTranslate bool   `json:"do_not_translate" toml:"do_not_translate"`                   // 保留原文不翻译，如品牌名
+	TargetLanguage string `json:"target_language,omitempty" toml:"target_language,omitempty"` // 译文所属的目标语言，为空时对所有目标语言生效
+}
+
+// Expected 译文中应出现的写法
+func (t GlossaryTerm) Expected() string {
+	if t.DoNotTranslate || t.Target == "" {
+		return t.Source
+	}
+	return t.Target
+}
+
+// Glossary 翻译术语表，为空时不影响翻译
+type Glossary []GlossaryTerm
+
+// ForLanguage 适用于目标语言lang的术语，多个目标语言的任务翻译每种语言前按此筛选
+func (g Glossary) ForLanguage(lang StandardLanguageCode) Glossary {
+	var terms Glossary
+	for _, term := range g {
+		if term.TargetLanguage == "" || strings.EqualFold(term.TargetLanguage, string(lang)) {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// Matches 原文中出现的术语
+func (g Glossary) Matches(text string) []GlossaryTerm {
+	var matched []GlossaryTerm
+	for _, term := range g {
+		if containsTerm(text, term.Source, term.CaseSensitive) {
+			matched = append(matched, term)
+		}
+	}
+	return matched
+}
+
+// Violations 原文中出现但译文没有按术语表翻译的术语
+func (g Glossary) Violations(originText, translatedText string) []GlossaryTerm {
+	var violations []GlossaryTerm
+	for _, term := range g.Matches(originText) {
+		if !containsTerm(translatedText, term.Expected(), term.CaseSensitive) {
+			violations = append(violations, term)
+		}
+	}
+	return violations
+}
+
+// PromptSection 生成插入翻译提示词的术语要求，只列出texts中出现的术语，没有时返回空字符串
+func (g Glossary) PromptSection(texts ...string) string {
+	seen := make(map[string]bool)
+	var lines []string
+	for _, text := range texts {
+		for _, term := range g.Matches(text) {
+			if seen[term.Source] {
+				continue
+			}
+			seen[term.Source] = true
+			lines = append(lines, glossaryPromptLine(term))
+		}
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return "\n**Glossary (mandatory)**:\nThe following terms MUST be translated exactly as listed, keep the given spelling and letter case:\n" + strings.Join(lines, "\n") + "\n"
+}
+
+// RetryPrompt 译文未遵守术语表时，在原提示词后追加的提醒
+func (g Glossary) RetryPrompt(prompt, failedTranslation string, violations []GlossaryTerm) string {
+	lines := make([]string, 0, len(violations))
+	for _, term := range violations {
+		lines = append(lines, glossaryPromptLine(term))
+	}
+	return prompt + fmt.Sprintf(`
+
+IMPORTANT: The previous translation "%s" did not follow the glossary. Translate again and make sure these terms appear exactly as listed:
+%s
+`, failedTranslation, strings.Join(lines, "\n"))
+}
+
+// Digest 术语表内容摘要，术语表变化后断点续跑不能复用之前的翻译结果
+func (g Glossary) Digest() string {
+	if len(g) == 0 {
+		return ""
+	}
+	hash := sha256.New()
+	for _, term := range g {
+		fmt.Fprintf(hash, "%s\x00%s\x00%t\x00%t", term.Source, term.Target, term.CaseSensitive, term.DoNotTranslate)
+		if term.TargetLanguage != "" {
+			fmt.Fprintf(hash, "\x00%s", term.TargetLanguage)
+		}
+		hash.Write([]byte("\n"))
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+func glossaryPromptLine(term GlossaryTerm) string {
+	if term.DoNotTranslate || term.Target == "" {
+		return fmt.Sprintf(`- "%s" → keep "%s" untranslated`, term.Source, term.Source)
+	}
+	return fmt.Sprintf(`- "%s" → "%s"`, term.Source, term.Target)
+}
+
+// containsTerm 以字母数字开头或结尾的术语按整词匹配，避免"AI"匹配到"said"；中日韩等术语直接按子串匹配
+func containsTerm(text, term string, caseSensitive bool) bool {
+	if term == "" || text == "" {
+		return false
+	}
+	pattern := regexp.QuoteMeta(term)
+	if first, _ := utf8.DecodeRuneInString(term); isAsciiWordRune(first) {
+		pattern = `\b` + pattern
+	}
+	if last, _ := utf8.DecodeLastRuneInString(term); isAsciiWordRune(last) {
+		pattern += `\b`
+	}
+	if !caseSensitive {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return strings.Contains(text, term)
+	}
+	return re.MatchString(text)
+}
+
+func isAsciiWordRune(r rune) bool {
+	return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_')
+}
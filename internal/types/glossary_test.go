@@ -0,0 +1,55 @@
+package types
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGlossaryViolations(t *testing.T) {
+	glossary := Glossary{
+		{Source: "KrillinAI", DoNotTranslate: true, CaseSensitive: true},
+		{Source: "subtitle", Target: "字幕"},
+		{Source: "AI", Target: "人工智能", CaseSensitive: true},
+	}
+	tests := []struct {
+		origin, translated string
+		want               string
+	}{
+		{"KrillinAI makes subtitles easy", "KrillinAI让字幕变得简单", ""},
+		{"KrillinAI makes Subtitle files", "krillinai生成字幕文件", "KrillinAI"},
+		{"Add a subtitle track", "添加一条音轨", "subtitle"},
+		// 整词匹配，said中的ai不算术语
+		{"He said hello", "他打了招呼", ""},
+		{"AI is everywhere", "AI无处不在", "AI"},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, term := range glossary.Violations(tt.origin, tt.translated) {
+			got = append(got, term.Source)
+		}
+		if strings.Join(got, ",") != tt.want {
+			t.Errorf("Violations(%q, %q) = %v, want %q", tt.origin, tt.translated, got, tt.want)
+		}
+	}
+}
+
+func TestGlossaryPromptSectionListsMatchedTermsOnly(t *testing.T) {
+	glossary := Glossary{
+		{Source: "KrillinAI", DoNotTranslate: true},
+		{Source: "字幕", Target: "subtitle"},
+		{Source: "dubbing", Target: "配音"},
+	}
+	if section := glossary.PromptSection("nothing relevant"); section != "" {
+		t.Fatalf("PromptSection() = %q, want empty", section)
+	}
+	section := glossary.PromptSection("KrillinAI 生成字幕", "KrillinAI again")
+	if !strings.Contains(section, `"KrillinAI" → keep "KrillinAI" untranslated`) || !strings.Contains(section, `"字幕" → "subtitle"`) {
+		t.Fatalf("PromptSection() = %q", section)
+	}
+	if strings.Contains(section, "dubbing") || strings.Count(section, "KrillinAI") != 2 {
+		t.Fatalf("PromptSection() = %q, want each matched term once", section)
+	}
+	if Glossary(nil).Digest() != "" || glossary.Digest() == glossary[:1].Digest() {
+		t.Fatal("Digest() should change with the terms")
+	}
+}
@@ -1,13 +1,15 @@
 package types
 
+import "context"
+
 type ChatCompleter interface {
-	ChatCompletion(query string) (string, error)
+	ChatCompletion(ctx context.Context, req ChatRequest) (string, error)
 }
 
 type Transcriber interface {
-	Transcription(audioFile, language, wordDir string) (*TranscriptionData, error)
+	Transcription(ctx context.Context, audioFile, language, wordDir string, hint TranscriptionHint) (*TranscriptionData, error)
 }
 
 type Ttser interface {
-	Text2Speech(text string, voice string, outputFile string) error
+	Text2Speech(ctx context.Context, text string, voice string, outputFile string) error
 }
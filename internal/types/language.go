@@ -1,7 +1,15 @@
 package types
 
+import (
+	"slices"
+	"strings"
+)
+
 type StandardLanguageCode string
 
+// LanguageNameAuto 源语言为auto或未填写时，转录前先识别音频的语言
+const LanguageNameAuto StandardLanguageCode = "auto"
+
 const (
 	// 第一批
 	LanguageNameSimplifiedChinese  StandardLanguageCode = "zh_cn"
@@ -221,3 +229,18 @@ func GetStandardLanguageName(code StandardLanguageCode) string {
 	}
 	return "未知"
 }
+
+// ParseLanguageList 解析逗号分隔的语言代码列表，去掉空白和重复的语言，保持原有顺序
+func ParseLanguageList(s string) []StandardLanguageCode {
+	var codes []StandardLanguageCode
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == '，'
+	}) {
+		code := StandardLanguageCode(strings.TrimSpace(part))
+		if code == "" || slices.Contains(codes, code) {
+			continue
+		}
+		codes = append(codes, code)
+	}
+	return codes
+}
@@ -0,0 +1,24 @@
+package types
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseLanguageList(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []StandardLanguageCode
+	}{
+		{"zh_cn", []StandardLanguageCode{"zh_cn"}},
+		{" zh_cn, ja ,ko", []StandardLanguageCode{"zh_cn", "ja", "ko"}},
+		// 中文逗号同样可以分隔，重复的语言只保留第一次出现的位置
+		{"ja，zh_cn,ja,,", []StandardLanguageCode{"ja", "zh_cn"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := ParseLanguageList(tt.in); !slices.Equal(got, tt.want) {
+			t.Fatalf("ParseLanguageList(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
@@ -1,6 +1,11 @@
 package types
 
-import subtitlestyle "krillin-ai/internal/subtitle_style"
+import (
+	subtitlestyle "krillin-ai/internal/subtitle_style"
+	"maps"
+	"slices"
+	"strings"
+)
 
 // var SplitTextPrompt = `你是一个英语处理专家，擅长翻译成%s和处理英文文本，根据句意和标点对句子进行拆分。
 
@@ -222,7 +227,7 @@ Use "Previous Sentences" to understand context and maintain coherence.
 7. Keep the original meaning but express it smoothly and naturally in the target language
 8. If sentence is incomplete/fragmentary, keep it that way but translate fluently
 9. IGNORE the "Next Sentences" - they are for reference only
-
+%s
 **Context**:
 [Previous Sentences]
 %s
@@ -279,6 +284,7 @@ const (
 	SubtitleTaskStatusProcessing uint8 = iota + 1
 	SubtitleTaskStatusSuccess
 	SubtitleTaskStatusFailed
+	SubtitleTaskStatusCancelled
 )
 
 const (
@@ -304,10 +310,19 @@ const (
 	SubtitleTaskAudioTranscriptionDataPersistenceFileNamePattern = "audio_transcription_data_%d.json"
 	SubtitleTaskTranslationRawDataPersistenceFileNamePattern     = "audio_translation_raw_data_%d.json"
 	SubtitleTaskTranslationDataPersistenceFileNamePattern        = "translation_data_%d.json"
+	SubtitleTaskSpeakerRegistryFileName                          = "speakers.json"
+	SubtitleTaskLanguageProbeFileNamePattern                     = "language_probe_%d.mp3"
+	SubtitleTaskHallucinationReportFileNamePattern               = "hallucination_report_%d.json"
+	SubtitleTaskHallucinationReportFileName                      = "hallucination_report.json"
+	SubtitleTaskReviewFileNamePattern                            = "review_%d.json"
+	SubtitleTaskReviewJsonFileName                               = "review.json"
+	SubtitleTaskReviewHtmlFileName                               = "review.html"
+	SubtitleTaskGlossaryReportFileName                           = "glossary_report.json"
 	SubtitleTaskTransferredVerticalVideoFileName                 = "transferred_vertical_video.mp4"
 	SubtitleTaskHorizontalEmbedVideoFileName                     = "horizontal_embed.mp4"
 	SubtitleTaskVerticalEmbedVideoFileName                       = "vertical_embed.mp4"
 	SubtitleTaskVideoWithTtsFileName                             = "video_with_tts.mp4"
+	SubtitleTaskLanguagesDirName                                 = "languages" // 多目标语言任务中，第二个及之后的目标语言的产物放在 languages/<语言代码> 下
 )
 
 const (
@@ -339,6 +354,10 @@ type SubtitleTaskStepParam struct {
 	VoiceCloneAudioUrl          string // 音色克隆的源音频oss地址
 	ReplaceWordsMap             map[string]string
 	OriginLanguage              StandardLanguageCode // 视频源语言
+	OriginLanguageDetected      bool                 // 源语言是否由auto自动识别得到
+	TranscriptionHint           TranscriptionHint    // 转录时传给模型的热词和背景说明
+	NoTranscriptionCache        bool                 // 不使用跨任务共享的转录缓存，重新转录并刷新缓存
+	Glossary                    Glossary             // 翻译术语表，已合并项目配置中的术语
 	TargetLanguage              StandardLanguageCode // 用户希望的目标翻译语言
 	UserUILanguage              StandardLanguageCode // 用户的使用语言
 	BilingualSrtFilePath        string
@@ -353,6 +372,7 @@ type SubtitleTaskStepParam struct {
 	MaxWordOneLine              int                     // 字幕一行最多显示多少个字
 	VideoWithTtsFilePath        string                  // 替换源视频的音频为tts结果后的视频路径
 	VttSwitch                   bool                    // 是否使用VTT格式字幕文件
+	Resume                      bool                    // 断点续跑，复用任务目录中与当前设置一致的分段转录和翻译结果
 	SubtitleStyle               *subtitlestyle.StyleSet // CLI/Agent 传入的字幕样式；nil 时使用默认样式
 	RenderWidth                 int                     // 当前待烧录字幕视频宽度，用于按字号估算自动换行
 	RenderHeight                int                     // 当前待烧录字幕视频高度，用于按字号估算自动换行
@@ -376,43 +396,99 @@ type SubtitleInfo struct {
 	Uid         uint32 `json:"uid" gorm:"column:uid"`                                // 用户id
 	Name        string `json:"name" gorm:"column:name"`                              // 字幕名称
 	DownloadUrl string `json:"download_url" gorm:"column:download_url"`              // 字幕地址
+	Language    string `json:"language" gorm:"column:language"`                      // 所属的目标语言，多目标语言任务按此分组
 	CreateTime  int64  `json:"create_time" gorm:"column:create_time;autoCreateTime"` // 创建时间
 }
 
 type SubtitleTask struct {
-	Id                    uint64         `json:"id" gorm:"column:id"`                                         // 自增id
-	TaskId                string         `json:"task_id" gorm:"column:task_id"`                               // 任务id
-	Title                 string         `json:"title" gorm:"column:title"`                                   // 标题
-	Description           string         `json:"description" gorm:"column:description"`                       // 描述
-	TranslatedTitle       string         `json:"translated_title" gorm:"column:translated_title"`             // 翻译后的标题
-	TranslatedDescription string         `json:"translated_description" gorm:"column:translated_description"` // 翻译后的描述
-	OriginLanguage        string         `json:"origin_language" gorm:"column:origin_language"`               // 视频原语言
-	TargetLanguage        string         `json:"target_language" gorm:"column:target_language"`               // 翻译任务的目标语言
-	VideoSrc              string         `json:"video_src" gorm:"column:video_src"`                           // 视频地址
-	Status                uint8          `json:"status" gorm:"column:status"`                                 // 1-处理中,2-成功,3-失败
-	LastSuccessStepNum    uint8          `json:"last_success_step_num" gorm:"column:last_success_step_num"`   // 最后成功的子任务序号，用于任务恢复
-	FailReason            string         `json:"fail_reason" gorm:"column:fail_reason"`                       // 失败原因
-	ProcessPct            uint8          `json:"process_percent" gorm:"column:process_percent"`               // 处理进度
-	Duration              uint32         `json:"duration" gorm:"column:duration"`                             // 视频时长
-	SrtNum                int            `json:"srt_num" gorm:"column:srt_num"`                               // 字幕数量
-	SubtitleInfos         []SubtitleInfo `gorm:"foreignKey:TaskId;references:TaskId"`
-	Cover                 string         `json:"cover" gorm:"column:cover"`                             // 封面
-	SpeechDownloadUrl     string         `json:"speech_download_url" gorm:"column:speech_download_url"` // 语音文件下载地址
-	CreateTime            int64          `json:"create_time" gorm:"column:create_time;autoCreateTime"`  // 创建时间
-	UpdateTime            int64          `json:"update_time" gorm:"column:update_time;autoUpdateTime"`  // 更新时间
+	Id                    uint64            `json:"id" gorm:"column:id"`                                         // 自增id
+	TaskId                string            `json:"task_id" gorm:"column:task_id"`                               // 任务id
+	Title                 string            `json:"title" gorm:"column:title"`                                   // 标题
+	Description           string            `json:"description" gorm:"column:description"`                       // 描述
+	TranslatedTitle       string            `json:"translated_title" gorm:"column:translated_title"`             // 翻译后的标题
+	TranslatedDescription string            `json:"translated_description" gorm:"column:translated_description"` // 翻译后的描述
+	OriginLanguage        string            `json:"origin_language" gorm:"column:origin_language"`               // 视频原语言
+	TargetLanguage        string            `json:"target_language" gorm:"column:target_language"`               // 翻译任务的目标语言，多个时以逗号分隔
+	VideoSrc              string            `json:"video_src" gorm:"column:video_src"`                           // 视频地址
+	Status                uint8             `json:"status" gorm:"column:status"`                                 // 1-处理中,2-成功,3-失败
+	LastSuccessStepNum    uint8             `json:"last_success_step_num" gorm:"column:last_success_step_num"`   // 最后成功的子任务序号，用于任务恢复
+	FailReason            string            `json:"fail_reason" gorm:"column:fail_reason"`                       // 失败原因
+	ProcessPct            uint8             `json:"process_percent" gorm:"column:process_percent"`               // 处理进度
+	Duration              uint32            `json:"duration" gorm:"column:duration"`                             // 视频时长
+	SrtNum                int               `json:"srt_num" gorm:"column:srt_num"`                               // 字幕数量
+	SubtitleInfos         []SubtitleInfo    `gorm:"foreignKey:TaskId;references:TaskId"`
+	Cover                 string            `json:"cover" gorm:"column:cover"`                                         // 封面
+	SpeechDownloadUrl     string            `json:"speech_download_url" gorm:"column:speech_download_url"`             // 语音文件下载地址
+	SpeechDownloadUrls    map[string]string `json:"speech_download_urls" gorm:"column:speech_urls;serializer:json"`    // 各目标语言的语音文件下载地址
+	CreateTime            int64             `json:"create_time" gorm:"column:create_time;autoCreateTime"`              // 创建时间
+	UpdateTime            int64             `json:"update_time" gorm:"column:update_time;autoUpdateTime"`              // 更新时间
+	CallbackUrl           string            `json:"callback_url" gorm:"column:callback_url"`                           // 任务结束后回调的地址
+	CallbackSecret        string            `json:"-" gorm:"-"`                                                        // 回调签名密钥，只保存在内存中
+	CallbackAttempts      []CallbackAttempt `json:"callback_attempts" gorm:"column:callback_attempts;serializer:json"` // 回调投递记录
+}
+
+// Clone 复制任务，切片和map不与原任务共用
+func (t *SubtitleTask) Clone() *SubtitleTask {
+	task := *t
+	task.SubtitleInfos = slices.Clone(t.SubtitleInfos)
+	task.SpeechDownloadUrls = maps.Clone(t.SpeechDownloadUrls)
+	task.CallbackAttempts = slices.Clone(t.CallbackAttempts)
+	return &task
+}
+
+// CallbackAttempt 一次回调投递的结果
+type CallbackAttempt struct {
+	Attempt    int    `json:"attempt"`
+	Time       int64  `json:"time"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Success    bool   `json:"success"`
 }
 
 type Word struct {
-	Num   int
-	Text  string
-	Start float64
-	End   float64
+	Num        int
+	Text       string
+	Start      float64
+	End        float64
+	Speaker    string  `json:",omitempty"` // 说话人标签，如S1，开启说话人识别时才有
+	Flag       string  `json:",omitempty"` // 被判定为幻觉内容的原因，仅在hallucination.action为mark时保留
+	Confidence float64 `json:",omitempty"` // 转录模型给出的置信度(0-1)，0表示转录服务未提供
 }
 
 type TranscriptionData struct {
 	Language string
 	Text     string
 	Words    []Word
+	Provider string `json:",omitempty"` // 产生该结果的转录服务，配置了多个转录服务时用于追溯回退情况
+}
+
+// maxTranscriptionPromptRunes whisper只参考提示词最后224个token，过长时保留末尾的词表部分
+const maxTranscriptionPromptRunes = 600
+
+// TranscriptionHint 转录提示，帮助模型正确识别产品名、人名和专业术语
+type TranscriptionHint struct {
+	Vocabulary []string // 专有名词、术语等热词
+	Context    string   // 自由文本的背景说明，如视频标题
+}
+
+func (h TranscriptionHint) IsEmpty() bool {
+	return len(h.Vocabulary) == 0 && strings.TrimSpace(h.Context) == ""
+}
+
+// Prompt 拼接为whisper类模型的initial prompt，背景说明在前，热词在后
+func (h TranscriptionHint) Prompt() string {
+	parts := make([]string, 0, 2)
+	if background := strings.TrimSpace(h.Context); background != "" {
+		parts = append(parts, background)
+	}
+	if len(h.Vocabulary) > 0 {
+		parts = append(parts, strings.Join(h.Vocabulary, ", ")+".")
+	}
+	prompt := []rune(strings.Join(parts, " "))
+	if len(prompt) > maxTranscriptionPromptRunes {
+		prompt = prompt[len(prompt)-maxTranscriptionPromptRunes:]
+	}
+	return strings.TrimSpace(string(prompt))
 }
 
 type SrtBlock struct {
@@ -0,0 +1,20 @@
+package types
+
+import "fmt"
+
+// TranscribeError 转录接口返回的错误，Retryable表示换服务或稍后重试可能成功（限流、超时、服务端错误）
+type TranscribeError struct {
+	Provider   string
+	StatusCode int
+	Message    string
+	Retryable  bool
+}
+
+func (e *TranscribeError) Error() string {
+	return fmt.Sprintf("%s transcription failed, status %d: %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// NewTranscribeError 与NewChatError相同，按HTTP状态码判断是否可重试，鉴权失败、参数错误等请求本身有问题的错误不可重试
+func NewTranscribeError(provider string, statusCode int, message string) *TranscribeError {
+	return &TranscribeError{Provider: provider, StatusCode: statusCode, Message: message, Retryable: retryableStatus(statusCode)}
+}
@@ -55,3 +55,21 @@ type WhispercppOutput struct {
 		} `json:"tokens"`
 	} `json:"transcription"`
 }
+
+// WhispercppServerOutput whisper-server的/inference接口在response_format=verbose_json时的返回
+type WhispercppServerOutput struct {
+	Language string `json:"language"`
+	Text     string `json:"text"`
+	Segments []struct {
+		Text  string  `json:"text"`
+		Start float64 `json:"start"`
+		End   float64 `json:"end"`
+		Words []struct {
+			Word        string  `json:"word"`
+			Start       float64 `json:"start"`
+			End         float64 `json:"end"`
+			Probability float64 `json:"probability"`
+		} `json:"words"`
+	} `json:"segments"`
+	Error string `json:"error"`
+}
@@ -1,16 +1,23 @@
 package types
 
 type WhisperXOutput struct {
-	Language string `json:"language"`
-	Segments []struct {
-		Start float64 `json:"start"`
-		End   float64 `json:"end"`
-		Words []struct {
-			Start       float64 `json:"start"`
-			End         float64 `json:"end"`
-			Word        string  `json:"word"`
-			Probability float64 `json:"score"`
-		} `json:"words"`
-		Text string `json:"text"`
-	} `json:"segments"`
+	Language string            `json:"language"`
+	Segments []WhisperXSegment `json:"segments"`
+}
+
+type WhisperXSegment struct {
+	Start   float64        `json:"start"`
+	End     float64        `json:"end"`
+	Words   []WhisperXWord `json:"words"`
+	Text    string         `json:"text"`
+	Speaker string         `json:"speaker"` // 开启--diarize时才有，如SPEAKER_00
+}
+
+// WhisperXWord 对齐模型无法处理的词（如数字）没有时间戳，Start/End为nil
+type WhisperXWord struct {
+	Start       *float64 `json:"start"`
+	End         *float64 `json:"end"`
+	Word        string   `json:"word"`
+	Probability float64  `json:"score"`
+	Speaker     string   `json:"speaker"`
 }
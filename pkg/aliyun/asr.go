@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"github.com/aliyun/alibaba-cloud-sdk-go/sdk"
 	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/auth/credentials"
+	sdkerrors "github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
 	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
 	"go.uber.org/zap"
 	"krillin-ai/config"
@@ -15,6 +16,7 @@ import (
 	"krillin-ai/pkg/util"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -29,6 +31,8 @@ type AsrClient struct {
 	pollInterval time.Duration
 	maxPollTime  time.Duration
 	ossClient    *OssClient
+	vocabMu      sync.Mutex
+	vocabs       map[string]*vocabulary // 正在使用的词表，按热词内容共享
 }
 
 func NewAsrClient(accessKeyID, accessKeySecret, appKey string, enableWords bool) (*AsrClient, error) {
@@ -59,6 +63,7 @@ func NewAsrClient(accessKeyID, accessKeySecret, appKey string, enableWords bool)
 		pollInterval: pollInterval,
 		maxPollTime:  maxPollTime,
 		ossClient:    NewOssClient(config.Conf.Transcribe.Aliyun.Oss.AccessKeyId, config.Conf.Transcribe.Aliyun.Oss.AccessKeySecret, config.Conf.Transcribe.Aliyun.Oss.Bucket),
+		vocabs:       make(map[string]*vocabulary),
 	}, nil
 }
 
@@ -91,7 +96,7 @@ type TaskResponse struct {
 	maxPollTime  time.Duration
 }
 
-func (c *AsrClient) Transcription(audioFile, language, workDir string) (*types.TranscriptionData, error) {
+func (c *AsrClient) Transcription(ctx context.Context, audioFile, language, workDir string, hint types.TranscriptionHint) (*types.TranscriptionData, error) {
 	const (
 		postRequestAction = "SubmitTask"
 		getRequestAction  = "GetTaskResult"
@@ -103,7 +108,7 @@ func (c *AsrClient) Transcription(audioFile, language, workDir string) (*types.T
 	)
 
 	// 处理音频
-	processedAudioFile, err := util.ProcessAudio(audioFile)
+	processedAudioFile, err := util.ProcessAudio(ctx, audioFile)
 	if err != nil {
 		log.GetLogger().Error("处理音频失败", zap.Error(err), zap.String("audio file", audioFile))
 		return nil, err
@@ -111,7 +116,7 @@ func (c *AsrClient) Transcription(audioFile, language, workDir string) (*types.T
 
 	// 上传音频文件
 	fileKey := util.GenerateRandStringWithUpperLowerNum(5) + filepath.Ext(audioFile)
-	err = c.ossClient.UploadFile(context.Background(), fileKey, processedAudioFile, c.ossClient.Bucket)
+	err = c.ossClient.UploadFile(ctx, fileKey, processedAudioFile, c.ossClient.Bucket)
 	if err != nil {
 		log.GetLogger().Error("StartVideoSubtitleTask UploadFile err", zap.Any("audio file", audioFile), zap.Error(err))
 		return nil, errors.New("上传声音克隆源失败")
@@ -126,6 +131,13 @@ func (c *AsrClient) Transcription(audioFile, language, workDir string) (*types.T
 		"version":      "4.0",
 		"enable_words": fmt.Sprintf("%v", c.enableWords),
 	}
+	// 录音文件识别没有initial prompt，只能使用热词
+	if vocabId, release, err := c.acquireVocabulary(hint.Vocabulary); err != nil {
+		log.GetLogger().Warn("创建阿里云热词表失败，不使用热词继续识别", zap.Error(err))
+	} else if vocabId != "" {
+		defer release()
+		taskParams["vocabulary_id"] = vocabId
+	}
 
 	task, err := json.Marshal(taskParams)
 	if err != nil {
@@ -142,11 +154,15 @@ func (c *AsrClient) Transcription(audioFile, language, workDir string) (*types.T
 
 	postResponse, err := c.client.ProcessCommonRequest(postRequest)
 	if err != nil {
+		var serverErr *sdkerrors.ServerError
+		if errors.As(err, &serverErr) {
+			return nil, types.NewTranscribeError("aliyun", serverErr.HttpStatus(), serverErr.Message())
+		}
 		return nil, fmt.Errorf("failed to submit task: %v", err)
 	}
 
 	if postResponse.GetHttpStatus() != 200 {
-		return nil, fmt.Errorf("recognition request failed, HTTP error code: %d", postResponse.GetHttpStatus())
+		return nil, types.NewTranscribeError("aliyun", postResponse.GetHttpStatus(), "recognition request failed")
 	}
 
 	var postResult TaskResponse
@@ -197,7 +213,11 @@ func (c *AsrClient) Transcription(audioFile, language, workDir string) (*types.T
 
 		switch getResult.StatusText {
 		case statusRunning, statusQueueing:
-			time.Sleep(c.pollInterval)
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(c.pollInterval):
+			}
 			continue
 		case statusSuccess:
 			if getResult.Result == nil || len(getResult.Result.Sentences) == 0 {
@@ -217,10 +237,11 @@ func (c *AsrClient) Transcription(audioFile, language, workDir string) (*types.T
 			if c.enableWords && getResult.Result.Words != nil {
 				for i, v := range getResult.Result.Words {
 					words = append(words, types.Word{
-						Num:   i,
-						Text:  strings.TrimSpace(v.Word), // 阿里云这边的word后面会有空格
-						Start: v.BeginTime / 1000,
-						End:   v.EndTime / 1000,
+						Num:        i,
+						Text:       strings.TrimSpace(v.Word), // 阿里云这边的word后面会有空格
+						Start:      v.BeginTime / 1000,
+						End:        v.EndTime / 1000,
+						Confidence: v.Confidence,
 					})
 				}
 			}
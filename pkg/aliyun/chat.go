@@ -4,7 +4,10 @@ import (
 	"context"
 	goopenai "github.com/sashabaranov/go-openai"
 	"go.uber.org/zap"
+	"krillin-ai/config"
+	"krillin-ai/internal/types"
 	"krillin-ai/log"
+	"math"
 )
 
 type ChatClient struct {
@@ -19,22 +22,25 @@ func NewChatClient(apiKey string) *ChatClient {
 	}
 }
 
-func (c ChatClient) ChatCompletion(query string) (string, error) {
+func (c ChatClient) ChatCompletion(ctx context.Context, chatReq types.ChatRequest) (string, error) {
+	chatReq = chatReq.WithDefaults(types.ChatDefaults(config.Conf.Llm.UseCase(chatReq.UseCase)))
+	var messages []goopenai.ChatCompletionMessage
+	if chatReq.System != "" {
+		messages = append(messages, goopenai.ChatCompletionMessage{Role: goopenai.ChatMessageRoleSystem, Content: chatReq.System})
+	}
+	messages = append(messages, goopenai.ChatCompletionMessage{Role: goopenai.ChatMessageRoleUser, Content: chatReq.Prompt})
 	req := goopenai.ChatCompletionRequest{
-		Model: "qwen-plus",
-		Messages: []goopenai.ChatCompletionMessage{
-			{
-				Role:    goopenai.ChatMessageRoleSystem,
-				Content: "You are an assistant that helps with subtitle translation.",
-			},
-			{
-				Role:    goopenai.ChatMessageRoleUser,
-				Content: query,
-			},
-		},
+		Model:     "qwen-plus",
+		Messages:  messages,
+		MaxTokens: chatReq.MaxTokens,
+		Stop:      chatReq.Stop,
+	}
+	if chatReq.Temperature != nil {
+		// 值为0时会被省略，用最小正数代替
+		req.Temperature = float32(math.Max(*chatReq.Temperature, math.SmallestNonzeroFloat32))
 	}
 
-	resp, err := c.CreateChatCompletion(context.Background(), req)
+	resp, err := c.CreateChatCompletion(ctx, req)
 	if err != nil {
 		log.GetLogger().Error("aliyun openai create chat completion failed", zap.Error(err))
 		return "", err
@@ -1,6 +1,7 @@
 package aliyun
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/gorilla/websocket"
@@ -55,7 +56,7 @@ func NewTtsClient(accessKeyId, accessKeySecret, appkey string) *TtsClient {
 	}
 }
 
-func (c *TtsClient) Text2Speech(text, voice, outputFile string) error {
+func (c *TtsClient) Text2Speech(ctx context.Context, text, voice, outputFile string) error {
 	file, err := os.OpenFile(outputFile, os.O_CREATE|os.O_WRONLY, 0666)
 	if err != nil {
 		return fmt.Errorf("failed to create file: %w", err)
@@ -70,7 +71,7 @@ func (c *TtsClient) Text2Speech(text, voice, outputFile string) error {
 		dialer.Proxy = http.ProxyURL(config.Conf.App.ParsedProxy)
 	}
 	dialer.HandshakeTimeout = 10 * time.Second
-	conn, _, err = dialer.Dial(fullURL, nil)
+	conn, _, err = dialer.DialContext(ctx, fullURL, nil)
 	if err != nil {
 		return err
 	}
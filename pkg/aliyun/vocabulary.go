@@ -0,0 +1,164 @@
+package aliyun
+
+import (
+	"encoding/json"
+	"fmt"
+	"krillin-ai/log"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
+	"go.uber.org/zap"
+)
+
+const (
+	vocabDomain     = "nls-meta.cn-shanghai.aliyuncs.com"
+	vocabApiVersion = "2019-02-28"
+	vocabProduct    = "nls-cloud-meta"
+	vocabWeight     = 4   // 热词权重，取值1-5，越大越容易被识别为热词
+	maxVocabWords   = 500 // 录音文件识别单个词表的热词数上限
+	maxHotWordUnits = 10  // 单个热词最多10个汉字或10个英文单词
+)
+
+type createVocabResponse struct {
+	VocabId   string `json:"VocabId"`
+	RequestId string `json:"RequestId"`
+}
+
+// vocabulary 阿里云上的一个热词表，refs为正在使用它的识别任务数
+type vocabulary struct {
+	id   string
+	refs int
+}
+
+// acquireVocabulary 录音文件识别不能直接传热词，需要先创建词表再通过vocabulary_id引用。
+// 相同的热词在使用期间共享一个词表，最后一个使用者调用release后删除云端词表，避免词表数量达到账号上限
+func (c *AsrClient) acquireVocabulary(words []string) (string, func(), error) {
+	words = hotWords(words)
+	if len(words) == 0 {
+		return "", func() {}, nil
+	}
+	key := strings.Join(words, "\n")
+	c.vocabMu.Lock()
+	defer c.vocabMu.Unlock()
+	vocab, ok := c.vocabs[key]
+	if !ok {
+		id, err := c.createVocabulary(words)
+		if err != nil {
+			return "", nil, err
+		}
+		vocab = &vocabulary{id: id}
+		c.vocabs[key] = vocab
+	}
+	vocab.refs++
+	return vocab.id, func() { c.releaseVocabulary(key) }, nil
+}
+
+func (c *AsrClient) releaseVocabulary(key string) {
+	c.vocabMu.Lock()
+	vocab, ok := c.vocabs[key]
+	if !ok {
+		c.vocabMu.Unlock()
+		return
+	}
+	vocab.refs--
+	if vocab.refs > 0 {
+		c.vocabMu.Unlock()
+		return
+	}
+	delete(c.vocabs, key)
+	c.vocabMu.Unlock()
+
+	if err := c.deleteVocabulary(vocab.id); err != nil {
+		log.GetLogger().Warn("删除阿里云热词表失败，请在控制台手动删除", zap.String("vocabId", vocab.id), zap.Error(err))
+	}
+}
+
+func (c *AsrClient) createVocabulary(words []string) (string, error) {
+	weights := make(map[string]int, len(words))
+	for _, word := range words {
+		weights[word] = vocabWeight
+	}
+	wordWeights, err := json.Marshal(weights)
+	if err != nil {
+		return "", err
+	}
+	request := requests.NewCommonRequest()
+	request.Domain = vocabDomain
+	request.Version = vocabApiVersion
+	request.Product = vocabProduct
+	request.ApiName = "CreateAsrVocab"
+	request.Method = "POST"
+	request.FormParams["Name"] = fmt.Sprintf("krillinai_%d", time.Now().UnixNano())
+	request.FormParams["WordWeights"] = string(wordWeights)
+
+	response, err := c.client.ProcessCommonRequest(request)
+	if err != nil {
+		return "", fmt.Errorf("failed to create asr vocab: %v", err)
+	}
+	var result createVocabResponse
+	if err = json.Unmarshal([]byte(response.GetHttpContentString()), &result); err != nil {
+		return "", fmt.Errorf("failed to parse create asr vocab response: %v", err)
+	}
+	if result.VocabId == "" {
+		return "", fmt.Errorf("empty vocab id in response: %s", response.GetHttpContentString())
+	}
+	log.GetLogger().Info("阿里云语音识别热词表创建成功", zap.String("vocabId", result.VocabId), zap.Int("words", len(words)))
+	return result.VocabId, nil
+}
+
+func (c *AsrClient) deleteVocabulary(id string) error {
+	request := requests.NewCommonRequest()
+	request.Domain = vocabDomain
+	request.Version = vocabApiVersion
+	request.Product = vocabProduct
+	request.ApiName = "DeleteAsrVocab"
+	request.Method = "POST"
+	request.FormParams["VocabId"] = id
+	if _, err := c.client.ProcessCommonRequest(request); err != nil {
+		return fmt.Errorf("failed to delete asr vocab: %v", err)
+	}
+	return nil
+}
+
+// hotWords 去重并跳过超过阿里云长度限制的热词
+func hotWords(vocabulary []string) []string {
+	seen := make(map[string]bool, len(vocabulary))
+	words := make([]string, 0, len(vocabulary))
+	for _, word := range vocabulary {
+		word = strings.TrimSpace(word)
+		if word == "" || seen[word] {
+			continue
+		}
+		if hotWordUnits(word) > maxHotWordUnits {
+			log.GetLogger().Warn("热词超过阿里云长度限制，已跳过", zap.String("word", word))
+			continue
+		}
+		seen[word] = true
+		words = append(words, word)
+		if len(words) == maxVocabWords {
+			break
+		}
+	}
+	return words
+}
+
+// hotWordUnits 汉字按字计数，其余按空格分隔的单词计数
+func hotWordUnits(word string) int {
+	units := 0
+	for _, field := range strings.Fields(word) {
+		han := 0
+		for _, r := range field {
+			if unicode.Is(unicode.Han, r) {
+				han++
+			}
+		}
+		if han > 0 {
+			units += han
+		} else {
+			units++
+		}
+	}
+	return units
+}
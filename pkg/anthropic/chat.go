@@ -0,0 +1,204 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"krillin-ai/config"
+	"krillin-ai/internal/types"
+	"krillin-ai/log"
+	"krillin-ai/pkg/util"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultBaseUrl = "https://api.anthropic.com"
+	// apiVersion Messages API版本，通过anthropic-version请求头指定
+	apiVersion = "2023-06-01"
+	// defaultMaxTokens Messages API要求必须指定max_tokens，未配置时使用
+	defaultMaxTokens = 4096
+)
+
+// Client 调用Anthropic Messages API的流式接口，实现 types.ChatCompleter。
+type Client struct {
+	BaseUrl    string
+	ApiKey     string
+	Model      string
+	httpClient *http.Client
+}
+
+// NewClient 创建Anthropic客户端，baseUrl为空时使用官方地址。
+func NewClient(baseUrl, apiKey, model string) *Client {
+	baseUrl = strings.TrimRight(strings.TrimSpace(baseUrl), "/")
+	if baseUrl == "" {
+		baseUrl = DefaultBaseUrl
+	}
+
+	transport := &http.Transport{}
+	if config.Conf.App.Proxy != "" && config.Conf.App.ParsedProxy != nil {
+		transport.Proxy = http.ProxyURL(config.Conf.App.ParsedProxy)
+	}
+
+	return &Client{
+		BaseUrl:    baseUrl,
+		ApiKey:     apiKey,
+		Model:      model,
+		httpClient: &http.Client{Transport: transport},
+	}
+}
+
+type message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type messagesRequest struct {
+	Model         string    `json:"model"`
+	MaxTokens     int       `json:"max_tokens"`
+	System        string    `json:"system,omitempty"`
+	Messages      []message `json:"messages"`
+	Temperature   *float64  `json:"temperature,omitempty"`
+	StopSequences []string  `json:"stop_sequences,omitempty"`
+	Stream        bool      `json:"stream"`
+}
+
+type usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// streamEvent 流式响应中的事件，不同type只填充对应字段
+type streamEvent struct {
+	Type    string `json:"type"`
+	Message struct {
+		Usage usage `json:"usage"`
+	} `json:"message"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+	Usage usage `json:"usage"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (c *Client) ChatCompletion(ctx context.Context, chatReq types.ChatRequest) (string, error) {
+	content, chatUsage, err := c.chat(ctx, chatReq.WithDefaults(types.ChatDefaults(config.Conf.Llm.UseCase(chatReq.UseCase))))
+	if err != nil {
+		log.GetLogger().Error("anthropic chat completion failed", zap.Error(err))
+		return "", err
+	}
+	log.GetLogger().Info("anthropic chat completion usage", zap.String("model", c.Model),
+		zap.Int("input_tokens", chatUsage.InputTokens), zap.Int("output_tokens", chatUsage.OutputTokens))
+	return content, nil
+}
+
+// buildRequestBody 组装流式请求体。Messages API没有通用的结构化输出参数，JSONSchema只依靠提示词约束
+func (c *Client) buildRequestBody(chatReq types.ChatRequest) ([]byte, error) {
+	maxTokens := chatReq.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = defaultMaxTokens
+	}
+	temperature := chatReq.Temperature
+	if temperature != nil && *temperature > 1 {
+		// Anthropic的temperature范围为0到1
+		limited := 1.0
+		temperature = &limited
+	}
+	return json.Marshal(messagesRequest{
+		Model:         c.Model,
+		MaxTokens:     maxTokens,
+		System:        chatReq.System,
+		Messages:      []message{{Role: "user", Content: chatReq.Prompt}},
+		Temperature:   temperature,
+		StopSequences: chatReq.Stop,
+		Stream:        true,
+	})
+}
+
+func (c *Client) chat(ctx context.Context, chatReq types.ChatRequest) (string, types.ChatUsage, error) {
+	var chatUsage types.ChatUsage
+	body, err := c.buildRequestBody(chatReq)
+	if err != nil {
+		return "", chatUsage, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseUrl+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return "", chatUsage, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("x-api-key", c.ApiKey)
+	req.Header.Set("anthropic-version", apiVersion)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", chatUsage, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return "", chatUsage, types.NewChatError("anthropic", resp.StatusCode, errorMessage(respBody))
+	}
+
+	var (
+		content strings.Builder
+		stopped bool
+	)
+	err = util.ReadSSE(resp.Body, func(_, data string) error {
+		var event streamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return fmt.Errorf("anthropic decode stream event failed: %w", err)
+		}
+		switch event.Type {
+		case "message_start":
+			chatUsage.InputTokens = event.Message.Usage.InputTokens
+			chatUsage.OutputTokens = event.Message.Usage.OutputTokens
+		case "content_block_delta":
+			if event.Delta.Type == "text_delta" {
+				content.WriteString(event.Delta.Text)
+			}
+		case "message_delta":
+			chatUsage.OutputTokens = event.Usage.OutputTokens
+		case "message_stop":
+			stopped = true
+		case "error":
+			return streamError(event.Error.Type, event.Error.Message)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", chatUsage, err
+	}
+	if !stopped {
+		// 连接在message_stop之前断开，已收到的内容不完整
+		return "", chatUsage, &types.ChatError{Provider: "anthropic", Message: "stream ended before message_stop", Retryable: true}
+	}
+	return content.String(), chatUsage, nil
+}
+
+// errorMessage 从错误响应中取出错误信息，解析失败时返回原始内容
+func errorMessage(body []byte) string {
+	var parsed streamEvent
+	if err := json.Unmarshal(body, &parsed); err == nil && parsed.Error.Message != "" {
+		return parsed.Error.Type + ": " + parsed.Error.Message
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// streamError 流式传输中途返回的错误没有状态码，按错误类型判断是否可重试
+func streamError(errorType, message string) error {
+	switch errorType {
+	case "overloaded_error", "api_error", "rate_limit_error", "timeout_error":
+		return &types.ChatError{Provider: "anthropic", Message: errorType + ": " + message, Retryable: true}
+	}
+	return &types.ChatError{Provider: "anthropic", Message: errorType + ": " + message}
+}
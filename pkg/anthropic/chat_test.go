@@ -0,0 +1,103 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"krillin-ai/internal/types"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestChatStreamsTextAndUsage(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" || r.Header.Get("x-api-key") != "sk-test" || r.Header.Get("anthropic-version") != apiVersion {
+			t.Errorf("unexpected request %s %v", r.URL.Path, r.Header)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":12,\"output_tokens\":1}}}\n\n")
+		fmt.Fprint(w, "event: ping\ndata: {\"type\":\"ping\"}\n\n")
+		fmt.Fprint(w, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"你好\"}}\n\n")
+		fmt.Fprint(w, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"世界\"}}\n\n")
+		fmt.Fprint(w, "event: message_delta\ndata: {\"type\":\"message_delta\",\"usage\":{\"output_tokens\":5}}\n\n")
+		fmt.Fprint(w, "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
+	}))
+	defer server.Close()
+
+	temperature := 0.0
+	client := NewClient(server.URL, "sk-test", "claude-test")
+	got, usage, err := client.chat(context.Background(), types.ChatRequest{System: "translator", Prompt: "hello world", Temperature: &temperature, Stop: []string{"END"}})
+	if err != nil || got != "你好世界" {
+		t.Fatalf("chat() = %q, %v", got, err)
+	}
+	if usage.InputTokens != 12 || usage.OutputTokens != 5 {
+		t.Fatalf("usage = %+v", usage)
+	}
+	if body["system"] != "translator" || body["max_tokens"] != float64(defaultMaxTokens) || body["temperature"] != float64(0) || body["stream"] != true {
+		t.Fatalf("request body = %v", body)
+	}
+	if fmt.Sprint(body["stop_sequences"]) != "[END]" {
+		t.Fatalf("stop_sequences = %v", body["stop_sequences"])
+	}
+}
+
+func TestChatMapsErrors(t *testing.T) {
+	for _, tc := range []struct {
+		status    int
+		stream    string
+		retryable bool
+	}{
+		{status: http.StatusUnauthorized, retryable: false},
+		{status: http.StatusTooManyRequests, retryable: true},
+		{status: 529, retryable: true},
+		{status: http.StatusOK, stream: "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n", retryable: true},
+		{status: http.StatusOK, stream: "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"invalid_request_error\",\"message\":\"bad\"}}\n\n", retryable: false},
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if tc.status != http.StatusOK {
+				w.WriteHeader(tc.status)
+				fmt.Fprint(w, `{"type":"error","error":{"type":"some_error","message":"failed"}}`)
+				return
+			}
+			fmt.Fprint(w, tc.stream)
+		}))
+		_, _, err := NewClient(server.URL, "sk-test", "claude-test").chat(context.Background(), types.ChatRequest{Prompt: "hi"})
+		server.Close()
+		if err == nil || types.IsRetryableChatError(err) != tc.retryable {
+			t.Fatalf("status %d: err = %v, retryable = %v, want %v", tc.status, err, types.IsRetryableChatError(err), tc.retryable)
+		}
+	}
+}
+
+func TestChatRejectsIncompleteStream(t *testing.T) {
+	const delta = "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"你好\"}}\n\n"
+	for name, write := range map[string]func(w http.ResponseWriter){
+		"disconnect": func(w http.ResponseWriter) {
+			fmt.Fprint(w, delta)
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		},
+		"truncated final event": func(w http.ResponseWriter) {
+			fmt.Fprint(w, delta+"event: message_stop\ndata: {\"type\":\"mess")
+		},
+		"missing message_stop": func(w http.ResponseWriter) {
+			fmt.Fprint(w, delta)
+		},
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			write(w)
+		}))
+		got, _, err := NewClient(server.URL, "sk-test", "claude-test").chat(context.Background(), types.ChatRequest{Prompt: "hi"})
+		server.Close()
+		// 不完整的译文不能当作结果返回，交给上层重试
+		if err == nil || got != "" || !types.IsRetryableChatError(err) {
+			t.Fatalf("%s: chat() = %q, %v, want retryable error", name, got, err)
+		}
+	}
+}
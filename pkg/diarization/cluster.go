@@ -0,0 +1,162 @@
+package diarization
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+)
+
+// Cluster 对片段特征做平均连接的层次聚类，相似度低于threshold时停止合并。
+// minSpeakers/maxSpeakers为0表示不限制。返回每个片段的簇编号，特征为nil的片段编号为-1
+func Cluster(embeddings [][]float64, threshold float64, minSpeakers, maxSpeakers int) []int {
+	labels := make([]int, len(embeddings))
+	var clusters [][]int
+	for i, embedding := range embeddings {
+		if embedding == nil {
+			labels[i] = -1
+			continue
+		}
+		clusters = append(clusters, []int{i})
+	}
+	for len(clusters) > 1 {
+		bestA, bestB, bestSim := -1, -1, math.Inf(-1)
+		for a := range clusters {
+			for b := a + 1; b < len(clusters); b++ {
+				if sim := averageSimilarity(embeddings, clusters[a], clusters[b]); sim > bestSim {
+					bestA, bestB, bestSim = a, b, sim
+				}
+			}
+		}
+		mustMerge := maxSpeakers > 0 && len(clusters) > maxSpeakers
+		if !mustMerge && (bestSim < threshold || (minSpeakers > 0 && len(clusters) <= minSpeakers)) {
+			break
+		}
+		clusters[bestA] = append(clusters[bestA], clusters[bestB]...)
+		clusters = append(clusters[:bestB], clusters[bestB+1:]...)
+	}
+	for label, members := range clusters {
+		for _, i := range members {
+			labels[i] = label
+		}
+	}
+	return labels
+}
+
+func averageSimilarity(embeddings [][]float64, a, b []int) float64 {
+	var sum float64
+	for _, i := range a {
+		for _, j := range b {
+			sum += CosineSimilarity(embeddings[i], embeddings[j])
+		}
+	}
+	return sum / float64(len(a)*len(b))
+}
+
+func CosineSimilarity(a, b []float64) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
+
+// Centroid 按时长加权平均多个片段的特征
+func Centroid(embeddings [][]float64, weights []float64) []float64 {
+	var (
+		centroid []float64
+		total    float64
+	)
+	for i, embedding := range embeddings {
+		if embedding == nil {
+			continue
+		}
+		if centroid == nil {
+			centroid = make([]float64, len(embedding))
+		}
+		for d := range embedding {
+			centroid[d] += embedding[d] * weights[i]
+		}
+		total += weights[i]
+	}
+	if total == 0 {
+		return nil
+	}
+	for d := range centroid {
+		centroid[d] /= total
+	}
+	return centroid
+}
+
+// Registry 跨音频分段的说话人登记表。各分段独立聚类，再按特征匹配到全局说话人，保证同一个人在整条视频里编号一致
+type Registry struct {
+	mu          sync.Mutex
+	Threshold   float64   `json:"threshold"`
+	MaxSpeakers int       `json:"max_speakers"`
+	Speakers    []speaker `json:"speakers"`
+}
+
+type speaker struct {
+	Centroid []float64 `json:"centroid"`
+	Weight   float64   `json:"weight"`
+}
+
+func NewRegistry(threshold float64, maxSpeakers int) *Registry {
+	return &Registry{Threshold: threshold, MaxSpeakers: maxSpeakers}
+}
+
+// Assign 返回与特征最相似的说话人标签（S1、S2...），都不够相似且未达到人数上限时登记新说话人
+func (r *Registry) Assign(centroid []float64, weight float64) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	best, bestSim := -1, math.Inf(-1)
+	for i, sp := range r.Speakers {
+		if sim := CosineSimilarity(sp.Centroid, centroid); sim > bestSim {
+			best, bestSim = i, sim
+		}
+	}
+	atLimit := r.MaxSpeakers > 0 && len(r.Speakers) >= r.MaxSpeakers
+	if best < 0 || (bestSim < r.Threshold && !atLimit) {
+		r.Speakers = append(r.Speakers, speaker{Centroid: append([]float64(nil), centroid...), Weight: weight})
+		return Label(len(r.Speakers) - 1)
+	}
+	sp := &r.Speakers[best]
+	for d := range sp.Centroid {
+		sp.Centroid[d] = (sp.Centroid[d]*sp.Weight + centroid[d]*weight) / (sp.Weight + weight)
+	}
+	sp.Weight += weight
+	return Label(best)
+}
+
+func Label(index int) string {
+	return fmt.Sprintf("S%d", index+1)
+}
+
+// Save 持久化登记表，断点续跑时复用已识别的说话人
+func (r *Registry) Save(path string) error {
+	r.mu.Lock()
+	data, err := json.Marshal(r)
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func LoadRegistry(path string) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r Registry
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
@@ -0,0 +1,73 @@
+package diarization
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+// voice 用几个谐波合成不同音色的测试音频
+func voice(pcm []float64, span Span, freqs ...float64) {
+	for i := int(span.Start * SampleRate); i < int(span.End*SampleRate); i++ {
+		t := float64(i) / SampleRate
+		for _, freq := range freqs {
+			pcm[i] += 0.2 * math.Sin(2*math.Pi*freq*t)
+		}
+	}
+}
+
+func TestClusterSeparatesSpeakers(t *testing.T) {
+	spans := []Span{{0, 1}, {1.2, 2.2}, {2.4, 3.4}, {3.6, 4.6}, {4.8, 4.9}}
+	pcm := make([]float64, 5*SampleRate)
+	low := []float64{120, 240, 360}
+	high := []float64{900, 1800, 2700}
+	voice(pcm, spans[0], low...)
+	voice(pcm, spans[1], high...)
+	voice(pcm, spans[2], low...)
+	voice(pcm, spans[3], high...)
+	voice(pcm, spans[4], low...)
+
+	embeddings := make([][]float64, len(spans))
+	for i, span := range spans {
+		embeddings[i] = Embed(pcm, span)
+	}
+	if embeddings[4] != nil {
+		t.Fatalf("short span should not be embedded")
+	}
+	labels := Cluster(embeddings, 0.8, 0, 0)
+	if labels[0] != labels[2] || labels[1] != labels[3] || labels[0] == labels[1] || labels[4] != -1 {
+		t.Fatalf("labels = %v", labels)
+	}
+
+	if got := Cluster(embeddings, 0.8, 0, 1); got[0] != got[1] {
+		t.Fatalf("max_speakers=1 labels = %v, want a single cluster", got)
+	}
+}
+
+func TestRegistryKeepsSpeakerIdsAcrossSegments(t *testing.T) {
+	registry := NewRegistry(0.8, 0)
+	a := []float64{1, 0, 0}
+	b := []float64{0, 1, 0}
+	if got := registry.Assign(a, 1); got != "S1" {
+		t.Fatalf("first speaker = %s", got)
+	}
+	if got := registry.Assign(b, 1); got != "S2" {
+		t.Fatalf("second speaker = %s", got)
+	}
+	if got := registry.Assign([]float64{0.1, 1, 0}, 1); got != "S2" {
+		t.Fatalf("returning speaker = %s, want S2", got)
+	}
+
+	path := filepath.Join(t.TempDir(), "speakers.json")
+	if err := registry.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded.MaxSpeakers = 2
+	if got := loaded.Assign([]float64{0, 0, 1}, 1); got != "S1" && got != "S2" {
+		t.Fatalf("speaker beyond max_speakers = %s, want an existing one", got)
+	}
+}
@@ -0,0 +1,101 @@
+package diarization
+
+import "math"
+
+const (
+	SampleRate  = 16000
+	frameSize   = 400 // 25ms
+	frameHop    = 160 // 10ms
+	bandCount   = 24
+	minFreq     = 80.0
+	maxFreq     = 5000.0
+	minSpanSecs = 0.4 // 短于此时长的语音片段特征不可靠，不参与聚类
+)
+
+// Span 一段连续的语音，单位为秒
+type Span struct {
+	Start float64
+	End   float64
+}
+
+func (s Span) Duration() float64 {
+	return s.End - s.Start
+}
+
+var bandFreqs = func() []float64 {
+	freqs := make([]float64, bandCount)
+	ratio := math.Pow(maxFreq/minFreq, 1/float64(bandCount-1))
+	for i := range freqs {
+		freqs[i] = minFreq * math.Pow(ratio, float64(i))
+	}
+	return freqs
+}()
+
+// Embed 计算语音片段的频谱形状特征：各对数间隔频带能量的对数，去掉帧内均值后取平均，与音量无关。
+// 片段过短或没有有效语音时返回nil
+func Embed(pcm []float64, span Span) []float64 {
+	if span.Duration() < minSpanSecs {
+		return nil
+	}
+	from := max(int(span.Start*SampleRate), 0)
+	to := min(int(span.End*SampleRate), len(pcm))
+	if to-from < frameSize {
+		return nil
+	}
+	embedding := make([]float64, bandCount)
+	frames := 0
+	energies := make([]float64, bandCount)
+	for offset := from; offset+frameSize <= to; offset += frameHop {
+		frame := pcm[offset : offset+frameSize]
+		if rms(frame) < 1e-3 { // 静音帧
+			continue
+		}
+		mean := 0.0
+		for i, freq := range bandFreqs {
+			energies[i] = math.Log(goertzel(frame, freq) + 1e-10)
+			mean += energies[i]
+		}
+		mean /= bandCount
+		for i := range embedding {
+			embedding[i] += energies[i] - mean
+		}
+		frames++
+	}
+	if frames == 0 {
+		return nil
+	}
+	for i := range embedding {
+		embedding[i] /= float64(frames)
+	}
+	return embedding
+}
+
+// goertzel 计算单个频率上的能量，加汉宁窗减少频谱泄漏
+func goertzel(frame []float64, freq float64) float64 {
+	coeff := 2 * math.Cos(2*math.Pi*freq/SampleRate)
+	var s1, s2 float64
+	n := float64(len(frame) - 1)
+	for i, sample := range frame {
+		window := 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/n)
+		s0 := sample*window + coeff*s1 - s2
+		s2, s1 = s1, s0
+	}
+	return s1*s1 + s2*s2 - coeff*s1*s2
+}
+
+func rms(frame []float64) float64 {
+	var sum float64
+	for _, sample := range frame {
+		sum += sample * sample
+	}
+	return math.Sqrt(sum / float64(len(frame)))
+}
+
+// PCMFromS16LE 将16bit小端单声道数据转换为[-1,1]的采样
+func PCMFromS16LE(data []byte) []float64 {
+	pcm := make([]float64, len(data)/2)
+	for i := range pcm {
+		pcm[i] = float64(int16(uint16(data[2*i])|uint16(data[2*i+1])<<8)) / 32768
+	}
+	return pcm
+}
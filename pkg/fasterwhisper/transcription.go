@@ -1,6 +1,7 @@
 package fasterwhisper
 
 import (
+	"context"
 	"encoding/json"
 	"krillin-ai/config"
 	"krillin-ai/internal/storage"
@@ -14,23 +15,28 @@ import (
 	"go.uber.org/zap"
 )
 
-func (c *FastwhisperProcessor) Transcription(audioFile, language, workDir string) (*types.TranscriptionData, error) {
+func (c *FastwhisperProcessor) Transcription(ctx context.Context, audioFile, language, workDir string, hint types.TranscriptionHint) (*types.TranscriptionData, error) {
 	cmdArgs := []string{
 		"--model_dir", "./models/",
 		"--model", c.Model,
 		"--one_word", "2",
 		"--output_format", "json",
-		"--language", language,
 		"--output_dir", workDir,
 		audioFile,
 	}
+	if language != "" { // 不指定语言时由模型自动识别
+		cmdArgs = append([]string{"--language", language}, cmdArgs...)
+	}
+	if prompt := hint.Prompt(); prompt != "" {
+		cmdArgs = append([]string{"--initial_prompt", prompt}, cmdArgs...)
+	}
 
 	if config.Conf.Transcribe.EnableGpuAcceleration {
 		cmdArgs = append(cmdArgs[:len(cmdArgs)-1], "--compute_type", "float16", cmdArgs[len(cmdArgs)-1])
 		log.GetLogger().Info("FastwhisperProcessor启用GPU加速", zap.String("model", c.Model))
 	}
 
-	cmd := exec.Command(storage.FasterwhisperPath, cmdArgs...)
+	cmd := exec.CommandContext(ctx, storage.FasterwhisperPath, cmdArgs...)
 	log.GetLogger().Info("FastwhisperProcessor转录开始", zap.String("cmd", cmd.String()))
 	output, err := cmd.CombinedOutput()
 	if err != nil && !strings.Contains(string(output), "Subtitles are written to") {
@@ -65,30 +71,34 @@ func (c *FastwhisperProcessor) Transcription(audioFile, language, workDir string
 				seperatedWords := strings.Split(word.Word, "—")
 				transcriptionData.Words = append(transcriptionData.Words, []types.Word{
 					{
-						Num:   num,
-						Text:  util.CleanPunction(strings.TrimSpace(seperatedWords[0])),
-						Start: word.Start,
-						End:   mid,
+						Num:        num,
+						Text:       util.CleanPunction(strings.TrimSpace(seperatedWords[0])),
+						Start:      word.Start,
+						End:        mid,
+						Confidence: word.Probability,
 					},
 					{
-						Num:   num + 1,
-						Text:  util.CleanPunction(strings.TrimSpace(seperatedWords[1])),
-						Start: mid,
-						End:   word.End,
+						Num:        num + 1,
+						Text:       util.CleanPunction(strings.TrimSpace(seperatedWords[1])),
+						Start:      mid,
+						End:        word.End,
+						Confidence: word.Probability,
 					},
 				}...)
 				num += 2
 			} else {
 				transcriptionData.Words = append(transcriptionData.Words, types.Word{
-					Num:   num,
-					Text:  util.CleanPunction(strings.TrimSpace(word.Word)),
-					Start: word.Start,
-					End:   word.End,
+					Num:        num,
+					Text:       util.CleanPunction(strings.TrimSpace(word.Word)),
+					Start:      word.Start,
+					End:        word.End,
+					Confidence: word.Probability,
 				})
 				num++
 			}
 		}
 	}
+	transcriptionData.Language = result.Language
 	log.GetLogger().Info("FastwhisperProcessor转录成功")
 	return &transcriptionData, nil
 }
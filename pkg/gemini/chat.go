@@ -0,0 +1,199 @@
+package gemini
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"krillin-ai/config"
+	"krillin-ai/internal/types"
+	"krillin-ai/log"
+	"krillin-ai/pkg/util"
+
+	"go.uber.org/zap"
+)
+
+const DefaultBaseUrl = "https://generativelanguage.googleapis.com"
+
+// Client 调用Gemini generateContent的流式接口，实现 types.ChatCompleter。
+type Client struct {
+	BaseUrl    string
+	ApiKey     string
+	Model      string
+	httpClient *http.Client
+}
+
+// NewClient 创建Gemini客户端，baseUrl为空时使用官方地址。
+func NewClient(baseUrl, apiKey, model string) *Client {
+	baseUrl = strings.TrimRight(strings.TrimSpace(baseUrl), "/")
+	if baseUrl == "" {
+		baseUrl = DefaultBaseUrl
+	}
+
+	transport := &http.Transport{}
+	if config.Conf.App.Proxy != "" && config.Conf.App.ParsedProxy != nil {
+		transport.Proxy = http.ProxyURL(config.Conf.App.ParsedProxy)
+	}
+
+	return &Client{
+		BaseUrl:    baseUrl,
+		ApiKey:     apiKey,
+		Model:      model,
+		httpClient: &http.Client{Transport: transport},
+	}
+}
+
+type part struct {
+	Text string `json:"text"`
+}
+
+type content struct {
+	Role  string `json:"role,omitempty"`
+	Parts []part `json:"parts"`
+}
+
+type generationConfig struct {
+	Temperature      *float64 `json:"temperature,omitempty"`
+	MaxOutputTokens  int      `json:"maxOutputTokens,omitempty"`
+	StopSequences    []string `json:"stopSequences,omitempty"`
+	ResponseMimeType string   `json:"responseMimeType,omitempty"`
+}
+
+type generateRequest struct {
+	SystemInstruction *content         `json:"systemInstruction,omitempty"`
+	Contents          []content        `json:"contents"`
+	GenerationConfig  generationConfig `json:"generationConfig"`
+}
+
+type generateResponse struct {
+	Candidates []struct {
+		Content      content `json:"content"`
+		FinishReason string  `json:"finishReason"`
+	} `json:"candidates"`
+	PromptFeedback struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+	} `json:"usageMetadata"`
+	Error *apiError `json:"error"`
+}
+
+type apiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+func (c *Client) ChatCompletion(ctx context.Context, chatReq types.ChatRequest) (string, error) {
+	text, chatUsage, err := c.chat(ctx, chatReq.WithDefaults(types.ChatDefaults(config.Conf.Llm.UseCase(chatReq.UseCase))))
+	if err != nil {
+		log.GetLogger().Error("gemini chat completion failed", zap.Error(err))
+		return "", err
+	}
+	log.GetLogger().Info("gemini chat completion usage", zap.String("model", c.Model),
+		zap.Int("input_tokens", chatUsage.InputTokens), zap.Int("output_tokens", chatUsage.OutputTokens))
+	return text, nil
+}
+
+// buildRequestBody 组装请求体，要求JSON输出且接口支持时设置responseMimeType，具体结构仍由提示词约束
+func (c *Client) buildRequestBody(chatReq types.ChatRequest) ([]byte, error) {
+	req := generateRequest{
+		Contents: []content{{Role: "user", Parts: []part{{Text: chatReq.Prompt}}}},
+		GenerationConfig: generationConfig{
+			Temperature:     chatReq.Temperature,
+			MaxOutputTokens: chatReq.MaxTokens,
+			StopSequences:   chatReq.Stop,
+		},
+	}
+	if chatReq.System != "" {
+		req.SystemInstruction = &content{Parts: []part{{Text: chatReq.System}}}
+	}
+	if chatReq.JSONSchema != nil && config.Conf.Llm.Json {
+		req.GenerationConfig.ResponseMimeType = "application/json"
+	}
+	return json.Marshal(req)
+}
+
+func (c *Client) chat(ctx context.Context, chatReq types.ChatRequest) (string, types.ChatUsage, error) {
+	var chatUsage types.ChatUsage
+	body, err := c.buildRequestBody(chatReq)
+	if err != nil {
+		return "", chatUsage, err
+	}
+	endpoint := fmt.Sprintf("%s/v1beta/models/%s:streamGenerateContent?alt=sse", c.BaseUrl, url.PathEscape(c.Model))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", chatUsage, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-goog-api-key", c.ApiKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", chatUsage, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return "", chatUsage, types.NewChatError("gemini", resp.StatusCode, errorMessage(respBody))
+	}
+
+	var (
+		text     strings.Builder
+		finished bool
+	)
+	err = util.ReadSSE(resp.Body, func(_, data string) error {
+		var chunk generateResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("gemini decode stream chunk failed: %w", err)
+		}
+		if chunk.Error != nil {
+			return types.NewChatError("gemini", chunk.Error.Code, chunk.Error.Status+": "+chunk.Error.Message)
+		}
+		if chunk.PromptFeedback.BlockReason != "" {
+			// 内容被安全策略拦截，原样重试结果相同
+			return &types.ChatError{Provider: "gemini", Message: "prompt blocked: " + chunk.PromptFeedback.BlockReason}
+		}
+		for _, candidate := range chunk.Candidates {
+			for _, p := range candidate.Content.Parts {
+				text.WriteString(p.Text)
+			}
+			finished = finished || candidate.FinishReason != ""
+		}
+		// usageMetadata是累计值，以最后一个分块为准
+		if chunk.UsageMetadata.PromptTokenCount > 0 {
+			chatUsage.InputTokens = chunk.UsageMetadata.PromptTokenCount
+			chatUsage.OutputTokens = chunk.UsageMetadata.CandidatesTokenCount
+		}
+		return nil
+	})
+	if err != nil {
+		return "", chatUsage, err
+	}
+	if !finished {
+		// 最后一个分块带有finishReason，没有收到说明连接中途断开
+		return "", chatUsage, &types.ChatError{Provider: "gemini", Message: "stream ended without finishReason", Retryable: true}
+	}
+	return text.String(), chatUsage, nil
+}
+
+// errorMessage 从错误响应中取出错误信息，解析失败时返回原始内容
+func errorMessage(body []byte) string {
+	var parsed generateResponse
+	if err := json.Unmarshal(body, &parsed); err == nil && parsed.Error != nil {
+		return parsed.Error.Status + ": " + parsed.Error.Message
+	}
+	// 部分代理按非SSE的流式接口以数组形式返回错误
+	var list []generateResponse
+	if err := json.Unmarshal(body, &list); err == nil && len(list) > 0 && list[0].Error != nil {
+		return list[0].Error.Status + ": " + list[0].Error.Message
+	}
+	return strings.TrimSpace(string(body))
+}
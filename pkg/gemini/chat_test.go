@@ -0,0 +1,94 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"krillin-ai/config"
+	"krillin-ai/internal/types"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestChatStreamsTextAndUsage(t *testing.T) {
+	original := config.Conf.Llm.Json
+	defer func() { config.Conf.Llm.Json = original }()
+	config.Conf.Llm.Json = true
+
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1beta/models/gemini-test:streamGenerateContent" || r.URL.Query().Get("alt") != "sse" || r.Header.Get("x-goog-api-key") != "key" {
+			t.Errorf("unexpected request %s %v", r.URL, r.Header)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"text\":\"{\\\"short_sentences\\\":\"}]}}],\"usageMetadata\":{\"promptTokenCount\":20,\"candidatesTokenCount\":3}}\n\n")
+		fmt.Fprint(w, "data: {\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"text\":\"[]}\"}]},\"finishReason\":\"STOP\"}],\"usageMetadata\":{\"promptTokenCount\":20,\"candidatesTokenCount\":7}}\n\n")
+	}))
+	defer server.Close()
+
+	got, usage, err := NewClient(server.URL, "key", "gemini-test").chat(context.Background(), types.ChatRequest{
+		System: "splitter", Prompt: "split", MaxTokens: 128, JSONSchema: types.ShortSentencesSchema,
+	})
+	if err != nil || got != `{"short_sentences":[]}` {
+		t.Fatalf("chat() = %q, %v", got, err)
+	}
+	if usage.InputTokens != 20 || usage.OutputTokens != 7 {
+		t.Fatalf("usage = %+v", usage)
+	}
+	generationConfig := body["generationConfig"].(map[string]any)
+	if generationConfig["maxOutputTokens"] != float64(128) || generationConfig["responseMimeType"] != "application/json" {
+		t.Fatalf("generationConfig = %v", generationConfig)
+	}
+	if fmt.Sprint(body["systemInstruction"]) != "map[parts:[map[text:splitter]]]" {
+		t.Fatalf("systemInstruction = %v", body["systemInstruction"])
+	}
+}
+
+func TestChatStreamErrors(t *testing.T) {
+	const partial = "data: {\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"text\":\"你好\"}]}}]}\n\n"
+	for _, tc := range []struct {
+		name      string
+		stream    string
+		retryable bool
+	}{
+		{name: "blocked prompt", stream: "data: {\"promptFeedback\":{\"blockReason\":\"SAFETY\"}}\n\n", retryable: false},
+		{name: "error chunk", stream: partial + "data: {\"error\":{\"code\":503,\"message\":\"overloaded\",\"status\":\"UNAVAILABLE\"}}\n\n", retryable: true},
+		{name: "missing finishReason", stream: partial, retryable: true},
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, tc.stream)
+		}))
+		got, _, err := NewClient(server.URL, "key", "gemini-test").chat(context.Background(), types.ChatRequest{Prompt: "hi"})
+		server.Close()
+		if err == nil || got != "" || types.IsRetryableChatError(err) != tc.retryable {
+			t.Fatalf("%s: chat() = %q, %v, retryable = %v, want %v", tc.name, got, err, types.IsRetryableChatError(err), tc.retryable)
+		}
+	}
+}
+
+func TestChatStopsOnContextCancel(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"text\":\"你好\"}]}}]}\n\n")
+		w.(http.Flusher).Flush()
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	_, _, err := NewClient(server.URL, "key", "gemini-test").chat(ctx, types.ChatRequest{Prompt: "hi"})
+	// 任务取消后不应再重试
+	if !errors.Is(err, context.Canceled) || types.IsRetryableChatError(err) {
+		t.Fatalf("chat() error = %v, want context.Canceled", err)
+	}
+}
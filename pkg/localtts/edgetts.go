@@ -22,7 +22,7 @@ func NewEdgeTtsClient() *EdgeTtsClient {
 	return &EdgeTtsClient{}
 }
 
-func (c *EdgeTtsClient) Text2Speech(text, voice, outputFile string) error {
+func (c *EdgeTtsClient) Text2Speech(ctx context.Context, text, voice, outputFile string) error {
 	// 清理语音名称中的额外空格
 	voice = strings.TrimSpace(voice)
 
@@ -72,7 +72,7 @@ func (c *EdgeTtsClient) Text2Speech(text, voice, outputFile string) error {
 			zap.Int("maxRetries", maxRetries),
 			zap.String("text_length", fmt.Sprintf("%d", len(text))))
 
-		err := c.attemptTTS(tempFileName, voice, absOutputFile, attempt)
+		err := c.attemptTTS(ctx, tempFileName, voice, absOutputFile, attempt)
 		if err == nil {
 			// 成功生成
 			log.GetLogger().Info("edge-tts转录完成", zap.String("output file", absOutputFile))
@@ -83,6 +83,9 @@ func (c *EdgeTtsClient) Text2Speech(text, voice, outputFile string) error {
 			return nil
 		}
 
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		log.GetLogger().Warn("edge-tts转录失败，准备重试",
 			zap.Int("attempt", attempt),
 			zap.Error(err))
@@ -98,7 +101,7 @@ func (c *EdgeTtsClient) Text2Speech(text, voice, outputFile string) error {
 	return fmt.Errorf("edge-tts转录失败，已重试%d次", maxRetries)
 }
 
-func (c *EdgeTtsClient) attemptTTS(tempFileName, voice, absOutputFile string, attempt int) error {
+func (c *EdgeTtsClient) attemptTTS(ctx context.Context, tempFileName, voice, absOutputFile string, attempt int) error {
 	// 使用新的edge-tts命令参数（文件输入方式）
 	cmdArgs := []string{
 		"--text-file", tempFileName,
@@ -109,7 +112,7 @@ func (c *EdgeTtsClient) attemptTTS(tempFileName, voice, absOutputFile string, at
 	}
 
 	// 创建带超时的上下文
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second) // 60秒超时
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second) // 60秒超时
 	defer cancel()
 
 	cmd := exec.CommandContext(ctx, storage.EdgeTtsPath, cmdArgs...)
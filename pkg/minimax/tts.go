@@ -140,7 +140,7 @@ func decodeAudio(respBody []byte) ([]byte, error) {
 }
 
 // Text2Speech 将文本合成为语音并写入 outputFile（wav）。
-func (c *TtsClient) Text2Speech(text, voice, outputFile string) error {
+func (c *TtsClient) Text2Speech(ctx context.Context, text, voice, outputFile string) error {
 	if c.ApiKey == "" {
 		return fmt.Errorf("minimax tts api key is empty")
 	}
@@ -150,7 +150,7 @@ func (c *TtsClient) Text2Speech(text, voice, outputFile string) error {
 		return fmt.Errorf("minimax tts build request failed: %w", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
 	defer cancel()
 
 	url := c.BaseUrl + "/v1/t2a_v2"
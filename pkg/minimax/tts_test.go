@@ -1,6 +1,7 @@
 package minimax
 
 import (
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"os"
@@ -103,7 +104,7 @@ func TestDecodeAudioEmpty(t *testing.T) {
 func TestText2SpeechRequiresApiKey(t *testing.T) {
 	c := NewTtsClient("", "", "")
 	out := filepath.Join(t.TempDir(), "out.wav")
-	if err := c.Text2Speech("hello", "", out); err == nil {
+	if err := c.Text2Speech(context.Background(), "hello", "", out); err == nil {
 		t.Fatal("Text2Speech() error = nil, want missing api key error")
 	}
 	if _, err := os.Stat(out); err == nil {
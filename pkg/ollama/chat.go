@@ -0,0 +1,171 @@
+package ollama
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"krillin-ai/config"
+	"krillin-ai/internal/types"
+	"krillin-ai/log"
+
+	"go.uber.org/zap"
+)
+
+const DefaultBaseUrl = "http://localhost:11434"
+
+// Client 调用Ollama原生的/api/chat接口，实现 types.ChatCompleter。
+type Client struct {
+	BaseUrl    string
+	Model      string
+	httpClient *http.Client
+}
+
+// NewClient 创建Ollama客户端，baseUrl为空时使用本机默认地址。本地服务不走代理
+func NewClient(baseUrl, model string) *Client {
+	baseUrl = strings.TrimRight(strings.TrimSpace(baseUrl), "/")
+	if baseUrl == "" {
+		baseUrl = DefaultBaseUrl
+	}
+	return &Client{
+		BaseUrl:    baseUrl,
+		Model:      model,
+		httpClient: &http.Client{},
+	}
+}
+
+type message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type options struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	NumPredict  int      `json:"num_predict,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+}
+
+type chatRequest struct {
+	Model    string          `json:"model"`
+	Messages []message       `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Format   json.RawMessage `json:"format,omitempty"`
+	Options  options         `json:"options"`
+}
+
+// chatResponse 流式响应的每一行，最后一行done为true并带有token统计
+type chatResponse struct {
+	Message         message `json:"message"`
+	Done            bool    `json:"done"`
+	PromptEvalCount int     `json:"prompt_eval_count"`
+	EvalCount       int     `json:"eval_count"`
+	Error           string  `json:"error"`
+}
+
+func (c *Client) ChatCompletion(ctx context.Context, chatReq types.ChatRequest) (string, error) {
+	content, chatUsage, err := c.chat(ctx, chatReq.WithDefaults(types.ChatDefaults(config.Conf.Llm.UseCase(chatReq.UseCase))))
+	if err != nil {
+		log.GetLogger().Error("ollama chat completion failed", zap.Error(err))
+		return "", err
+	}
+	log.GetLogger().Info("ollama chat completion usage", zap.String("model", c.Model),
+		zap.Int("input_tokens", chatUsage.InputTokens), zap.Int("output_tokens", chatUsage.OutputTokens))
+	return content, nil
+}
+
+// buildRequestBody 组装流式请求体，Ollama的format字段直接接受JSON Schema
+func (c *Client) buildRequestBody(chatReq types.ChatRequest) ([]byte, error) {
+	var messages []message
+	if chatReq.System != "" {
+		messages = append(messages, message{Role: "system", Content: chatReq.System})
+	}
+	messages = append(messages, message{Role: "user", Content: chatReq.Prompt})
+	req := chatRequest{
+		Model:    c.Model,
+		Messages: messages,
+		Stream:   true,
+		Options: options{
+			Temperature: chatReq.Temperature,
+			NumPredict:  chatReq.MaxTokens,
+			Stop:        chatReq.Stop,
+		},
+	}
+	if chatReq.JSONSchema != nil && config.Conf.Llm.Json {
+		req.Format = chatReq.JSONSchema.Schema
+	}
+	return json.Marshal(req)
+}
+
+func (c *Client) chat(ctx context.Context, chatReq types.ChatRequest) (string, types.ChatUsage, error) {
+	var chatUsage types.ChatUsage
+	body, err := c.buildRequestBody(chatReq)
+	if err != nil {
+		return "", chatUsage, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseUrl+"/api/chat", bytes.NewReader(body))
+	if err != nil {
+		return "", chatUsage, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", chatUsage, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return "", chatUsage, types.NewChatError("ollama", resp.StatusCode, errorMessage(respBody))
+	}
+
+	// 响应为每行一个JSON对象
+	var (
+		content strings.Builder
+		done    bool
+	)
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var chunk chatResponse
+		if err = json.Unmarshal(line, &chunk); err != nil {
+			return "", chatUsage, fmt.Errorf("ollama decode stream chunk failed: %w", err)
+		}
+		if chunk.Error != "" {
+			// 流式传输中途的错误多为模型运行出错（如显存不足），稍后重试可能恢复
+			return "", chatUsage, &types.ChatError{Provider: "ollama", Message: chunk.Error, Retryable: true}
+		}
+		content.WriteString(chunk.Message.Content)
+		if chunk.Done {
+			chatUsage.InputTokens = chunk.PromptEvalCount
+			chatUsage.OutputTokens = chunk.EvalCount
+			done = true
+			break
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return "", chatUsage, err
+	}
+	if !done {
+		// 连接在done之前断开，已收到的内容不完整
+		return "", chatUsage, &types.ChatError{Provider: "ollama", Message: "stream ended before done", Retryable: true}
+	}
+	return content.String(), chatUsage, nil
+}
+
+// errorMessage 从错误响应中取出错误信息，解析失败时返回原始内容
+func errorMessage(body []byte) string {
+	var parsed chatResponse
+	if err := json.Unmarshal(body, &parsed); err == nil && parsed.Error != "" {
+		return parsed.Error
+	}
+	return strings.TrimSpace(string(body))
+}
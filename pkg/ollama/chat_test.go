@@ -0,0 +1,90 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"krillin-ai/config"
+	"krillin-ai/internal/types"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestChatStreamsTextAndUsage(t *testing.T) {
+	original := config.Conf.Llm.Json
+	defer func() { config.Conf.Llm.Json = original }()
+	config.Conf.Llm.Json = true
+
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		fmt.Fprintln(w, `{"model":"qwen","message":{"role":"assistant","content":"你好"},"done":false}`)
+		fmt.Fprintln(w, `{"model":"qwen","message":{"role":"assistant","content":"世界"},"done":false}`)
+		fmt.Fprintln(w, `{"model":"qwen","message":{"role":"assistant","content":""},"done":true,"prompt_eval_count":9,"eval_count":4}`)
+	}))
+	defer server.Close()
+
+	temperature := 0.0
+	got, usage, err := NewClient(server.URL, "qwen").chat(context.Background(), types.ChatRequest{
+		System: "translator", Prompt: "hello", Temperature: &temperature, MaxTokens: 64, JSONSchema: types.ShortSentencesSchema,
+	})
+	if err != nil || got != "你好世界" {
+		t.Fatalf("chat() = %q, %v", got, err)
+	}
+	if usage.InputTokens != 9 || usage.OutputTokens != 4 {
+		t.Fatalf("usage = %+v", usage)
+	}
+	options := body["options"].(map[string]any)
+	// temperature为0也要传给ollama，否则使用模型默认温度
+	if options["temperature"] != float64(0) || options["num_predict"] != float64(64) {
+		t.Fatalf("options = %v", options)
+	}
+	if format, ok := body["format"].(map[string]any); !ok || format["type"] != "object" {
+		t.Fatalf("format = %v", body["format"])
+	}
+	if messages := body["messages"].([]any); len(messages) != 2 || messages[0].(map[string]any)["role"] != "system" {
+		t.Fatalf("messages = %v", messages)
+	}
+}
+
+func TestChatStreamErrors(t *testing.T) {
+	const partial = `{"model":"qwen","message":{"role":"assistant","content":"你好"},"done":false}` + "\n"
+	for _, tc := range []struct {
+		name      string
+		status    int
+		write     func(w http.ResponseWriter)
+		retryable bool
+	}{
+		// 模型未下载时ollama返回404，重试无意义
+		{name: "model not found", status: http.StatusNotFound, write: func(w http.ResponseWriter) {
+			fmt.Fprintln(w, `{"error":"model \"qwen\" not found, try pulling it first"}`)
+		}},
+		{name: "error line", status: http.StatusOK, retryable: true, write: func(w http.ResponseWriter) {
+			fmt.Fprint(w, partial+`{"error":"CUDA error: out of memory"}`+"\n")
+		}},
+		{name: "disconnect", status: http.StatusOK, retryable: true, write: func(w http.ResponseWriter) {
+			fmt.Fprint(w, partial)
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}},
+		{name: "truncated final line", status: http.StatusOK, retryable: true, write: func(w http.ResponseWriter) {
+			fmt.Fprint(w, partial+`{"model":"qwen","message":{"role":"assistant","content":""},"do`)
+		}},
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tc.status)
+			tc.write(w)
+		}))
+		got, _, err := NewClient(server.URL, "qwen").chat(context.Background(), types.ChatRequest{Prompt: "hi"})
+		server.Close()
+		if err == nil || got != "" || types.IsRetryableChatError(err) != tc.retryable {
+			t.Fatalf("%s: chat() = %q, %v, retryable = %v, want %v", tc.name, got, err, types.IsRetryableChatError(err), tc.retryable)
+		}
+	}
+}
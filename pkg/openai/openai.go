@@ -3,42 +3,29 @@ package openai
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	openai "github.com/sashabaranov/go-openai"
 	"go.uber.org/zap"
 	"io"
 	"krillin-ai/config"
+	"krillin-ai/internal/types"
 	"krillin-ai/log"
+	"math"
 	"net/http"
 	"os"
 	"strings"
 )
 
-func (c *Client) ChatCompletion(query string) (string, error) {
-	var responseFormat *openai.ChatCompletionResponseFormat
+func (c *Client) ChatCompletion(ctx context.Context, chatReq types.ChatRequest) (string, error) {
+	req := newChatCompletionRequest(chatReq.WithDefaults(types.ChatDefaults(config.Conf.Llm.UseCase(chatReq.UseCase))))
+	req.Model = config.Conf.Llm.Model
+	req.Stream = true
 
-	req := openai.ChatCompletionRequest{
-		Model: config.Conf.Llm.Model,
-		Messages: []openai.ChatCompletionMessage{
-			{
-				Role:    openai.ChatMessageRoleSystem,
-				Content: "You are an assistant that helps with subtitle translation.",
-			},
-			{
-				Role:    openai.ChatMessageRoleUser,
-				Content: query,
-			},
-		},
-		Temperature:    0.9,
-		Stream:         true,
-		MaxTokens:      8192,
-		ResponseFormat: responseFormat,
-	}
-
-	stream, err := c.client.CreateChatCompletionStream(context.Background(), req)
+	stream, err := c.client.CreateChatCompletionStream(ctx, req)
 	if err != nil {
 		log.GetLogger().Error("openai create chat completion stream failed", zap.Error(err))
-		return "", err
+		return "", chatError(err)
 	}
 	defer stream.Close()
 
@@ -50,7 +37,7 @@ func (c *Client) ChatCompletion(query string) (string, error) {
 		}
 		if err != nil {
 			log.GetLogger().Error("openai stream receive failed", zap.Error(err))
-			return "", err
+			return "", chatError(err)
 		}
 		if len(response.Choices) == 0 {
 			log.GetLogger().Info("openai stream receive no choices", zap.Any("response", response))
@@ -63,7 +50,50 @@ func (c *Client) ChatCompletion(query string) (string, error) {
 	return resContent, nil
 }
 
-func (c *Client) Text2Speech(text, voice string, outputFile string) error {
+// chatError 把接口返回的错误转换为带状态码的 types.ChatError，便于调用方判断是否重试
+func chatError(err error) error {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) && apiErr.HTTPStatusCode != 0 {
+		return types.NewChatError("openai", apiErr.HTTPStatusCode, apiErr.Message)
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) && reqErr.HTTPStatusCode != 0 {
+		return types.NewChatError("openai", reqErr.HTTPStatusCode, reqErr.Error())
+	}
+	return err
+}
+
+// newChatCompletionRequest 把通用的请求转换为openai接口的请求，调用前需已补全默认参数
+func newChatCompletionRequest(chatReq types.ChatRequest) openai.ChatCompletionRequest {
+	var messages []openai.ChatCompletionMessage
+	if chatReq.System != "" {
+		messages = append(messages, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleSystem, Content: chatReq.System})
+	}
+	messages = append(messages, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: chatReq.Prompt})
+
+	req := openai.ChatCompletionRequest{
+		Messages:  messages,
+		MaxTokens: chatReq.MaxTokens,
+		Stop:      chatReq.Stop,
+	}
+	if chatReq.Temperature != nil {
+		// go-openai会省略值为0的temperature，服务端此时按默认温度采样，用最小正数代替以保证结果稳定
+		req.Temperature = float32(math.Max(*chatReq.Temperature, math.SmallestNonzeroFloat32))
+	}
+	if chatReq.JSONSchema != nil && config.Conf.Llm.Json {
+		req.ResponseFormat = &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+				Name:   chatReq.JSONSchema.Name,
+				Schema: chatReq.JSONSchema.Schema,
+				Strict: true,
+			},
+		}
+	}
+	return req
+}
+
+func (c *Client) Text2Speech(ctx context.Context, text, voice string, outputFile string) error {
 	baseUrl := config.Conf.Tts.Openai.BaseUrl
 	if baseUrl == "" {
 		baseUrl = "https://api.openai.com/v1"
@@ -77,7 +107,7 @@ func (c *Client) Text2Speech(text, voice string, outputFile string) error {
 		"voice":"%s",
 		"response_format": "wav"
 	}`, text, voice)
-	req, err := http.NewRequest("POST", url, strings.NewReader(reqBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(reqBody))
 	if err != nil {
 		return err
 	}
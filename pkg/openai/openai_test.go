@@ -0,0 +1,82 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"krillin-ai/config"
+	"krillin-ai/internal/types"
+	"krillin-ai/log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestChatCompletionSendsUseCaseDefaultsAndSchema(t *testing.T) {
+	original := config.Conf.Llm
+	defer func() { config.Conf.Llm = original }()
+	config.Conf.Llm.Json = true
+	config.Conf.Llm.Split = config.LlmUseCase{SystemPrompt: "split only", MaxTokens: 256, Stop: []string{"END"}}
+
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"{\\\"short_sentences\\\"\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\":[]}\"}}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test", "")
+	got, err := client.ChatCompletion(context.Background(), types.ChatRequest{
+		UseCase:    config.LlmUseCaseSplit,
+		Prompt:     "split this",
+		JSONSchema: types.ShortSentencesSchema,
+	})
+	if err != nil || got != `{"short_sentences":[]}` {
+		t.Fatalf("ChatCompletion() = %q, %v", got, err)
+	}
+
+	messages := body["messages"].([]any)
+	if len(messages) != 2 || messages[0].(map[string]any)["content"] != "split only" || messages[1].(map[string]any)["content"] != "split this" {
+		t.Fatalf("messages = %v", messages)
+	}
+	// temperature为0时也要发送，否则服务端会按默认温度采样
+	if temperature, ok := body["temperature"].(float64); !ok || temperature > 1e-6 {
+		t.Fatalf("temperature = %v", body["temperature"])
+	}
+	if body["max_tokens"] != float64(256) || fmt.Sprint(body["stop"]) != "[END]" {
+		t.Fatalf("max_tokens = %v, stop = %v", body["max_tokens"], body["stop"])
+	}
+	format := body["response_format"].(map[string]any)
+	if format["type"] != "json_schema" || format["json_schema"].(map[string]any)["name"] != "short_sentences" {
+		t.Fatalf("response_format = %v", format)
+	}
+
+	// 接口不支持结构化输出时不附带response_format
+	config.Conf.Llm.Json = false
+	if _, err = client.ChatCompletion(context.Background(), types.ChatRequest{UseCase: config.LlmUseCaseSplit, Prompt: "split this", JSONSchema: types.ShortSentencesSchema}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := body["response_format"]; ok {
+		t.Fatalf("response_format should be omitted, body = %v", body)
+	}
+}
+
+func TestChatCompletionHonoursContext(t *testing.T) {
+	log.InitLogger()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewClient(server.URL, "test", "").ChatCompletion(ctx, types.ChatRequest{Prompt: "hi"}); err == nil {
+		t.Fatal("cancelled context should abort the request")
+	}
+}
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"context"
 	"go.uber.org/zap"
 	"krillin-ai/internal/storage"
 	"krillin-ai/log"
@@ -10,10 +11,10 @@ import (
 )
 
 // 把音频处理成单声道、16k采样率
-func ProcessAudio(filePath string) (string, error) {
+func ProcessAudio(ctx context.Context, filePath string) (string, error) {
 	dest := strings.ReplaceAll(filePath, filepath.Ext(filePath), "_mono_16K.mp3")
 	cmdArgs := []string{"-i", filePath, "-ac", "1", "-ar", "16000", "-b:a", "192k", dest}
-	cmd := exec.Command(storage.FfmpegPath, cmdArgs...)
+	cmd := exec.CommandContext(ctx, storage.FfmpegPath, cmdArgs...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		log.GetLogger().Error("处理音频失败", zap.Error(err), zap.String("audio file", filePath), zap.String("output", string(output)))
@@ -0,0 +1,190 @@
+package util
+
+import (
+	"krillin-ai/internal/types"
+	"strings"
+	"unicode"
+)
+
+// whisperLanguageNames openai whisper接口返回的是语言英文名，这里映射为ISO代码
+var whisperLanguageNames = map[string]string{
+	"english": "en", "chinese": "zh", "japanese": "ja", "korean": "ko", "german": "de", "spanish": "es",
+	"russian": "ru", "french": "fr", "portuguese": "pt", "turkish": "tr", "polish": "pl", "catalan": "ca",
+	"dutch": "nl", "arabic": "ar", "swedish": "sv", "italian": "it", "indonesian": "id", "hindi": "hi",
+	"finnish": "fi", "vietnamese": "vi", "hebrew": "he", "ukrainian": "uk", "greek": "el", "malay": "ms",
+	"czech": "cs", "romanian": "ro", "danish": "da", "hungarian": "hu", "tamil": "ta", "norwegian": "no",
+	"thai": "th", "urdu": "ur", "croatian": "hr", "bulgarian": "bg", "lithuanian": "lt", "malayalam": "ml",
+	"slovak": "sk", "telugu": "te", "persian": "fa", "latvian": "lv", "bengali": "bn", "serbian": "sr",
+	"slovenian": "sl", "kannada": "kn", "estonian": "et", "macedonian": "mk", "icelandic": "is",
+	"armenian": "hy", "bosnian": "bs", "kazakh": "kk", "albanian": "sq", "swahili": "sw", "marathi": "mr",
+	"punjabi": "pa", "khmer": "km", "yoruba": "yo", "afrikaans": "af", "georgian": "ka", "tajik": "tg",
+	"amharic": "am", "lao": "lo", "uzbek": "uz", "pashto": "ps", "turkmen": "tk", "maltese": "mt",
+	"luxembourgish": "lb", "tagalog": "fil", "lingala": "ln", "hausa": "ha", "javanese": "jv",
+	"cantonese": "yue", "nynorsk": "no",
+}
+
+// languageCodeAliases whisper等模型使用的旧代码或变体
+var languageCodeAliases = map[string]string{
+	"tl": "fil", "jw": "jv", "nb": "no", "nn": "no", "iw": "he", "in": "id", "mo": "ro",
+}
+
+// NormalizeDetectedLanguage 把转录服务识别出的语言（ISO代码或whisper的英文名）映射为标准语言代码。
+// 中文根据文本判断简繁，无法映射时返回false
+func NormalizeDetectedLanguage(language, text string) (types.StandardLanguageCode, bool) {
+	code := strings.ToLower(strings.TrimSpace(language))
+	if name, ok := whisperLanguageNames[code]; ok {
+		code = name
+	}
+	code = strings.ReplaceAll(code, "-", "_")
+	switch code {
+	case "zh_cn", "zh_hans", "zh_sg":
+		return types.LanguageNameSimplifiedChinese, true
+	case "zh_tw", "zh_hant", "zh_hk", "yue":
+		return types.LanguageNameTraditionalChinese, true
+	case "zh":
+		return chineseVariant(text), true
+	}
+	if base, _, found := strings.Cut(code, "_"); found {
+		code = base
+	}
+	if alias, ok := languageCodeAliases[code]; ok {
+		code = alias
+	}
+	if _, ok := types.StandardLanguageCode2Name[types.StandardLanguageCode(code)]; ok {
+		return types.StandardLanguageCode(code), true
+	}
+	return "", false
+}
+
+// scriptLanguages 只用一种文字书写的语言，按文字系统即可判断
+var scriptLanguages = []struct {
+	table    *unicode.RangeTable
+	language types.StandardLanguageCode
+}{
+	{unicode.Hangul, types.LanguageNameKorean},
+	{unicode.Thai, types.LanguageNameThai},
+	{unicode.Arabic, types.LanguageNameArabic},
+	{unicode.Hebrew, types.LanguageNameHebrew},
+	{unicode.Devanagari, types.LanguageNameHindi},
+	{unicode.Greek, types.LanguageNameGreek},
+	{unicode.Georgian, types.LanguageNameGeorgian},
+	{unicode.Armenian, types.LanguageNameArmenian},
+	{unicode.Bengali, types.LanguageNameBengali},
+	{unicode.Tamil, types.LanguageNameTamil},
+	{unicode.Khmer, types.LanguageNameKhmer},
+	{unicode.Lao, types.LanguageNameLao},
+}
+
+// latinStopwords 拉丁字母语言各自最常见的虚词，用于在转录服务不返回语言时粗略区分，得分相同时取靠前的语言
+var latinStopwords = []struct {
+	language  types.StandardLanguageCode
+	stopwords []string
+}{
+	{types.LanguageNameEnglish, []string{"the", "and", "is", "you", "that", "it", "to", "of", "this", "what"}},
+	{types.LanguageNameFrench, []string{"le", "la", "les", "et", "est", "je", "vous", "une", "pas", "que"}},
+	{types.LanguageNameGerman, []string{"der", "die", "und", "ist", "ich", "das", "nicht", "sie", "ein", "mit"}},
+	{types.LanguageNameSpanish, []string{"el", "los", "que", "es", "y", "una", "por", "pero", "con", "muy"}},
+	{types.LanguageNameItalian, []string{"il", "che", "di", "non", "sono", "una", "per", "gli", "della", "questo"}},
+	{types.LanguageNamePortuguese, []string{"o", "os", "que", "não", "uma", "para", "com", "você", "isso", "muito"}},
+	{types.LanguageNameDutch, []string{"de", "het", "een", "en", "niet", "ik", "dat", "je", "zijn", "wat"}},
+	{types.LanguageNameIndonesian, []string{"yang", "dan", "ini", "itu", "tidak", "saya", "dengan", "untuk", "ada", "kita"}},
+}
+
+// DetectLanguageFromText 按文字系统和常见虚词判断文本语言，转录服务不返回语言时使用
+func DetectLanguageFromText(text string) (types.StandardLanguageCode, bool) {
+	var han, kana, cyrillic, latin, vietnamese, turkish int
+	scripts := make([]int, len(scriptLanguages))
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+			if strings.ContainsRune("ăâđêôơưạảấầẩẫậắằẳẵặẹẻẽếềểễệỉịọỏốồổỗộớờởỡợụủứừửữựỳỵỷỹ", unicode.ToLower(r)) {
+				vietnamese++
+			}
+			if strings.ContainsRune("ğış", unicode.ToLower(r)) {
+				turkish++
+			}
+		default:
+			for i, script := range scriptLanguages {
+				if unicode.Is(script.table, r) {
+					scripts[i]++
+				}
+			}
+		}
+	}
+	best, bestCount := -1, 0
+	for i, count := range scripts {
+		if count > bestCount {
+			best, bestCount = i, count
+		}
+	}
+	switch {
+	case kana > 0 && kana*10 >= han: // 日文中假名占比不会太低
+		return types.LanguageNameJapanese, true
+	case han > 0 && han >= bestCount && han >= cyrillic && han >= latin:
+		return chineseVariant(text), true
+	case bestCount > 0 && bestCount >= cyrillic && bestCount >= latin:
+		return scriptLanguages[best].language, true
+	case cyrillic > 0 && cyrillic >= latin:
+		if strings.ContainsAny(strings.ToLower(text), "іїєґ") {
+			return types.LanguageNameUkrainian, true
+		}
+		return types.LanguageNameRussian, true
+	case latin > 0:
+		if vietnamese*20 >= latin {
+			return types.LanguageNameVietnamese, true
+		}
+		if turkish*50 >= latin {
+			return types.LanguageNameTurkish, true
+		}
+		return latinLanguage(text), true
+	}
+	return "", false
+}
+
+func latinLanguage(text string) types.StandardLanguageCode {
+	counts := make(map[string]int)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		counts[word]++
+	}
+	best, bestScore := types.LanguageNameEnglish, 0
+	for _, candidate := range latinStopwords {
+		score := 0
+		for _, stopword := range candidate.stopwords {
+			score += counts[stopword]
+		}
+		if score > bestScore {
+			best, bestScore = candidate.language, score
+		}
+	}
+	return best
+}
+
+// chineseVariant 比较常用字的简繁写法出现次数判断简体还是繁体
+func chineseVariant(text string) types.StandardLanguageCode {
+	const (
+		simplified  = "这们个来为说时会对学国过还后么与开关见没样发现经问题让话动长实东车谢听头"
+		traditional = "這們個來為說時會對學國過還後麼與開關見沒樣發現經問題讓話動長實東車謝聽頭"
+	)
+	simplifiedCount, traditionalCount := 0, 0
+	for _, r := range text {
+		if strings.ContainsRune(simplified, r) {
+			simplifiedCount++
+		}
+		if strings.ContainsRune(traditional, r) {
+			traditionalCount++
+		}
+	}
+	if traditionalCount > simplifiedCount {
+		return types.LanguageNameTraditionalChinese
+	}
+	return types.LanguageNameSimplifiedChinese
+}
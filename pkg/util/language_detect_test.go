@@ -0,0 +1,54 @@
+package util
+
+import (
+	"krillin-ai/internal/types"
+	"testing"
+)
+
+func TestNormalizeDetectedLanguage(t *testing.T) {
+	cases := []struct {
+		language, text string
+		want           types.StandardLanguageCode
+	}{
+		{"english", "", types.LanguageNameEnglish},
+		{"en", "", types.LanguageNameEnglish},
+		{"zh", "我们这个问题", types.LanguageNameSimplifiedChinese},
+		{"chinese", "我們這個問題", types.LanguageNameTraditionalChinese},
+		{"pt-BR", "", types.LanguageNamePortuguese},
+		{"tl", "", types.LanguageNameFilipino},
+		{"jw", "", types.LanguageNameJavanese},
+	}
+	for _, c := range cases {
+		if got, ok := NormalizeDetectedLanguage(c.language, c.text); !ok || got != c.want {
+			t.Errorf("NormalizeDetectedLanguage(%q) = %q, %v, want %q", c.language, got, ok, c.want)
+		}
+	}
+	if _, ok := NormalizeDetectedLanguage("klingon", ""); ok {
+		t.Error("NormalizeDetectedLanguage accepted unknown language")
+	}
+}
+
+func TestDetectLanguageFromText(t *testing.T) {
+	cases := []struct {
+		text string
+		want types.StandardLanguageCode
+	}{
+		{"今天我们来聊一聊这个问题", types.LanguageNameSimplifiedChinese},
+		{"今天我們來聊一聊這個問題", types.LanguageNameTraditionalChinese},
+		{"今日はいい天気ですね", types.LanguageNameJapanese},
+		{"안녕하세요 여러분", types.LanguageNameKorean},
+		{"Привет, как дела?", types.LanguageNameRussian},
+		{"This is what the video is about.", types.LanguageNameEnglish},
+		{"Je pense que vous avez raison, et ce n'est pas une blague.", types.LanguageNameFrench},
+		{"Ich weiß nicht, ob das die richtige Antwort ist.", types.LanguageNameGerman},
+		{"Xin chào các bạn, hôm nay chúng ta sẽ học tiếng Việt", types.LanguageNameVietnamese},
+	}
+	for _, c := range cases {
+		if got, ok := DetectLanguageFromText(c.text); !ok || got != c.want {
+			t.Errorf("DetectLanguageFromText(%q) = %q, want %q", c.text, got, c.want)
+		}
+	}
+	if _, ok := DetectLanguageFromText("123 !!"); ok {
+		t.Error("DetectLanguageFromText detected language without letters")
+	}
+}
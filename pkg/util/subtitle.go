@@ -2,6 +2,7 @@ package util
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"krillin-ai/internal/storage"
 	"krillin-ai/internal/types"
@@ -98,6 +99,8 @@ type SrtBlock struct {
 	Timestamp              string
 	TargetLanguageSentence string
 	OriginLanguageSentence string
+	AvgConfidence          float64 // 字幕对应单词的平均转录置信度，0表示转录服务未提供
+	MinConfidence          float64 // 字幕对应单词中最低的转录置信度
 }
 
 func TrimString(s string) string {
@@ -258,9 +261,9 @@ func GetRecognizableString(s string) string {
 	return string(result)
 }
 
-func GetAudioDuration(inputFile string) (float64, error) {
+func GetAudioDuration(ctx context.Context, inputFile string) (float64, error) {
 	// 使用 ffprobe 获取精确时长
-	cmd := exec.Command(storage.FfprobePath, "-i", inputFile, "-show_entries", "format=duration", "-v", "quiet", "-of", "csv=p=0")
+	cmd := exec.CommandContext(ctx, storage.FfprobePath, "-i", inputFile, "-show_entries", "format=duration", "-v", "quiet", "-of", "csv=p=0")
 	cmdOutput, err := cmd.Output()
 	if err != nil {
 		return 0, fmt.Errorf("GetAudioDuration failed to get audio duration: %w", err)
@@ -0,0 +1,209 @@
+package vad
+
+import (
+	"math"
+	"slices"
+)
+
+const (
+	SampleRate  = 16000
+	frameSize   = 480 // 30ms
+	frameSecs   = float64(frameSize) / SampleRate
+	minEnergyDb = -55.0 // 低于此能量的帧一律视为静音
+	noiseRank   = 0.1   // 取能量排在10%位置的帧作为底噪估计
+	maxSpeechZc = 0.35  // 过零率高于此值且能量不够突出的帧多为噪声
+	strongDb    = 10.0  // 高出阈值这么多的帧不看过零率，直接视为语音
+)
+
+// Region 一段连续的语音或静音，单位为秒
+type Region struct {
+	Start float64
+	End   float64
+}
+
+func (r Region) Duration() float64 {
+	return r.End - r.Start
+}
+
+// Options 检测参数，时长单位为秒
+type Options struct {
+	ThresholdDb float64 // 语音帧能量需高出底噪的分贝数，越大越不容易把背景声当成语音
+	MinOnset    float64 // 连续多久的语音帧才算语音开始，过滤短促的噪声
+	Hangover    float64 // 语音帧结束后继续保持语音状态的时长，避免在字词间的短停顿处断开
+	MinSpeech   float64 // 短于此时长的语音段被丢弃
+	MinSilence  float64 // 短于此时长的静音被并入两侧的语音段
+	Padding     float64 // 语音段两端各外扩的时长
+}
+
+func DefaultOptions() Options {
+	return Options{
+		ThresholdDb: 12,
+		MinOnset:    0.09,
+		Hangover:    0.3,
+		MinSpeech:   0.25,
+		MinSilence:  0.3,
+		Padding:     0.1,
+	}
+}
+
+// Detector 基于帧能量、过零率和拖尾保持的语音活动检测。
+// 以io.Writer方式接收16kHz单声道s16le数据，每帧只保留两个特征，可以流式处理很长的音频
+type Detector struct {
+	opts     Options
+	frame    []float64
+	pending  []byte // 上次写入时剩下的不足一个采样的字节
+	energies []float64
+	zcrs     []float64
+}
+
+func NewDetector(opts Options) *Detector {
+	return &Detector{opts: opts, frame: make([]float64, 0, frameSize)}
+}
+
+func (d *Detector) Write(p []byte) (int, error) {
+	n := len(p)
+	if len(d.pending) > 0 {
+		p = append(d.pending, p...)
+		d.pending = nil
+	}
+	for i := 0; i+1 < len(p); i += 2 {
+		sample := int16(p[i]) | int16(p[i+1])<<8
+		d.frame = append(d.frame, float64(sample)/32768)
+		if len(d.frame) == frameSize {
+			d.addFrame(d.frame)
+			d.frame = d.frame[:0]
+		}
+	}
+	if len(p)%2 == 1 {
+		d.pending = []byte{p[len(p)-1]}
+	}
+	return n, nil
+}
+
+// WriteSamples 写入已归一化到[-1,1]的采样
+func (d *Detector) WriteSamples(samples []float64) {
+	for _, sample := range samples {
+		d.frame = append(d.frame, sample)
+		if len(d.frame) == frameSize {
+			d.addFrame(d.frame)
+			d.frame = d.frame[:0]
+		}
+	}
+}
+
+func (d *Detector) addFrame(frame []float64) {
+	power := 0.0
+	crossings := 0
+	for i, sample := range frame {
+		power += sample * sample
+		if i > 0 && (sample >= 0) != (frame[i-1] >= 0) {
+			crossings++
+		}
+	}
+	d.energies = append(d.energies, 10*math.Log10(power/float64(len(frame))+1e-10))
+	d.zcrs = append(d.zcrs, float64(crossings)/float64(len(frame)))
+}
+
+// Duration 已写入音频的时长
+func (d *Detector) Duration() float64 {
+	return float64(len(d.energies))*frameSecs + float64(len(d.frame))/SampleRate
+}
+
+// Regions 返回检测到的语音段，按时间顺序排列且互不重叠
+func (d *Detector) Regions() []Region {
+	if len(d.energies) == 0 {
+		return nil
+	}
+	threshold := d.threshold()
+	onsetFrames := max(int(math.Ceil(d.opts.MinOnset/frameSecs)), 1)
+	hangoverFrames := int(math.Round(d.opts.Hangover / frameSecs))
+
+	var regions []Region
+	inSpeech := false
+	run, silent, start := 0, 0, 0
+	for i, energy := range d.energies {
+		speech := energy > threshold && (d.zcrs[i] < maxSpeechZc || energy > threshold+strongDb)
+		if speech {
+			run++
+		} else {
+			run = 0
+		}
+		if !inSpeech {
+			if run >= onsetFrames {
+				inSpeech, silent, start = true, 0, i-run+1
+			}
+			continue
+		}
+		if speech {
+			silent = 0
+			continue
+		}
+		silent++
+		if silent > hangoverFrames {
+			regions = append(regions, Region{Start: float64(start) * frameSecs, End: float64(i-silent+1) * frameSecs})
+			inSpeech = false
+		}
+	}
+	if inSpeech {
+		regions = append(regions, Region{Start: float64(start) * frameSecs, End: float64(len(d.energies)-silent) * frameSecs})
+	}
+	return d.smooth(regions)
+}
+
+// threshold 用低分位的帧能量估计底噪，语音阈值为底噪加上ThresholdDb
+func (d *Detector) threshold() float64 {
+	sorted := slices.Clone(d.energies)
+	slices.Sort(sorted)
+	floor := sorted[int(float64(len(sorted)-1)*noiseRank)]
+	return max(floor+d.opts.ThresholdDb, minEnergyDb)
+}
+
+// smooth 合并间隔过短的语音段，丢弃过短的语音段，再外扩语音段两端
+func (d *Detector) smooth(regions []Region) []Region {
+	var merged []Region
+	for _, region := range regions {
+		if n := len(merged); n > 0 && region.Start-merged[n-1].End < d.opts.MinSilence {
+			merged[n-1].End = region.End
+			continue
+		}
+		merged = append(merged, region)
+	}
+	merged = slices.DeleteFunc(merged, func(r Region) bool {
+		return r.Duration() < d.opts.MinSpeech
+	})
+	duration := d.Duration()
+	var padded []Region
+	for _, region := range merged {
+		region.Start = max(region.Start-d.opts.Padding, 0)
+		region.End = min(region.End+d.opts.Padding, duration)
+		if n := len(padded); n > 0 && region.Start <= padded[n-1].End {
+			padded[n-1].End = region.End
+			continue
+		}
+		padded = append(padded, region)
+	}
+	return padded
+}
+
+// Detect 对一段完整的采样做语音活动检测
+func Detect(samples []float64, opts Options) []Region {
+	detector := NewDetector(opts)
+	detector.WriteSamples(samples)
+	return detector.Regions()
+}
+
+// Gaps 返回[0,duration]内语音段之间的静音段，包括开头和结尾的静音
+func Gaps(regions []Region, duration float64) []Region {
+	var gaps []Region
+	last := 0.0
+	for _, region := range regions {
+		if region.Start > last {
+			gaps = append(gaps, Region{Start: last, End: region.Start})
+		}
+		last = max(last, region.End)
+	}
+	if duration > last {
+		gaps = append(gaps, Region{Start: last, End: duration})
+	}
+	return gaps
+}
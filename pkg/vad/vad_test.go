@@ -0,0 +1,89 @@
+package vad
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// synth 按片段拼接测试音频：voiced为true时生成带谐波的浊音，否则为底噪
+func synth(parts []struct {
+	secs   float64
+	voiced bool
+}) []float64 {
+	rng := rand.New(rand.NewSource(1))
+	var samples []float64
+	for _, part := range parts {
+		for i := range int(part.secs * SampleRate) {
+			t := float64(i) / SampleRate
+			sample := rng.NormFloat64() * 0.001
+			if part.voiced {
+				for h := 1; h <= 5; h++ {
+					sample += 0.1 / float64(h) * math.Sin(2*math.Pi*150*float64(h)*t)
+				}
+			}
+			samples = append(samples, sample)
+		}
+	}
+	return samples
+}
+
+func TestDetectFindsSpeechRegions(t *testing.T) {
+	samples := synth([]struct {
+		secs   float64
+		voiced bool
+	}{{1, false}, {1, true}, {0.15, false}, {1, true}, {3, false}, {1, true}, {1, false}})
+	regions := Detect(samples, DefaultOptions())
+	if len(regions) != 2 {
+		t.Fatalf("regions = %+v, want 2 (short pause merged)", regions)
+	}
+	want := []Region{{Start: 1, End: 3.15}, {Start: 6.15, End: 7.15}}
+	for i, region := range regions {
+		if math.Abs(region.Start-want[i].Start) > 0.15 || math.Abs(region.End-want[i].End) > 0.15 {
+			t.Fatalf("region %d = %+v, want about %+v", i, region, want[i])
+		}
+	}
+
+	gaps := Gaps(regions, 8.15)
+	if len(gaps) != 3 || gaps[0].Start != 0 || gaps[2].End != 8.15 {
+		t.Fatalf("gaps = %+v", gaps)
+	}
+}
+
+func TestDetectIgnoresSilenceAndShortClicks(t *testing.T) {
+	if regions := Detect(synth([]struct {
+		secs   float64
+		voiced bool
+	}{{3, false}}), DefaultOptions()); len(regions) != 0 {
+		t.Fatalf("silence regions = %+v", regions)
+	}
+	if regions := Detect(synth([]struct {
+		secs   float64
+		voiced bool
+	}{{1, false}, {0.06, true}, {1, false}}), DefaultOptions()); len(regions) != 0 {
+		t.Fatalf("click regions = %+v", regions)
+	}
+}
+
+func TestDetectorWriteMatchesSamples(t *testing.T) {
+	samples := synth([]struct {
+		secs   float64
+		voiced bool
+	}{{0.5, false}, {1, true}, {0.5, false}})
+	raw := make([]byte, len(samples)*2)
+	for i, sample := range samples {
+		binary.LittleEndian.PutUint16(raw[i*2:], uint16(int16(sample*32767)))
+	}
+	detector := NewDetector(DefaultOptions())
+	// 奇数长度的写入会把采样拆到两次写入中
+	for len(raw) > 0 {
+		n := min(len(raw), 1001)
+		detector.Write(raw[:n])
+		raw = raw[n:]
+	}
+	got, want := detector.Regions(), Detect(samples, DefaultOptions())
+	if len(got) != len(want) || len(got) != 1 || math.Abs(got[0].Start-want[0].Start) > 0.01 {
+		t.Fatalf("Write regions = %+v, WriteSamples regions = %+v", got, want)
+	}
+}
@@ -2,29 +2,33 @@ package whisper
 
 import (
 	"context"
+	"errors"
 	"github.com/sashabaranov/go-openai"
 	"go.uber.org/zap"
 	"krillin-ai/internal/types"
 	"krillin-ai/log"
+	"math"
 	"strings"
 )
 
-func (c *Client) Transcription(audioFile, language, workDir string) (*types.TranscriptionData, error) {
+func (c *Client) Transcription(ctx context.Context, audioFile, language, workDir string, hint types.TranscriptionHint) (*types.TranscriptionData, error) {
 	resp, err := c.client.CreateTranscription(
-		context.Background(),
+		ctx,
 		openai.AudioRequest{
 			Model:    openai.Whisper1,
 			FilePath: audioFile,
 			Format:   openai.AudioResponseFormatVerboseJSON,
 			TimestampGranularities: []openai.TranscriptionTimestampGranularity{
 				openai.TranscriptionTimestampGranularityWord,
+				openai.TranscriptionTimestampGranularitySegment, // 单词没有概率，用所在句子的平均概率作为置信度
 			},
 			Language: language,
+			Prompt:   hint.Prompt(),
 		},
 	)
 	if err != nil {
 		log.GetLogger().Error("openai create transcription failed", zap.Error(err))
-		return nil, err
+		return nil, transcribeError(err)
 	}
 
 	transcriptionData := &types.TranscriptionData{
@@ -34,31 +38,35 @@ func (c *Client) Transcription(audioFile, language, workDir string) (*types.Tran
 	}
 	num := 0
 	for _, word := range resp.Words {
+		confidence := segmentConfidence(resp, word.Start, word.End)
 		if strings.Contains(word.Word, "—") {
 			// 对称切分
 			mid := (word.Start + word.End) / 2
 			seperatedWords := strings.Split(word.Word, "—")
 			transcriptionData.Words = append(transcriptionData.Words, []types.Word{
 				{
-					Num:   num,
-					Text:  seperatedWords[0],
-					Start: word.Start,
-					End:   mid,
+					Num:        num,
+					Text:       seperatedWords[0],
+					Start:      word.Start,
+					End:        mid,
+					Confidence: confidence,
 				},
 				{
-					Num:   num + 1,
-					Text:  seperatedWords[1],
-					Start: mid,
-					End:   word.End,
+					Num:        num + 1,
+					Text:       seperatedWords[1],
+					Start:      mid,
+					End:        word.End,
+					Confidence: confidence,
 				},
 			}...)
 			num += 2
 		} else {
 			transcriptionData.Words = append(transcriptionData.Words, types.Word{
-				Num:   num,
-				Text:  word.Word,
-				Start: word.Start,
-				End:   word.End,
+				Num:        num,
+				Text:       word.Word,
+				Start:      word.Start,
+				End:        word.End,
+				Confidence: confidence,
 			})
 			num++
 		}
@@ -66,3 +74,27 @@ func (c *Client) Transcription(audioFile, language, workDir string) (*types.Tran
 
 	return transcriptionData, nil
 }
+
+// transcribeError 把接口返回的错误转换为带状态码的 types.TranscribeError，便于转录服务链判断是否回退
+func transcribeError(err error) error {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) && apiErr.HTTPStatusCode != 0 {
+		return types.NewTranscribeError("openai", apiErr.HTTPStatusCode, apiErr.Message)
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) && reqErr.HTTPStatusCode != 0 {
+		return types.NewTranscribeError("openai", reqErr.HTTPStatusCode, reqErr.Error())
+	}
+	return err
+}
+
+// segmentConfidence 返回单词中点所在句子的平均token概率，找不到句子时返回0
+func segmentConfidence(resp openai.AudioResponse, start, end float64) float64 {
+	mid := (start + end) / 2
+	for _, segment := range resp.Segments {
+		if mid >= segment.Start && mid <= segment.End {
+			return math.Exp(segment.AvgLogprob)
+		}
+	}
+	return 0
+}
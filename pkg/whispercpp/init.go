@@ -1,12 +1,18 @@
 package whispercpp
 
+import "net/http"
+
 type WhispercppProcessor struct {
-	WorkDir string // 生成中间文件的目录
-	Model   string
+	WorkDir   string // 生成中间文件的目录
+	Model     string
+	ServerUrl string // whisper-server地址，为空时调用本地whisper-cli
+	client    *http.Client
 }
 
-func NewWhispercppProcessor(model string) *WhispercppProcessor {
+func NewWhispercppProcessor(model, serverUrl string) *WhispercppProcessor {
 	return &WhispercppProcessor{
-		Model: model,
+		Model:     model,
+		ServerUrl: serverUrl,
+		client:    &http.Client{},
 	}
 }
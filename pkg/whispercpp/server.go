@@ -0,0 +1,97 @@
+package whispercpp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"krillin-ai/internal/types"
+	"krillin-ai/log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// transcribeByServer 调用whisper-server的/inference接口，verbose_json中的words即带时间戳的token
+func (c *WhispercppProcessor) transcribeByServer(ctx context.Context, audioFile, language, prompt string) ([]timedSegment, string, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	file, err := os.Open(audioFile)
+	if err != nil {
+		return nil, "", err
+	}
+	defer file.Close()
+	part, err := writer.CreateFormFile("file", filepath.Base(audioFile))
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err = io.Copy(part, file); err != nil {
+		return nil, "", err
+	}
+	fields := map[string]string{
+		"language":        language,
+		"response_format": "verbose_json",
+		"split_on_word":   "true",
+		"temperature":     "0",
+	}
+	if prompt != "" {
+		fields["prompt"] = prompt
+	}
+	for key, value := range fields {
+		if err = writer.WriteField(key, value); err != nil {
+			return nil, "", err
+		}
+	}
+	if err = writer.Close(); err != nil {
+		return nil, "", err
+	}
+
+	endpoint := strings.TrimSuffix(c.ServerUrl, "/")
+	if !strings.HasSuffix(endpoint, "/inference") {
+		endpoint += "/inference"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, body)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	log.GetLogger().Info("WhispercppProcessor请求whisper-server转录", zap.String("url", endpoint), zap.String("audio file", audioFile))
+	resp, err := c.client.Do(req)
+	if err != nil {
+		log.GetLogger().Error("WhispercppProcessor 请求whisper-server失败", zap.Error(err))
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		log.GetLogger().Error("WhispercppProcessor whisper-server返回错误", zap.Int("status", resp.StatusCode), zap.String("body", string(respBody)))
+		return nil, "", fmt.Errorf("whisper-server returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var result types.WhispercppServerOutput
+	if err = json.Unmarshal(respBody, &result); err != nil {
+		log.GetLogger().Error("WhispercppProcessor 解析whisper-server返回失败", zap.String("body", string(respBody)), zap.Error(err))
+		return nil, "", err
+	}
+	if result.Error != "" {
+		return nil, "", fmt.Errorf("whisper-server error: %s", result.Error)
+	}
+
+	segments := make([]timedSegment, 0, len(result.Segments))
+	for _, segment := range result.Segments {
+		tokens := make([]timedToken, 0, len(segment.Words))
+		for _, word := range segment.Words {
+			tokens = append(tokens, timedToken{Text: word.Word, Start: word.Start, End: word.End, P: word.Probability})
+		}
+		segments = append(segments, timedSegment{Text: segment.Text, Tokens: tokens})
+	}
+	return segments, result.Language, nil
+}
@@ -0,0 +1,71 @@
+package whispercpp
+
+import (
+	"context"
+	"krillin-ai/internal/types"
+	"krillin-ai/log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTranscriptionByServer(t *testing.T) {
+	log.InitLogger()
+	var gotFields map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/inference" || r.Method != http.MethodPost {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("parse form: %v", err)
+		}
+		if _, _, err := r.FormFile("file"); err != nil {
+			t.Errorf("missing file: %v", err)
+		}
+		gotFields = map[string]string{
+			"language":        r.FormValue("language"),
+			"response_format": r.FormValue("response_format"),
+			"prompt":          r.FormValue("prompt"),
+		}
+		w.Write([]byte(`{"language":"en","text":"Hello world","segments":[{"text":" Hello world","start":0,"end":1.2,
+			"words":[{"word":"[_BEG_]","start":0,"end":0},{"word":" Hello","start":0,"end":0.5},{"word":" world.","start":0.5,"end":1.2}]}]}`))
+	}))
+	defer server.Close()
+
+	audio := filepath.Join(t.TempDir(), "a.wav")
+	if err := os.WriteFile(audio, []byte("RIFF"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	data, err := NewWhispercppProcessor("large-v2", server.URL+"/").Transcription(context.Background(), audio, "en", t.TempDir(),
+		types.TranscriptionHint{Vocabulary: []string{"KrillinAI", "Whisper"}, Context: "KrillinAI demo"})
+	if err != nil {
+		t.Fatalf("Transcription() error = %v", err)
+	}
+	if gotFields["language"] != "en" || gotFields["response_format"] != "verbose_json" || gotFields["prompt"] != "KrillinAI demo KrillinAI, Whisper." {
+		t.Fatalf("form fields = %v", gotFields)
+	}
+	if len(data.Words) != 2 {
+		t.Fatalf("words = %+v, want 2 words", data.Words)
+	}
+	if w := data.Words[1]; w.Num != 1 || w.Text != "world" || w.Start != 0.5 || w.End != 1.2 {
+		t.Fatalf("second word = %+v", w)
+	}
+}
+
+func TestTranscriptionByServerError(t *testing.T) {
+	log.InitLogger()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"error":"failed to read audio"}`))
+	}))
+	defer server.Close()
+
+	audio := filepath.Join(t.TempDir(), "a.wav")
+	if err := os.WriteFile(audio, []byte("RIFF"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewWhispercppProcessor("", server.URL).Transcription(context.Background(), audio, "en", t.TempDir(), types.TranscriptionHint{}); err == nil {
+		t.Fatal("Transcription() error = nil, want server error")
+	}
+}
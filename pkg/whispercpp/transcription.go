@@ -1,8 +1,10 @@
 package whispercpp
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"krillin-ai/config"
 	"krillin-ai/internal/storage"
 	"krillin-ai/internal/types"
 	"krillin-ai/log"
@@ -10,29 +12,74 @@ import (
 	"os"
 	"os/exec"
 	"regexp"
+	"runtime"
 	"strconv"
 	"strings"
 
 	"go.uber.org/zap"
 )
 
-func (c *WhispercppProcessor) Transcription(audioFile, language, workDir string) (*types.TranscriptionData, error) {
+func (c *WhispercppProcessor) Transcription(ctx context.Context, audioFile, language, workDir string, hint types.TranscriptionHint) (*types.TranscriptionData, error) {
+	if language == "" {
+		language = "auto" // whisper.cpp用auto表示自动识别语言
+	}
+	var (
+		segments []timedSegment
+		detected string
+		err      error
+	)
+	if c.ServerUrl != "" {
+		segments, detected, err = c.transcribeByServer(ctx, audioFile, language, hint.Prompt())
+	} else {
+		segments, detected, err = c.transcribeByCli(ctx, audioFile, language, hint.Prompt())
+	}
+	if err != nil {
+		return nil, err
+	}
+	transcriptionData := toTranscriptionData(segments)
+	transcriptionData.Language = detected
+	log.GetLogger().Info("WhispercppProcessor转录成功")
+	return transcriptionData, nil
+}
+
+// timedSegment whisper.cpp一句话的转录结果，token时间单位为秒
+type timedSegment struct {
+	Text   string
+	Tokens []timedToken
+}
+
+type timedToken struct {
+	Text  string
+	Start float64
+	End   float64
+	P     float64 // token概率
+}
+
+// transcribeByCli 返回转录结果和whisper.cpp识别出的语言
+func (c *WhispercppProcessor) transcribeByCli(ctx context.Context, audioFile, language, prompt string) ([]timedSegment, string, error) {
 	name := util.ChangeFileExtension(audioFile, "")
 	cmdArgs := []string{
 		"-m", fmt.Sprintf("./models/whispercpp/ggml-%s.bin", c.Model),
 		"--output-json-full",
-		"--flash-attn",
 		"--split-on-word",
 		"--language", language,
 		"--output-file", name,
 		"--file", audioFile,
 	}
-	cmd := exec.Command(storage.WhispercppPath, cmdArgs...)
+	if prompt != "" {
+		cmdArgs = append(cmdArgs, "--prompt", prompt)
+	}
+	if runtime.GOOS == "windows" || config.Conf.Transcribe.EnableGpuAcceleration {
+		cmdArgs = append(cmdArgs, "--flash-attn")
+	} else {
+		cmdArgs = append(cmdArgs, "--threads", strconv.Itoa(runtime.NumCPU()))
+	}
+	cmd := exec.CommandContext(ctx, storage.WhispercppPath, cmdArgs...)
 	log.GetLogger().Info("WhispercppProcessor转录开始", zap.String("cmd", cmd.String()))
 	output, err := cmd.CombinedOutput()
 	if err != nil && !strings.Contains(string(output), "output_json: saving output to") {
 		log.GetLogger().Error("WhispercppProcessor  cmd 执行失败", zap.String("output", string(output)), zap.Error(err))
-		return nil, err
+		return nil, "", err
 	}
 	log.GetLogger().Info("WhispercppProcessor转录json生成完毕", zap.String("audio file", audioFile))
 
@@ -40,68 +87,83 @@ func (c *WhispercppProcessor) Transcription(audioFile, language, workDir string)
 	fileData, err := os.Open(util.ChangeFileExtension(audioFile, ".json"))
 	if err != nil {
 		log.GetLogger().Error("WhispercppProcessor 打开json文件失败", zap.Error(err))
-		return nil, err
+		return nil, "", err
 	}
 	defer fileData.Close()
 	decoder := json.NewDecoder(fileData)
 	if err = decoder.Decode(&result); err != nil {
 		log.GetLogger().Error("WhispercppProcessor 解析json文件失败", zap.Error(err))
-		return nil, err
+		return nil, "", err
 	}
 
-	var (
-		transcriptionData types.TranscriptionData
-		num               int
-	)
+	segments := make([]timedSegment, 0, len(result.Transcription))
 	for _, segment := range result.Transcription {
-		transcriptionData.Text += strings.ReplaceAll(segment.Text, "—", " ") // 连字符处理，因为模型存在很多错误添加到连字符
-		for _, word := range segment.Tokens {
-			fromSec, err := parseTimestampToSeconds(word.Timestamps.From)
+		tokens := make([]timedToken, 0, len(segment.Tokens))
+		for _, token := range segment.Tokens {
+			fromSec, err := parseTimestampToSeconds(token.Timestamps.From)
 			if err != nil {
 				log.GetLogger().Error("解析开始时间失败", zap.Error(err))
-				return nil, err
+				return nil, "", err
 			}
-
-			toSec, err := parseTimestampToSeconds(word.Timestamps.To)
+			toSec, err := parseTimestampToSeconds(token.Timestamps.To)
 			if err != nil {
 				log.GetLogger().Error("解析结束时间失败", zap.Error(err))
-				return nil, err
+				return nil, "", err
 			}
-			regex := regexp.MustCompile(`^\[.*\]$`)
-			if regex.MatchString(word.Text) {
+			tokens = append(tokens, timedToken{Text: token.Text, Start: fromSec, End: toSec, P: token.P})
+		}
+		segments = append(segments, timedSegment{Text: segment.Text, Tokens: tokens})
+	}
+	return segments, result.Result.Language, nil
+}
+
+var specialTokenRegex = regexp.MustCompile(`^\[.*\]$`)
+
+// toTranscriptionData 将token时间戳映射为单词，跳过[_BEG_]等特殊token
+func toTranscriptionData(segments []timedSegment) *types.TranscriptionData {
+	var (
+		transcriptionData types.TranscriptionData
+		num               int
+	)
+	for _, segment := range segments {
+		transcriptionData.Text += strings.ReplaceAll(segment.Text, "—", " ") // 连字符处理，因为模型存在很多错误添加到连字符
+		for _, word := range segment.Tokens {
+			if specialTokenRegex.MatchString(strings.TrimSpace(word.Text)) {
 				continue
 			} else if strings.Contains(word.Text, "—") {
 				// 对称切分
-				mid := (fromSec + toSec) / 2
+				mid := (word.Start + word.End) / 2
 				seperatedWords := strings.Split(word.Text, "—")
 				transcriptionData.Words = append(transcriptionData.Words, []types.Word{
 					{
-						Num:   num,
-						Text:  util.CleanPunction(strings.TrimSpace(seperatedWords[0])),
-						Start: fromSec,
-						End:   mid,
+						Num:        num,
+						Text:       util.CleanPunction(strings.TrimSpace(seperatedWords[0])),
+						Start:      word.Start,
+						End:        mid,
+						Confidence: word.P,
 					},
 					{
-						Num:   num + 1,
-						Text:  util.CleanPunction(strings.TrimSpace(seperatedWords[1])),
-						Start: mid,
-						End:   toSec,
+						Num:        num + 1,
+						Text:       util.CleanPunction(strings.TrimSpace(seperatedWords[1])),
+						Start:      mid,
+						End:        word.End,
+						Confidence: word.P,
 					},
 				}...)
 				num += 2
 			} else {
 				transcriptionData.Words = append(transcriptionData.Words, types.Word{
-					Num:   num,
-					Text:  util.CleanPunction(strings.TrimSpace(word.Text)),
-					Start: fromSec,
-					End:   toSec,
+					Num:        num,
+					Text:       util.CleanPunction(strings.TrimSpace(word.Text)),
+					Start:      word.Start,
+					End:        word.End,
+					Confidence: word.P,
 				})
 				num++
 			}
 		}
 	}
-	log.GetLogger().Info("WhispercppProcessor转录成功")
-	return &transcriptionData, nil
+	return &transcriptionData
 }
 
 // 新增时间戳转换函数
@@ -1,6 +1,7 @@
 package whisperkit
 
 import (
+	"context"
 	"encoding/json"
 	"krillin-ai/internal/storage"
 	"krillin-ai/internal/types"
@@ -13,20 +14,25 @@ import (
 	"go.uber.org/zap"
 )
 
-func (c *WhisperKitProcessor) Transcription(audioFile, language, workDir string) (*types.TranscriptionData, error) {
+func (c *WhisperKitProcessor) Transcription(ctx context.Context, audioFile, language, workDir string, hint types.TranscriptionHint) (*types.TranscriptionData, error) {
 	cmdArgs := []string{
 		"transcribe",
 		"--model-path", "./models/whisperkit/openai_whisper-large-v2",
 		"--audio-encoder-compute-units", "all",
 		"--text-decoder-compute-units", "all",
-		"--language", language,
 		"--report",
 		"--report-path", workDir,
 		"--word-timestamps",
 		"--skip-special-tokens",
 		"--audio-path", audioFile,
 	}
-	cmd := exec.Command(storage.WhisperKitPath, cmdArgs...)
+	if language != "" { // 不指定语言时由模型自动识别
+		cmdArgs = append(cmdArgs, "--language", language)
+	}
+	if prompt := hint.Prompt(); prompt != "" {
+		cmdArgs = append(cmdArgs, "--prompt", prompt)
+	}
+	cmd := exec.CommandContext(ctx, storage.WhisperKitPath, cmdArgs...)
 	log.GetLogger().Info("WhisperKitProcessor转录开始", zap.String("cmd", cmd.String()))
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -61,30 +67,34 @@ func (c *WhisperKitProcessor) Transcription(audioFile, language, workDir string)
 				seperatedWords := strings.Split(word.Word, "—")
 				transcriptionData.Words = append(transcriptionData.Words, []types.Word{
 					{
-						Num:   num,
-						Text:  util.CleanPunction(strings.TrimSpace(seperatedWords[0])),
-						Start: word.Start,
-						End:   mid,
+						Num:        num,
+						Text:       util.CleanPunction(strings.TrimSpace(seperatedWords[0])),
+						Start:      word.Start,
+						End:        mid,
+						Confidence: word.Probability,
 					},
 					{
-						Num:   num + 1,
-						Text:  util.CleanPunction(strings.TrimSpace(seperatedWords[1])),
-						Start: mid,
-						End:   word.End,
+						Num:        num + 1,
+						Text:       util.CleanPunction(strings.TrimSpace(seperatedWords[1])),
+						Start:      mid,
+						End:        word.End,
+						Confidence: word.Probability,
 					},
 				}...)
 				num += 2
 			} else {
 				transcriptionData.Words = append(transcriptionData.Words, types.Word{
-					Num:   num,
-					Text:  util.CleanPunction(strings.TrimSpace(word.Word)),
-					Start: word.Start,
-					End:   word.End,
+					Num:        num,
+					Text:       util.CleanPunction(strings.TrimSpace(word.Word)),
+					Start:      word.Start,
+					End:        word.End,
+					Confidence: word.Probability,
 				})
 				num++
 			}
 		}
 	}
+	transcriptionData.Language = result.Language
 	log.GetLogger().Info("WhisperKitProcessor转录成功")
 	return &transcriptionData, nil
 }
@@ -1,62 +1,76 @@
 package whisperx
 
 import (
+	"context"
 	"encoding/json"
+	"krillin-ai/config"
 	"krillin-ai/internal/storage"
 	"krillin-ai/internal/types"
 	"krillin-ai/log"
 	"krillin-ai/pkg/util"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 
 	"go.uber.org/zap"
 )
 
-func (c *WhisperXProcessor) Transcription(audioFile, language, workDir string) (*types.TranscriptionData, error) {
-	var (
-		cmdArgs []string
-		envPath string
-		cmd     *exec.Cmd
-	)
-	if runtime.GOOS == "windows" {
-		envPath = ".\\bin\\whisperx\\.venv\\Scripts\\activate"
-	} else {
-		envPath = storage.WhisperXPath
+func (c *WhisperXProcessor) Transcription(ctx context.Context, audioFile, language, workDir string, hint types.TranscriptionHint) (*types.TranscriptionData, error) {
+	cfg := config.Conf.Transcribe.Whisperx
+	device := "cpu"
+	if config.Conf.Transcribe.EnableGpuAcceleration {
+		device = "cuda"
 	}
-	if runtime.GOOS == "windows" {
-		cmdArgs = []string{
-			"&&",
-			storage.WhisperXPath,
-			audioFile,
-			"--model_dir", "./models/whisperx",
-			"--model", c.Model,
-			"--language", language,
-			"--output_dir", workDir,
-			"--compute_type", "float16",
-			"--batch_size", "8",
-			"--model_cache_only", "True",
+	cmdArgs := []string{
+		audioFile,
+		"--model_dir", "./models/whisperx",
+		"--model", c.Model,
+		"--output_dir", workDir,
+		"--output_format", "json",
+		"--device", device,
+		"--compute_type", cfg.ComputeTypeFor(config.Conf.Transcribe.EnableGpuAcceleration),
+		"--batch_size", strconv.Itoa(cfg.BatchSize),
+	}
+	if language != "" { // 不指定语言时由whisperx自动识别
+		cmdArgs = append(cmdArgs, "--language", language)
+	}
+	if prompt := hint.Prompt(); prompt != "" {
+		cmdArgs = append(cmdArgs, "--initial_prompt", prompt)
+	}
+	if !cfg.Align {
+		cmdArgs = append(cmdArgs, "--no_align")
+	}
+	if cfg.ModelCacheOnly {
+		cmdArgs = append(cmdArgs, "--model_cache_only", "True")
+	}
+	if diarization := config.Conf.Transcribe.Diarization; diarization.Enable && diarization.Provider == "whisperx" {
+		cmdArgs = append(cmdArgs, "--diarize", "--hf_token", diarization.HfToken)
+		if diarization.MinSpeakers > 0 {
+			cmdArgs = append(cmdArgs, "--min_speakers", strconv.Itoa(diarization.MinSpeakers))
 		}
-		cmd = exec.Command(envPath, cmdArgs...)
+		if diarization.MaxSpeakers > 0 {
+			cmdArgs = append(cmdArgs, "--max_speakers", strconv.Itoa(diarization.MaxSpeakers))
+		}
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		envPath := ".\\bin\\whisperx\\.venv\\Scripts\\activate"
+		cmd = exec.CommandContext(ctx, envPath, append([]string{"&&", storage.WhisperXPath}, cmdArgs...)...)
 	} else {
-		cmdArgs = []string{
-			audioFile,
-			"--model_dir", "./models/whisperx",
-			"--model", c.Model,
-			"--language", language,
-			"--output_dir", workDir,
-			"--compute_type", "float16",
-			"--batch_size", "16",
-			"--model_cache_only", "True",
+		cmd = exec.CommandContext(ctx, storage.WhisperXPath, cmdArgs...)
+		if strings.HasPrefix(storage.WhisperXPath, "./bin/whisperx/") {
+			// 内置环境的cudnn不在系统库路径中
+			cmd.Env = append(os.Environ(), "LD_LIBRARY_PATH=./bin/whisperx/.venv/lib/python3.12/site-packages/nvidia/cudnn/lib")
 		}
-		cmd = exec.Command(envPath, cmdArgs...)
-		cudaLibPath := "LD_LIBRARY_PATH=./bin/whisperx/.venv/lib/python3.12/site-packages/nvidia/cudnn/lib"
-		currentEnv := os.Environ()
-		newEnv := append(currentEnv, cudaLibPath)
-		cmd.Env = newEnv
 	}
-	log.GetLogger().Info("WhisperXProcessor转录开始", zap.String("cmd", cmd.String()))
+	cmdLog := cmd.String()
+	if token := config.Conf.Transcribe.Diarization.HfToken; token != "" {
+		cmdLog = strings.ReplaceAll(cmdLog, token, "***")
+	}
+	log.GetLogger().Info("WhisperXProcessor转录开始", zap.String("cmd", cmdLog))
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		log.GetLogger().Error("WhisperXProcessor  cmd 执行失败", zap.String("output", string(output)), zap.Error(err))
@@ -65,7 +79,8 @@ func (c *WhisperXProcessor) Transcription(audioFile, language, workDir string) (
 	log.GetLogger().Info("WhisperXProcessor转录json生成完毕", zap.String("audio file", audioFile))
 
 	var result types.WhisperXOutput
-	fileData, err := os.Open(util.ChangeFileExtension(audioFile, ".json"))
+	jsonFile := filepath.Join(workDir, util.ChangeFileExtension(filepath.Base(audioFile), ".json"))
+	fileData, err := os.Open(jsonFile)
 	if err != nil {
 		log.GetLogger().Error("WhisperXProcessor 打开json文件失败", zap.Error(err))
 		return nil, err
@@ -77,43 +92,107 @@ func (c *WhisperXProcessor) Transcription(audioFile, language, workDir string) (
 		return nil, err
 	}
 
+	transcriptionData := toTranscriptionData(result)
+	log.GetLogger().Info("WhisperXProcessor转录成功")
+	return transcriptionData, nil
+}
+
+// toTranscriptionData 将whisperx输出转换为词级转录结果，供TimestampGenerator生成字幕时间轴
+func toTranscriptionData(result types.WhisperXOutput) *types.TranscriptionData {
 	var (
-		transcriptionData types.TranscriptionData
+		transcriptionData = types.TranscriptionData{Language: result.Language}
 		num               int
 	)
 	for _, segment := range result.Segments {
 		transcriptionData.Text += strings.ReplaceAll(segment.Text, "—", " ") // 连字符处理，因为模型存在很多错误添加到连字符
-		for _, word := range segment.Words {
-			if strings.Contains(word.Word, "—") {
+		words := segmentWords(segment)
+		for _, word := range words {
+			if strings.Contains(word.Text, "—") {
 				// 对称切分
 				mid := (word.Start + word.End) / 2
-				seperatedWords := strings.Split(word.Word, "—")
+				seperatedWords := strings.Split(word.Text, "—")
 				transcriptionData.Words = append(transcriptionData.Words, []types.Word{
 					{
-						Num:   num,
-						Text:  util.CleanPunction(strings.TrimSpace(seperatedWords[0])),
-						Start: word.Start,
-						End:   mid,
+						Num:        num,
+						Text:       util.CleanPunction(strings.TrimSpace(seperatedWords[0])),
+						Start:      word.Start,
+						End:        mid,
+						Speaker:    word.Speaker,
+						Confidence: word.Confidence,
 					},
 					{
-						Num:   num + 1,
-						Text:  util.CleanPunction(strings.TrimSpace(seperatedWords[1])),
-						Start: mid,
-						End:   word.End,
+						Num:        num + 1,
+						Text:       util.CleanPunction(strings.TrimSpace(seperatedWords[1])),
+						Start:      mid,
+						End:        word.End,
+						Speaker:    word.Speaker,
+						Confidence: word.Confidence,
 					},
 				}...)
 				num += 2
 			} else {
 				transcriptionData.Words = append(transcriptionData.Words, types.Word{
-					Num:   num,
-					Text:  util.CleanPunction(strings.TrimSpace(word.Word)),
-					Start: word.Start,
-					End:   word.End,
+					Num:        num,
+					Text:       util.CleanPunction(strings.TrimSpace(word.Text)),
+					Start:      word.Start,
+					End:        word.End,
+					Speaker:    word.Speaker,
+					Confidence: word.Confidence,
 				})
 				num++
 			}
 		}
 	}
-	log.GetLogger().Info("WhisperXProcessor转录成功")
-	return &transcriptionData, nil
+	return &transcriptionData
+}
+
+// segmentWords 取出句子中的单词及时间戳：对齐失败的词沿用相邻词的时间，未对齐时按字符数在句子时长内均分
+func segmentWords(segment types.WhisperXSegment) []types.Word {
+	if len(segment.Words) == 0 {
+		return estimateWords(segment)
+	}
+	words := make([]types.Word, len(segment.Words))
+	prevEnd := segment.Start
+	for i, word := range segment.Words {
+		words[i].Text = word.Word
+		words[i].Confidence = word.Probability
+		words[i].Speaker = word.Speaker
+		if words[i].Speaker == "" {
+			words[i].Speaker = segment.Speaker
+		}
+		if word.Start != nil && word.End != nil {
+			words[i].Start, words[i].End = *word.Start, *word.End
+			prevEnd = *word.End
+			continue
+		}
+		nextStart := segment.End
+		for _, next := range segment.Words[i+1:] {
+			if next.Start != nil {
+				nextStart = *next.Start
+				break
+			}
+		}
+		words[i].Start, words[i].End = prevEnd, max(prevEnd, nextStart)
+	}
+	return words
+}
+
+func estimateWords(segment types.WhisperXSegment) []types.Word {
+	fields := strings.Fields(segment.Text)
+	totalChars := 0
+	for _, field := range fields {
+		totalChars += len([]rune(field))
+	}
+	if totalChars == 0 {
+		return nil
+	}
+	duration := segment.End - segment.Start
+	words := make([]types.Word, 0, len(fields))
+	cursor := segment.Start
+	for _, field := range fields {
+		end := cursor + duration*float64(len([]rune(field)))/float64(totalChars)
+		words = append(words, types.Word{Text: field, Start: cursor, End: end, Speaker: segment.Speaker})
+		cursor = end
+	}
+	return words
 }
@@ -0,0 +1,45 @@
+package whisperx
+
+import (
+	"krillin-ai/internal/types"
+	"testing"
+)
+
+func ptr(v float64) *float64 { return &v }
+
+func TestToTranscriptionDataFillsMissingWordTimestamps(t *testing.T) {
+	result := types.WhisperXOutput{Segments: []types.WhisperXSegment{{
+		Start: 1, End: 4, Text: " it costs 20 dollars",
+		Words: []types.WhisperXWord{
+			{Word: "it", Start: ptr(1), End: ptr(1.2)},
+			{Word: "costs", Start: ptr(1.3), End: ptr(1.8)},
+			{Word: "20"},
+			{Word: "dollars", Start: ptr(2.5), End: ptr(3)},
+		},
+	}}}
+
+	data := toTranscriptionData(result)
+	if len(data.Words) != 4 {
+		t.Fatalf("words = %d, want 4", len(data.Words))
+	}
+	if w := data.Words[2]; w.Start != 1.8 || w.End != 2.5 || w.Num != 2 {
+		t.Fatalf("unaligned word = %+v, want start 1.8 end 2.5", w)
+	}
+}
+
+func TestToTranscriptionDataEstimatesWithoutAlignment(t *testing.T) {
+	result := types.WhisperXOutput{Segments: []types.WhisperXSegment{{
+		Start: 0, End: 6, Text: " ab abcd",
+	}}}
+
+	data := toTranscriptionData(result)
+	if len(data.Words) != 2 {
+		t.Fatalf("words = %d, want 2", len(data.Words))
+	}
+	if data.Words[0].End != 2 || data.Words[1].Start != 2 || data.Words[1].End != 6 {
+		t.Fatalf("estimated words = %+v", data.Words)
+	}
+	if data.Text != " ab abcd" {
+		t.Fatalf("text = %q", data.Text)
+	}
+}